@@ -19,7 +19,7 @@ import (
 
 var releaseNotesService *release_notes.ReleaseNotesService
 
-func setupLogging() (*os.File, error) {
+func setupLogging(console io.Writer) (*os.File, error) {
 	// 创建 logs 目录
 	logsDir := "logs"
 	if err := os.MkdirAll(logsDir, 0755); err != nil {
@@ -36,7 +36,7 @@ func setupLogging() (*os.File, error) {
 	}
 
 	// 设置日志同时输出到控制台和文件
-	multiWriter := io.MultiWriter(os.Stdout, logFile)
+	multiWriter := io.MultiWriter(console, logFile)
 	log.SetOutput(multiWriter)
 
 	// 设置日志格式
@@ -48,16 +48,22 @@ func setupLogging() (*os.File, error) {
 func main() {
 	var configPath string
 	var configPathShort string
+	var mcpStdio bool
 	flag.StringVar(&configPath, "config", "", "configs.json path")
 	flag.StringVar(&configPathShort, "c", "", "configs.json path (shorthand)")
+	flag.BoolVar(&mcpStdio, "mcp-stdio", false, "serve profiles as an MCP server over stdio instead of HTTP")
 	flag.Parse()
 
 	if configPath == "" {
 		configPath = configPathShort
 	}
 
-	// 设置日志
-	logFile, err := setupLogging()
+	// 设置日志（stdio MCP 模式下 stdout 用于协议消息，控制台日志改写到 stderr）
+	console := io.Writer(os.Stdout)
+	if mcpStdio {
+		console = os.Stderr
+	}
+	logFile, err := setupLogging(console)
 	if err != nil {
 		log.Fatalf("Failed to setup logging: %v", err)
 	}
@@ -70,10 +76,35 @@ func main() {
 		log.Fatalf("Failed to load config: %v", err)
 	}
 	handler.InitWorkflowSessionManager()
+	handler.InitResponseCache()
+	handler.InitAdmission()
+	handler.InitBatchManager()
+	handler.InitCompareStore()
+	handler.InitSessionRegistry()
+	handler.InitApprovals()
+	handler.InitSkillStore()
+
+	if mcpStdio {
+		if err := handler.ServeMCPStdio(context.Background(), os.Stdin, os.Stdout); err != nil {
+			log.Fatalf("MCP stdio server failed: %v", err)
+		}
+		return
+	}
 
 	// 使用 http.HandleFunc 注册 "/invoke" 路由到 handleInvoke
 	http.HandleFunc("/invoke", handler.HandleInvoke)
 	http.HandleFunc("/chat", handler.HandleChat)
+	http.HandleFunc("/batch", handler.HandleBatch)
+	http.HandleFunc("/batch/", handler.HandleBatch)
+	http.HandleFunc("/compare", handler.HandleCompare)
+	http.HandleFunc("/sessions", handler.HandleSessions)
+	http.HandleFunc("/sessions/", handler.HandleSessions)
+	http.HandleFunc("/approvals", handler.HandleApprovals)
+	http.HandleFunc("/approvals/", handler.HandleApprovals)
+	if mcpCfg := handler.GetMCPGatewayConfig(); mcpCfg.Enabled {
+		http.HandleFunc(mcpCfg.Path, handler.HandleMCP)
+		log.Printf("🧰 MCP server enabled at %s", mcpCfg.Path)
+	}
 
 	// Initialize Release Notes Service with config
 	rnConfig := handler.GetReleaseNotesConfig()
@@ -3,6 +3,7 @@ module dify-cli-gateway
 go 1.22
 
 require (
+	github.com/leanovate/gopter v0.2.11
 	github.com/redis/go-redis/v9 v9.6.1
 	github.com/stretchr/testify v1.10.0
 )
@@ -10,6 +11,5 @@ require (
 require (
 	github.com/cespare/xxhash/v2 v2.2.0 // indirect
 	github.com/dgryski/go-rendezvous v0.0.0-20200823014737-9f7001d12a5f // indirect
-	github.com/leanovate/gopter v0.2.11 // indirect
 	github.com/yuin/goldmark v1.7.13 // indirect
 )
@@ -0,0 +1,273 @@
+package admission
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+)
+
+type waiter struct {
+	req        Request
+	tag        float64
+	enqueuedAt time.Time
+	ready      chan struct{}
+	admitted   bool
+}
+
+// Scheduler CLI 执行准入调度器
+// 类别之间按严格优先级放行；同一类别内按租户做加权公平排队（WFQ），
+// 每个请求的虚拟完成时间 = max(类别虚拟时间, 租户上次完成时间) + 1/权重，最小者先放行
+type Scheduler struct {
+	mu              sync.Mutex
+	cfg             Config
+	running         int
+	runningByTenant map[string]int
+	queues          map[Class][]*waiter
+	virtualTime     map[Class]float64
+	lastFinish      map[string]float64
+	stats           Stats
+}
+
+// NewScheduler 创建调度器
+func NewScheduler(cfg Config) *Scheduler {
+	return &Scheduler{
+		cfg:             normalizeConfig(cfg),
+		runningByTenant: make(map[string]int),
+		queues:          make(map[Class][]*waiter),
+		virtualTime:     make(map[Class]float64),
+		lastFinish:      make(map[string]float64),
+	}
+}
+
+func normalizeConfig(cfg Config) Config {
+	if cfg.MaxConcurrent <= 0 {
+		cfg.MaxConcurrent = defaultMaxConcurrent
+	}
+	if cfg.MaxQueueDepth <= 0 {
+		cfg.MaxQueueDepth = defaultMaxQueueDepth
+	}
+	if cfg.MaxWait <= 0 {
+		cfg.MaxWait = defaultMaxWait
+	}
+	return cfg
+}
+
+// Configure 热更新配置，保留已排队的请求
+func (s *Scheduler) Configure(cfg Config) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.cfg = normalizeConfig(cfg)
+	s.dispatch()
+}
+
+// Acquire 申请执行槽位，阻塞直到放行、超时、队列已满或 ctx 取消（客户端断开）
+// 成功时返回 release（必须调用）与排队时长
+func (s *Scheduler) Acquire(ctx context.Context, req Request) (func(), time.Duration, error) {
+	if req.Class == "" {
+		req.Class = ClassInteractive
+	}
+
+	s.mu.Lock()
+	if s.running < s.cfg.MaxConcurrent && s.queuedLocked() == 0 {
+		s.admitLocked(req.Tenant)
+		s.mu.Unlock()
+		return s.releaseFunc(req.Tenant), 0, nil
+	}
+	if s.queuedLocked() >= s.cfg.MaxQueueDepth {
+		s.stats.Rejected++
+		s.mu.Unlock()
+		return nil, 0, ErrQueueFull
+	}
+
+	w := &waiter{
+		req:        req,
+		tag:        s.nextTagLocked(req),
+		enqueuedAt: time.Now(),
+		ready:      make(chan struct{}),
+	}
+	s.queues[req.Class] = append(s.queues[req.Class], w)
+	s.stats.Queued++
+	maxWait := s.cfg.MaxWait
+	s.mu.Unlock()
+
+	timer := time.NewTimer(maxWait)
+	defer timer.Stop()
+
+	var waitErr error
+	select {
+	case <-w.ready:
+		return s.releaseFunc(req.Tenant), time.Since(w.enqueuedAt), nil
+	case <-ctx.Done():
+		waitErr = ctx.Err()
+	case <-timer.C:
+		waitErr = ErrWaitTimeout
+	}
+
+	s.mu.Lock()
+	if w.admitted {
+		// 放行与取消同时发生：归还刚拿到的槽位
+		s.releaseLocked(req.Tenant)
+	} else {
+		s.removeLocked(w)
+	}
+	if waitErr == ErrWaitTimeout {
+		s.stats.TimedOut++
+	} else {
+		s.stats.Canceled++
+	}
+	s.mu.Unlock()
+	return nil, time.Since(w.enqueuedAt), waitErr
+}
+
+// Snapshot 返回当前状态
+func (s *Scheduler) Snapshot() State {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	state := State{
+		MaxConcurrent:   s.cfg.MaxConcurrent,
+		MaxQueueDepth:   s.cfg.MaxQueueDepth,
+		MaxWaitMS:       s.cfg.MaxWait.Milliseconds(),
+		Running:         s.running,
+		Queued:          s.queuedLocked(),
+		RunningByTenant: make(map[string]int, len(s.runningByTenant)),
+		Classes:         make(map[Class]ClassState, len(classOrder)),
+		Items:           []QueuedItem{},
+		Stats:           s.stats,
+	}
+	for tenant, count := range s.runningByTenant {
+		state.RunningByTenant[tenant] = count
+	}
+	for _, class := range classOrder {
+		classState := ClassState{Tenants: map[string]int{}}
+		for _, w := range s.queues[class] {
+			classState.Queued++
+			classState.Tenants[w.req.Tenant]++
+			state.Items = append(state.Items, QueuedItem{
+				Tenant:   w.req.Tenant,
+				Class:    class,
+				Profile:  w.req.Profile,
+				WaitedMS: now.Sub(w.enqueuedAt).Milliseconds(),
+			})
+		}
+		state.Classes[class] = classState
+	}
+	return state
+}
+
+func (s *Scheduler) releaseFunc(tenant string) func() {
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			s.releaseLocked(tenant)
+		})
+	}
+}
+
+func (s *Scheduler) admitLocked(tenant string) {
+	s.running++
+	s.runningByTenant[tenant]++
+	s.stats.Admitted++
+}
+
+func (s *Scheduler) releaseLocked(tenant string) {
+	s.running--
+	if s.runningByTenant[tenant] <= 1 {
+		delete(s.runningByTenant, tenant)
+	} else {
+		s.runningByTenant[tenant]--
+	}
+	s.dispatch()
+}
+
+// dispatch 在有空闲槽位时按优先级与虚拟完成时间放行排队请求
+func (s *Scheduler) dispatch() {
+	for s.running < s.cfg.MaxConcurrent {
+		w := s.popNextLocked()
+		if w == nil {
+			return
+		}
+		w.admitted = true
+		s.admitLocked(w.req.Tenant)
+		close(w.ready)
+	}
+}
+
+func (s *Scheduler) popNextLocked() *waiter {
+	for _, class := range classOrder {
+		queue := s.queues[class]
+		if len(queue) == 0 {
+			continue
+		}
+		best := 0
+		for i, w := range queue {
+			if w.tag < queue[best].tag {
+				best = i
+			}
+		}
+		w := queue[best]
+		s.queues[class] = append(queue[:best], queue[best+1:]...)
+		s.virtualTime[class] = w.tag
+		s.forgetIdleTenantsLocked(class)
+		return w
+	}
+	return nil
+}
+
+func (s *Scheduler) removeLocked(target *waiter) {
+	queue := s.queues[target.req.Class]
+	for i, w := range queue {
+		if w == target {
+			s.queues[target.req.Class] = append(queue[:i], queue[i+1:]...)
+			s.forgetIdleTenantsLocked(target.req.Class)
+			return
+		}
+	}
+}
+
+func (s *Scheduler) nextTagLocked(req Request) float64 {
+	weight := s.cfg.TenantWeights[req.Tenant]
+	if weight <= 0 {
+		weight = 1
+	}
+	key := string(req.Class) + "/" + req.Tenant
+	start := s.virtualTime[req.Class]
+	if last := s.lastFinish[key]; last > start {
+		start = last
+	}
+	tag := start + 1/float64(weight)
+	s.lastFinish[key] = tag
+	return tag
+}
+
+// forgetIdleTenantsLocked 删除类别中已无排队请求的租户的上次完成时间，避免 lastFinish 随租户数无限增长：
+// 不晚于类别虚拟时间的记录不再影响后续请求的虚拟完成时间；类别队列排空时，取消请求留下的记录也一并丢弃
+func (s *Scheduler) forgetIdleTenantsLocked(class Class) {
+	queue := s.queues[class]
+	queued := make(map[string]bool, len(queue))
+	for _, w := range queue {
+		queued[w.req.Tenant] = true
+	}
+	prefix := string(class) + "/"
+	for key, last := range s.lastFinish {
+		tenant, ok := strings.CutPrefix(key, prefix)
+		if !ok || queued[tenant] {
+			continue
+		}
+		if last <= s.virtualTime[class] || len(queue) == 0 {
+			delete(s.lastFinish, key)
+		}
+	}
+}
+
+func (s *Scheduler) queuedLocked() int {
+	total := 0
+	for _, queue := range s.queues {
+		total += len(queue)
+	}
+	return total
+}
@@ -0,0 +1,157 @@
+package admission
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// waitQueued 等待队列达到指定长度
+func waitQueued(t *testing.T, s *Scheduler, n int) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for s.Snapshot().Queued != n {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %d queued requests, got %d", n, s.Snapshot().Queued)
+		}
+		time.Sleep(2 * time.Millisecond)
+	}
+}
+
+// runQueued 依次排入请求，按放行顺序记录 tenant/class
+func runQueued(t *testing.T, s *Scheduler, hold func(), reqs []Request) []string {
+	t.Helper()
+	var (
+		mu    sync.Mutex
+		order []string
+		wg    sync.WaitGroup
+	)
+	for i, req := range reqs {
+		wg.Add(1)
+		go func(req Request) {
+			defer wg.Done()
+			release, _, err := s.Acquire(context.Background(), req)
+			if err != nil {
+				t.Errorf("Acquire failed: %v", err)
+				return
+			}
+			mu.Lock()
+			order = append(order, req.Tenant+"/"+string(req.Class))
+			mu.Unlock()
+			release()
+		}(req)
+		waitQueued(t, s, i+1)
+	}
+	hold()
+	wg.Wait()
+	return order
+}
+
+func TestScheduler_PriorityClasses(t *testing.T) {
+	s := NewScheduler(Config{MaxConcurrent: 1})
+	release, _, err := s.Acquire(context.Background(), Request{Tenant: "holder"})
+	if err != nil {
+		t.Fatalf("Acquire failed: %v", err)
+	}
+
+	order := runQueued(t, s, release, []Request{
+		{Tenant: "a", Class: ClassBackground},
+		{Tenant: "a", Class: ClassBatch},
+		{Tenant: "a", Class: ClassInteractive},
+	})
+	expected := []string{"a/interactive", "a/batch", "a/background"}
+	for i := range expected {
+		if order[i] != expected[i] {
+			t.Fatalf("expected order %v, got %v", expected, order)
+		}
+	}
+}
+
+func TestScheduler_WeightedFairQueuing(t *testing.T) {
+	s := NewScheduler(Config{MaxConcurrent: 1, TenantWeights: map[string]int{"b": 2}})
+	release, _, _ := s.Acquire(context.Background(), Request{Tenant: "holder"})
+
+	// a 先排入 3 个请求，b 权重为 2 后排入 3 个：b 不应等 a 全部完成
+	order := runQueued(t, s, release, []Request{
+		{Tenant: "a", Class: ClassBatch},
+		{Tenant: "a", Class: ClassBatch},
+		{Tenant: "a", Class: ClassBatch},
+		{Tenant: "b", Class: ClassBatch},
+		{Tenant: "b", Class: ClassBatch},
+		{Tenant: "b", Class: ClassBatch},
+	})
+	expected := []string{"b/batch", "a/batch", "b/batch", "b/batch", "a/batch", "a/batch"}
+	for i := range expected {
+		if order[i] != expected[i] {
+			t.Fatalf("expected order %v, got %v", expected, order)
+		}
+	}
+}
+
+func TestScheduler_LimitsAndCancel(t *testing.T) {
+	s := NewScheduler(Config{MaxConcurrent: 1, MaxQueueDepth: 1, MaxWait: 30 * time.Millisecond})
+	release, _, _ := s.Acquire(context.Background(), Request{Tenant: "holder"})
+	defer release()
+
+	if _, _, err := s.Acquire(context.Background(), Request{Tenant: "a"}); !errors.Is(err, ErrWaitTimeout) {
+		t.Fatalf("expected wait timeout, got %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		_, _, err := s.Acquire(ctx, Request{Tenant: "a"})
+		done <- err
+	}()
+	waitQueued(t, s, 1)
+
+	if _, _, err := s.Acquire(context.Background(), Request{Tenant: "b"}); !errors.Is(err, ErrQueueFull) {
+		t.Fatalf("expected queue full, got %v", err)
+	}
+
+	cancel()
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected canceled, got %v", err)
+	}
+
+	state := s.Snapshot()
+	if state.Queued != 0 || state.Running != 1 {
+		t.Fatalf("unexpected state after cancel: %+v", state)
+	}
+	if state.Stats.TimedOut != 1 || state.Stats.Canceled != 1 || state.Stats.Rejected != 1 {
+		t.Fatalf("unexpected stats: %+v", state.Stats)
+	}
+}
+
+func TestScheduler_ForgetsIdleTenants(t *testing.T) {
+	s := NewScheduler(Config{MaxConcurrent: 1})
+	release, _, _ := s.Acquire(context.Background(), Request{Tenant: "holder"})
+
+	reqs := []Request{}
+	for _, tenant := range []string{"a", "b", "c", "a"} {
+		reqs = append(reqs, Request{Tenant: tenant, Class: ClassBatch})
+	}
+	runQueued(t, s, release, reqs)
+
+	// 排队请求取消后同样不留下记录
+	release, _, _ = s.Acquire(context.Background(), Request{Tenant: "holder"})
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		_, _, err := s.Acquire(ctx, Request{Tenant: "d", Class: ClassBatch})
+		done <- err
+	}()
+	waitQueued(t, s, 1)
+	cancel()
+	<-done
+	release()
+
+	s.mu.Lock()
+	remaining := len(s.lastFinish)
+	s.mu.Unlock()
+	if remaining != 0 {
+		t.Fatalf("tenants without queued requests should be forgotten, %d left", remaining)
+	}
+}
@@ -0,0 +1,108 @@
+package admission
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Class 优先级类别，高优先级类别的请求总是先于低优先级类别被放行
+type Class string
+
+const (
+	ClassInteractive Class = "interactive"
+	ClassBatch       Class = "batch"
+	ClassBackground  Class = "background"
+)
+
+// classOrder 按优先级从高到低排列
+var classOrder = []Class{ClassInteractive, ClassBatch, ClassBackground}
+
+const (
+	defaultMaxConcurrent = 4
+	defaultMaxQueueDepth = 100
+	defaultMaxWait       = 2 * time.Minute
+)
+
+var (
+	ErrQueueFull   = errors.New("admission queue is full")
+	ErrWaitTimeout = errors.New("admission queue wait timed out")
+)
+
+// ParseClass 解析优先级类别，空值返回 interactive
+func ParseClass(value string) (Class, error) {
+	switch Class(strings.ToLower(strings.TrimSpace(value))) {
+	case "":
+		return ClassInteractive, nil
+	case ClassInteractive:
+		return ClassInteractive, nil
+	case ClassBatch:
+		return ClassBatch, nil
+	case ClassBackground:
+		return ClassBackground, nil
+	default:
+		return "", fmt.Errorf("unsupported priority class: %s", value)
+	}
+}
+
+// Rank 返回类别的优先级序号，数值越小优先级越高
+func (c Class) Rank() int {
+	for i, class := range classOrder {
+		if class == c {
+			return i
+		}
+	}
+	return len(classOrder)
+}
+
+// Config 调度器配置
+type Config struct {
+	MaxConcurrent int            // 同时执行的 CLI 数量上限
+	MaxQueueDepth int            // 排队请求数上限，超出直接拒绝
+	MaxWait       time.Duration  // 最长排队时间
+	TenantWeights map[string]int // 租户权重（同一类别内按权重公平分配），默认 1
+}
+
+// Request 一次准入请求
+type Request struct {
+	Tenant  string
+	Class   Class
+	Profile string
+}
+
+// State 调度器状态快照
+type State struct {
+	MaxConcurrent   int                  `json:"max_concurrent"`
+	MaxQueueDepth   int                  `json:"max_queue_depth"`
+	MaxWaitMS       int64                `json:"max_wait_ms"`
+	Running         int                  `json:"running"`
+	Queued          int                  `json:"queued"`
+	RunningByTenant map[string]int       `json:"running_by_tenant"`
+	Classes         map[Class]ClassState `json:"classes"`
+	Items           []QueuedItem         `json:"items"`
+	Stats           Stats                `json:"stats"`
+}
+
+// ClassState 单个类别的排队情况
+type ClassState struct {
+	Queued  int            `json:"queued"`
+	Tenants map[string]int `json:"tenants"`
+}
+
+// QueuedItem 排队中的请求
+type QueuedItem struct {
+	Tenant   string `json:"tenant"`
+	Class    Class  `json:"class"`
+	Profile  string `json:"profile,omitempty"`
+	WaitedMS int64  `json:"waited_ms"`
+}
+
+// Stats 累计计数
+type Stats struct {
+	Admitted int64 `json:"admitted"`
+	Queued   int64 `json:"queued"`
+	Rejected int64 `json:"rejected"`
+	TimedOut int64 `json:"timed_out"`
+	Canceled int64 `json:"canceled"`
+}
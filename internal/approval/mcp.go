@@ -0,0 +1,75 @@
+package approval
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"dify-cli-gateway/internal/mcp_server"
+)
+
+// MCP server 与工具名：Claude 中引用为 mcp__<ServerName>__<ToolName>
+const (
+	ServerName = "gateway_approval"
+	ToolName   = "approve"
+)
+
+// PermissionPromptTool 传给 claude --permission-prompt-tool 的完整工具名
+const PermissionPromptTool = "mcp__" + ServerName + "__" + ToolName
+
+var permissionInputSchema = json.RawMessage(`{
+	"type": "object",
+	"properties": {
+		"tool_name": {"type": "string"},
+		"input": {"type": "object"},
+		"tool_use_id": {"type": "string"}
+	},
+	"required": ["tool_name", "input"]
+}`)
+
+// NewPermissionServer 创建某次 CLI 调用的 permission-prompt MCP server
+func NewPermissionServer(q *Queue, token string) *mcp_server.Server {
+	return mcp_server.NewServer(ServerName, "1.0.0", mcp_server.Tool{
+		Name:        ToolName,
+		Description: "Ask the gateway operator or caller to approve a tool call",
+		InputSchema: permissionInputSchema,
+		Handler: func(ctx context.Context, args json.RawMessage) (mcp_server.ToolResult, error) {
+			var call struct {
+				ToolName  string          `json:"tool_name"`
+				Input     json.RawMessage `json:"input"`
+				ToolUseID string          `json:"tool_use_id"`
+			}
+			if err := json.Unmarshal(args, &call); err != nil || call.ToolName == "" {
+				return mcp_server.ToolResult{}, fmt.Errorf("invalid permission request")
+			}
+			decision, _, err := q.Submit(ctx, token, call.ToolName, call.Input, call.ToolUseID)
+			if err != nil {
+				return mcp_server.ToolResult{}, err
+			}
+			return mcp_server.ToolResult{Text: permissionResponse(decision, call.Input)}, nil
+		},
+	})
+}
+
+// permissionResponse 按 Claude permission-prompt 约定编码决定
+func permissionResponse(decision Decision, input json.RawMessage) string {
+	var payload interface{}
+	if decision.Allow {
+		updated := decision.UpdatedInput
+		if len(updated) == 0 {
+			updated = input
+		}
+		if len(updated) == 0 {
+			updated = json.RawMessage("{}")
+		}
+		payload = map[string]interface{}{"behavior": "allow", "updatedInput": updated}
+	} else {
+		message := decision.Message
+		if message == "" {
+			message = "denied by reviewer"
+		}
+		payload = map[string]interface{}{"behavior": "deny", "message": message}
+	}
+	data, _ := json.Marshal(payload)
+	return string(data)
+}
@@ -0,0 +1,256 @@
+package approval
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Config 审批队列配置
+type Config struct {
+	Timeout     time.Duration // 默认等待时长，默认 5 分钟
+	HistorySize int           // 保留最近已决定的审批数量，默认 200
+	Notify      func(Event)   // 事件回调（如 webhook），在独立 goroutine 中调用
+}
+
+type resolution struct {
+	decision Decision
+	request  Request
+}
+
+type pendingEntry struct {
+	req   *Request
+	scope string
+	done  chan resolution
+}
+
+// Queue 待审批的工具调用队列
+type Queue struct {
+	mu          sync.Mutex
+	cfg         Config
+	scopes      map[string]Scope
+	pending     map[string]*pendingEntry
+	recent      []Request
+	subscribers map[chan Event]struct{}
+	now         func() time.Time
+}
+
+// NewQueue 创建审批队列
+func NewQueue(cfg Config) *Queue {
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 5 * time.Minute
+	}
+	if cfg.HistorySize <= 0 {
+		cfg.HistorySize = 200
+	}
+	return &Queue{
+		cfg:         cfg,
+		scopes:      make(map[string]Scope),
+		pending:     make(map[string]*pendingEntry),
+		subscribers: make(map[chan Event]struct{}),
+		now:         time.Now,
+	}
+}
+
+// Open 登记一次 CLI 调用的审批上下文，返回用于 MCP 端点的随机 token
+func (q *Queue) Open(scope Scope) string {
+	token := randomHex(24)
+	q.mu.Lock()
+	q.scopes[token] = scope
+	q.mu.Unlock()
+	return token
+}
+
+// Close CLI 调用结束后注销上下文，并拒绝其仍在等待的审批
+func (q *Queue) Close(token string) {
+	q.mu.Lock()
+	delete(q.scopes, token)
+	var orphaned []string
+	for id, entry := range q.pending {
+		if entry.scope == token {
+			orphaned = append(orphaned, id)
+		}
+	}
+	q.mu.Unlock()
+
+	for _, id := range orphaned {
+		q.resolve(id, Decision{Message: "the CLI run has finished"}, DecidedByClosed)
+	}
+}
+
+// Submit 提交一次工具调用审批并等待决定（命中规则时立即返回）
+func (q *Queue) Submit(ctx context.Context, token, tool string, input json.RawMessage, toolUseID string) (Decision, Request, error) {
+	q.mu.Lock()
+	scope, ok := q.scopes[token]
+	q.mu.Unlock()
+	if !ok {
+		return Decision{}, Request{}, ErrUnknownScope
+	}
+
+	timeout := scope.Timeout
+	if timeout <= 0 {
+		timeout = q.cfg.Timeout
+	}
+	now := q.now()
+	req := &Request{
+		ID:        "apv_" + randomHex(8),
+		Tool:      tool,
+		Input:     input,
+		ToolUseID: toolUseID,
+		Profile:   scope.Profile,
+		CLI:       scope.CLI,
+		Owner:     scope.Owner,
+		Tenant:    scope.Tenant,
+		Status:    StatusPending,
+		CreatedAt: now,
+		ExpiresAt: now.Add(timeout),
+	}
+
+	if rule, ok := MatchRules(scope.Rules, tool, input); ok {
+		decision := Decision{Allow: rule.Allow()}
+		if !decision.Allow {
+			decision.Message = fmt.Sprintf("denied by rule for %s", rule.Tool)
+		}
+		q.mu.Lock()
+		q.finishLocked(req, decision, DecidedByRule)
+		final := *req
+		q.mu.Unlock()
+		return decision, final, nil
+	}
+
+	entry := &pendingEntry{req: req, scope: token, done: make(chan resolution, 1)}
+	q.mu.Lock()
+	q.pending[req.ID] = entry
+	q.emitLocked(Event{Type: EventPending, Approval: *req})
+	q.mu.Unlock()
+	log.Printf("⏸️  [Approval] %s waiting for decision: tool=%s profile=%s (timeout %v)", req.ID, tool, scope.Profile, timeout)
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case res := <-entry.done:
+		return res.decision, res.request, nil
+	case <-timer.C:
+		q.resolve(req.ID, Decision{
+			Allow:   scope.TimeoutDecision == "allow",
+			Message: fmt.Sprintf("approval timed out after %v", timeout),
+		}, DecidedByTimeout)
+	case <-ctx.Done():
+		q.resolve(req.ID, Decision{Message: "approval request was cancelled"}, DecidedByClosed)
+	}
+	// 超时/取消与人工决定可能同时发生，以先写入的决定为准
+	res := <-entry.done
+	return res.decision, res.request, nil
+}
+
+// Decide 对待审批请求给出决定
+func (q *Queue) Decide(id string, decision Decision, by string) (Request, error) {
+	return q.resolve(id, decision, by)
+}
+
+func (q *Queue) resolve(id string, decision Decision, by string) (Request, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	entry, ok := q.pending[id]
+	if !ok {
+		for _, req := range q.recent {
+			if req.ID == id {
+				return req, ErrAlreadyDecided
+			}
+		}
+		return Request{}, ErrNotFound
+	}
+	delete(q.pending, id)
+	q.finishLocked(entry.req, decision, by)
+	final := *entry.req
+	entry.done <- resolution{decision: decision, request: final}
+	return final, nil
+}
+
+func (q *Queue) finishLocked(req *Request, decision Decision, by string) {
+	decidedAt := q.now()
+	req.DecidedAt = &decidedAt
+	req.DecidedBy = by
+	req.Message = decision.Message
+	req.Status = StatusDenied
+	if decision.Allow {
+		req.Status = StatusApproved
+	}
+	q.recent = append(q.recent, *req)
+	if len(q.recent) > q.cfg.HistorySize {
+		q.recent = q.recent[len(q.recent)-q.cfg.HistorySize:]
+	}
+	q.emitLocked(Event{Type: EventDecided, Approval: *req})
+	log.Printf("✅ [Approval] %s %s by %s: tool=%s", req.ID, req.Status, by, req.Tool)
+}
+
+// Pending 返回等待中的审批（按创建时间排序）
+func (q *Queue) Pending() []Request {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	list := make([]Request, 0, len(q.pending))
+	for _, entry := range q.pending {
+		list = append(list, *entry.req)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].CreatedAt.Before(list[j].CreatedAt) })
+	return list
+}
+
+// Get 查询等待中或最近已决定的审批
+func (q *Queue) Get(id string) (Request, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if entry, ok := q.pending[id]; ok {
+		return *entry.req, true
+	}
+	for i := len(q.recent) - 1; i >= 0; i-- {
+		if q.recent[i].ID == id {
+			return q.recent[i], true
+		}
+	}
+	return Request{}, false
+}
+
+// Subscribe 订阅审批事件；消费过慢时丢弃事件
+func (q *Queue) Subscribe() (<-chan Event, func()) {
+	ch := make(chan Event, 32)
+	q.mu.Lock()
+	q.subscribers[ch] = struct{}{}
+	q.mu.Unlock()
+	return ch, func() {
+		q.mu.Lock()
+		if _, ok := q.subscribers[ch]; ok {
+			delete(q.subscribers, ch)
+			close(ch)
+		}
+		q.mu.Unlock()
+	}
+}
+
+func (q *Queue) emitLocked(event Event) {
+	for ch := range q.subscribers {
+		select {
+		case ch <- event:
+		default:
+			log.Printf("⚠️  [Approval] Subscriber is too slow, dropped %s event for %s", event.Type, event.Approval.ID)
+		}
+	}
+	if q.cfg.Notify != nil {
+		go q.cfg.Notify(event)
+	}
+}
+
+func randomHex(n int) string {
+	buf := make([]byte, n)
+	if _, err := rand.Read(buf); err != nil {
+		return fmt.Sprintf("%x", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(buf)
+}
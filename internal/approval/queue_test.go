@@ -0,0 +1,121 @@
+package approval
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRuleMatch(t *testing.T) {
+	rule := Rule{Tool: "Bash", Args: map[string]string{"command": `^(ls|git status)\b`}}
+	if !rule.Match("Bash", json.RawMessage(`{"command":"ls -la"}`)) {
+		t.Error("expected ls to match")
+	}
+	if rule.Match("Bash", json.RawMessage(`{"command":"rm -rf /"}`)) {
+		t.Error("rm must not match")
+	}
+	if rule.Match("Bash", json.RawMessage(`{}`)) {
+		t.Error("missing arg must not match")
+	}
+	if !(Rule{Tool: "mcp__github__get_*"}).Match("mcp__github__get_issue", nil) {
+		t.Error("expected wildcard tool to match")
+	}
+	if (Rule{Tool: "Read", Args: map[string]string{"limit": "^1\\d$"}}).Match("Read", json.RawMessage(`{"limit":200}`)) {
+		t.Error("non-string args should match against their JSON text")
+	}
+	if err := (Rule{Tool: "Bash", Args: map[string]string{"command": "("}}).Validate(); err == nil {
+		t.Error("expected invalid regex to fail validation")
+	}
+}
+
+func TestQueue_RuleDecision(t *testing.T) {
+	q := NewQueue(Config{})
+	token := q.Open(Scope{Profile: "p", Rules: []Rule{
+		{Tool: "Read"},
+		{Tool: "Bash", Args: map[string]string{"command": "^rm "}, Action: "deny"},
+	}})
+
+	decision, req, err := q.Submit(context.Background(), token, "Read", json.RawMessage(`{"file_path":"a"}`), "t1")
+	if err != nil || !decision.Allow || req.DecidedBy != DecidedByRule || req.Status != StatusApproved {
+		t.Errorf("expected rule approval, got %+v %+v %v", decision, req, err)
+	}
+	decision, _, _ = q.Submit(context.Background(), token, "Bash", json.RawMessage(`{"command":"rm -rf x"}`), "t2")
+	if decision.Allow || !strings.Contains(decision.Message, "rule") {
+		t.Errorf("expected rule denial, got %+v", decision)
+	}
+	if _, _, err := q.Submit(context.Background(), "unknown", "Read", nil, ""); !errors.Is(err, ErrUnknownScope) {
+		t.Errorf("expected ErrUnknownScope, got %v", err)
+	}
+}
+
+func TestQueue_ManualDecision(t *testing.T) {
+	q := NewQueue(Config{})
+	events, cancel := q.Subscribe()
+	defer cancel()
+	token := q.Open(Scope{Profile: "p", Owner: "o1"})
+
+	type result struct {
+		decision Decision
+		req      Request
+	}
+	done := make(chan result, 1)
+	go func() {
+		d, req, _ := q.Submit(context.Background(), token, "Bash", json.RawMessage(`{"command":"make"}`), "t1")
+		done <- result{d, req}
+	}()
+
+	event := <-events
+	if event.Type != EventPending || event.Approval.Owner != "o1" || len(q.Pending()) != 1 {
+		t.Fatalf("unexpected pending event: %+v", event)
+	}
+	if _, err := q.Decide(event.Approval.ID, Decision{Allow: true}, DecidedByOperator); err != nil {
+		t.Fatalf("decide: %v", err)
+	}
+	res := <-done
+	if !res.decision.Allow || res.req.Status != StatusApproved || res.req.DecidedBy != DecidedByOperator {
+		t.Errorf("unexpected result: %+v", res)
+	}
+	if _, err := q.Decide(event.Approval.ID, Decision{}, DecidedByUser); !errors.Is(err, ErrAlreadyDecided) {
+		t.Errorf("expected ErrAlreadyDecided, got %v", err)
+	}
+	if got, ok := q.Get(event.Approval.ID); !ok || got.Status != StatusApproved {
+		t.Errorf("decided approval should stay queryable: %+v", got)
+	}
+}
+
+func TestQueue_TimeoutAndClose(t *testing.T) {
+	q := NewQueue(Config{})
+	token := q.Open(Scope{Timeout: 20 * time.Millisecond, TimeoutDecision: "allow"})
+	decision, req, _ := q.Submit(context.Background(), token, "Bash", nil, "")
+	if !decision.Allow || req.DecidedBy != DecidedByTimeout {
+		t.Errorf("expected timeout default allow, got %+v %+v", decision, req)
+	}
+
+	token = q.Open(Scope{})
+	done := make(chan Decision, 1)
+	go func() {
+		d, _, _ := q.Submit(context.Background(), token, "Bash", nil, "")
+		done <- d
+	}()
+	for len(q.Pending()) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	q.Close(token)
+	if d := <-done; d.Allow {
+		t.Error("closing the scope should deny pending approvals")
+	}
+}
+
+func TestPermissionResponse(t *testing.T) {
+	allow := permissionResponse(Decision{Allow: true}, json.RawMessage(`{"command":"ls"}`))
+	if allow != `{"behavior":"allow","updatedInput":{"command":"ls"}}` {
+		t.Errorf("unexpected allow payload: %s", allow)
+	}
+	deny := permissionResponse(Decision{Message: "no"}, nil)
+	if deny != `{"behavior":"deny","message":"no"}` {
+		t.Errorf("unexpected deny payload: %s", deny)
+	}
+}
@@ -0,0 +1,79 @@
+package approval
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"regexp"
+)
+
+// Rule 自动审批规则：工具名与参数均匹配时直接给出决定
+type Rule struct {
+	Tool   string            `json:"tool"`             // 工具名，支持 * 通配（如 "mcp__github__get_*"）
+	Args   map[string]string `json:"args,omitempty"`   // 参数名 → 正则，全部匹配才生效；非字符串参数按 JSON 文本匹配
+	Action string            `json:"action,omitempty"` // allow（默认）/ deny
+}
+
+// Allow 规则是否为放行
+func (r Rule) Allow() bool {
+	return r.Action != "deny"
+}
+
+// Validate 校验通配符与正则
+func (r Rule) Validate() error {
+	if r.Tool == "" {
+		return fmt.Errorf("rule tool is required")
+	}
+	if _, err := path.Match(r.Tool, ""); err != nil {
+		return fmt.Errorf("invalid tool pattern %q: %v", r.Tool, err)
+	}
+	if r.Action != "" && r.Action != "allow" && r.Action != "deny" {
+		return fmt.Errorf("invalid rule action %q", r.Action)
+	}
+	for name, pattern := range r.Args {
+		if _, err := regexp.Compile(pattern); err != nil {
+			return fmt.Errorf("invalid pattern for arg %q: %v", name, err)
+		}
+	}
+	return nil
+}
+
+// Match 判断工具调用是否命中规则（参数缺失视为不匹配）
+func (r Rule) Match(tool string, input json.RawMessage) bool {
+	if ok, err := path.Match(r.Tool, tool); err != nil || !ok {
+		return false
+	}
+	if len(r.Args) == 0 {
+		return true
+	}
+	var args map[string]json.RawMessage
+	if err := json.Unmarshal(input, &args); err != nil {
+		return false
+	}
+	for name, pattern := range r.Args {
+		raw, ok := args[name]
+		if !ok {
+			return false
+		}
+		value := string(raw)
+		var text string
+		if json.Unmarshal(raw, &text) == nil {
+			value = text
+		}
+		re, err := regexp.Compile(pattern)
+		if err != nil || !re.MatchString(value) {
+			return false
+		}
+	}
+	return true
+}
+
+// MatchRules 返回第一条命中的规则
+func MatchRules(rules []Rule, tool string, input json.RawMessage) (Rule, bool) {
+	for _, rule := range rules {
+		if rule.Match(tool, input) {
+			return rule, true
+		}
+	}
+	return Rule{}, false
+}
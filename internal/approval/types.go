@@ -0,0 +1,78 @@
+package approval
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// Status 审批状态
+type Status string
+
+const (
+	StatusPending  Status = "pending"
+	StatusApproved Status = "approved"
+	StatusDenied   Status = "denied"
+)
+
+// 决定来源
+const (
+	DecidedByRule     = "rule"     // profile 自动审批规则
+	DecidedByOperator = "operator" // 管理后台
+	DecidedByUser     = "user"     // 发起调用的 API Key
+	DecidedByTimeout  = "timeout"  // 超时后按默认决定处理
+	DecidedByClosed   = "closed"   // CLI 调用已结束
+)
+
+var (
+	ErrNotFound       = errors.New("approval not found")
+	ErrAlreadyDecided = errors.New("approval already decided")
+	ErrUnknownScope   = errors.New("unknown approval scope")
+)
+
+// Scope 一次 CLI 调用的审批上下文（对应一个 permission-prompt MCP 端点）
+type Scope struct {
+	Profile         string
+	CLI             string
+	Owner           string // 调用方 API Key 指纹
+	Tenant          string
+	Rules           []Rule
+	Timeout         time.Duration // <=0 使用队列默认值
+	TimeoutDecision string        // 超时后的决定：deny（默认）/ allow
+}
+
+// Request 一次工具调用审批
+type Request struct {
+	ID        string          `json:"id"`
+	Tool      string          `json:"tool"`
+	Input     json.RawMessage `json:"input,omitempty"`
+	ToolUseID string          `json:"tool_use_id,omitempty"`
+	Profile   string          `json:"profile,omitempty"`
+	CLI       string          `json:"cli,omitempty"`
+	Owner     string          `json:"owner,omitempty"`
+	Tenant    string          `json:"tenant,omitempty"`
+	Status    Status          `json:"status"`
+	CreatedAt time.Time       `json:"created_at"`
+	ExpiresAt time.Time       `json:"expires_at"`
+	DecidedAt *time.Time      `json:"decided_at,omitempty"`
+	DecidedBy string          `json:"decided_by,omitempty"`
+	Message   string          `json:"message,omitempty"`
+}
+
+// Decision 审批决定
+type Decision struct {
+	Allow        bool
+	Message      string          // 拒绝原因（返回给 CLI）
+	UpdatedInput json.RawMessage // 允许时可替换工具参数，空表示沿用原参数
+}
+
+// Event 审批事件（SSE 与 webhook 共用）
+type Event struct {
+	Type     string  `json:"type"` // approval.pending / approval.decided
+	Approval Request `json:"approval"`
+}
+
+const (
+	EventPending = "approval.pending"
+	EventDecided = "approval.decided"
+)
@@ -0,0 +1,59 @@
+package approval
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+)
+
+// NewWebhookNotifier 将审批事件 POST 到各个 URL；配置 secret 时附带 X-Gateway-Signature: sha256=<hmac>
+func NewWebhookNotifier(urls []string, secret string, timeout time.Duration) func(Event) {
+	if len(urls) == 0 {
+		return nil
+	}
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+	client := &http.Client{Timeout: timeout}
+
+	return func(event Event) {
+		body, err := json.Marshal(event)
+		if err != nil {
+			log.Printf("⚠️  [Approval] Failed to encode webhook payload: %v", err)
+			return
+		}
+		for _, url := range urls {
+			req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+			if err != nil {
+				log.Printf("⚠️  [Approval] Invalid webhook URL %s: %v", url, err)
+				continue
+			}
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("X-Gateway-Event", event.Type)
+			if secret != "" {
+				req.Header.Set("X-Gateway-Signature", "sha256="+Sign(secret, body))
+			}
+			resp, err := client.Do(req)
+			if err != nil {
+				log.Printf("⚠️  [Approval] Webhook %s failed: %v", url, err)
+				continue
+			}
+			resp.Body.Close()
+			if resp.StatusCode >= 300 {
+				log.Printf("⚠️  [Approval] Webhook %s returned %d", url, resp.StatusCode)
+			}
+		}
+	}
+}
+
+// Sign 计算 webhook 请求体的 HMAC-SHA256 签名（hex）
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,69 @@
+package batch
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"strconv"
+)
+
+// exportRecord 导出的单条结果
+type exportRecord struct {
+	Index      int        `json:"index"`
+	ID         string     `json:"id,omitempty"`
+	Status     ItemStatus `json:"status"`
+	Prompt     string     `json:"prompt"`
+	Response   string     `json:"response,omitempty"`
+	Error      string     `json:"error,omitempty"`
+	SessionID  string     `json:"session_id,omitempty"`
+	Attempts   int        `json:"attempts"`
+	DurationMS int64      `json:"duration_ms,omitempty"`
+}
+
+// WriteJSONL 以 JSONL 格式导出结果（每行一个条目）
+func WriteJSONL(w io.Writer, b *Batch) error {
+	encoder := json.NewEncoder(w)
+	for _, item := range b.Items {
+		if err := encoder.Encode(exportRecord{
+			Index:      item.Index,
+			ID:         item.ID,
+			Status:     item.Status,
+			Prompt:     item.Prompt,
+			Response:   item.Response,
+			Error:      item.Error,
+			SessionID:  item.SessionID,
+			Attempts:   item.Attempts,
+			DurationMS: item.DurationMS,
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteCSV 以 CSV 格式导出结果
+func WriteCSV(w io.Writer, b *Batch) error {
+	writer := csv.NewWriter(w)
+	header := []string{"index", "id", "status", "prompt", "response", "error", "session_id", "attempts", "duration_ms"}
+	if err := writer.Write(header); err != nil {
+		return err
+	}
+	for _, item := range b.Items {
+		record := []string{
+			strconv.Itoa(item.Index),
+			item.ID,
+			string(item.Status),
+			item.Prompt,
+			item.Response,
+			item.Error,
+			item.SessionID,
+			strconv.Itoa(item.Attempts),
+			strconv.FormatInt(item.DurationMS, 10),
+		}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
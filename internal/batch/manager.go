@@ -0,0 +1,424 @@
+package batch
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const defaultConcurrency = 4
+
+// ManagerConfig 批任务管理器配置
+type ManagerConfig struct {
+	StorageDir     string // 进度持久化目录，为空时仅保存在内存
+	MaxConcurrency int    // 单个批任务的并发上限
+}
+
+// Manager 管理批任务的创建、执行、取消与恢复
+type Manager struct {
+	mu       sync.Mutex
+	cfg      ManagerConfig
+	executor Executor
+	batches  map[string]*Batch
+	cancels  map[string]context.CancelFunc
+	done     map[string]chan struct{}
+}
+
+// NewManager 创建管理器，并从存储目录加载历史批任务
+// 上次未完成的批任务标记为 interrupted，可通过 Resume 继续
+func NewManager(cfg ManagerConfig, executor Executor) (*Manager, error) {
+	if executor == nil {
+		return nil, fmt.Errorf("executor is required")
+	}
+	if cfg.MaxConcurrency <= 0 {
+		cfg.MaxConcurrency = defaultConcurrency
+	}
+	m := &Manager{
+		cfg:      cfg,
+		executor: executor,
+		batches:  make(map[string]*Batch),
+		cancels:  make(map[string]context.CancelFunc),
+		done:     make(map[string]chan struct{}),
+	}
+	if err := m.load(); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// Submit 创建并开始执行批任务
+func (m *Manager) Submit(input *Input) (Summary, error) {
+	if input == nil || len(input.Items) == 0 {
+		return Summary{}, fmt.Errorf("batch has no items")
+	}
+
+	concurrency := input.Concurrency
+	if concurrency <= 0 || concurrency > m.cfg.MaxConcurrency {
+		concurrency = m.cfg.MaxConcurrency
+	}
+	now := time.Now()
+	b := &Batch{
+		ID:          newBatchID(),
+		Status:      StatusPending,
+		Concurrency: concurrency,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+		Labels:      input.Labels,
+		Items:       make([]*Item, 0, len(input.Items)),
+	}
+	for i, item := range input.Items {
+		copied := *item
+		copied.Index = i
+		copied.Status = ItemPending
+		if copied.System == "" {
+			copied.System = input.System
+		}
+		if copied.Profile == "" {
+			copied.Profile = input.Profile
+		}
+		if copied.CLI == "" {
+			copied.CLI = input.CLI
+		}
+		b.Items = append(b.Items, &copied)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.batches[b.ID] = b
+	m.startLocked(b)
+	log.Printf("📦 [Batch] Submitted %s: items=%d concurrency=%d", b.ID, len(b.Items), concurrency)
+	return b.summary(), nil
+}
+
+// Get 返回批任务快照（含条目结果）
+func (m *Manager) Get(id string) (*Batch, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	b, ok := m.batches[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return cloneBatch(b), nil
+}
+
+// Summary 返回批任务概要
+func (m *Manager) Summary(id string) (Summary, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	b, ok := m.batches[id]
+	if !ok {
+		return Summary{}, ErrNotFound
+	}
+	return b.summary(), nil
+}
+
+// List 按创建时间倒序返回批任务概要；match 非空时只返回提交方标签匹配的批任务
+func (m *Manager) List(match func(labels map[string]string) bool) []Summary {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	summaries := make([]Summary, 0, len(m.batches))
+	for _, b := range m.batches {
+		if match != nil && !match(b.Labels) {
+			continue
+		}
+		summaries = append(summaries, b.summary())
+	}
+	sort.Slice(summaries, func(i, j int) bool {
+		return summaries[i].CreatedAt.After(summaries[j].CreatedAt)
+	})
+	return summaries
+}
+
+// Cancel 取消执行中的批任务；已完成的条目结果保留
+func (m *Manager) Cancel(id string) error {
+	m.mu.Lock()
+	if _, ok := m.batches[id]; !ok {
+		m.mu.Unlock()
+		return ErrNotFound
+	}
+	cancel, ok := m.cancels[id]
+	m.mu.Unlock()
+	if !ok {
+		return ErrNotRunning
+	}
+	cancel()
+	log.Printf("🛑 [Batch] Cancel requested: %s", id)
+	return nil
+}
+
+// Resume 继续执行未完成（pending / canceled）的条目，retryFailed 为 true 时同时重试失败条目
+func (m *Manager) Resume(id string, retryFailed bool) (Summary, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	b, ok := m.batches[id]
+	if !ok {
+		return Summary{}, ErrNotFound
+	}
+	if _, running := m.cancels[id]; running {
+		return Summary{}, ErrAlreadyRunning
+	}
+
+	resumed := 0
+	for _, item := range b.Items {
+		switch {
+		case item.Status == ItemPending, item.Status == ItemCanceled, item.Status == ItemRunning:
+		case item.Status == ItemFailed && retryFailed:
+		default:
+			continue
+		}
+		item.Status = ItemPending
+		item.Error = ""
+		resumed++
+	}
+	if resumed == 0 {
+		return b.summary(), nil
+	}
+	b.FinishedAt = nil
+	m.startLocked(b)
+	log.Printf("🔁 [Batch] Resumed %s: items=%d", id, resumed)
+	return b.summary(), nil
+}
+
+// Delete 取消并删除批任务
+func (m *Manager) Delete(id string) error {
+	m.mu.Lock()
+	if _, ok := m.batches[id]; !ok {
+		m.mu.Unlock()
+		return ErrNotFound
+	}
+	cancel, running := m.cancels[id]
+	done := m.done[id]
+	m.mu.Unlock()
+
+	if running {
+		cancel()
+		<-done
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.batches, id)
+	if path := m.batchPath(id); path != "" {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove batch file: %v", err)
+		}
+	}
+	return nil
+}
+
+// Wait 等待批任务本轮执行结束（用于测试与优雅退出）
+func (m *Manager) Wait(id string) {
+	m.mu.Lock()
+	done, ok := m.done[id]
+	m.mu.Unlock()
+	if ok {
+		<-done
+	}
+}
+
+func (m *Manager) startLocked(b *Batch) {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	m.cancels[b.ID] = cancel
+	m.done[b.ID] = done
+	b.Status = StatusRunning
+	b.UpdatedAt = time.Now()
+	m.saveLocked(b)
+	go m.run(ctx, b, done)
+}
+
+func (m *Manager) run(ctx context.Context, b *Batch, done chan struct{}) {
+	defer close(done)
+
+	sem := make(chan struct{}, b.Concurrency)
+	var wg sync.WaitGroup
+
+	m.mu.Lock()
+	pending := make([]*Item, 0, len(b.Items))
+	for _, item := range b.Items {
+		if item.Status == ItemPending {
+			pending = append(pending, item)
+		}
+	}
+	m.mu.Unlock()
+
+dispatch:
+	for _, item := range pending {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			break dispatch
+		}
+		wg.Add(1)
+		go func(item *Item) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			m.runItem(ctx, b, item)
+		}(item)
+	}
+	wg.Wait()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	if ctx.Err() != nil {
+		for _, item := range b.Items {
+			if item.Status == ItemPending {
+				item.Status = ItemCanceled
+			}
+		}
+		b.Status = StatusCanceled
+	} else {
+		b.Status = StatusCompleted
+	}
+	b.FinishedAt = &now
+	b.UpdatedAt = now
+	delete(m.cancels, b.ID)
+	delete(m.done, b.ID)
+	m.saveLocked(b)
+
+	counts := b.counts()
+	log.Printf("📦 [Batch] %s %s: succeeded=%d failed=%d canceled=%d", b.ID, b.Status, counts.Succeeded, counts.Failed, counts.Canceled)
+}
+
+func (m *Manager) runItem(ctx context.Context, b *Batch, item *Item) {
+	m.mu.Lock()
+	if ctx.Err() != nil {
+		m.mu.Unlock()
+		return
+	}
+	started := time.Now()
+	item.Status = ItemRunning
+	item.Attempts++
+	item.StartedAt = &started
+	item.FinishedAt = nil
+	snapshot := *item
+	labels := b.Labels
+	m.mu.Unlock()
+
+	result, err := m.executor(ctx, snapshot, labels)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	finished := time.Now()
+	item.FinishedAt = &finished
+	item.DurationMS = finished.Sub(started).Milliseconds()
+	switch {
+	case err != nil && ctx.Err() != nil:
+		item.Status = ItemCanceled
+		item.Error = "canceled"
+	case err != nil:
+		// 单条失败不影响其它条目
+		item.Status = ItemFailed
+		item.Error = err.Error()
+		log.Printf("⚠️  [Batch] %s item %d failed: %v", b.ID, item.Index, err)
+	default:
+		item.Status = ItemSucceeded
+		item.Error = ""
+		item.Response = result.Response
+		item.SessionID = result.SessionID
+	}
+	b.UpdatedAt = finished
+	m.saveLocked(b)
+}
+
+func (m *Manager) batchPath(id string) string {
+	if m.cfg.StorageDir == "" {
+		return ""
+	}
+	return filepath.Join(m.cfg.StorageDir, id+".json")
+}
+
+// saveLocked 原子写入批任务进度（临时文件 + rename）
+func (m *Manager) saveLocked(b *Batch) {
+	path := m.batchPath(b.ID)
+	if path == "" {
+		return
+	}
+	if err := os.MkdirAll(m.cfg.StorageDir, 0755); err != nil {
+		log.Printf("⚠️  [Batch] Failed to create storage dir: %v", err)
+		return
+	}
+	data, err := json.Marshal(b)
+	if err != nil {
+		log.Printf("⚠️  [Batch] Failed to marshal %s: %v", b.ID, err)
+		return
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		log.Printf("⚠️  [Batch] Failed to save %s: %v", b.ID, err)
+		return
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		log.Printf("⚠️  [Batch] Failed to save %s: %v", b.ID, err)
+	}
+}
+
+func (m *Manager) load() error {
+	if m.cfg.StorageDir == "" {
+		return nil
+	}
+	entries, err := os.ReadDir(m.cfg.StorageDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to read batch storage: %v", err)
+	}
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".json") {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(m.cfg.StorageDir, entry.Name()))
+		if err != nil {
+			log.Printf("⚠️  [Batch] Failed to read %s: %v", entry.Name(), err)
+			continue
+		}
+		var b Batch
+		if err := json.Unmarshal(data, &b); err != nil || b.ID == "" {
+			log.Printf("⚠️  [Batch] Skipping invalid batch file %s", entry.Name())
+			continue
+		}
+		if b.Status == StatusRunning || b.Status == StatusPending {
+			b.Status = StatusInterrupted
+			for _, item := range b.Items {
+				if item.Status == ItemRunning {
+					item.Status = ItemPending
+				}
+			}
+		}
+		m.batches[b.ID] = &b
+	}
+	if len(m.batches) > 0 {
+		log.Printf("📦 [Batch] Loaded %d batches from %s", len(m.batches), m.cfg.StorageDir)
+	}
+	return nil
+}
+
+func cloneBatch(b *Batch) *Batch {
+	clone := *b
+	clone.Items = make([]*Item, len(b.Items))
+	for i, item := range b.Items {
+		copied := *item
+		clone.Items[i] = &copied
+	}
+	return &clone
+}
+
+func newBatchID() string {
+	buf := make([]byte, 8)
+	if _, err := rand.Read(buf); err != nil {
+		return fmt.Sprintf("batch_%d", time.Now().UnixNano())
+	}
+	return "batch_" + hex.EncodeToString(buf)
+}
@@ -0,0 +1,118 @@
+package batch
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestParseInput_Formats(t *testing.T) {
+	jsonl := "{\"prompt\":\"a\"}\n\n{\"message\":\"b\",\"id\":\"x\"}\n"
+	input, err := ParseInput([]byte(jsonl))
+	if err != nil {
+		t.Fatalf("JSONL parse failed: %v", err)
+	}
+	if len(input.Items) != 2 || input.Items[1].Prompt != "b" || input.Items[1].ID != "x" {
+		t.Fatalf("unexpected JSONL items: %+v", input.Items)
+	}
+
+	input, err = ParseInput([]byte(`[{"prompt":"a"},{"prompt":"b","profile":"p"}]`))
+	if err != nil || len(input.Items) != 2 || input.Items[1].Profile != "p" {
+		t.Fatalf("unexpected array parse: %v %+v", err, input)
+	}
+
+	input, err = ParseInput([]byte(`{"system":"classify","template":"Text: {{text}} ({{n}})","rows":[{"id":1,"text":"hi","n":2}]}`))
+	if err != nil {
+		t.Fatalf("template parse failed: %v", err)
+	}
+	if input.System != "classify" || input.Items[0].Prompt != "Text: hi (2)" || input.Items[0].ID != "1" {
+		t.Fatalf("unexpected template items: %+v", input.Items[0])
+	}
+
+	if _, err := ParseInput([]byte(`{"template":"{{missing}}","rows":[{"text":"hi"}]}`)); err == nil {
+		t.Fatal("expected error for missing template field")
+	}
+	if _, err := ParseInput([]byte(`[{"prompt":""}]`)); err == nil {
+		t.Fatal("expected error for empty prompt")
+	}
+}
+
+func TestManager_PartialFailureAndExport(t *testing.T) {
+	executor := func(ctx context.Context, item Item, labels map[string]string) (Result, error) {
+		if item.Prompt == "bad" {
+			return Result{}, errors.New("boom")
+		}
+		return Result{Response: "echo " + item.Prompt + " " + item.System}, nil
+	}
+	m, err := NewManager(ManagerConfig{StorageDir: t.TempDir(), MaxConcurrency: 2}, executor)
+	if err != nil {
+		t.Fatalf("NewManager failed: %v", err)
+	}
+
+	summary, err := m.Submit(&Input{System: "sys", Items: []*Item{{Prompt: "a"}, {Prompt: "bad"}, {Prompt: "c"}}})
+	if err != nil {
+		t.Fatalf("Submit failed: %v", err)
+	}
+	m.Wait(summary.ID)
+
+	summary, _ = m.Summary(summary.ID)
+	if summary.Status != StatusCompleted || summary.Counts.Succeeded != 2 || summary.Counts.Failed != 1 {
+		t.Fatalf("unexpected summary: %+v", summary)
+	}
+
+	b, _ := m.Get(summary.ID)
+	var csvOut, jsonlOut bytes.Buffer
+	if err := WriteCSV(&csvOut, b); err != nil {
+		t.Fatalf("WriteCSV failed: %v", err)
+	}
+	if err := WriteJSONL(&jsonlOut, b); err != nil {
+		t.Fatalf("WriteJSONL failed: %v", err)
+	}
+	if !strings.Contains(csvOut.String(), "echo a sys") || !strings.Contains(jsonlOut.String(), `"error":"boom"`) {
+		t.Fatalf("unexpected export:\n%s\n%s", csvOut.String(), jsonlOut.String())
+	}
+}
+
+func TestManager_CancelAndResume(t *testing.T) {
+	dir := t.TempDir()
+	release := make(chan struct{})
+	started := make(chan struct{}, 10)
+	executor := func(ctx context.Context, item Item, labels map[string]string) (Result, error) {
+		started <- struct{}{}
+		select {
+		case <-release:
+			return Result{Response: "ok"}, nil
+		case <-ctx.Done():
+			return Result{}, ctx.Err()
+		}
+	}
+	m, _ := NewManager(ManagerConfig{StorageDir: dir, MaxConcurrency: 1}, executor)
+	summary, _ := m.Submit(&Input{Items: []*Item{{Prompt: "a"}, {Prompt: "b"}, {Prompt: "c"}}})
+
+	<-started
+	if err := m.Cancel(summary.ID); err != nil {
+		t.Fatalf("Cancel failed: %v", err)
+	}
+	m.Wait(summary.ID)
+	summary, _ = m.Summary(summary.ID)
+	if summary.Status != StatusCanceled || summary.Counts.Canceled != 3 {
+		t.Fatalf("unexpected summary after cancel: %+v", summary)
+	}
+
+	// 重新加载后可以继续执行
+	reloaded, err := NewManager(ManagerConfig{StorageDir: dir, MaxConcurrency: 2}, executor)
+	if err != nil {
+		t.Fatalf("reload failed: %v", err)
+	}
+	close(release)
+	if _, err := reloaded.Resume(summary.ID, false); err != nil {
+		t.Fatalf("Resume failed: %v", err)
+	}
+	reloaded.Wait(summary.ID)
+	summary, _ = reloaded.Summary(summary.ID)
+	if summary.Status != StatusCompleted || summary.Counts.Succeeded != 3 {
+		t.Fatalf("unexpected summary after resume: %+v", summary)
+	}
+}
@@ -0,0 +1,182 @@
+package batch
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Input 解析后的批任务输入，条目未指定的 system / profile / cli 使用公共值
+type Input struct {
+	System      string
+	Profile     string
+	CLI         string
+	Concurrency int
+	Labels      map[string]string // 提交方信息，由 handler 填充
+	Items       []*Item
+}
+
+// inputItem 单条输入，兼容 /chat 的 prompt / message 字段
+type inputItem struct {
+	ID      string `json:"id,omitempty"`
+	Prompt  string `json:"prompt"`
+	Message string `json:"message"`
+	System  string `json:"system,omitempty"`
+	Profile string `json:"profile,omitempty"`
+	CLI     string `json:"cli,omitempty"`
+}
+
+// inputEnvelope JSON 对象形式：items 列表，或 template + rows
+type inputEnvelope struct {
+	System      string                   `json:"system,omitempty"`
+	Profile     string                   `json:"profile,omitempty"`
+	CLI         string                   `json:"cli,omitempty"`
+	Concurrency int                      `json:"concurrency,omitempty"`
+	Items       []inputItem              `json:"items,omitempty"`
+	Template    string                   `json:"template,omitempty"`
+	Rows        []map[string]interface{} `json:"rows,omitempty"`
+}
+
+var templateFieldPattern = regexp.MustCompile(`\{\{\s*([A-Za-z0-9_.\-]+)\s*\}\}`)
+
+// ParseInput 解析批任务输入：JSONL、JSON 数组，或 {"items": [...]} / {"template": "...", "rows": [...]}
+func ParseInput(body []byte) (*Input, error) {
+	trimmed := bytes.TrimSpace(body)
+	if len(trimmed) == 0 {
+		return nil, fmt.Errorf("empty batch input")
+	}
+
+	var envelope inputEnvelope
+	switch {
+	case trimmed[0] == '[':
+		if err := json.Unmarshal(trimmed, &envelope.Items); err != nil {
+			return nil, fmt.Errorf("invalid JSON array: %v", err)
+		}
+	case trimmed[0] == '{' && isSingleJSONValue(trimmed):
+		if err := json.Unmarshal(trimmed, &envelope); err != nil {
+			return nil, fmt.Errorf("invalid JSON object: %v", err)
+		}
+		if len(envelope.Items) == 0 && envelope.Template == "" {
+			// 单行 JSONL
+			var item inputItem
+			if err := json.Unmarshal(trimmed, &item); err != nil {
+				return nil, fmt.Errorf("invalid JSON object: %v", err)
+			}
+			envelope.Items = []inputItem{item}
+		}
+	default:
+		items, err := parseJSONL(trimmed)
+		if err != nil {
+			return nil, err
+		}
+		envelope.Items = items
+	}
+
+	input := &Input{
+		System:      envelope.System,
+		Profile:     envelope.Profile,
+		CLI:         envelope.CLI,
+		Concurrency: envelope.Concurrency,
+	}
+
+	if envelope.Template != "" {
+		if len(envelope.Items) > 0 {
+			return nil, fmt.Errorf("template and items cannot be used together")
+		}
+		for i, row := range envelope.Rows {
+			prompt, err := renderTemplate(envelope.Template, row)
+			if err != nil {
+				return nil, fmt.Errorf("row %d: %v", i, err)
+			}
+			item := &Item{Index: i, Prompt: prompt}
+			if id, ok := row["id"]; ok {
+				item.ID = fmt.Sprint(id)
+			}
+			input.Items = append(input.Items, item)
+		}
+	} else {
+		for i, raw := range envelope.Items {
+			prompt := raw.Prompt
+			if prompt == "" {
+				prompt = raw.Message
+			}
+			if strings.TrimSpace(prompt) == "" {
+				return nil, fmt.Errorf("item %d: prompt is required", i)
+			}
+			input.Items = append(input.Items, &Item{
+				Index:   i,
+				ID:      raw.ID,
+				Prompt:  prompt,
+				System:  raw.System,
+				Profile: raw.Profile,
+				CLI:     raw.CLI,
+			})
+		}
+	}
+
+	if len(input.Items) == 0 {
+		return nil, fmt.Errorf("batch has no items")
+	}
+	return input, nil
+}
+
+func parseJSONL(body []byte) ([]inputItem, error) {
+	var items []inputItem
+	scanner := bufio.NewScanner(bytes.NewReader(body))
+	scanner.Buffer(make([]byte, 0, 64*1024), 16<<20)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var item inputItem
+		if err := json.Unmarshal(line, &item); err != nil {
+			return nil, fmt.Errorf("invalid JSONL at line %d: %v", lineNo, err)
+		}
+		items = append(items, item)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read JSONL: %v", err)
+	}
+	return items, nil
+}
+
+// isSingleJSONValue 判断输入是否是单个 JSON 值（区分 JSON 对象与多行 JSONL）
+func isSingleJSONValue(body []byte) bool {
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	var value json.RawMessage
+	if err := decoder.Decode(&value); err != nil {
+		return false
+	}
+	return !decoder.More()
+}
+
+// renderTemplate 用行数据替换模板中的 {{field}}，缺失字段报错
+func renderTemplate(template string, row map[string]interface{}) (string, error) {
+	var missing []string
+	rendered := templateFieldPattern.ReplaceAllStringFunc(template, func(match string) string {
+		field := templateFieldPattern.FindStringSubmatch(match)[1]
+		value, ok := row[field]
+		if !ok || value == nil {
+			missing = append(missing, field)
+			return match
+		}
+		if text, ok := value.(string); ok {
+			return text
+		}
+		data, err := json.Marshal(value)
+		if err != nil {
+			return fmt.Sprint(value)
+		}
+		return string(data)
+	})
+	if len(missing) > 0 {
+		return "", fmt.Errorf("missing template fields: %s", strings.Join(missing, ", "))
+	}
+	return rendered, nil
+}
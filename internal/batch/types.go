@@ -0,0 +1,133 @@
+package batch
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// Status 批任务状态
+type Status string
+
+const (
+	StatusPending     Status = "pending"
+	StatusRunning     Status = "running"
+	StatusCompleted   Status = "completed"   // 所有条目已结束（可能包含失败条目）
+	StatusCanceled    Status = "canceled"    // 被用户取消，可 resume
+	StatusInterrupted Status = "interrupted" // 服务重启时未完成，可 resume
+)
+
+// ItemStatus 单个条目状态
+type ItemStatus string
+
+const (
+	ItemPending   ItemStatus = "pending"
+	ItemRunning   ItemStatus = "running"
+	ItemSucceeded ItemStatus = "succeeded"
+	ItemFailed    ItemStatus = "failed"
+	ItemCanceled  ItemStatus = "canceled"
+)
+
+var (
+	ErrNotFound       = errors.New("batch not found")
+	ErrAlreadyRunning = errors.New("batch is already running")
+	ErrNotRunning     = errors.New("batch is not running")
+)
+
+// Item 批任务中的一条请求及其结果
+type Item struct {
+	Index      int        `json:"index"`
+	ID         string     `json:"id,omitempty"` // 调用方自定义 ID（可选）
+	Prompt     string     `json:"prompt"`
+	System     string     `json:"system,omitempty"`
+	Profile    string     `json:"profile,omitempty"`
+	CLI        string     `json:"cli,omitempty"`
+	Status     ItemStatus `json:"status"`
+	Response   string     `json:"response,omitempty"`
+	SessionID  string     `json:"session_id,omitempty"`
+	Error      string     `json:"error,omitempty"`
+	Attempts   int        `json:"attempts"`
+	StartedAt  *time.Time `json:"started_at,omitempty"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+	DurationMS int64      `json:"duration_ms,omitempty"`
+}
+
+// Batch 批任务
+type Batch struct {
+	ID          string            `json:"id"`
+	Status      Status            `json:"status"`
+	Concurrency int               `json:"concurrency"`
+	CreatedAt   time.Time         `json:"created_at"`
+	UpdatedAt   time.Time         `json:"updated_at"`
+	FinishedAt  *time.Time        `json:"finished_at,omitempty"`
+	Labels      map[string]string `json:"labels,omitempty"` // 提交方信息（如租户），执行时透传给 Executor
+	Items       []*Item           `json:"items"`
+}
+
+// Counts 各状态条目数
+type Counts struct {
+	Total     int `json:"total"`
+	Pending   int `json:"pending"`
+	Running   int `json:"running"`
+	Succeeded int `json:"succeeded"`
+	Failed    int `json:"failed"`
+	Canceled  int `json:"canceled"`
+}
+
+// Summary 批任务概要（不含条目结果）
+type Summary struct {
+	ID          string     `json:"id"`
+	Status      Status     `json:"status"`
+	Concurrency int        `json:"concurrency"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+	FinishedAt  *time.Time `json:"finished_at,omitempty"`
+	Counts      Counts     `json:"counts"`
+	Progress    float64    `json:"progress"`
+}
+
+// Result 单个条目的执行结果
+type Result struct {
+	Response  string
+	SessionID string
+}
+
+// Executor 执行单个条目（由 handler 通过 runCLI 实现）
+type Executor func(ctx context.Context, item Item, labels map[string]string) (Result, error)
+
+func (b *Batch) counts() Counts {
+	counts := Counts{Total: len(b.Items)}
+	for _, item := range b.Items {
+		switch item.Status {
+		case ItemPending:
+			counts.Pending++
+		case ItemRunning:
+			counts.Running++
+		case ItemSucceeded:
+			counts.Succeeded++
+		case ItemFailed:
+			counts.Failed++
+		case ItemCanceled:
+			counts.Canceled++
+		}
+	}
+	return counts
+}
+
+func (b *Batch) summary() Summary {
+	counts := b.counts()
+	var progress float64
+	if counts.Total > 0 {
+		progress = float64(counts.Succeeded+counts.Failed) / float64(counts.Total)
+	}
+	return Summary{
+		ID:          b.ID,
+		Status:      b.Status,
+		Concurrency: b.Concurrency,
+		CreatedAt:   b.CreatedAt,
+		UpdatedAt:   b.UpdatedAt,
+		FinishedAt:  b.FinishedAt,
+		Counts:      counts,
+		Progress:    progress,
+	}
+}
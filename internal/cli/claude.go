@@ -6,6 +6,7 @@ import (
 	"log"
 	"os/exec"
 	"strings"
+	"time"
 )
 
 // ClaudeCLI 实现 Claude Code CLI
@@ -29,14 +30,27 @@ func (c *ClaudeCLI) Name() string {
 }
 
 func (c *ClaudeCLI) Run(opts *RunOptions) (string, error) {
-	var args []string
+	// 准备 prompt（过长或以 "-" 开头时通过 stdin 传入）
+	prompt, err := preparePrompt("Claude", opts, promptSupport{Stdin: true})
+	if err != nil {
+		return "", err
+	}
 
 	// 构建基础参数
+	args := []string{"-p"}
+	if prompt.Arg != "" {
+		args = append(args, prompt.Arg)
+	}
+	if opts.CaptureSteps {
+		// stream-json 逐行输出 tool_use / tool_result 事件，用于记录工具调用步骤
+		args = append(args, "--output-format", "stream-json", "--verbose")
+	} else {
+		args = append(args, "--output-format", "json")
+	}
 	if opts.SessionID != "" {
-		args = []string{"-p", opts.Prompt, "--output-format", "json", "--resume", opts.SessionID}
+		args = append(args, "--resume", opts.SessionID)
 		log.Printf("🔄 [Claude] Resuming session: %s", opts.SessionID)
 	} else {
-		args = []string{"-p", opts.Prompt, "--output-format", "json"}
 		log.Printf("🆕 [Claude] Creating new session")
 	}
 
@@ -53,6 +67,25 @@ func (c *ClaudeCLI) Run(opts *RunOptions) (string, error) {
 		log.Printf("🔐 [Claude] Permission mode: %s", opts.PermissionMode)
 	}
 
+	// profile 的 mcp_servers 与网关注入的 server（如审批用的 permission-prompt server）
+	if len(opts.MCPServers) > 0 {
+		configPath, cleanup, err := writeMCPConfig(opts.MCPServers)
+		if err != nil {
+			return "", err
+		}
+		defer cleanup()
+		args = append(args, "--mcp-config", configPath)
+		log.Printf("🔌 [Claude] MCP servers: %v", mcpServerNames(opts.MCPServers))
+	}
+	if opts.StrictMCPConfig {
+		// 未配置任何 server 时同样生效：不加载任何 MCP server
+		args = append(args, "--strict-mcp-config")
+	}
+	if opts.PermissionPromptTool != "" {
+		args = append(args, "--permission-prompt-tool", opts.PermissionPromptTool)
+		log.Printf("🙋 [Claude] Permission prompt tool: %s", opts.PermissionPromptTool)
+	}
+
 	// 添加系统提示词
 	if opts.SystemPrompt != "" {
 		args = append(args, "--append-system-prompt", opts.SystemPrompt)
@@ -67,13 +100,23 @@ func (c *ClaudeCLI) Run(opts *RunOptions) (string, error) {
 		log.Printf("📚 [Claude] Using %d skill(s): %v", len(opts.Skills), opts.Skills)
 	}
 
-	log.Printf("⚙️  [Claude] Executing: claude %s", strings.Join(args, " "))
+	log.Printf("⚙️  [Claude] Executing: %s", redactCommand("claude", args, opts))
 
 	// 执行命令
 	cmd := exec.Command("claude", args...)
-	cmd.Env = buildEnv(opts.Env)
+	cmd.Env = buildEnv(opts.EnvPolicy, opts.Env)
+	cmd.Stdin = prompt.Stdin
+	finish, err := prepareCommand("Claude", cmd, opts)
+	if err != nil {
+		return "", err
+	}
+	defer finish()
+
+	if opts.CaptureSteps {
+		return c.runStream(cmd, opts.Prompt)
+	}
 
-	output, err := cmd.CombinedOutput()
+	output, err := combinedOutput(cmd)
 	log.Printf("📊 [Claude] Output length: %d bytes", len(output))
 
 	if err != nil {
@@ -109,9 +152,10 @@ func (c *ClaudeCLI) parseOutput(output string, prompt string) (string, error) {
 
 	// 构建统一输出格式
 	result := CLIOutput{
-		SessionID: claudeOut.SessionID,
-		User:      prompt,
-		Response:  claudeOut.Result,
+		SessionID:    claudeOut.SessionID,
+		User:         prompt,
+		Response:     claudeOut.Result,
+		TotalCostUSD: claudeOut.TotalCostUSD,
 	}
 
 	jsonBytes, err := json.Marshal(result)
@@ -121,3 +165,95 @@ func (c *ClaudeCLI) parseOutput(output string, prompt string) (string, error) {
 
 	return string(jsonBytes), nil
 }
+
+// claudeStreamEvent stream-json 输出中的一行
+type claudeStreamEvent struct {
+	Type    string `json:"type"`
+	Subtype string `json:"subtype"`
+	Message struct {
+		Content []struct {
+			Type      string          `json:"type"`
+			ID        string          `json:"id"`
+			Name      string          `json:"name"`
+			Input     json.RawMessage `json:"input"`
+			ToolUseID string          `json:"tool_use_id"`
+			Content   json.RawMessage `json:"content"`
+			IsError   bool            `json:"is_error"`
+		} `json:"content"`
+	} `json:"message"`
+	ClaudeOutput
+}
+
+// runStream 以 stream-json 执行并记录工具调用步骤
+func (c *ClaudeCLI) runStream(cmd *exec.Cmd, prompt string) (string, error) {
+	steps := newStepTracker()
+	var final *ClaudeOutput
+	stdout, stderr, err := runLines(cmd, func(line []byte, at time.Time) {
+		var event claudeStreamEvent
+		if json.Unmarshal(line, &event) != nil {
+			return
+		}
+		switch event.Type {
+		case "assistant":
+			for _, block := range event.Message.Content {
+				if block.Type == "tool_use" {
+					steps.start(block.ID, block.Name, block.Input, at)
+				}
+			}
+		case "user":
+			for _, block := range event.Message.Content {
+				if block.Type == "tool_result" {
+					steps.finish(block.ToolUseID, "", nil, toolResultText(block.Content), block.IsError, at)
+				}
+			}
+		case "result":
+			out := event.ClaudeOutput
+			final = &out
+		}
+	})
+	log.Printf("📊 [Claude] Output length: %d bytes", len(stdout))
+
+	if err != nil {
+		log.Printf("❌ [Claude] Execution error: %v", err)
+		return "", fmt.Errorf("claude CLI execution failed: %v, output: %s", err, stdout+stderr)
+	}
+	if final == nil {
+		return "", fmt.Errorf("no result event in claude output: %s", truncate(stdout+stderr, 2000))
+	}
+
+	log.Printf("✨ [Claude] Result preview: %s (%d step(s))", truncate(final.Result, 100), len(steps.result()))
+	result := CLIOutput{
+		SessionID:    final.SessionID,
+		User:         prompt,
+		Response:     final.Result,
+		TotalCostUSD: final.TotalCostUSD,
+		Steps:        steps.result(),
+	}
+	jsonBytes, err := json.Marshal(result)
+	if err != nil {
+		return final.Result, nil
+	}
+	return string(jsonBytes), nil
+}
+
+// toolResultText 将 tool_result 的 content（字符串或 [{type:text,text}]）展开为文本
+func toolResultText(raw json.RawMessage) string {
+	var text string
+	if json.Unmarshal(raw, &text) == nil {
+		return text
+	}
+	var blocks []struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	}
+	if json.Unmarshal(raw, &blocks) == nil {
+		parts := make([]string, 0, len(blocks))
+		for _, block := range blocks {
+			if block.Text != "" {
+				parts = append(parts, block.Text)
+			}
+		}
+		return strings.Join(parts, "\n")
+	}
+	return string(raw)
+}
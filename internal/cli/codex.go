@@ -1,14 +1,27 @@
 package cli
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"log"
 	"os/exec"
 	"strings"
+	"time"
 )
 
-// CodexCLI 实现 OpenAI Codex CLI
+// CodexOptions Codex 专用执行选项（profile.codex）
+type CodexOptions struct {
+	Sandbox          string   `json:"sandbox,omitempty"`             // read-only / workspace-write / danger-full-access，默认 danger-full-access
+	Profile          string   `json:"profile,omitempty"`             // ~/.codex/config.toml 中的 profile（--profile）
+	Config           []string `json:"config,omitempty"`              // 额外的 -c key=value 覆盖
+	SkipGitRepoCheck bool     `json:"skip_git_repo_check,omitempty"` // 允许在非 git 目录运行
+}
+
+// defaultCodexSandbox 未配置时保持原有行为
+const defaultCodexSandbox = "danger-full-access"
+
+// CodexCLI 实现 OpenAI Codex CLI（基于 codex exec --json 事件流）
 type CodexCLI struct{}
 
 func NewCodexCLI() *CodexCLI {
@@ -27,99 +40,290 @@ func (c *CodexCLI) Run(opts *RunOptions) (string, error) {
 	if opts.PermissionMode != "" {
 		log.Printf("⚠️  [Codex] Does not support --permission-mode parameter")
 	}
+	warnUnsupportedMCPConfig("Codex", opts)
 
-	var args []string
-	var useStdin bool
+	// 准备 prompt（过长或以 "-" 开头时通过 stdin 传入，参数位置使用 "-"）
+	prompt, err := preparePrompt("Codex", opts, promptSupport{Stdin: true})
+	if err != nil {
+		return "", err
+	}
+	promptArg := prompt.Arg
+	if prompt.Delivery == PromptDeliveryStdin {
+		promptArg = "-"
+	}
 
-	if opts.SessionID != "" {
-		// 继续指定会话
-		args = []string{"exec", "resume", opts.SessionID, opts.Prompt}
-		log.Printf("🔄 [Codex] Resuming session: %s", opts.SessionID)
-	} else if opts.NewSession {
-		// 创建新会话
-		model := opts.Model
-		if model == "" {
-			model = "gpt-5.1"
+	args := c.buildArgs(opts, promptArg)
+	log.Printf("⚙️  [Codex] Executing: %s", redactCommand("codex", args, opts))
+
+	cmd := exec.Command("codex", args...)
+	cmd.Env = buildEnv(opts.EnvPolicy, opts.Env)
+	cmd.Stdin = prompt.Stdin
+	finish, err := prepareCommand("Codex", cmd, opts)
+	if err != nil {
+		return "", err
+	}
+	defer finish()
+
+	events := newCodexEvents()
+	stdout, stderr, runErr := runLines(cmd, events.parseLine)
+	log.Printf("📊 [Codex] Output length: %d bytes (stderr %d bytes)", len(stdout), len(stderr))
+
+	if runErr != nil || events.Failure != "" {
+		detail := events.Failure
+		if detail == "" {
+			detail = strings.TrimSpace(stderr)
 		}
-		args = []string{"exec", "--model", model, "--sandbox", "danger-full-access", opts.Prompt}
-		log.Printf("🆕 [Codex] Creating new session with model: %s", model)
-	} else {
-		// 继续最近的会话（通过 stdin 传入 prompt）
-		args = []string{"exec", "resume", "--last"}
-		useStdin = true
-		log.Printf("🔄 [Codex] Resuming last session")
+		if runErr == nil {
+			runErr = fmt.Errorf("turn failed")
+		}
+		log.Printf("❌ [Codex] Execution error: %v", runErr)
+		return "", fmt.Errorf("codex CLI execution failed: %v, output: %s", runErr, truncate(detail, 2000))
+	}
+	if events.ThreadID == "" && len(events.Messages) == 0 {
+		return "", fmt.Errorf("codex CLI produced no JSON events, output: %s", truncate(strings.TrimSpace(stdout+"\n"+stderr), 2000))
 	}
 
-	log.Printf("⚙️  [Codex] Executing: codex %s", strings.Join(args, " "))
+	return c.formatOutput(opts, events)
+}
 
-	cmd := exec.Command("codex", args...)
-	cmd.Env = buildEnv(opts.Env)
+// buildArgs 构建 codex exec 参数；未指定 session_id 时总是新建会话（不使用 --last，避免接续其他调用方的会话）
+func (c *CodexCLI) buildArgs(opts *RunOptions, promptArg string) []string {
+	codexOpts := opts.Codex
+	if codexOpts == nil {
+		codexOpts = &CodexOptions{}
+	}
 
-	if useStdin {
-		cmd.Stdin = strings.NewReader(opts.Prompt)
-		log.Printf("📝 [Codex] Sending prompt via stdin")
+	args := []string{"exec", "--json"}
+	if opts.Model != "" {
+		args = append(args, "--model", opts.Model)
+	}
+	sandbox := codexOpts.Sandbox
+	if sandbox == "" {
+		sandbox = defaultCodexSandbox
+	}
+	args = append(args, "--sandbox", sandbox)
+	if codexOpts.Profile != "" {
+		args = append(args, "--profile", codexOpts.Profile)
+	}
+	if opts.WorkDir != "" {
+		args = append(args, "--cd", opts.WorkDir)
+	}
+	if codexOpts.SkipGitRepoCheck {
+		args = append(args, "--skip-git-repo-check")
+	}
+	for _, override := range codexOpts.Config {
+		args = append(args, "-c", override)
+	}
+
+	if opts.SessionID != "" {
+		log.Printf("🔄 [Codex] Resuming session: %s", opts.SessionID)
+		return append(args, "resume", opts.SessionID, promptArg)
+	}
+	if !opts.NewSession {
+		log.Printf("⚠️  [Codex] No session_id provided, starting a new session")
 	}
+	log.Printf("🆕 [Codex] Creating new session (model=%s, sandbox=%s)", opts.Model, sandbox)
+	return append(args, promptArg)
+}
 
-	output, err := cmd.CombinedOutput()
-	log.Printf("📊 [Codex] Output length: %d bytes", len(output))
+func (c *CodexCLI) formatOutput(opts *RunOptions, events *codexEvents) (string, error) {
+	steps := events.steps.result()
+	log.Printf("✅ [Codex] Thread %s: %d message(s), %d step(s)", events.ThreadID, len(events.Messages), len(steps))
+	result := CLIOutput{
+		SessionID: events.ThreadID,
+		User:      opts.Prompt,
+		Response:  strings.Join(events.Messages, "\n\n"),
+		Usage:     events.Usage,
+	}
+	if opts.CaptureSteps {
+		result.Steps = steps
+	}
 
+	jsonBytes, err := json.Marshal(result)
 	if err != nil {
-		log.Printf("❌ [Codex] Execution error: %v", err)
-		return "", fmt.Errorf("codex CLI execution failed: %v, output: %s", err, string(output))
+		log.Printf("❌ [Codex] Failed to marshal output: %v", err)
+		return "", fmt.Errorf("failed to marshal codex output: %v", err)
 	}
+	return string(jsonBytes), nil
+}
+
+// codexEvents codex exec --json 事件流的解析结果
+type codexEvents struct {
+	ThreadID string
+	Messages []string // agent_message 文本（按出现顺序）
+	Usage    *TokenUsage
+	Failure  string // turn.failed / error 事件的信息
 
-	return c.parseOutput(string(output))
+	steps *stepTracker // 命令执行 / MCP 工具调用 / 文件修改 / 网页搜索
 }
 
-func (c *CodexCLI) parseOutput(output string) (string, error) {
-	lines := strings.Split(output, "\n")
-	var sessionID, userPrompt string
-	var lastCodexIndex int = -1
+func newCodexEvents() *codexEvents {
+	return &codexEvents{steps: newStepTracker()}
+}
 
-	// 第一遍：找到所有关键位置
-	for i, line := range lines {
-		trimmed := strings.TrimSpace(line)
+type codexEvent struct {
+	Type     string          `json:"type"`
+	ThreadID string          `json:"thread_id"`
+	Item     *codexEventItem `json:"item"`
+	Usage    *struct {
+		InputTokens       int `json:"input_tokens"`
+		CachedInputTokens int `json:"cached_input_tokens"`
+		OutputTokens      int `json:"output_tokens"`
+	} `json:"usage"`
+	Error *struct {
+		Message string `json:"message"`
+	} `json:"error"`
+	Message string `json:"message"`
 
-		if strings.HasPrefix(trimmed, "session id:") {
-			sessionID = strings.TrimSpace(strings.TrimPrefix(trimmed, "session id:"))
-		}
+	// 旧版本（0.2x）事件格式：{"id":"0","msg":{"type":...}}
+	Msg *struct {
+		Type             string `json:"type"`
+		SessionID        string `json:"session_id"`
+		Message          string `json:"message"`
+		LastAgentMessage string `json:"last_agent_message"`
+	} `json:"msg"`
+}
 
-		if trimmed == "user" && i+1 < len(lines) {
-			userPrompt = strings.TrimSpace(lines[i+1])
-		}
+type codexEventItem struct {
+	ID               string          `json:"id"`
+	Type             string          `json:"type"`
+	ItemType         string          `json:"item_type"` // 早期版本字段名
+	Text             string          `json:"text"`
+	Command          string          `json:"command"`
+	AggregatedOutput string          `json:"aggregated_output"`
+	ExitCode         *int            `json:"exit_code"`
+	Status           string          `json:"status"`
+	Server           string          `json:"server"`
+	Tool             string          `json:"tool"`
+	Arguments        json.RawMessage `json:"arguments"`
+	Result           json.RawMessage `json:"result"`
+	Error            json.RawMessage `json:"error"`
+	Changes          json.RawMessage `json:"changes"`
+	Query            string          `json:"query"`
+	Message          string          `json:"message"`
+}
 
-		if trimmed == "codex" {
-			lastCodexIndex = i
-		}
+// parseCodexEvents 解析完整的 JSONL 事件流，忽略无法识别的行
+func parseCodexEvents(output []byte) *codexEvents {
+	events := newCodexEvents()
+	now := time.Now()
+	for _, line := range bytes.Split(output, []byte("\n")) {
+		events.parseLine(line, now)
+	}
+	return events
+}
+
+func (e *codexEvents) parseLine(line []byte, at time.Time) {
+	line = bytes.TrimSpace(line)
+	if len(line) == 0 || line[0] != '{' {
+		return
+	}
+	var event codexEvent
+	if err := json.Unmarshal(line, &event); err != nil {
+		return
 	}
+	e.apply(&event, at)
+}
 
-	// 第二遍：从最后一个 "codex" 标记开始收集答案
-	var answerLines []string
-	if lastCodexIndex != -1 {
-		for j := lastCodexIndex + 1; j < len(lines); j++ {
-			trimmed := strings.TrimSpace(lines[j])
-			if strings.HasPrefix(trimmed, "tokens used") {
-				break
+func (e *codexEvents) apply(event *codexEvent, at time.Time) {
+	if event.Msg != nil {
+		e.applyLegacy(event)
+		return
+	}
+	switch event.Type {
+	case "thread.started":
+		e.ThreadID = event.ThreadID
+	case "item.started":
+		if event.Item != nil {
+			if tool, input := codexItemTool(event.Item); tool != "" {
+				e.steps.start(event.Item.ID, tool, input, at)
 			}
-			if trimmed != "" {
-				answerLines = append(answerLines, trimmed)
+		}
+	case "item.completed":
+		if event.Item != nil {
+			e.applyItem(event.Item, at)
+		}
+	case "turn.completed":
+		if event.Usage != nil {
+			e.Usage = &TokenUsage{
+				InputTokens:       event.Usage.InputTokens,
+				CachedInputTokens: event.Usage.CachedInputTokens,
+				OutputTokens:      event.Usage.OutputTokens,
 			}
 		}
+	case "turn.failed":
+		if event.Error != nil {
+			e.Failure = event.Error.Message
+		} else {
+			e.Failure = "turn failed"
+		}
+	case "error":
+		e.Failure = event.Message
 	}
+}
 
-	answer := strings.Join(answerLines, "\n")
+func codexItemType(item *codexEventItem) string {
+	if item.Type != "" {
+		return item.Type
+	}
+	return item.ItemType
+}
 
-	result := CLIOutput{
-		SessionID: sessionID,
-		User:      userPrompt,
-		Response:  answer,
+// codexItemTool 返回工具类 item 的名称与输入；非工具类返回空
+func codexItemTool(item *codexEventItem) (string, json.RawMessage) {
+	switch codexItemType(item) {
+	case "command_execution":
+		return "shell", jsonValue(map[string]string{"command": item.Command})
+	case "mcp_tool_call":
+		return item.Server + "." + item.Tool, item.Arguments
+	case "file_change":
+		return "apply_patch", item.Changes
+	case "web_search":
+		return "web_search", jsonValue(map[string]string{"query": item.Query})
 	}
+	return "", nil
+}
 
-	jsonBytes, err := json.Marshal(result)
-	if err != nil {
-		log.Printf("❌ [Codex] Failed to marshal output: %v", err)
-		return fmt.Sprintf("session id: %s\nuser: %s\ncodex: %s", sessionID, userPrompt, answer), nil
+func (e *codexEvents) applyItem(item *codexEventItem, at time.Time) {
+	switch codexItemType(item) {
+	case "agent_message", "assistant_message":
+		if text := strings.TrimSpace(item.Text); text != "" {
+			e.Messages = append(e.Messages, text)
+		}
+		return
+	case "error":
+		log.Printf("⚠️  [Codex] Item error: %s", item.Message)
+		return
 	}
 
-	return string(jsonBytes), nil
+	tool, input := codexItemTool(item)
+	if tool == "" {
+		return
+	}
+	output := item.AggregatedOutput
+	if len(item.Result) > 0 && string(item.Result) != "null" {
+		output = string(item.Result)
+	}
+	if len(item.Error) > 0 && string(item.Error) != "null" {
+		output = string(item.Error)
+	}
+	failed := item.Status == "failed" || (item.ExitCode != nil && *item.ExitCode != 0)
+	e.steps.finish(item.ID, tool, input, output, failed, at)
+}
+
+func (e *codexEvents) applyLegacy(event *codexEvent) {
+	switch event.Msg.Type {
+	case "session_configured":
+		e.ThreadID = event.Msg.SessionID
+	case "agent_message":
+		if text := strings.TrimSpace(event.Msg.Message); text != "" {
+			e.Messages = append(e.Messages, text)
+		}
+	case "task_complete":
+		if len(e.Messages) == 0 && event.Msg.LastAgentMessage != "" {
+			e.Messages = append(e.Messages, event.Msg.LastAgentMessage)
+		}
+	case "error":
+		e.Failure = event.Msg.Message
+	}
 }
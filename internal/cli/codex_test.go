@@ -0,0 +1,76 @@
+package cli
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestParseCodexEvents(t *testing.T) {
+	stream := strings.Join([]string{
+		`Reading prompt from stdin...`,
+		`{"type":"thread.started","thread_id":"0199a213-81c0-7800-8aa1-bbab2a035a53"}`,
+		`{"type":"turn.started"}`,
+		`{"type":"item.completed","item":{"id":"item_0","type":"reasoning","text":"**Inspecting files**"}}`,
+		`{"type":"item.started","item":{"id":"item_1","type":"command_execution","command":"bash -lc ls","aggregated_output":"","status":"in_progress"}}`,
+		`{"type":"item.completed","item":{"id":"item_1","type":"command_execution","command":"bash -lc ls","aggregated_output":"go.mod\n","exit_code":0,"status":"completed"}}`,
+		`{"type":"item.completed","item":{"id":"item_2","type":"agent_message","text":"The repo contains go.mod."}}`,
+		`{"type":"turn.completed","usage":{"input_tokens":24763,"cached_input_tokens":24448,"output_tokens":122}}`,
+	}, "\n")
+
+	events := parseCodexEvents([]byte(stream))
+	if events.ThreadID != "0199a213-81c0-7800-8aa1-bbab2a035a53" {
+		t.Errorf("unexpected thread id: %q", events.ThreadID)
+	}
+	if len(events.Messages) != 1 || events.Messages[0] != "The repo contains go.mod." {
+		t.Errorf("unexpected messages: %v", events.Messages)
+	}
+	steps := events.steps.result()
+	if len(steps) != 1 || steps[0].Tool != "shell" || steps[0].Output != "go.mod" || steps[0].Status != StepStatusOK {
+		t.Errorf("unexpected steps: %+v", steps)
+	}
+	if events.Usage == nil || events.Usage.InputTokens != 24763 || events.Usage.OutputTokens != 122 {
+		t.Errorf("unexpected usage: %+v", events.Usage)
+	}
+
+	output, err := NewCodexCLI().formatOutput(&RunOptions{Prompt: "ls"}, events)
+	if err != nil {
+		t.Fatalf("format: %v", err)
+	}
+	var parsed CLIOutput
+	if err := json.Unmarshal([]byte(output), &parsed); err != nil || parsed.SessionID != events.ThreadID || parsed.Usage == nil || parsed.Steps != nil {
+		t.Errorf("unexpected output: %s (%v)", output, err)
+	}
+	output, _ = NewCodexCLI().formatOutput(&RunOptions{Prompt: "ls", CaptureSteps: true}, events)
+	if err := json.Unmarshal([]byte(output), &parsed); err != nil || len(parsed.Steps) != 1 {
+		t.Errorf("steps should be included when requested: %s", output)
+	}
+
+	failed := parseCodexEvents([]byte(`{"type":"thread.started","thread_id":"t"}` + "\n" + `{"type":"turn.failed","error":{"message":"quota exceeded"}}`))
+	if failed.Failure != "quota exceeded" {
+		t.Errorf("expected failure to be captured, got %q", failed.Failure)
+	}
+}
+
+func TestCodexBuildArgs(t *testing.T) {
+	c := NewCodexCLI()
+
+	args := strings.Join(c.buildArgs(&RunOptions{Prompt: "hi"}, "hi"), " ")
+	if strings.Contains(args, "--last") || strings.Contains(args, "resume") {
+		t.Errorf("missing session id must start a new session, got %q", args)
+	}
+	if args != "exec --json --sandbox danger-full-access hi" {
+		t.Errorf("unexpected default args: %q", args)
+	}
+
+	args = strings.Join(c.buildArgs(&RunOptions{
+		SessionID: "abc",
+		Model:     "gpt-5-codex",
+		WorkDir:   "/work",
+		Codex:     &CodexOptions{Sandbox: "workspace-write", Profile: "ci", Config: []string{"model_reasoning_effort=high"}},
+	}, "-"), " ")
+	want := "exec --json --model gpt-5-codex --sandbox workspace-write --profile ci --cd /work -c model_reasoning_effort=high resume abc -"
+	if args != want {
+		t.Errorf("args = %q, want %q", args, want)
+	}
+}
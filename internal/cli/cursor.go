@@ -6,6 +6,7 @@ import (
 	"log"
 	"os/exec"
 	"strings"
+	"time"
 )
 
 // CursorCLI 实现 Cursor Agent CLI
@@ -31,10 +32,15 @@ func (c *CursorCLI) Name() string {
 
 func (c *CursorCLI) Run(opts *RunOptions) (string, error) {
 	var args []string
+	warnUnsupportedMCPConfig("Cursor", opts)
 
 	// 基础参数：使用 print 模式（非交互）、强制模式、浏览器支持、JSON 输出
 	// --print 参数确保在非交互环境（如 HTTP 请求、crontab）中正常运行
 	args = []string{"--print", "--force", "--browser", "--output-format", "json"}
+	if opts.CaptureSteps {
+		// stream-json 逐行输出 tool_call 事件，用于记录工具调用步骤
+		args[len(args)-1] = "stream-json"
+	}
 
 	// 检测是否为 HTTP 请求（非交互环境）
 	// HTTP_REQUEST 标志由 handler 设置，用于区分 HTTP 请求和 CLI 直接调用
@@ -80,25 +86,31 @@ func (c *CursorCLI) Run(opts *RunOptions) (string, error) {
 		log.Printf("🔐 [Cursor] Force mode enabled")
 	}
 
-	// 添加 prompt
-	args = append(args, opts.Prompt)
+	// 添加 prompt（cursor-agent 不读取 stdin，无法作为参数传递的 prompt 直接报错）
+	prompt, err := preparePrompt("Cursor", opts, promptSupport{})
+	if err != nil {
+		return "", err
+	}
+	args = append(args, prompt.Arg)
 
-	log.Printf("⚙️  [Cursor] Executing: cursor-agent %s", strings.Join(args, " "))
+	log.Printf("⚙️  [Cursor] Executing: %s", redactCommand("cursor-agent", args, opts))
 
 	cmd := exec.Command("cursor-agent", args...)
 	
 	// 构建环境变量，添加禁用 TTY 的配置
-	env := opts.Env
-	if env == nil {
-		env = make(map[string]string)
+	// 禁用 Ink 的 raw mode，避免在非交互环境中出错（不修改 opts.Env，它可能是 profile 配置本身）
+	cmd.Env = mergeEnv(buildEnv(opts.EnvPolicy, opts.Env), "CI=true", "TERM=dumb")
+	finish, err := prepareCommand("Cursor", cmd, opts)
+	if err != nil {
+		return "", err
+	}
+	defer finish()
+
+	if opts.CaptureSteps {
+		return c.runStream(cmd, opts.Prompt)
 	}
-	// 禁用 Ink 的 raw mode，避免在非交互环境中出错
-	env["CI"] = "true"
-	env["TERM"] = "dumb"
-	
-	cmd.Env = buildEnv(env)
 
-	output, err := cmd.CombinedOutput()
+	output, err := combinedOutput(cmd)
 	log.Printf("📊 [Cursor] Output length: %d bytes", len(output))
 
 	if err != nil {
@@ -106,10 +118,71 @@ func (c *CursorCLI) Run(opts *RunOptions) (string, error) {
 		return "", fmt.Errorf("cursor-agent CLI execution failed: %v, output: %s", err, string(output))
 	}
 
-	return c.parseOutput(string(output), opts.Prompt)
+	return c.parseOutput(string(output), opts.Prompt, nil)
+}
+
+// cursorToolCallEvent stream-json 中的 tool_call 事件
+type cursorToolCallEvent struct {
+	Type     string                     `json:"type"`
+	Subtype  string                     `json:"subtype"`
+	CallID   string                     `json:"call_id"`
+	ToolCall map[string]json.RawMessage `json:"tool_call"`
+}
+
+// runStream 以 stream-json 执行并记录工具调用步骤
+func (c *CursorCLI) runStream(cmd *exec.Cmd, prompt string) (string, error) {
+	steps := newStepTracker()
+	stdout, stderr, err := runLines(cmd, func(line []byte, at time.Time) {
+		var event cursorToolCallEvent
+		if json.Unmarshal(line, &event) != nil || event.Type != "tool_call" {
+			return
+		}
+		name, args, output, isError := parseCursorToolCall(event.ToolCall)
+		switch event.Subtype {
+		case "started":
+			steps.start(event.CallID, name, args, at)
+		case "completed":
+			steps.finish(event.CallID, name, args, output, isError, at)
+		}
+	})
+	log.Printf("📊 [Cursor] Output length: %d bytes", len(stdout))
+
+	if err != nil {
+		log.Printf("❌ [Cursor] Execution error: %v", err)
+		return "", fmt.Errorf("cursor-agent CLI execution failed: %v, output: %s", err, stdout+stderr)
+	}
+	return c.parseOutput(stdout, prompt, steps.result())
+}
+
+// parseCursorToolCall 解析 {"<kind>ToolCall": {"args": ..., "result": {"success"|"error": ...}}} 形式的工具调用
+func parseCursorToolCall(call map[string]json.RawMessage) (name string, args json.RawMessage, output string, isError bool) {
+	for key, raw := range call {
+		var body struct {
+			Args      json.RawMessage            `json:"args"`
+			Name      string                     `json:"name"`
+			Arguments json.RawMessage            `json:"arguments"`
+			Result    map[string]json.RawMessage `json:"result"`
+		}
+		if json.Unmarshal(raw, &body) != nil {
+			continue
+		}
+		name = strings.TrimSuffix(key, "ToolCall")
+		args = body.Args
+		if body.Name != "" {
+			// function 类型：{"function": {"name": ..., "arguments": ...}}
+			name = body.Name
+			args = body.Arguments
+		}
+		for kind, value := range body.Result {
+			output = string(value)
+			isError = kind != "success"
+		}
+		return name, args, output, isError
+	}
+	return "", nil, "", false
 }
 
-func (c *CursorCLI) parseOutput(output string, prompt string) (string, error) {
+func (c *CursorCLI) parseOutput(output string, prompt string, steps []Step) (string, error) {
 	// Cursor Agent 输出单行 JSON（type=result 时包含最终结果）
 	lines := strings.Split(output, "\n")
 
@@ -149,6 +222,7 @@ func (c *CursorCLI) parseOutput(output string, prompt string) (string, error) {
 		SessionID: sessionID,
 		User:      prompt,
 		Response:  lastResult,
+		Steps:     steps,
 	}
 
 	jsonBytes, err := json.Marshal(result)
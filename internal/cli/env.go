@@ -0,0 +1,155 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// 环境变量继承方式
+const (
+	EnvInheritAll       = "all"       // 继承网关的环境变量（网关自身的 secret 除外）
+	EnvInheritNone      = "none"      // 不继承，只使用 profile env
+	EnvInheritAllowlist = "allowlist" // 只继承 allow 中列出的变量（默认）
+)
+
+// gatewaySecretEnv 网关自身使用的 secret 变量（ADMIN_UI_TOKEN、Redis 密码等），all 模式下同样不继承
+var (
+	gatewaySecretEnvMu sync.RWMutex
+	gatewaySecretEnv   = map[string]bool{}
+)
+
+// SetGatewaySecretEnv 设置网关自身使用的 secret 变量名（加载配置时调用）
+func SetGatewaySecretEnv(keys []string) {
+	secrets := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		if key != "" {
+			secrets[key] = true
+		}
+	}
+	gatewaySecretEnvMu.Lock()
+	gatewaySecretEnv = secrets
+	gatewaySecretEnvMu.Unlock()
+}
+
+func isGatewaySecretEnv(key string) bool {
+	gatewaySecretEnvMu.RLock()
+	defer gatewaySecretEnvMu.RUnlock()
+	return gatewaySecretEnv[key]
+}
+
+// DefaultEnvAllowlist allowlist 模式未配置 allow 时继承的变量（不含任何凭据；代理变量保证 CLI 在代理环境下仍能访问模型 API）
+var DefaultEnvAllowlist = []string{
+	"PATH", "HOME", "USER", "LOGNAME", "SHELL", "LANG", "LANGUAGE", "LC_*", "TZ", "TMPDIR", "TERM",
+	"HTTP_PROXY", "HTTPS_PROXY", "NO_PROXY", "http_proxy", "https_proxy", "no_proxy",
+}
+
+// EnvPolicy CLI 子进程从网关继承环境变量的策略；profile env 总是覆盖继承的同名变量
+type EnvPolicy struct {
+	Inherit string   `json:"inherit,omitempty"` // allowlist（默认）/ none / all
+	Allow   []string `json:"allow,omitempty"`   // allowlist 模式下继承的变量名，支持 "LC_*" 前缀匹配，为空时使用 DefaultEnvAllowlist
+}
+
+// Validate 校验策略
+func (p *EnvPolicy) Validate() error {
+	if p == nil {
+		return nil
+	}
+	switch p.Inherit {
+	case "", EnvInheritAll, EnvInheritNone, EnvInheritAllowlist:
+	default:
+		return fmt.Errorf("invalid env_policy.inherit %q (expected all, none or allowlist)", p.Inherit)
+	}
+	for _, name := range p.Allow {
+		if name == "" || strings.Contains(strings.TrimSuffix(name, "*"), "*") || strings.Contains(name, "=") {
+			return fmt.Errorf("invalid env_policy.allow entry %q", name)
+		}
+	}
+	return nil
+}
+
+// allows 变量是否在 allowlist 中
+func (p *EnvPolicy) allows(key string) bool {
+	allow := p.Allow
+	if len(allow) == 0 {
+		allow = DefaultEnvAllowlist
+	}
+	for _, name := range allow {
+		if prefix, ok := strings.CutSuffix(name, "*"); ok {
+			if strings.HasPrefix(key, prefix) {
+				return true
+			}
+		} else if key == name {
+			return true
+		}
+	}
+	return false
+}
+
+// inheritedEnv 按策略筛选网关自身的环境变量；未配置策略时按默认 allowlist 处理
+func (p *EnvPolicy) inheritedEnv() []string {
+	if p == nil {
+		p = &EnvPolicy{}
+	}
+	mode := p.Inherit
+	if mode == "" {
+		mode = EnvInheritAllowlist
+	}
+	if mode == EnvInheritNone {
+		return nil
+	}
+	var env []string
+	for _, entry := range os.Environ() {
+		key, _, ok := strings.Cut(entry, "=")
+		if !ok {
+			continue
+		}
+		if mode == EnvInheritAllowlist && !p.allows(key) {
+			continue
+		}
+		if mode == EnvInheritAll && isGatewaySecretEnv(key) {
+			continue
+		}
+		env = append(env, entry)
+	}
+	return env
+}
+
+// buildEnv 构建命令执行的环境变量：按策略继承网关环境变量，再用 envMap 覆盖同名变量
+func buildEnv(policy *EnvPolicy, envMap map[string]string) []string {
+	keys := make([]string, 0, len(envMap))
+	for key := range envMap {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	overrides := make([]string, 0, len(keys))
+	for _, key := range keys {
+		overrides = append(overrides, key+"="+envMap[key])
+	}
+	return mergeEnv(policy.inheritedEnv(), overrides...)
+}
+
+// BuildEnv 按同一策略为网关启动的其它子进程（如 MCP 连通性测试）构造环境变量
+func BuildEnv(policy *EnvPolicy, envMap map[string]string) []string {
+	return buildEnv(policy, envMap)
+}
+
+// mergeEnv 追加 KEY=VALUE，已存在的同名变量被替换而不是重复出现
+func mergeEnv(env []string, overrides ...string) []string {
+	merged := make([]string, 0, len(env)+len(overrides))
+	index := make(map[string]int, len(env)+len(overrides))
+	for _, entries := range [][]string{env, overrides} {
+		for _, entry := range entries {
+			key, _, _ := strings.Cut(entry, "=")
+			if i, ok := index[key]; ok {
+				merged[i] = entry
+				continue
+			}
+			index[key] = len(merged)
+			merged = append(merged, entry)
+		}
+	}
+	return merged
+}
@@ -0,0 +1,68 @@
+package cli
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestBuildEnv_PolicyAndOverrides(t *testing.T) {
+	t.Setenv("ADMIN_UI_TOKEN", "gateway-secret")
+	t.Setenv("LC_ALL", "C.UTF-8")
+	t.Setenv("MODEL_REGION", "us")
+	t.Setenv("HTTPS_PROXY", "http://proxy:3128")
+
+	count := func(env []string, key string) int {
+		n := 0
+		for _, entry := range env {
+			if strings.HasPrefix(entry, key+"=") {
+				n++
+			}
+		}
+		return n
+	}
+
+	SetGatewaySecretEnv([]string{"ADMIN_UI_TOKEN"})
+	defer SetGatewaySecretEnv(nil)
+	all := buildEnv(&EnvPolicy{Inherit: EnvInheritAll}, map[string]string{"MODEL_REGION": "eu"})
+	if count(all, "MODEL_REGION") != 1 || envValue(all, "MODEL_REGION") != "eu" || envValue(all, "PATH") == "" {
+		t.Errorf("profile env should replace inherited values without duplicates: %v", all)
+	}
+	if count(all, "ADMIN_UI_TOKEN") != 0 {
+		t.Errorf("gateway secrets must not be inherited even in all mode: %v", all)
+	}
+	if defaults := buildEnv(nil, nil); count(defaults, "MODEL_REGION") != 0 || envValue(defaults, "LC_ALL") != "C.UTF-8" {
+		t.Errorf("unset policy should use the default allowlist: %v", defaults)
+	}
+
+	allowlist := buildEnv(&EnvPolicy{Inherit: EnvInheritAllowlist}, map[string]string{"OPENAI_API_KEY": "sk"})
+	if count(allowlist, "ADMIN_UI_TOKEN") != 0 || count(allowlist, "MODEL_REGION") != 0 {
+		t.Errorf("default allowlist should not inherit gateway variables: %v", allowlist)
+	}
+	if envValue(allowlist, "LC_ALL") != "C.UTF-8" || envValue(allowlist, "OPENAI_API_KEY") != "sk" {
+		t.Errorf("allowlist should keep LC_* and profile env: %v", allowlist)
+	}
+	if envValue(allowlist, "HTTPS_PROXY") != "http://proxy:3128" {
+		t.Errorf("default allowlist should keep proxy variables: %v", allowlist)
+	}
+	custom := buildEnv(&EnvPolicy{Inherit: EnvInheritAllowlist, Allow: []string{"MODEL_*"}}, nil)
+	if len(custom) != 1 || custom[0] != "MODEL_REGION=us" {
+		t.Errorf("custom allowlist should replace the default one: %v", custom)
+	}
+
+	none := buildEnv(&EnvPolicy{Inherit: EnvInheritNone}, map[string]string{"HOME": "/srv/home"})
+	if len(none) != 1 || none[0] != "HOME=/srv/home" {
+		t.Errorf("inherit none should only contain profile env: %v", none)
+	}
+
+	merged := mergeEnv([]string{"A=1", "B=2"}, "A=3", "C=4")
+	if strings.Join(merged, ",") != "A=3,B=2,C=4" {
+		t.Errorf("unexpected merge result: %v", merged)
+	}
+
+	if err := (&EnvPolicy{Inherit: "some"}).Validate(); err == nil {
+		t.Error("unknown inherit mode should be rejected")
+	}
+	if err := (&EnvPolicy{Inherit: EnvInheritAllowlist, Allow: []string{"A*B"}}).Validate(); err == nil {
+		t.Error("only trailing * should be accepted in allow entries")
+	}
+}
@@ -16,6 +16,7 @@ type GeminiOutput struct {
 	Response string `json:"response,omitempty"`
 	Stats    struct {
 		Models map[string]interface{} `json:"models,omitempty"`
+		Tools  *toolCallStats         `json:"tools,omitempty"`
 	} `json:"stats,omitempty"`
 }
 
@@ -65,15 +66,35 @@ func (g *GeminiCLI) Run(opts *RunOptions) (string, error) {
 		log.Printf("🔧 [Gemini] Allowed tools: %v", opts.AllowedTools)
 	}
 
-	// 添加 prompt（作为位置参数）
-	args = append(args, opts.Prompt)
+	// profile 的 mcp_servers（写入临时系统 settings，调用结束后删除）
+	mcpArgs, mcpEnv, mcpCleanup, err := applyGeminiMCPConfig("Gemini", geminiSystemSettingsEnv, opts)
+	if err != nil {
+		return "", err
+	}
+	defer mcpCleanup()
+	args = append(args, mcpArgs...)
 
-	log.Printf("⚙️  [Gemini] Executing: gemini %s", strings.Join(args, " "))
+	// 添加 prompt（作为位置参数；过长或以 "-" 开头时通过 stdin 传入）
+	prompt, err := preparePrompt("Gemini", opts, promptSupport{Stdin: true})
+	if err != nil {
+		return "", err
+	}
+	if prompt.Arg != "" {
+		args = append(args, prompt.Arg)
+	}
+
+	log.Printf("⚙️  [Gemini] Executing: %s", redactCommand("gemini", args, opts))
 
 	cmd := exec.Command("gemini", args...)
-	cmd.Env = buildEnv(opts.Env)
+	cmd.Env = mergeEnv(buildEnv(opts.EnvPolicy, opts.Env), mcpEnv...)
+	cmd.Stdin = prompt.Stdin
+	finish, err := prepareCommand("Gemini", cmd, opts)
+	if err != nil {
+		return "", err
+	}
+	defer finish()
 
-	output, err := cmd.CombinedOutput()
+	output, err := combinedOutput(cmd)
 	log.Printf("📊 [Gemini] Output length: %d bytes", len(output))
 
 	if err != nil {
@@ -81,10 +102,10 @@ func (g *GeminiCLI) Run(opts *RunOptions) (string, error) {
 		return "", fmt.Errorf("gemini CLI execution failed: %v, output: %s", err, string(output))
 	}
 
-	return g.parseOutput(string(output), opts.Prompt)
+	return g.parseOutput(string(output), opts.Prompt, opts.CaptureSteps)
 }
 
-func (g *GeminiCLI) parseOutput(output string, prompt string) (string, error) {
+func (g *GeminiCLI) parseOutput(output string, prompt string, captureSteps bool) (string, error) {
 	// Gemini 输出可能包含前置信息（如 "Loaded cached credentials."）
 	// 需要找到 JSON 的起始位置
 	jsonStart := strings.Index(output, "{")
@@ -114,6 +135,10 @@ func (g *GeminiCLI) parseOutput(output string, prompt string) (string, error) {
 		User:      prompt,
 		Response:  response,
 	}
+	if captureSteps {
+		// Gemini 的 JSON 输出只包含按工具汇总的统计，没有逐次调用明细
+		result.Steps = geminiOut.Stats.Tools.steps()
+	}
 
 	jsonBytes, err := json.Marshal(result)
 	if err != nil {
@@ -1,12 +1,14 @@
 package cli
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"log"
-	"strings"
 	"sync"
 	"time"
+
+	"dify-cli-gateway/internal/response_cache"
 )
 
 // IflowCLI 实现 iFlow CLI - 支持扩展、中间件、缓存和监控
@@ -46,16 +48,10 @@ type IflowOutput struct {
 	Metadata     map[string]string `json:"metadata,omitempty"`
 }
 
-// ResponseCache 响应缓存
+// ResponseCache 响应缓存（按 Strategy 淘汰，按 MaxSizeMB 计算内存占用）
 type ResponseCache struct {
-	entries map[string]*cacheEntry
-	config  *CacheConfig
-	mu      sync.RWMutex
-}
-
-type cacheEntry struct {
-	value      string
-	expiration time.Time
+	store  *response_cache.MemoryStore
+	config *CacheConfig
 }
 
 // MetricsCollector 指标收集器
@@ -71,6 +67,12 @@ type requestMetrics struct {
 	lastRequest   time.Time
 	cacheHits     int
 	cacheMisses   int
+	coalesced     int
+
+	resourceRuns    int   // 上报了资源用量的调用次数
+	peakMemoryBytes int64 // 单次调用的最大峰值内存
+	cpuTimeMS       int64 // 累计 CPU 时间
+	oomKills        int
 }
 
 // MiddlewareChain 中间件链
@@ -229,6 +231,9 @@ func (i *IflowCLI) Run(opts *RunOptions) (string, error) {
 			// 缓存中存储的是最终结果，直接返回
 			return cached, nil
 		}
+		if i.metrics != nil {
+			i.metrics.RecordCacheMiss(i.Name())
+		}
 	}
 
 	// 3. 执行CLI命令（带重试）
@@ -331,32 +336,18 @@ func isKnownCLIName(name string) bool {
 	}
 }
 
-// generateCacheKey 生成缓存键
+// generateCacheKey 生成缓存键（规范化后的 SHA-256）
 func (i *IflowCLI) generateCacheKey(opts *RunOptions) string {
-	parts := []string{
-		"iflow",
-		opts.Prompt,
-		opts.SystemPrompt,
-		opts.Model,
-		opts.PermissionMode,
-	}
-
-	// 添加允许的工具
-	if len(opts.AllowedTools) > 0 {
-		parts = append(parts, strings.Join(opts.AllowedTools, ","))
-	}
-
-	// 添加Skills
-	if len(opts.Skills) > 0 {
-		parts = append(parts, strings.Join(opts.Skills, ","))
-	}
-
-	// 添加SessionID（如果存在）
-	if opts.SessionID != "" {
-		parts = append(parts, opts.SessionID)
-	}
-
-	return fmt.Sprintf("%x", []byte(strings.Join(parts, "|")))
+	return response_cache.Key(response_cache.KeyInput{
+		CLI:            "iflow",
+		Model:          opts.Model,
+		Prompt:         opts.Prompt,
+		System:         opts.SystemPrompt,
+		Tools:          opts.AllowedTools,
+		Skills:         opts.Skills,
+		PermissionMode: opts.PermissionMode,
+		SessionID:      opts.SessionID,
+	})
 }
 
 // addMetadata 添加元数据到响应
@@ -423,69 +414,37 @@ func (i *IflowCLI) addMiddlewareByName(name string, config map[string]interface{
 
 // NewResponseCache 创建响应缓存
 func NewResponseCache(config *CacheConfig) *ResponseCache {
+	strategy, err := response_cache.ParseStrategy(config.Strategy)
+	if err != nil {
+		log.Printf("⚠️  [IflowCLI] %v, fallback to lru", err)
+		strategy = response_cache.StrategyLRU
+	}
+	store, _ := response_cache.NewMemoryStore(strategy, response_cache.MaxBytesFromMB(config.MaxSizeMB))
 	return &ResponseCache{
-		entries: make(map[string]*cacheEntry),
-		config:  config,
+		store:  store,
+		config: config,
 	}
 }
 
 func (c *ResponseCache) Get(key string) (string, bool) {
-	c.mu.RLock()
-	defer c.mu.RUnlock()
-
-	entry, exists := c.entries[key]
-	if !exists {
-		return "", false
-	}
-
-	if time.Now().After(entry.expiration) {
-		return "", false
-	}
-
-	return entry.value, true
+	value, found, _ := c.store.Get(context.Background(), key)
+	return value, found
 }
 
 func (c *ResponseCache) Set(key string, value string) {
-	c.mu.Lock()
-	defer c.mu.Unlock()
-
-	// 清理过期条目
-	c.cleanup()
-
-	// 检查大小限制
-	if len(c.entries) >= 1000 { // 简化：最大条目数
-		// LRU策略：删除最旧的
-		var oldestKey string
-		var oldestTime time.Time
-		for k, v := range c.entries {
-			if oldestTime.IsZero() || v.expiration.Before(oldestTime) {
-				oldestKey = k
-				oldestTime = v.expiration
-			}
-		}
-		delete(c.entries, oldestKey)
-	}
-
 	ttl := time.Duration(c.config.TTLMinutes) * time.Minute
-	c.entries[key] = &cacheEntry{
-		value:      value,
-		expiration: time.Now().Add(ttl),
+	if err := c.store.Set(context.Background(), key, value, ttl); err != nil {
+		log.Printf("⚠️  [IflowCLI] Cache set skipped: %v", err)
 	}
 }
 
 func (c *ResponseCache) Clear() {
-	c.mu.Lock()
-	defer c.mu.Unlock()
-	c.entries = make(map[string]*cacheEntry)
+	c.store.Clear(context.Background())
 }
 
-func (c *ResponseCache) cleanup() {
-	now := time.Now()
-	for key, entry := range c.entries {
-		if now.After(entry.expiration) {
-			delete(c.entries, key)
-		}
-	}
+// Stats 返回缓存统计
+func (c *ResponseCache) Stats() response_cache.Stats {
+	return c.store.Stats()
 }
 
 // NewMetricsCollector 创建指标收集器
@@ -539,6 +498,41 @@ func (m *MetricsCollector) RecordCacheMiss(cliName string) {
 	metrics.cacheMisses++
 }
 
+// RecordCoalesced 记录合并到其它进行中请求的调用
+func (m *MetricsCollector) RecordCoalesced(cliName string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	metrics, exists := m.requests[cliName]
+	if !exists {
+		metrics = &requestMetrics{}
+		m.requests[cliName] = metrics
+	}
+
+	metrics.coalesced++
+}
+
+// RecordResourceUsage 记录 CLI 子进程的资源用量
+func (m *MetricsCollector) RecordResourceUsage(cliName string, usage ResourceUsage) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	metrics, exists := m.requests[cliName]
+	if !exists {
+		metrics = &requestMetrics{}
+		m.requests[cliName] = metrics
+	}
+
+	metrics.resourceRuns++
+	metrics.cpuTimeMS += usage.CPUTimeMS
+	if usage.PeakMemoryBytes > metrics.peakMemoryBytes {
+		metrics.peakMemoryBytes = usage.PeakMemoryBytes
+	}
+	if usage.OOMKilled {
+		metrics.oomKills++
+	}
+}
+
 func (m *MetricsCollector) GetSummary() map[string]interface{} {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
@@ -562,7 +556,13 @@ func (m *MetricsCollector) GetSummary() map[string]interface{} {
 			"success_rate": successRate,
 			"cache_hits":   metrics.cacheHits,
 			"cache_misses": metrics.cacheMisses,
+			"coalesced":    metrics.coalesced,
 			"last_request": metrics.lastRequest.Format(time.RFC3339),
+
+			"resource_runs":     metrics.resourceRuns,
+			"peak_memory_bytes": metrics.peakMemoryBytes,
+			"cpu_time_ms":       metrics.cpuTimeMS,
+			"oom_kills":         metrics.oomKills,
 		}
 	}
 	return result
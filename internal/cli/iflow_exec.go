@@ -21,6 +21,7 @@ func (i *IflowExecCLI) Name() string {
 
 func (i *IflowExecCLI) Run(opts *RunOptions) (string, error) {
 	var args []string
+	warnUnsupportedMCPConfig("iFlow", opts)
 
 	if opts.Model != "" {
 		args = append(args, "--model", opts.Model)
@@ -54,16 +55,27 @@ func (i *IflowExecCLI) Run(opts *RunOptions) (string, error) {
 		log.Printf("⚠️  [iFlow] Allowed tools are not supported by CLI flags")
 	}
 
-	if opts.Prompt != "" {
-		args = append(args, "-p", opts.Prompt)
+	// 过长或以 "-" 开头的 prompt 通过 stdin 传入
+	prompt, err := preparePrompt("iFlow", opts, promptSupport{Stdin: true})
+	if err != nil {
+		return "", err
+	}
+	if prompt.Arg != "" {
+		args = append(args, "-p", prompt.Arg)
 	}
 
-	log.Printf("⚙️  [iFlow] Executing: iflow %s", strings.Join(args, " "))
+	log.Printf("⚙️  [iFlow] Executing: %s", redactCommand("iflow", args, opts))
 
 	cmd := exec.Command("iflow", args...)
-	cmd.Env = buildEnv(opts.Env)
+	cmd.Env = buildEnv(opts.EnvPolicy, opts.Env)
+	cmd.Stdin = prompt.Stdin
+	finish, err := prepareCommand("iFlow", cmd, opts)
+	if err != nil {
+		return "", err
+	}
+	defer finish()
 
-	output, err := cmd.CombinedOutput()
+	output, err := combinedOutput(cmd)
 	log.Printf("📊 [iFlow] Output length: %d bytes", len(output))
 	log.Printf("🧾 [iFlow] Raw output:\n%s", string(output))
 
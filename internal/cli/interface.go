@@ -23,14 +23,41 @@ type RunOptions struct {
 	AllowedTools   []string          // 允许使用的工具列表
 	PermissionMode string            // 权限模式
 	Skills         []string          // Skills 路径列表
-	Env            map[string]string // 环境变量
+	Env            map[string]string // 环境变量（覆盖继承的同名变量）
+	EnvPolicy      *EnvPolicy        // 从网关继承环境变量的策略，nil 表示默认 allowlist
 	Model          string            // 模型名称
 	WorkDir        string            // 工作目录
+
+	PromptDelivery    string // prompt 传递方式：auto（默认）/arg/stdin
+	PromptArgMaxBytes int    // auto 模式下超过该长度改用 stdin（不支持 stdin 的 CLI 直接报错），默认 32KB
+
+	Codex        *CodexOptions // Codex 专用选项（sandbox / profile / config 覆盖）
+	CaptureSteps bool          // 在输出中附带工具调用步骤（steps）
+
+	MCPServers           map[string]MCPServerConfig // 本次调用的 MCP servers（写入临时配置，Claude / Gemini / Qwen）
+	StrictMCPConfig      bool                       // 只使用 MCPServers，忽略用户目录中的 MCP 配置
+	PermissionPromptTool string                     // 非交互模式下处理权限确认的 MCP 工具（仅 Claude）
+
+	Sandbox            *SandboxConfig      // 在 bubblewrap 沙箱中执行 CLI（仅 Linux）
+	SandboxStateDir    string              // 沙箱内 CLI 会话记录的独立目录（data/homes/<profile>，由网关创建）
+	SandboxOwnsWorkDir bool                // WorkDir 由网关创建（data/workspaces/<profile>），以其他用户运行时可以移交所有权
+	Resources          *ResourceLimits     // 子进程资源限制（cgroup v2 + rlimit）
+	OnResourceUsage    func(ResourceUsage) // CLI 进程结束后回调资源用量（管道用于写入元数据与指标）
 }
 
 // CLIOutput 定义统一的输出格式
 type CLIOutput struct {
-	SessionID string `json:"session_id"`
-	User      string `json:"user"`
-	Response  string `json:"response"`
+	SessionID    string      `json:"session_id"`
+	User         string      `json:"user"`
+	Response     string      `json:"response"`
+	TotalCostUSD float64     `json:"total_cost_usd,omitempty"` // CLI 上报的费用（仅部分 CLI 提供）
+	Usage        *TokenUsage `json:"usage,omitempty"`          // CLI 上报的 token 用量（仅部分 CLI 提供）
+	Steps        []Step      `json:"steps,omitempty"`          // 工具调用步骤（RunOptions.CaptureSteps 时返回）
+}
+
+// TokenUsage token 用量
+type TokenUsage struct {
+	InputTokens       int `json:"input_tokens"`
+	CachedInputTokens int `json:"cached_input_tokens,omitempty"`
+	OutputTokens      int `json:"output_tokens"`
 }
@@ -0,0 +1,126 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+)
+
+// Gemini / Qwen 的系统级 settings.json 路径环境变量（优先级高于用户与工作区配置）
+const (
+	geminiSystemSettingsEnv = "GEMINI_CLI_SYSTEM_SETTINGS_PATH"
+	qwenSystemSettingsEnv   = "QWEN_CODE_SYSTEM_SETTINGS_PATH"
+)
+
+// noMCPServerName 严格模式下 profile 未配置任何 server 时传入的占位名，使 CLI 不加载任何 MCP server
+const noMCPServerName = "__none__"
+
+// MCPServerConfig 单个 MCP server 配置（与 claude --mcp-config 的 mcpServers 条目一致）
+type MCPServerConfig struct {
+	Type    string            `json:"type,omitempty"` // stdio（默认）/ http / sse
+	Command string            `json:"command,omitempty"`
+	Args    []string          `json:"args,omitempty"`
+	Env     map[string]string `json:"env,omitempty"`
+	URL     string            `json:"url,omitempty"`
+	Headers map[string]string `json:"headers,omitempty"`
+}
+
+// writeMCPConfig 将 MCP servers 写入临时配置文件（0600，可能包含 token），返回路径与清理函数
+func writeMCPConfig(servers map[string]MCPServerConfig) (string, func(), error) {
+	return writeMCPFile(map[string]interface{}{"mcpServers": servers})
+}
+
+// geminiMCPServers 转换为 Gemini / Qwen settings.json 的 mcpServers 格式（http → httpUrl，sse → url）
+func geminiMCPServers(servers map[string]MCPServerConfig) map[string]map[string]interface{} {
+	converted := make(map[string]map[string]interface{}, len(servers))
+	for name, server := range servers {
+		entry := map[string]interface{}{}
+		switch {
+		case server.URL != "" && server.Type == "sse":
+			entry["url"] = server.URL
+		case server.URL != "":
+			entry["httpUrl"] = server.URL
+		default:
+			entry["command"] = server.Command
+			if len(server.Args) > 0 {
+				entry["args"] = server.Args
+			}
+		}
+		if len(server.Env) > 0 {
+			entry["env"] = server.Env
+		}
+		if len(server.Headers) > 0 {
+			entry["headers"] = server.Headers
+		}
+		converted[name] = entry
+	}
+	return converted
+}
+
+// applyGeminiMCPConfig 为 Gemini / Qwen 写入临时系统 settings 并返回额外参数、环境变量与清理函数
+// 严格模式下通过 --allowed-mcp-server-names 屏蔽用户目录中配置的其他 server
+func applyGeminiMCPConfig(tag, settingsEnv string, opts *RunOptions) ([]string, []string, func(), error) {
+	if len(opts.MCPServers) == 0 && !opts.StrictMCPConfig {
+		return nil, nil, func() {}, nil
+	}
+
+	var env []string
+	cleanup := func() {}
+	if len(opts.MCPServers) > 0 {
+		path, remove, err := writeMCPFile(map[string]interface{}{"mcpServers": geminiMCPServers(opts.MCPServers)})
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		env = append(env, settingsEnv+"="+path)
+		cleanup = remove
+	}
+
+	var args []string
+	if opts.StrictMCPConfig {
+		names := mcpServerNames(opts.MCPServers)
+		if len(names) == 0 {
+			names = []string{noMCPServerName}
+		}
+		args = append(args, "--allowed-mcp-server-names", strings.Join(names, ","))
+	}
+	log.Printf("🔌 [%s] Profile MCP servers: %v (strict=%v)", tag, mcpServerNames(opts.MCPServers), opts.StrictMCPConfig)
+	return args, env, cleanup, nil
+}
+
+// warnUnsupportedMCPConfig 不支持按调用传入 MCP 配置的 CLI 记录警告
+func warnUnsupportedMCPConfig(tag string, opts *RunOptions) {
+	if len(opts.MCPServers) > 0 || opts.StrictMCPConfig {
+		log.Printf("⚠️  [%s] Per-profile mcp_servers are not supported, using the CLI's own MCP configuration", tag)
+	}
+}
+
+func mcpServerNames(servers map[string]MCPServerConfig) []string {
+	names := make([]string, 0, len(servers))
+	for name := range servers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func writeMCPFile(payload interface{}) (string, func(), error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to encode mcp config: %v", err)
+	}
+	file, err := os.CreateTemp("", "cli-gateway-mcp-*.json")
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to create mcp config: %v", err)
+	}
+	cleanup := func() { os.Remove(file.Name()) }
+	_, writeErr := file.Write(data)
+	closeErr := file.Close()
+	if writeErr != nil || closeErr != nil {
+		cleanup()
+		return "", nil, fmt.Errorf("failed to write mcp config: %v", firstError(writeErr, closeErr))
+	}
+	return file.Name(), cleanup, nil
+}
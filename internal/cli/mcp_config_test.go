@@ -0,0 +1,69 @@
+package cli
+
+import (
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestWriteMCPConfig(t *testing.T) {
+	path, cleanup, err := writeMCPConfig(map[string]MCPServerConfig{
+		"github": {Command: "npx", Args: []string{"-y", "@modelcontextprotocol/server-github"}, Env: map[string]string{"GITHUB_TOKEN": "t"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(path)
+	if err != nil || info.Mode().Perm() != 0o600 {
+		t.Fatalf("config should be private: %v %v", info, err)
+	}
+	data, _ := os.ReadFile(path)
+	if !strings.Contains(string(data), `"mcpServers":{"github":{"command":"npx"`) {
+		t.Errorf("unexpected config: %s", data)
+	}
+	cleanup()
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Error("cleanup should remove the config file")
+	}
+}
+
+func TestApplyGeminiMCPConfig(t *testing.T) {
+	args, env, cleanup, err := applyGeminiMCPConfig("Gemini", geminiSystemSettingsEnv, &RunOptions{StrictMCPConfig: true})
+	if err != nil || len(env) != 0 || strings.Join(args, " ") != "--allowed-mcp-server-names "+noMCPServerName {
+		t.Errorf("strict mode without servers should allow none: %v %v %v", args, env, err)
+	}
+	cleanup()
+
+	args, env, cleanup, err = applyGeminiMCPConfig("Gemini", geminiSystemSettingsEnv, &RunOptions{
+		StrictMCPConfig: true,
+		MCPServers: map[string]MCPServerConfig{
+			"docs":  {Type: "http", URL: "https://docs.example.com/mcp", Headers: map[string]string{"Authorization": "Bearer x"}},
+			"local": {Command: "node", Args: []string{"server.js"}},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cleanup()
+	if strings.Join(args, " ") != "--allowed-mcp-server-names docs,local" {
+		t.Errorf("unexpected args: %v", args)
+	}
+	if len(env) != 1 || !strings.HasPrefix(env[0], geminiSystemSettingsEnv+"=") {
+		t.Fatalf("unexpected env: %v", env)
+	}
+	data, _ := os.ReadFile(strings.TrimPrefix(env[0], geminiSystemSettingsEnv+"="))
+	var settings struct {
+		MCPServers map[string]map[string]interface{} `json:"mcpServers"`
+	}
+	if err := json.Unmarshal(data, &settings); err != nil {
+		t.Fatal(err)
+	}
+	if settings.MCPServers["docs"]["httpUrl"] != "https://docs.example.com/mcp" || settings.MCPServers["local"]["command"] != "node" {
+		t.Errorf("unexpected settings: %s", data)
+	}
+
+	if args, env, _, _ := applyGeminiMCPConfig("Gemini", geminiSystemSettingsEnv, &RunOptions{}); args != nil || env != nil {
+		t.Error("no profile mcp_servers should leave the CLI untouched")
+	}
+}
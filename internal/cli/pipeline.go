@@ -0,0 +1,240 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+)
+
+// PipelineRequest 表示流经网关中间件管道的一次 CLI 调用
+type PipelineRequest struct {
+	CLI       string            // 实际执行的 CLI 名称
+	Profile   string            // 使用的 profile 名称
+	Options   *RunOptions       // CLI 执行选项（中间件可修改）
+	Metadata  map[string]string // 请求元数据（中间件之间共享，可读写）
+	StartedAt time.Time         // 请求进入管道的时间
+	Context   context.Context   // 调用方的请求上下文（客户端断开时取消等待），可为空
+}
+
+// Meta 读取请求元数据
+func (r *PipelineRequest) Meta(key string) string {
+	if r == nil || r.Metadata == nil {
+		return ""
+	}
+	return r.Metadata[key]
+}
+
+// SetMeta 写入请求元数据
+func (r *PipelineRequest) SetMeta(key string, value string) {
+	if r.Metadata == nil {
+		r.Metadata = make(map[string]string)
+	}
+	r.Metadata[key] = value
+}
+
+// PipelineHandler 执行请求并返回 CLI 输出
+type PipelineHandler func(req *PipelineRequest) (string, error)
+
+// PipelineMiddleware 网关级中间件
+// Handle 可在调用 next 前后处理请求与结果；不调用 next 即短路（如缓存命中、guard 拦截）
+type PipelineMiddleware interface {
+	Name() string
+	Handle(req *PipelineRequest, next PipelineHandler) (string, error)
+}
+
+// PipelineMiddlewareBuilder 根据 MiddlewareConfig 创建中间件
+type PipelineMiddlewareBuilder func(cfg MiddlewareConfig) (PipelineMiddleware, error)
+
+// Pipeline 网关中间件管道，按添加顺序由外向内执行
+type Pipeline struct {
+	middlewares []PipelineMiddleware
+}
+
+var (
+	pipelineBuilders   = make(map[string]PipelineMiddlewareBuilder)
+	pipelineBuildersMu sync.RWMutex
+)
+
+// NewPipeline 创建中间件管道
+func NewPipeline(middlewares ...PipelineMiddleware) *Pipeline {
+	p := &Pipeline{middlewares: make([]PipelineMiddleware, 0, len(middlewares))}
+	for _, m := range middlewares {
+		p.Use(m)
+	}
+	return p
+}
+
+// Use 追加中间件
+func (p *Pipeline) Use(middleware PipelineMiddleware) {
+	if middleware == nil {
+		return
+	}
+	p.middlewares = append(p.middlewares, middleware)
+}
+
+// Clone 复制管道（共享中间件实例），便于在缓存的管道上为单个请求追加中间件
+func (p *Pipeline) Clone() *Pipeline {
+	if p == nil {
+		return NewPipeline()
+	}
+	return NewPipeline(p.middlewares...)
+}
+
+// Len 返回中间件数量
+func (p *Pipeline) Len() int {
+	if p == nil {
+		return 0
+	}
+	return len(p.middlewares)
+}
+
+// Names 返回中间件名称（按执行顺序）
+func (p *Pipeline) Names() []string {
+	if p == nil {
+		return nil
+	}
+	names := make([]string, 0, len(p.middlewares))
+	for _, m := range p.middlewares {
+		names = append(names, m.Name())
+	}
+	return names
+}
+
+// Run 通过管道执行 runner
+func (p *Pipeline) Run(runner CLIRunner, req *PipelineRequest) (string, error) {
+	if runner == nil {
+		return "", fmt.Errorf("runner is nil")
+	}
+	if req == nil {
+		req = &PipelineRequest{}
+	}
+	if req.Options == nil {
+		req.Options = &RunOptions{}
+	}
+	if req.Metadata == nil {
+		req.Metadata = make(map[string]string)
+	}
+	if req.StartedAt.IsZero() {
+		req.StartedAt = time.Now()
+	}
+	if req.CLI == "" {
+		req.CLI = runner.Name()
+	}
+
+	handler := PipelineHandler(func(r *PipelineRequest) (string, error) {
+		return runner.Run(withResourceReporting(r))
+	})
+	if p != nil {
+		for i := len(p.middlewares) - 1; i >= 0; i-- {
+			middleware := p.middlewares[i]
+			next := handler
+			handler = func(r *PipelineRequest) (string, error) {
+				return middleware.Handle(r, next)
+			}
+		}
+	}
+
+	return handler(req)
+}
+
+// RegisterPipelineMiddleware 注册网关中间件构造器（同名覆盖）
+func RegisterPipelineMiddleware(name string, builder PipelineMiddlewareBuilder) {
+	pipelineBuildersMu.Lock()
+	defer pipelineBuildersMu.Unlock()
+	pipelineBuilders[name] = builder
+}
+
+// ListPipelineMiddlewares 返回已注册的网关中间件名称
+func ListPipelineMiddlewares() []string {
+	pipelineBuildersMu.RLock()
+	defer pipelineBuildersMu.RUnlock()
+	names := make([]string, 0, len(pipelineBuilders))
+	for name := range pipelineBuilders {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// BuildPipeline 按配置顺序构建管道，跳过未启用的中间件
+func BuildPipeline(configs []MiddlewareConfig) (*Pipeline, error) {
+	pipeline := NewPipeline()
+	for _, cfg := range configs {
+		if !cfg.Enabled {
+			continue
+		}
+		pipelineBuildersMu.RLock()
+		builder, ok := pipelineBuilders[cfg.Name]
+		pipelineBuildersMu.RUnlock()
+		if !ok {
+			return nil, fmt.Errorf("unknown middleware: %s", cfg.Name)
+		}
+		middleware, err := builder(cfg)
+		if err != nil {
+			return nil, fmt.Errorf("middleware '%s' config error: %v", cfg.Name, err)
+		}
+		pipeline.Use(middleware)
+	}
+	if pipeline.Len() > 0 {
+		log.Printf("🔗 [Pipeline] Built with middlewares: %v", pipeline.Names())
+	}
+	return pipeline, nil
+}
+
+// rewriteOutputResponse 修改统一输出 JSON 中的 response 字段，保留其它字段
+// 如果输出不是 JSON 对象，则直接对整个字符串应用转换
+func rewriteOutputResponse(output string, fn func(string) string) string {
+	var payload map[string]interface{}
+	if err := json.Unmarshal([]byte(output), &payload); err != nil {
+		return fn(output)
+	}
+	response, ok := payload["response"].(string)
+	if !ok {
+		return output
+	}
+	payload["response"] = fn(response)
+	jsonBytes, err := json.Marshal(payload)
+	if err != nil {
+		return output
+	}
+	return string(jsonBytes)
+}
+
+// setOutputMetadata 在统一输出 JSON 的 metadata 字段中写入键值
+func setOutputMetadata(output string, key string, value string) string {
+	var payload map[string]interface{}
+	if err := json.Unmarshal([]byte(output), &payload); err != nil {
+		return output
+	}
+	metadata, _ := payload["metadata"].(map[string]interface{})
+	if metadata == nil {
+		metadata = make(map[string]interface{})
+	}
+	metadata[key] = value
+	payload["metadata"] = metadata
+	jsonBytes, err := json.Marshal(payload)
+	if err != nil {
+		return output
+	}
+	return string(jsonBytes)
+}
+
+// withResourceReporting 让 CLI 进程结束后的资源用量写入请求元数据与网关指标
+func withResourceReporting(r *PipelineRequest) *RunOptions {
+	opts := *r.Options
+	previous := opts.OnResourceUsage
+	opts.OnResourceUsage = func(usage ResourceUsage) {
+		for key, value := range usage.Metadata() {
+			r.SetMeta(key, value)
+		}
+		GatewayMetrics().RecordResourceUsage(r.CLI, usage)
+		if previous != nil {
+			previous(usage)
+		}
+	}
+	return &opts
+}
@@ -0,0 +1,357 @@
+package cli
+
+import (
+	"fmt"
+	"log"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// gatewayMetrics 网关级指标收集器（由 metrics/timing 中间件写入）
+var gatewayMetrics = NewMetricsCollector()
+
+// GatewayMetrics 返回网关级指标收集器
+func GatewayMetrics() *MetricsCollector {
+	return gatewayMetrics
+}
+
+func init() {
+	RegisterPipelineMiddleware("logging", func(cfg MiddlewareConfig) (PipelineMiddleware, error) {
+		return &loggingPipelineMiddleware{}, nil
+	})
+	RegisterPipelineMiddleware("metrics", func(cfg MiddlewareConfig) (PipelineMiddleware, error) {
+		return &metricsPipelineMiddleware{collector: gatewayMetrics}, nil
+	})
+	RegisterPipelineMiddleware("retry", newRetryPipelineMiddleware)
+	RegisterPipelineMiddleware("timing", func(cfg MiddlewareConfig) (PipelineMiddleware, error) {
+		return &timingPipelineMiddleware{inject: configBool(cfg.Config, "inject", true)}, nil
+	})
+	RegisterPipelineMiddleware("prompt_rewrite", newPromptRewriteMiddleware)
+	RegisterPipelineMiddleware("response_postprocess", newResponsePostprocessMiddleware)
+	RegisterPipelineMiddleware("pii_redaction", newPIIRedactionMiddleware)
+}
+
+// loggingPipelineMiddleware 记录请求与结果
+type loggingPipelineMiddleware struct{}
+
+func (m *loggingPipelineMiddleware) Name() string {
+	return "logging"
+}
+
+func (m *loggingPipelineMiddleware) Handle(req *PipelineRequest, next PipelineHandler) (string, error) {
+	log.Printf("📝 [Pipeline:Logging] Before: cli=%s profile=%s prompt=%s", req.CLI, req.Profile, truncate(req.Options.Prompt, 50))
+	result, err := next(req)
+	if err != nil {
+		log.Printf("❌ [Pipeline:Logging] After error: %v", err)
+	} else {
+		log.Printf("✅ [Pipeline:Logging] After: result_length=%d", len(result))
+	}
+	return result, err
+}
+
+// metricsPipelineMiddleware 按 CLI 记录调用次数、耗时与错误
+type metricsPipelineMiddleware struct {
+	collector *MetricsCollector
+}
+
+func (m *metricsPipelineMiddleware) Name() string {
+	return "metrics"
+}
+
+func (m *metricsPipelineMiddleware) Handle(req *PipelineRequest, next PipelineHandler) (string, error) {
+	start := time.Now()
+	result, err := next(req)
+	m.collector.RecordRequest(req.CLI, time.Since(start), err != nil)
+	return result, err
+}
+
+// retryPipelineMiddleware 失败时按线性退避重试
+type retryPipelineMiddleware struct {
+	maxRetries int
+	backoff    time.Duration
+}
+
+func newRetryPipelineMiddleware(cfg MiddlewareConfig) (PipelineMiddleware, error) {
+	maxRetries := configInt(cfg.Config, "max_retries", 3)
+	if maxRetries < 1 || maxRetries > 10 {
+		return nil, fmt.Errorf("max_retries must be between 1 and 10")
+	}
+	return &retryPipelineMiddleware{
+		maxRetries: maxRetries,
+		backoff:    time.Duration(configInt(cfg.Config, "backoff_ms", 100)) * time.Millisecond,
+	}, nil
+}
+
+func (m *retryPipelineMiddleware) Name() string {
+	return "retry"
+}
+
+func (m *retryPipelineMiddleware) Handle(req *PipelineRequest, next PipelineHandler) (string, error) {
+	// 续接会话的调用重试会向会话重复追加轮次
+	if req.Options != nil && req.Options.SessionID != "" {
+		return next(req)
+	}
+	// 内层中间件会就地改写 prompt，每次重试前恢复原始选项，避免改写叠加
+	var snapshot RunOptions
+	if req.Options != nil {
+		snapshot = *req.Options
+	}
+	var result string
+	var err error
+	for attempt := 1; attempt <= m.maxRetries; attempt++ {
+		if attempt > 1 && req.Options != nil {
+			*req.Options = snapshot
+		}
+		result, err = next(req)
+		if err == nil {
+			return result, nil
+		}
+		if attempt < m.maxRetries {
+			log.Printf("⚠️  [Pipeline:Retry] Attempt %d failed, retrying... (%v)", attempt, err)
+			time.Sleep(time.Duration(attempt) * m.backoff)
+		}
+	}
+	return result, err
+}
+
+// timingPipelineMiddleware 记录耗时并写入请求与响应元数据
+type timingPipelineMiddleware struct {
+	inject bool
+}
+
+func (m *timingPipelineMiddleware) Name() string {
+	return "timing"
+}
+
+func (m *timingPipelineMiddleware) Handle(req *PipelineRequest, next PipelineHandler) (string, error) {
+	start := time.Now()
+	result, err := next(req)
+	duration := time.Since(start)
+	durationMS := strconv.FormatInt(duration.Milliseconds(), 10)
+	req.SetMeta("duration_ms", durationMS)
+	log.Printf("⏱️  [Pipeline:Timing] cli=%s profile=%s took %v", req.CLI, req.Profile, duration)
+	if err == nil && m.inject {
+		result = setOutputMetadata(result, "duration_ms", durationMS)
+	}
+	return result, err
+}
+
+// promptRewriteMiddleware 在执行前改写 prompt / system prompt
+// 配置：template（{{prompt}} 占位符）、prefix、suffix、system_append、trim
+type promptRewriteMiddleware struct {
+	template     string
+	prefix       string
+	suffix       string
+	systemAppend string
+	trim         bool
+}
+
+func newPromptRewriteMiddleware(cfg MiddlewareConfig) (PipelineMiddleware, error) {
+	template := cfg.Config["template"]
+	if template != "" && !strings.Contains(template, "{{prompt}}") {
+		return nil, fmt.Errorf("template must contain {{prompt}}")
+	}
+	return &promptRewriteMiddleware{
+		template:     template,
+		prefix:       cfg.Config["prefix"],
+		suffix:       cfg.Config["suffix"],
+		systemAppend: cfg.Config["system_append"],
+		trim:         configBool(cfg.Config, "trim", false),
+	}, nil
+}
+
+func (m *promptRewriteMiddleware) Name() string {
+	return "prompt_rewrite"
+}
+
+func (m *promptRewriteMiddleware) Handle(req *PipelineRequest, next PipelineHandler) (string, error) {
+	prompt := req.Options.Prompt
+	if m.trim {
+		prompt = strings.TrimSpace(prompt)
+	}
+	if m.template != "" {
+		prompt = strings.ReplaceAll(m.template, "{{prompt}}", prompt)
+	}
+	req.Options.Prompt = m.prefix + prompt + m.suffix
+	if m.systemAppend != "" {
+		if req.Options.SystemPrompt == "" {
+			req.Options.SystemPrompt = m.systemAppend
+		} else {
+			req.Options.SystemPrompt = req.Options.SystemPrompt + "\n\n" + m.systemAppend
+		}
+	}
+	return next(req)
+}
+
+var thinkBlockPattern = regexp.MustCompile(`(?s)<think>.*?</think>`)
+
+// responsePostprocessMiddleware 对 response 字段做后处理
+// 配置：trim（默认 true）、strip_think、max_length、prefix、suffix
+type responsePostprocessMiddleware struct {
+	trim       bool
+	stripThink bool
+	maxLength  int
+	prefix     string
+	suffix     string
+}
+
+func newResponsePostprocessMiddleware(cfg MiddlewareConfig) (PipelineMiddleware, error) {
+	maxLength := configInt(cfg.Config, "max_length", 0)
+	if maxLength < 0 {
+		return nil, fmt.Errorf("max_length cannot be negative")
+	}
+	return &responsePostprocessMiddleware{
+		trim:       configBool(cfg.Config, "trim", true),
+		stripThink: configBool(cfg.Config, "strip_think", false),
+		maxLength:  maxLength,
+		prefix:     cfg.Config["prefix"],
+		suffix:     cfg.Config["suffix"],
+	}, nil
+}
+
+func (m *responsePostprocessMiddleware) Name() string {
+	return "response_postprocess"
+}
+
+func (m *responsePostprocessMiddleware) Handle(req *PipelineRequest, next PipelineHandler) (string, error) {
+	result, err := next(req)
+	if err != nil {
+		return result, err
+	}
+	return rewriteOutputResponse(result, m.process), nil
+}
+
+func (m *responsePostprocessMiddleware) process(response string) string {
+	if m.stripThink {
+		response = thinkBlockPattern.ReplaceAllString(response, "")
+	}
+	if m.trim {
+		response = strings.TrimSpace(response)
+	}
+	if m.maxLength > 0 {
+		runes := []rune(response)
+		if len(runes) > m.maxLength {
+			response = string(runes[:m.maxLength])
+		}
+	}
+	return m.prefix + response + m.suffix
+}
+
+var piiPatterns = map[string]*regexp.Regexp{
+	"email":     regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}`),
+	"id_card":   regexp.MustCompile(`\b\d{17}[\dXx]\b`),
+	"bank_card": regexp.MustCompile(`\b(?:\d[ \-]?){15,18}\d\b`),
+	"phone":     regexp.MustCompile(`(?:\+86[ \-]?|\b)1[3-9]\d{9}\b`),
+	"ipv4":      regexp.MustCompile(`\b(?:\d{1,3}\.){3}\d{1,3}\b`),
+}
+
+// piiPatternOrder 按从长到短的顺序匹配，避免身份证号被手机号规则截断
+var piiPatternOrder = []string{"email", "id_card", "bank_card", "phone", "ipv4"}
+
+// piiRedactionMiddleware 对 prompt 和/或 response 中的个人敏感信息脱敏
+// 配置：targets（prompt,response）、patterns（逗号分隔，默认全部）、replacement
+type piiRedactionMiddleware struct {
+	redactPrompt   bool
+	redactResponse bool
+	patterns       []string
+	replacement    string
+}
+
+func newPIIRedactionMiddleware(cfg MiddlewareConfig) (PipelineMiddleware, error) {
+	m := &piiRedactionMiddleware{replacement: cfg.Config["replacement"]}
+
+	targets := splitConfigList(cfg.Config["targets"])
+	if len(targets) == 0 {
+		targets = []string{"prompt", "response"}
+	}
+	for _, target := range targets {
+		switch target {
+		case "prompt":
+			m.redactPrompt = true
+		case "response":
+			m.redactResponse = true
+		default:
+			return nil, fmt.Errorf("unknown target: %s", target)
+		}
+	}
+
+	enabled := splitConfigList(cfg.Config["patterns"])
+	for _, name := range piiPatternOrder {
+		if len(enabled) == 0 || containsString(enabled, name) {
+			m.patterns = append(m.patterns, name)
+		}
+	}
+	for _, name := range enabled {
+		if _, ok := piiPatterns[name]; !ok {
+			return nil, fmt.Errorf("unknown pattern: %s", name)
+		}
+	}
+	return m, nil
+}
+
+func (m *piiRedactionMiddleware) Name() string {
+	return "pii_redaction"
+}
+
+func (m *piiRedactionMiddleware) Handle(req *PipelineRequest, next PipelineHandler) (string, error) {
+	if m.redactPrompt {
+		req.Options.Prompt = m.redact(req.Options.Prompt)
+	}
+	result, err := next(req)
+	if err != nil || !m.redactResponse {
+		return result, err
+	}
+	return rewriteOutputResponse(result, m.redact), nil
+}
+
+func (m *piiRedactionMiddleware) redact(text string) string {
+	for _, name := range m.patterns {
+		replacement := m.replacement
+		if replacement == "" {
+			replacement = "[REDACTED:" + name + "]"
+		}
+		text = piiPatterns[name].ReplaceAllString(text, replacement)
+	}
+	return text
+}
+
+// 配置辅助函数（MiddlewareConfig.Config 为 map[string]string）
+
+func configInt(cfg map[string]string, key string, defaultValue int) int {
+	if value, ok := cfg[key]; ok && value != "" {
+		if parsed, err := strconv.Atoi(strings.TrimSpace(value)); err == nil {
+			return parsed
+		}
+	}
+	return defaultValue
+}
+
+func configBool(cfg map[string]string, key string, defaultValue bool) bool {
+	if value, ok := cfg[key]; ok && value != "" {
+		if parsed, err := strconv.ParseBool(strings.TrimSpace(value)); err == nil {
+			return parsed
+		}
+	}
+	return defaultValue
+}
+
+func splitConfigList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if trimmed := strings.TrimSpace(item); trimmed != "" {
+			items = append(items, trimmed)
+		}
+	}
+	return items
+}
+
+func containsString(items []string, target string) bool {
+	for _, item := range items {
+		if item == target {
+			return true
+		}
+	}
+	return false
+}
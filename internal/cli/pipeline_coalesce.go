@@ -0,0 +1,166 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sync"
+
+	"dify-cli-gateway/internal/response_cache"
+)
+
+// coalesceCall 一次进行中的 CLI 执行，相同请求的调用方共享其结果
+type coalesceCall struct {
+	done    chan struct{}
+	result  string
+	err     error
+	waiters int // 等待方数量（不含发起方）
+
+	// 共享执行使用独立的上下文，只有当所有调用方（含发起方）都离开后才取消
+	ctx    context.Context
+	cancel context.CancelFunc
+	refs   int
+}
+
+// leave 调用方断开时减少引用，最后一个离开时取消共享执行（需持有 group.mu）
+func (c *coalesceCall) leave() {
+	c.refs--
+	if c.refs == 0 {
+		c.cancel()
+	}
+}
+
+// coalesceGroup 进程内的进行中请求表（管道按请求构建，因此必须是全局共享）
+type coalesceGroup struct {
+	mu    sync.Mutex
+	calls map[string]*coalesceCall
+}
+
+var gatewayCoalesceGroup = &coalesceGroup{calls: make(map[string]*coalesceCall)}
+
+// InFlightCoalesced 返回当前进行中的可合并请求数
+func InFlightCoalesced() int {
+	gatewayCoalesceGroup.mu.Lock()
+	defer gatewayCoalesceGroup.mu.Unlock()
+	return len(gatewayCoalesceGroup.calls)
+}
+
+func init() {
+	RegisterPipelineMiddleware("coalesce", func(cfg MiddlewareConfig) (PipelineMiddleware, error) {
+		return &coalescePipelineMiddleware{group: gatewayCoalesceGroup, collector: gatewayMetrics}, nil
+	})
+}
+
+// coalescePipelineMiddleware 合并相同的无会话并发请求，只启动一个 CLI 进程
+type coalescePipelineMiddleware struct {
+	group     *coalesceGroup
+	collector *MetricsCollector
+}
+
+func (m *coalescePipelineMiddleware) Name() string {
+	return "coalesce"
+}
+
+func (m *coalescePipelineMiddleware) Handle(req *PipelineRequest, next PipelineHandler) (string, error) {
+	// 有会话上下文的请求依赖历史，不能合并
+	if req.Options.SessionID != "" || req.Meta("workflow_run_id") != "" {
+		return next(req)
+	}
+
+	key := req.Profile + ":" + response_cache.Key(response_cache.KeyInput{
+		CLI:            req.CLI,
+		Model:          req.Options.Model,
+		Prompt:         req.Options.Prompt,
+		System:         req.Options.SystemPrompt,
+		Tools:          req.Options.AllowedTools,
+		Skills:         req.Options.Skills,
+		PermissionMode: req.Options.PermissionMode,
+		Steps:          req.Options.CaptureSteps,
+	})
+
+	ctx := req.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	m.group.mu.Lock()
+	if call, ok := m.group.calls[key]; ok {
+		call.waiters++
+		call.refs++
+		m.group.mu.Unlock()
+		log.Printf("🔗 [Pipeline:Coalesce] Joined in-flight request: cli=%s profile=%s", req.CLI, req.Profile)
+		select {
+		case <-call.done:
+		case <-ctx.Done():
+			m.group.mu.Lock()
+			call.waiters--
+			call.leave()
+			m.group.mu.Unlock()
+			return "", ctx.Err()
+		}
+		if m.collector != nil {
+			m.collector.RecordCoalesced(req.CLI)
+		}
+		req.SetMeta("coalesced", "true")
+		req.SetMeta("short_circuit", m.Name())
+		return SharedOutput(call.result), call.err
+	}
+	call := &coalesceCall{done: make(chan struct{}), refs: 1}
+	call.ctx, call.cancel = context.WithCancel(context.Background())
+	m.group.calls[key] = call
+	m.group.mu.Unlock()
+
+	// 发起方断开只减少引用，仍在等待的调用方继续获得结果
+	leaderDone := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			m.group.mu.Lock()
+			call.leave()
+			m.group.mu.Unlock()
+		case <-leaderDone:
+		}
+	}()
+
+	defer func() {
+		close(leaderDone)
+		m.group.mu.Lock()
+		delete(m.group.calls, key)
+		waiters := call.waiters
+		m.group.mu.Unlock()
+		call.cancel()
+		close(call.done)
+		if waiters > 0 {
+			log.Printf("🔗 [Pipeline:Coalesce] Shared result with %d waiting request(s): cli=%s profile=%s", waiters, req.CLI, req.Profile)
+		}
+	}()
+
+	// 准入排队等后续中间件使用共享上下文；发起方自己的请求对象在返回前恢复
+	original := req.Context
+	req.Context = call.ctx
+	defer func() { req.Context = original }()
+
+	// 执行异常退出（panic）时，等待方收到错误而不是空结果
+	call.err = fmt.Errorf("coalesced request aborted")
+	call.result, call.err = next(req)
+	return call.result, call.err
+}
+
+// SharedOutput 去掉属于发起方的 session_id 与 steps：合并请求的等待方与命中缓存的调用方
+// 不能共享（或续接）发起方的 CLI 会话，也不能看到其工具调用记录
+func SharedOutput(output string) string {
+	var payload map[string]interface{}
+	if err := json.Unmarshal([]byte(output), &payload); err != nil {
+		return output
+	}
+	if _, ok := payload["session_id"]; ok {
+		payload["session_id"] = ""
+	}
+	delete(payload, "steps")
+	jsonBytes, err := json.Marshal(payload)
+	if err != nil {
+		return output
+	}
+	return string(jsonBytes)
+}
@@ -0,0 +1,464 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// recordingMiddleware 记录执行顺序，可选择短路
+type recordingMiddleware struct {
+	name   string
+	trace  *[]string
+	stopAt bool
+}
+
+func (m *recordingMiddleware) Name() string {
+	return m.name
+}
+
+func (m *recordingMiddleware) Handle(req *PipelineRequest, next PipelineHandler) (string, error) {
+	*m.trace = append(*m.trace, "before:"+m.name)
+	if m.stopAt {
+		return `{"response":"short-circuit"}`, nil
+	}
+	result, err := next(req)
+	*m.trace = append(*m.trace, "after:"+m.name)
+	return result, err
+}
+
+// TestPipeline_Order 测试中间件按洋葱模型执行
+func TestPipeline_Order(t *testing.T) {
+	var trace []string
+	pipeline := NewPipeline(
+		&recordingMiddleware{name: "a", trace: &trace},
+		&recordingMiddleware{name: "b", trace: &trace},
+	)
+	runner := &mockCLIRunner{name: "mock", output: `{"response":"ok"}`}
+
+	result, err := pipeline.Run(runner, &PipelineRequest{Options: &RunOptions{Prompt: "hi"}})
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if result != `{"response":"ok"}` {
+		t.Errorf("Unexpected result: %s", result)
+	}
+
+	expected := "before:a,before:b,after:b,after:a"
+	if strings.Join(trace, ",") != expected {
+		t.Errorf("Expected order %s, got %s", expected, strings.Join(trace, ","))
+	}
+}
+
+// TestPipeline_ShortCircuit 测试短路时不执行 runner
+func TestPipeline_ShortCircuit(t *testing.T) {
+	var trace []string
+	pipeline := NewPipeline(
+		&recordingMiddleware{name: "a", trace: &trace},
+		&recordingMiddleware{name: "stop", trace: &trace, stopAt: true},
+		&recordingMiddleware{name: "c", trace: &trace},
+	)
+	runner := &mockCLIRunner{name: "mock", err: errors.New("runner should not be called")}
+
+	result, err := pipeline.Run(runner, &PipelineRequest{})
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if !strings.Contains(result, "short-circuit") {
+		t.Errorf("Expected short-circuit result, got %s", result)
+	}
+	if strings.Join(trace, ",") != "before:a,before:stop,after:a" {
+		t.Errorf("Unexpected trace: %v", trace)
+	}
+}
+
+// TestBuildPipeline 测试根据配置构建管道
+func TestBuildPipeline(t *testing.T) {
+	pipeline, err := BuildPipeline([]MiddlewareConfig{
+		{Name: "timing", Enabled: true},
+		{Name: "logging", Enabled: false},
+		{Name: "retry", Enabled: true, Config: map[string]string{"max_retries": "2"}},
+	})
+	if err != nil {
+		t.Fatalf("BuildPipeline failed: %v", err)
+	}
+	if strings.Join(pipeline.Names(), ",") != "timing,retry" {
+		t.Errorf("Unexpected middlewares: %v", pipeline.Names())
+	}
+
+	if _, err := BuildPipeline([]MiddlewareConfig{{Name: "unknown", Enabled: true}}); err == nil {
+		t.Error("Expected error for unknown middleware")
+	}
+	if _, err := BuildPipeline([]MiddlewareConfig{{Name: "retry", Enabled: true, Config: map[string]string{"max_retries": "50"}}}); err == nil {
+		t.Error("Expected error for invalid retry config")
+	}
+}
+
+// TestPipeline_PromptRewriteAndPostprocess 测试 prompt 改写与响应后处理
+func TestPipeline_PromptRewriteAndPostprocess(t *testing.T) {
+	pipeline, err := BuildPipeline([]MiddlewareConfig{
+		{Name: "prompt_rewrite", Enabled: true, Config: map[string]string{"template": "Q: {{prompt}}", "system_append": "be brief"}},
+		{Name: "response_postprocess", Enabled: true, Config: map[string]string{"strip_think": "true", "max_length": "5"}},
+	})
+	if err != nil {
+		t.Fatalf("BuildPipeline failed: %v", err)
+	}
+
+	var seen *RunOptions
+	runner := &optionsCapturingRunner{output: `{"session_id":"s1","response":"<think>hmm</think>  hello world  "}`, seen: &seen}
+	result, err := pipeline.Run(runner, &PipelineRequest{Options: &RunOptions{Prompt: "hi"}})
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	if seen.Prompt != "Q: hi" {
+		t.Errorf("Expected rewritten prompt, got %q", seen.Prompt)
+	}
+	if seen.SystemPrompt != "be brief" {
+		t.Errorf("Expected system prompt appended, got %q", seen.SystemPrompt)
+	}
+
+	var output map[string]interface{}
+	if err := json.Unmarshal([]byte(result), &output); err != nil {
+		t.Fatalf("Invalid output JSON: %v", err)
+	}
+	if output["response"] != "hello" {
+		t.Errorf("Expected post-processed response 'hello', got %v", output["response"])
+	}
+	if output["session_id"] != "s1" {
+		t.Errorf("Expected session_id preserved, got %v", output["session_id"])
+	}
+}
+
+// TestPipeline_PIIRedaction 测试 PII 脱敏
+func TestPipeline_PIIRedaction(t *testing.T) {
+	pipeline, err := BuildPipeline([]MiddlewareConfig{
+		{Name: "pii_redaction", Enabled: true},
+	})
+	if err != nil {
+		t.Fatalf("BuildPipeline failed: %v", err)
+	}
+
+	var seen *RunOptions
+	runner := &optionsCapturingRunner{output: `{"response":"mail me at bob@example.com"}`, seen: &seen}
+	result, err := pipeline.Run(runner, &PipelineRequest{Options: &RunOptions{Prompt: "call 13812345678 or +8613912345678, id 11010519491231002X"}})
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	if strings.Contains(seen.Prompt, "13812345678") || strings.Contains(seen.Prompt, "13912345678") {
+		t.Errorf("Expected phone redacted, got %q", seen.Prompt)
+	}
+	if !strings.Contains(seen.Prompt, "[REDACTED:id_card]") {
+		t.Errorf("Expected id card redacted, got %q", seen.Prompt)
+	}
+	if strings.Contains(result, "bob@example.com") {
+		t.Errorf("Expected email redacted in response, got %s", result)
+	}
+
+	if _, err := BuildPipeline([]MiddlewareConfig{{Name: "pii_redaction", Enabled: true, Config: map[string]string{"patterns": "ssn"}}}); err == nil {
+		t.Error("Expected error for unknown pattern")
+	}
+}
+
+// TestPipeline_TimingMetadata 测试耗时元数据
+func TestPipeline_TimingMetadata(t *testing.T) {
+	pipeline, err := BuildPipeline([]MiddlewareConfig{{Name: "timing", Enabled: true}})
+	if err != nil {
+		t.Fatalf("BuildPipeline failed: %v", err)
+	}
+
+	req := &PipelineRequest{}
+	result, err := pipeline.Run(&mockCLIRunner{name: "mock", output: `{"response":"ok"}`}, req)
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if req.Meta("duration_ms") == "" {
+		t.Error("Expected duration_ms in request metadata")
+	}
+	if !strings.Contains(result, `"duration_ms"`) {
+		t.Errorf("Expected duration_ms in output metadata, got %s", result)
+	}
+}
+
+type optionsCapturingRunner struct {
+	output string
+	seen   **RunOptions
+}
+
+func (r *optionsCapturingRunner) Name() string {
+	return "capture"
+}
+
+func (r *optionsCapturingRunner) Run(opts *RunOptions) (string, error) {
+	*r.seen = opts
+	return r.output, nil
+}
+
+// blockingRunner 阻塞直到 release 关闭，用于构造并发请求
+type blockingRunner struct {
+	calls   atomic.Int32
+	release chan struct{}
+}
+
+func (r *blockingRunner) Name() string {
+	return "blocking"
+}
+
+func (r *blockingRunner) Run(opts *RunOptions) (string, error) {
+	r.calls.Add(1)
+	<-r.release
+	return `{"response":"shared"}`, nil
+}
+
+// TestPipeline_Coalesce 测试相同的无会话并发请求只执行一次
+func TestPipeline_Coalesce(t *testing.T) {
+	pipeline, err := BuildPipeline([]MiddlewareConfig{{Name: "coalesce", Enabled: true}})
+	if err != nil {
+		t.Fatalf("BuildPipeline failed: %v", err)
+	}
+	runner := &blockingRunner{release: make(chan struct{})}
+
+	const callers = 5
+	var wg sync.WaitGroup
+	results := make([]string, callers)
+	for i := 0; i < callers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i], _ = pipeline.Run(runner, &PipelineRequest{Profile: "p", Options: &RunOptions{Prompt: "same"}})
+		}(i)
+	}
+
+	// 等待所有调用方进入：1 个执行中，其余在等待
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		gatewayCoalesceGroup.mu.Lock()
+		waiters := 0
+		for _, call := range gatewayCoalesceGroup.calls {
+			waiters = call.waiters
+		}
+		gatewayCoalesceGroup.mu.Unlock()
+		if waiters == callers-1 || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	close(runner.release)
+	wg.Wait()
+
+	if runner.calls.Load() != 1 {
+		t.Errorf("Expected 1 execution, got %d", runner.calls.Load())
+	}
+	for i, result := range results {
+		if result != `{"response":"shared"}` {
+			t.Errorf("Caller %d got unexpected result: %s", i, result)
+		}
+	}
+
+	// 带会话的请求不合并
+	sessionRunner := &mockCLIRunner{name: "mock", output: `{"response":"ok"}`}
+	req := &PipelineRequest{Profile: "p", Options: &RunOptions{Prompt: "same", SessionID: "s1"}}
+	if _, err := pipeline.Run(sessionRunner, req); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if req.Meta("coalesced") != "" {
+		t.Error("Expected session request not to be coalesced")
+	}
+}
+
+// sessionBlockingRunner 与 blockingRunner 相同，但输出带有会话与步骤
+type sessionBlockingRunner struct {
+	blockingRunner
+}
+
+func (r *sessionBlockingRunner) Run(opts *RunOptions) (string, error) {
+	r.blockingRunner.Run(opts)
+	return `{"session_id":"leader","response":"shared","steps":[{"tool":"Bash"}]}`, nil
+}
+
+// TestPipeline_CoalesceWaiters 测试等待方拿不到发起方的会话，且客户端断开时不再等待
+func TestPipeline_CoalesceWaiters(t *testing.T) {
+	pipeline, err := BuildPipeline([]MiddlewareConfig{{Name: "coalesce", Enabled: true}})
+	if err != nil {
+		t.Fatalf("BuildPipeline failed: %v", err)
+	}
+	runner := &sessionBlockingRunner{blockingRunner{release: make(chan struct{})}}
+	waitForWaiters := func(n int) {
+		deadline := time.Now().Add(2 * time.Second)
+		for time.Now().Before(deadline) {
+			gatewayCoalesceGroup.mu.Lock()
+			waiters := 0
+			for _, call := range gatewayCoalesceGroup.calls {
+				waiters = call.waiters
+			}
+			gatewayCoalesceGroup.mu.Unlock()
+			if waiters == n {
+				return
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+		t.Fatalf("expected %d waiters", n)
+	}
+
+	leader := make(chan string)
+	go func() {
+		result, _ := pipeline.Run(runner, &PipelineRequest{Profile: "p", Options: &RunOptions{Prompt: "waiters"}})
+		leader <- result
+	}()
+	for runner.calls.Load() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	canceled := make(chan error)
+	go func() {
+		_, err := pipeline.Run(runner, &PipelineRequest{Profile: "p", Options: &RunOptions{Prompt: "waiters"}, Context: ctx})
+		canceled <- err
+	}()
+	waitForWaiters(1)
+	cancel()
+	if err := <-canceled; !errors.Is(err, context.Canceled) {
+		t.Errorf("canceled waiter should return its context error, got %v", err)
+	}
+
+	follower := make(chan string)
+	go func() {
+		result, _ := pipeline.Run(runner, &PipelineRequest{Profile: "p", Options: &RunOptions{Prompt: "waiters"}})
+		follower <- result
+	}()
+	waitForWaiters(1)
+	close(runner.release)
+
+	if result := <-leader; !strings.Contains(result, `"session_id":"leader"`) {
+		t.Errorf("leader should keep its session: %s", result)
+	}
+	if result := <-follower; result != `{"response":"shared","session_id":""}` {
+		t.Errorf("waiter should not receive the leader's session or steps: %s", result)
+	}
+}
+
+// queueMiddleware 模拟准入排队：在 release 之前阻塞，请求上下文取消时返回其错误
+type queueMiddleware struct {
+	entered chan struct{}
+	release chan struct{}
+}
+
+func (m *queueMiddleware) Name() string {
+	return "queue"
+}
+
+func (m *queueMiddleware) Handle(req *PipelineRequest, next PipelineHandler) (string, error) {
+	m.entered <- struct{}{}
+	select {
+	case <-m.release:
+		return `{"response":"shared","session_id":"leader"}`, nil
+	case <-req.Context.Done():
+		return "", req.Context.Err()
+	}
+}
+
+func TestPipeline_CoalesceSurvivesLeaderCancel(t *testing.T) {
+	pipeline, err := BuildPipeline([]MiddlewareConfig{{Name: "coalesce", Enabled: true}})
+	if err != nil {
+		t.Fatalf("BuildPipeline failed: %v", err)
+	}
+	queue := &queueMiddleware{entered: make(chan struct{}, 1), release: make(chan struct{})}
+	pipeline.Use(queue)
+	run := func(ctx context.Context, prompt string) chan error {
+		done := make(chan error, 1)
+		go func() {
+			result, err := pipeline.Run(&sessionBlockingRunner{}, &PipelineRequest{Profile: "p", Options: &RunOptions{Prompt: prompt}, Context: ctx})
+			if err == nil && !strings.Contains(result, `"response":"shared"`) {
+				err = fmt.Errorf("unexpected result %s", result)
+			}
+			done <- err
+		}()
+		return done
+	}
+	waitForJoin := func() {
+		for deadline := time.Now().Add(2 * time.Second); time.Now().Before(deadline); time.Sleep(5 * time.Millisecond) {
+			gatewayCoalesceGroup.mu.Lock()
+			joined := 0
+			for _, call := range gatewayCoalesceGroup.calls {
+				joined = call.waiters
+			}
+			gatewayCoalesceGroup.mu.Unlock()
+			if joined == 1 {
+				return
+			}
+		}
+		t.Fatal("follower did not join")
+	}
+
+	// 发起方断开后，仍在等待的调用方照常拿到结果
+	leaderCtx, cancelLeader := context.WithCancel(context.Background())
+	leader := run(leaderCtx, "leader-cancel")
+	<-queue.entered
+	follower := run(context.Background(), "leader-cancel")
+	waitForJoin()
+	cancelLeader()
+	time.Sleep(20 * time.Millisecond)
+	close(queue.release)
+	if err := <-follower; err != nil {
+		t.Errorf("follower should still get the shared result: %v", err)
+	}
+	<-leader
+
+	// 所有调用方都断开后才取消共享执行
+	queue.release = make(chan struct{})
+	aloneCtx, cancelAlone := context.WithCancel(context.Background())
+	alone := run(aloneCtx, "alone")
+	<-queue.entered
+	cancelAlone()
+	if err := <-alone; !errors.Is(err, context.Canceled) {
+		t.Errorf("shared execution should be canceled once every caller left, got %v", err)
+	}
+}
+
+// flakyRunner 前 failures 次调用失败，记录每次看到的 prompt
+type flakyRunner struct {
+	failures int
+	prompts  []string
+}
+
+func (r *flakyRunner) Name() string {
+	return "flaky"
+}
+
+func (r *flakyRunner) Run(opts *RunOptions) (string, error) {
+	r.prompts = append(r.prompts, opts.Prompt)
+	if len(r.prompts) <= r.failures {
+		return "", errors.New("transient")
+	}
+	return `{"response":"ok"}`, nil
+}
+
+// TestPipeline_RetryRestoresOptions 测试重试不会叠加 prompt 改写，且不重放续接会话的调用
+func TestPipeline_RetryRestoresOptions(t *testing.T) {
+	pipeline, err := BuildPipeline([]MiddlewareConfig{
+		{Name: "retry", Enabled: true, Config: map[string]string{"max_retries": "3", "backoff_ms": "1"}},
+		{Name: "prompt_rewrite", Enabled: true, Config: map[string]string{"prefix": "Q: "}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	runner := &flakyRunner{failures: 2}
+	if _, err := pipeline.Run(runner, &PipelineRequest{Options: &RunOptions{Prompt: "hi"}}); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(runner.prompts, "|") != "Q: hi|Q: hi|Q: hi" {
+		t.Errorf("each attempt should see the same rewritten prompt, got %q", runner.prompts)
+	}
+
+	resumed := &flakyRunner{failures: 1}
+	if _, err := pipeline.Run(resumed, &PipelineRequest{Options: &RunOptions{Prompt: "hi", SessionID: "s1"}}); err == nil || len(resumed.prompts) != 1 {
+		t.Errorf("resumed sessions should not be retried, attempts=%d err=%v", len(resumed.prompts), err)
+	}
+}
@@ -0,0 +1,129 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"strings"
+)
+
+// PromptDelivery prompt 传给 CLI 进程的方式
+type PromptDelivery string
+
+const (
+	PromptDeliveryAuto  PromptDelivery = "auto"  // 默认：prompt 过长或以 "-" 开头时改用 stdin
+	PromptDeliveryArg   PromptDelivery = "arg"   // 命令行参数
+	PromptDeliveryStdin PromptDelivery = "stdin" // 标准输入
+)
+
+// DefaultPromptArgMaxBytes auto 模式下作为参数传递的 prompt 上限
+const DefaultPromptArgMaxBytes = 32 * 1024
+
+// promptSupport 描述 CLI 支持的 prompt 传递方式
+type promptSupport struct {
+	Stdin bool
+}
+
+// preparedPrompt 已确定传递方式的 prompt
+type preparedPrompt struct {
+	Delivery PromptDelivery
+	Arg      string    // 作为参数传入的内容（stdin 模式下为空）
+	Stdin    io.Reader // stdin 模式下的输入
+}
+
+// ParsePromptDelivery 解析 prompt 传递方式，空值为 auto
+func ParsePromptDelivery(value string) (PromptDelivery, error) {
+	switch PromptDelivery(strings.ToLower(strings.TrimSpace(value))) {
+	case "", PromptDeliveryAuto:
+		return PromptDeliveryAuto, nil
+	case PromptDeliveryArg:
+		return PromptDeliveryArg, nil
+	case PromptDeliveryStdin:
+		return PromptDeliveryStdin, nil
+	default:
+		return "", fmt.Errorf("unsupported prompt delivery: %s", value)
+	}
+}
+
+// promptArgLimit auto 模式下作为参数传递的 prompt 上限
+func promptArgLimit(opts *RunOptions) int {
+	if opts.PromptArgMaxBytes > 0 {
+		return opts.PromptArgMaxBytes
+	}
+	return DefaultPromptArgMaxBytes
+}
+
+// promptFitsArg prompt 能否安全地作为命令行参数传递
+func promptFitsArg(opts *RunOptions) bool {
+	return len(opts.Prompt) <= promptArgLimit(opts) && !strings.HasPrefix(opts.Prompt, "-")
+}
+
+// resolvePromptDelivery 根据配置、prompt 内容与 CLI 能力选择传递方式
+func resolvePromptDelivery(tag string, opts *RunOptions, support promptSupport) PromptDelivery {
+	requested, err := ParsePromptDelivery(opts.PromptDelivery)
+	if err != nil {
+		log.Printf("⚠️  [%s] %v, falling back to auto", tag, err)
+		requested = PromptDeliveryAuto
+	}
+
+	if requested == PromptDeliveryAuto && promptFitsArg(opts) {
+		return PromptDeliveryArg
+	}
+	switch {
+	case requested == PromptDeliveryArg:
+		return PromptDeliveryArg
+	case support.Stdin:
+		// stdin 以及 auto 下过长或以 "-" 开头的 prompt
+		return PromptDeliveryStdin
+	}
+	log.Printf("⚠️  [%s] Prompt delivery %q not supported, passing prompt as argument", tag, requested)
+	return PromptDeliveryArg
+}
+
+// preparePrompt 按选定方式准备 prompt；CLI 不支持 stdin 且 prompt 无法作为参数传递时返回错误，
+// 而不是把 prompt 改写成“读取某个文件”的间接指令
+func preparePrompt(tag string, opts *RunOptions, support promptSupport) (*preparedPrompt, error) {
+	delivery := resolvePromptDelivery(tag, opts, support)
+	prepared := &preparedPrompt{Delivery: delivery}
+
+	if delivery == PromptDeliveryStdin {
+		prepared.Stdin = strings.NewReader(opts.Prompt)
+		log.Printf("📝 [%s] Sending prompt via %s (%d bytes)", tag, delivery, len(opts.Prompt))
+		return prepared, nil
+	}
+	explicitArg := strings.EqualFold(strings.TrimSpace(opts.PromptDelivery), string(PromptDeliveryArg))
+	if !support.Stdin && !explicitArg && !promptFitsArg(opts) {
+		if strings.HasPrefix(opts.Prompt, "-") {
+			return nil, fmt.Errorf("%s cannot read the prompt from stdin and a prompt starting with \"-\" would be parsed as an option", strings.ToLower(tag))
+		}
+		return nil, fmt.Errorf("prompt is %d bytes, over the %d-byte argument limit, and %s cannot read the prompt from stdin; shorten the prompt or raise prompt_arg_max_bytes",
+			len(opts.Prompt), promptArgLimit(opts), strings.ToLower(tag))
+	}
+	prepared.Arg = opts.Prompt
+	return prepared, nil
+}
+
+// redactCommand 返回用于日志的命令行，prompt 与系统提示词替换为长度占位
+func redactCommand(bin string, args []string, opts *RunOptions) string {
+	redacted := make([]string, len(args))
+	for i, arg := range args {
+		switch {
+		case opts.Prompt != "" && arg == opts.Prompt:
+			redacted[i] = fmt.Sprintf("<prompt: %d bytes>", len(arg))
+		case opts.SystemPrompt != "" && arg == opts.SystemPrompt:
+			redacted[i] = fmt.Sprintf("<system-prompt: %d bytes>", len(arg))
+		default:
+			redacted[i] = arg
+		}
+	}
+	return strings.TrimSpace(bin + " " + strings.Join(redacted, " "))
+}
+
+func firstError(errs ...error) error {
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,70 @@
+package cli
+
+import (
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestResolvePromptDelivery(t *testing.T) {
+	long := strings.Repeat("x", 64)
+	cases := []struct {
+		name    string
+		opts    RunOptions
+		support promptSupport
+		want    PromptDelivery
+	}{
+		{"short prompt stays arg", RunOptions{Prompt: "hello"}, promptSupport{Stdin: true}, PromptDeliveryArg},
+		{"long prompt uses stdin", RunOptions{Prompt: long, PromptArgMaxBytes: 32}, promptSupport{Stdin: true}, PromptDeliveryStdin},
+		{"dash prompt uses stdin", RunOptions{Prompt: "-v explain"}, promptSupport{Stdin: true}, PromptDeliveryStdin},
+		{"unsupported falls back to arg", RunOptions{Prompt: long, PromptArgMaxBytes: 32}, promptSupport{}, PromptDeliveryArg},
+		{"explicit arg", RunOptions{Prompt: long, PromptArgMaxBytes: 32, PromptDelivery: "arg"}, promptSupport{Stdin: true}, PromptDeliveryArg},
+		{"explicit stdin without support", RunOptions{Prompt: "hi", PromptDelivery: "stdin"}, promptSupport{}, PromptDeliveryArg},
+	}
+	for _, tc := range cases {
+		opts := tc.opts
+		if got := resolvePromptDelivery("Test", &opts, tc.support); got != tc.want {
+			t.Errorf("%s: got %s, want %s", tc.name, got, tc.want)
+		}
+	}
+
+	if _, err := ParsePromptDelivery("file"); err == nil {
+		t.Error("file prompt delivery was never implemented and should be rejected")
+	}
+}
+
+func TestPreparePrompt_StdinAndUnsupported(t *testing.T) {
+	opts := &RunOptions{Prompt: "--- long prompt ---", PromptDelivery: "stdin"}
+	prepared, err := preparePrompt("Test", opts, promptSupport{Stdin: true})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	data, _ := io.ReadAll(prepared.Stdin)
+	if prepared.Arg != "" || string(data) != opts.Prompt {
+		t.Errorf("unexpected stdin delivery: arg=%q stdin=%q", prepared.Arg, data)
+	}
+
+	// 不支持 stdin 的 CLI（cursor-agent）：无法作为参数传递时报错，而不是间接通过临时文件
+	long := &RunOptions{Prompt: strings.Repeat("x", 64), PromptArgMaxBytes: 32}
+	if _, err := preparePrompt("Cursor", long, promptSupport{}); err == nil || !strings.Contains(err.Error(), "prompt_arg_max_bytes") {
+		t.Errorf("oversized prompt without stdin support should be rejected, got %v", err)
+	}
+	if _, err := preparePrompt("Cursor", &RunOptions{Prompt: "-v explain"}, promptSupport{}); err == nil {
+		t.Error("dash prompt without stdin support should be rejected")
+	}
+	long.PromptDelivery = "arg"
+	if prepared, err := preparePrompt("Cursor", long, promptSupport{}); err != nil || prepared.Arg != long.Prompt {
+		t.Errorf("explicit arg delivery should pass the prompt through: %v", err)
+	}
+}
+
+func TestRedactCommand(t *testing.T) {
+	opts := &RunOptions{Prompt: "secret question", SystemPrompt: "secret system"}
+	got := redactCommand("claude", []string{"-p", "secret question", "--append-system-prompt", "secret system"}, opts)
+	if strings.Contains(got, "secret") {
+		t.Errorf("command not redacted: %s", got)
+	}
+	if !strings.Contains(got, "<prompt: 15 bytes>") {
+		t.Errorf("missing prompt placeholder: %s", got)
+	}
+}
@@ -16,6 +16,7 @@ type QwenOutput struct {
 	Response string `json:"response,omitempty"`
 	Stats    struct {
 		Models map[string]interface{} `json:"models,omitempty"`
+		Tools  *toolCallStats         `json:"tools,omitempty"`
 	} `json:"stats,omitempty"`
 }
 
@@ -53,15 +54,35 @@ func (q *QwenCLI) Run(opts *RunOptions) (string, error) {
 		log.Printf("🔧 [Qwen] Allowed tools: %v", opts.AllowedTools)
 	}
 
-	// 添加 prompt（作为位置参数）
-	args = append(args, opts.Prompt)
+	// profile 的 mcp_servers（写入临时系统 settings，调用结束后删除）
+	mcpArgs, mcpEnv, mcpCleanup, err := applyGeminiMCPConfig("Qwen", qwenSystemSettingsEnv, opts)
+	if err != nil {
+		return "", err
+	}
+	defer mcpCleanup()
+	args = append(args, mcpArgs...)
 
-	log.Printf("⚙️  [Qwen] Executing: qwen %s", strings.Join(args, " "))
+	// 添加 prompt（作为位置参数；过长或以 "-" 开头时通过 stdin 传入）
+	prompt, err := preparePrompt("Qwen", opts, promptSupport{Stdin: true})
+	if err != nil {
+		return "", err
+	}
+	if prompt.Arg != "" {
+		args = append(args, prompt.Arg)
+	}
+
+	log.Printf("⚙️  [Qwen] Executing: %s", redactCommand("qwen", args, opts))
 
 	cmd := exec.Command("qwen", args...)
-	cmd.Env = buildEnv(opts.Env)
+	cmd.Env = mergeEnv(buildEnv(opts.EnvPolicy, opts.Env), mcpEnv...)
+	cmd.Stdin = prompt.Stdin
+	finish, err := prepareCommand("Qwen", cmd, opts)
+	if err != nil {
+		return "", err
+	}
+	defer finish()
 
-	output, err := cmd.CombinedOutput()
+	output, err := combinedOutput(cmd)
 	log.Printf("📊 [Qwen] Output length: %d bytes", len(output))
 
 	if err != nil {
@@ -69,10 +90,10 @@ func (q *QwenCLI) Run(opts *RunOptions) (string, error) {
 		return "", fmt.Errorf("qwen CLI execution failed: %v, output: %s", err, string(output))
 	}
 
-	return q.parseOutput(string(output), opts.Prompt)
+	return q.parseOutput(string(output), opts.Prompt, opts.CaptureSteps)
 }
 
-func (q *QwenCLI) parseOutput(output string, prompt string) (string, error) {
+func (q *QwenCLI) parseOutput(output string, prompt string, captureSteps bool) (string, error) {
 	// Qwen 输出可能包含前置信息，需要找到 JSON 的起始位置
 	jsonStart := strings.Index(output, "{")
 	if jsonStart == -1 {
@@ -100,6 +121,10 @@ func (q *QwenCLI) parseOutput(output string, prompt string) (string, error) {
 		User:      prompt,
 		Response:  response,
 	}
+	if captureSteps {
+		// Qwen 的 JSON 输出只包含按工具汇总的统计，没有逐次调用明细
+		result.Steps = qwenOut.Stats.Tools.steps()
+	}
 
 	jsonBytes, err := json.Marshal(result)
 	if err != nil {
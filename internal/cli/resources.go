@@ -0,0 +1,202 @@
+package cli
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"os/exec"
+	"strconv"
+	"sync"
+)
+
+// ResourceLimits CLI 子进程资源限制：Linux 上每次调用使用独立的 cgroup v2，外加 rlimit
+type ResourceLimits struct {
+	CgroupParent  string  `json:"cgroup_parent,omitempty"`    // 父 cgroup 目录，默认 /sys/fs/cgroup/cli-gateway
+	MemoryMB      int     `json:"memory_mb,omitempty"`        // memory.max（MB）
+	CPUs          float64 `json:"cpus,omitempty"`             // cpu.max，按核数计算（1.5 表示 1.5 个核）
+	MaxPids       int     `json:"max_pids,omitempty"`         // pids.max
+	MaxFileSizeMB int     `json:"max_file_size_mb,omitempty"` // RLIMIT_FSIZE（MB）
+	MaxOpenFiles  int     `json:"max_open_files,omitempty"`   // RLIMIT_NOFILE
+}
+
+// 资源用量的统计方式
+const (
+	ResourceModeCgroup     = "cgroup"      // cgroup v2（含所有子进程）
+	ResourceModeCgroupLate = "cgroup-late" // cgroup v2，但内核不支持 CLONE_INTO_CGROUP，进程启动后才迁入，此前 fork 的子进程不受限制
+	ResourceModeRlimit     = "rlimit"      // 仅 rlimit，用量来自 CLI 主进程的 rusage
+	ResourceModeNone       = "none"        // 未配置限制，用量来自 CLI 主进程的 rusage
+)
+
+// ResourceUsage 单次 CLI 调用的资源用量
+type ResourceUsage struct {
+	Mode            string `json:"mode"`
+	PeakMemoryBytes int64  `json:"peak_memory_bytes,omitempty"`
+	CPUTimeMS       int64  `json:"cpu_time_ms,omitempty"`
+	PeakPids        int64  `json:"peak_pids,omitempty"`
+	OOMKilled       bool   `json:"oom_killed,omitempty"`
+}
+
+// Metadata 返回写入请求元数据的键值
+func (u ResourceUsage) Metadata() map[string]string {
+	meta := map[string]string{"resource_mode": u.Mode}
+	if u.PeakMemoryBytes > 0 {
+		meta["resource_peak_memory_bytes"] = strconv.FormatInt(u.PeakMemoryBytes, 10)
+	}
+	if u.CPUTimeMS > 0 {
+		meta["resource_cpu_ms"] = strconv.FormatInt(u.CPUTimeMS, 10)
+	}
+	if u.PeakPids > 0 {
+		meta["resource_peak_pids"] = strconv.FormatInt(u.PeakPids, 10)
+	}
+	if u.OOMKilled {
+		meta["resource_oom_killed"] = "true"
+	}
+	return meta
+}
+
+// hasCgroupLimits 是否配置了需要 cgroup 的限制
+func (l *ResourceLimits) hasCgroupLimits() bool {
+	return l != nil && (l.MemoryMB > 0 || l.CPUs > 0 || l.MaxPids > 0)
+}
+
+// hasRlimits 是否配置了 rlimit
+func (l *ResourceLimits) hasRlimits() bool {
+	return l != nil && (l.MaxFileSizeMB > 0 || l.MaxOpenFiles > 0)
+}
+
+// prepareCommand 在启动前为 CLI 命令套上沙箱与资源限制；返回的 finish 需在命令结束后调用，
+// 它会清理 cgroup 并通过 opts.OnResourceUsage 上报用量
+func prepareCommand(tag string, cmd *exec.Cmd, opts *RunOptions) (func(), error) {
+	sandboxCleanup, err := applySandbox(tag, cmd, opts)
+	if err != nil {
+		return func() {}, err
+	}
+	limiter := applyResourceLimits(tag, cmd, opts.Resources)
+	return func() {
+		usage := limiter.finish(cmd)
+		sandboxCleanup()
+		if cmd.ProcessState != nil {
+			log.Printf("📈 [%s] Resources: mode=%s peak_memory=%dB cpu=%dms pids=%d oom_killed=%v",
+				tag, usage.Mode, usage.PeakMemoryBytes, usage.CPUTimeMS, usage.PeakPids, usage.OOMKilled)
+			if opts.OnResourceUsage != nil {
+				opts.OnResourceUsage(usage)
+			}
+		}
+	}, nil
+}
+
+// commandStartHooks 命令启动后需要立即执行的操作（老内核上加入 cgroup、没有 prlimit 时按 pid 设置 rlimit）
+var commandStartHooks sync.Map
+
+func onCommandStart(cmd *exec.Cmd, hook func(pid int)) {
+	commandStartHooks.Store(cmd, hook)
+}
+
+// startCommand 启动命令并执行启动钩子；CLI runner 统一通过它（或 combinedOutput / runLines）启动子进程
+func startCommand(cmd *exec.Cmd) error {
+	err := cmd.Start()
+	if hook, ok := commandStartHooks.LoadAndDelete(cmd); ok && err == nil {
+		hook.(func(int))(cmd.Process.Pid)
+	}
+	return err
+}
+
+// combinedOutput 等价于 cmd.CombinedOutput，但经过 startCommand 启动
+func combinedOutput(cmd *exec.Cmd) ([]byte, error) {
+	var buf bytes.Buffer
+	cmd.Stdout = &buf
+	cmd.Stderr = &buf
+	if err := startCommand(cmd); err != nil {
+		return nil, err
+	}
+	err := cmd.Wait()
+	return buf.Bytes(), err
+}
+
+// resourceLimiter 单次调用的资源限制状态
+type resourceLimiter struct {
+	tag    string
+	mode   string
+	cgroup *cgroupHandle
+}
+
+// applyResourceLimits 为命令准备 cgroup 与 rlimit；cgroup 不可用时记录警告并退化为仅 rlimit
+func applyResourceLimits(tag string, cmd *exec.Cmd, limits *ResourceLimits) *resourceLimiter {
+	limiter := &resourceLimiter{tag: tag, mode: ResourceModeNone}
+	if limits == nil {
+		return limiter
+	}
+	lateRlimits := false
+	if limits.hasRlimits() {
+		limiter.mode = ResourceModeRlimit
+		if !wrapWithPrlimit(cmd, limits) {
+			log.Printf("⚠️  [%s] prlimit not found, applying rlimits after start (processes forked before that are not limited)", tag)
+			lateRlimits = true
+		}
+	}
+	lateCgroup := false
+	if limits.hasCgroupLimits() {
+		group, err := newCgroup(tag, limits)
+		if err != nil {
+			log.Printf("⚠️  [%s] cgroup v2 unavailable, falling back to rlimits only: %v", tag, err)
+			limiter.mode = ResourceModeRlimit
+		} else {
+			limiter.cgroup = group
+			limiter.mode = ResourceModeCgroup
+			if !group.attach(cmd) {
+				log.Printf("⚠️  [%s] Kernel lacks CLONE_INTO_CGROUP, moving the process into the cgroup after start (processes forked before that are not limited)", tag)
+				limiter.mode = ResourceModeCgroupLate
+				lateCgroup = true
+			}
+		}
+	}
+
+	if !lateRlimits && !lateCgroup {
+		return limiter
+	}
+	onCommandStart(cmd, func(pid int) {
+		if lateCgroup {
+			limiter.cgroup.afterStart(pid)
+		}
+		if lateRlimits {
+			if err := setProcessRlimits(pid, limits); err != nil {
+				log.Printf("⚠️  [%s] Failed to apply rlimits to pid %d: %v", tag, pid, err)
+			}
+		}
+	})
+	return limiter
+}
+
+// wrapWithPrlimit 用 prlimit(1) 包装命令，使 rlimit 在 exec 之前生效并由所有子进程继承；
+// 找不到 prlimit 时返回 false，由调用方在启动后按 pid 设置
+func wrapWithPrlimit(cmd *exec.Cmd, limits *ResourceLimits) bool {
+	prlimitPath, err := exec.LookPath("prlimit")
+	if err != nil {
+		return false
+	}
+	args := []string{prlimitPath}
+	if limits.MaxFileSizeMB > 0 {
+		args = append(args, fmt.Sprintf("--fsize=%d", int64(limits.MaxFileSizeMB)<<20))
+	}
+	if limits.MaxOpenFiles > 0 {
+		args = append(args, fmt.Sprintf("--nofile=%d", limits.MaxOpenFiles))
+	}
+	cmd.Args = append(append(args, "--", cmd.Path), cmd.Args[1:]...)
+	cmd.Path = prlimitPath
+	return true
+}
+
+// finish 读取用量并清理 cgroup
+func (l *resourceLimiter) finish(cmd *exec.Cmd) ResourceUsage {
+	commandStartHooks.Delete(cmd)
+	usage := ResourceUsage{Mode: l.mode}
+	if state := cmd.ProcessState; state != nil {
+		usage.CPUTimeMS = (state.UserTime() + state.SystemTime()).Milliseconds()
+		usage.PeakMemoryBytes = maxRSSBytes(state)
+	}
+	if l.cgroup != nil {
+		l.cgroup.readUsage(&usage)
+		l.cgroup.remove()
+	}
+	return usage
+}
@@ -0,0 +1,262 @@
+//go:build linux
+
+package cli
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+// cgroupMountRoot cgroup v2 挂载点（测试中可替换）
+var cgroupMountRoot = "/sys/fs/cgroup"
+
+var (
+	cgroupSeq        atomic.Int64
+	cgroupFDOnce     sync.Once
+	cgroupFDSupports bool
+)
+
+// cgroupHandle 单次调用的 cgroup（leaf）
+type cgroupHandle struct {
+	path  string
+	dir   *os.File
+	viaFD bool
+}
+
+// newCgroup 在父 cgroup 下创建本次调用的子 cgroup 并写入 memory.max / cpu.max / pids.max
+func newCgroup(tag string, limits *ResourceLimits) (*cgroupHandle, error) {
+	if _, err := os.Stat(filepath.Join(cgroupMountRoot, "cgroup.controllers")); err != nil {
+		return nil, fmt.Errorf("cgroup v2 is not mounted at %s", cgroupMountRoot)
+	}
+	parent := limits.CgroupParent
+	if parent == "" {
+		parent = filepath.Join(cgroupMountRoot, "cli-gateway")
+	}
+	parent = filepath.Clean(parent)
+	if rel, err := filepath.Rel(cgroupMountRoot, parent); err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+		return nil, fmt.Errorf("cgroup_parent must be below %s: %s", cgroupMountRoot, parent)
+	}
+	if err := os.MkdirAll(parent, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create %s: %v", parent, err)
+	}
+
+	var controllers []string
+	if limits.MemoryMB > 0 {
+		controllers = append(controllers, "memory")
+	}
+	if limits.CPUs > 0 {
+		controllers = append(controllers, "cpu")
+	}
+	if limits.MaxPids > 0 {
+		controllers = append(controllers, "pids")
+	}
+	enableCgroupControllers(parent, controllers)
+
+	path := filepath.Join(parent, fmt.Sprintf("%s-%d-%d", strings.ToLower(tag), os.Getpid(), cgroupSeq.Add(1)))
+	if err := os.Mkdir(path, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create %s: %v", path, err)
+	}
+	handle := &cgroupHandle{path: path}
+
+	settings := map[string]string{}
+	if limits.MemoryMB > 0 {
+		settings["memory.max"] = strconv.FormatInt(int64(limits.MemoryMB)<<20, 10)
+	}
+	if limits.CPUs > 0 {
+		const period = 100000
+		quota := int64(limits.CPUs * period)
+		if quota < 1000 {
+			quota = 1000
+		}
+		settings["cpu.max"] = fmt.Sprintf("%d %d", quota, period)
+	}
+	if limits.MaxPids > 0 {
+		settings["pids.max"] = strconv.Itoa(limits.MaxPids)
+	}
+	for name, value := range settings {
+		if err := os.WriteFile(filepath.Join(path, name), []byte(value), 0o644); err != nil {
+			handle.remove()
+			return nil, fmt.Errorf("failed to set %s (controller not enabled in %s?): %v", name, parent, err)
+		}
+	}
+
+	dir, err := os.Open(path)
+	if err != nil {
+		handle.remove()
+		return nil, fmt.Errorf("failed to open %s: %v", path, err)
+	}
+	handle.dir = dir
+	return handle, nil
+}
+
+// enableCgroupControllers 从挂载点到 parent 逐级开启所需控制器（已开启或无权限时忽略，后续写入限制时再报错）
+func enableCgroupControllers(parent string, controllers []string) {
+	rel, _ := filepath.Rel(cgroupMountRoot, parent)
+	dir := cgroupMountRoot
+	for _, part := range append([]string{""}, strings.Split(rel, string(filepath.Separator))...) {
+		dir = filepath.Join(dir, part)
+		available, err := os.ReadFile(filepath.Join(dir, "cgroup.controllers"))
+		if err != nil {
+			return
+		}
+		fields := strings.Fields(string(available))
+		for _, controller := range controllers {
+			for _, field := range fields {
+				if field == controller {
+					os.WriteFile(filepath.Join(dir, "cgroup.subtree_control"), []byte("+"+controller), 0o644)
+					break
+				}
+			}
+		}
+	}
+}
+
+// attach 内核支持 CLONE_INTO_CGROUP（5.7+）时让子进程直接在 cgroup 中创建；
+// 返回 false 表示只能在启动后通过 afterStart 迁入
+func (h *cgroupHandle) attach(cmd *exec.Cmd) bool {
+	if !cloneIntoCgroupSupported() {
+		return false
+	}
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.UseCgroupFD = true
+	cmd.SysProcAttr.CgroupFD = int(h.dir.Fd())
+	h.viaFD = true
+	return true
+}
+
+func (h *cgroupHandle) afterStart(pid int) {
+	if h.viaFD {
+		return
+	}
+	if err := os.WriteFile(filepath.Join(h.path, "cgroup.procs"), []byte(strconv.Itoa(pid)), 0o644); err != nil {
+		log.Printf("⚠️  Failed to move pid %d into %s: %v", pid, h.path, err)
+	}
+}
+
+// readUsage 读取峰值内存、CPU 时间、峰值进程数与 OOM 次数（较老的内核没有 memory.peak / pids.peak）
+func (h *cgroupHandle) readUsage(usage *ResourceUsage) {
+	if value, ok := readCgroupInt(filepath.Join(h.path, "memory.peak")); ok {
+		usage.PeakMemoryBytes = value
+	}
+	if value, ok := readCgroupInt(filepath.Join(h.path, "pids.peak")); ok {
+		usage.PeakPids = value
+	}
+	if stats := readCgroupKeyed(filepath.Join(h.path, "cpu.stat")); stats["usage_usec"] > 0 {
+		usage.CPUTimeMS = stats["usage_usec"] / 1000
+	}
+	if events := readCgroupKeyed(filepath.Join(h.path, "memory.events")); events["oom_kill"] > 0 {
+		usage.OOMKilled = true
+	}
+}
+
+// remove 删除 cgroup；仍有残留进程（如 CLI 启动的后台进程）时先通过 cgroup.kill 结束它们
+func (h *cgroupHandle) remove() {
+	if h.dir != nil {
+		h.dir.Close()
+	}
+	if err := os.Remove(h.path); err == nil || os.IsNotExist(err) {
+		return
+	}
+	os.WriteFile(filepath.Join(h.path, "cgroup.kill"), []byte("1"), 0o644)
+	var err error
+	for i := 0; i < 20; i++ {
+		if err = os.Remove(h.path); err == nil || os.IsNotExist(err) {
+			return
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	log.Printf("⚠️  Failed to remove cgroup %s: %v", h.path, err)
+}
+
+func readCgroupInt(path string) (int64, bool) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, false
+	}
+	value, err := strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64)
+	return value, err == nil
+}
+
+// readCgroupKeyed 解析 "key value" 格式的文件（cpu.stat、memory.events）
+func readCgroupKeyed(path string) map[string]int64 {
+	values := map[string]int64{}
+	file, err := os.Open(path)
+	if err != nil {
+		return values
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 2 {
+			if value, err := strconv.ParseInt(fields[1], 10, 64); err == nil {
+				values[fields[0]] = value
+			}
+		}
+	}
+	return values
+}
+
+// cloneIntoCgroupSupported 内核版本 >= 5.7
+func cloneIntoCgroupSupported() bool {
+	cgroupFDOnce.Do(func() {
+		var uts syscall.Utsname
+		if err := syscall.Uname(&uts); err != nil {
+			return
+		}
+		var release strings.Builder
+		for _, c := range uts.Release {
+			if c == 0 {
+				break
+			}
+			release.WriteByte(byte(c))
+		}
+		var major, minor int
+		fmt.Sscanf(release.String(), "%d.%d", &major, &minor)
+		cgroupFDSupports = major > 5 || (major == 5 && minor >= 7)
+	})
+	return cgroupFDSupports
+}
+
+// setProcessRlimits 没有 prlimit(1) 时通过 prlimit64 为已启动的进程设置 RLIMIT_FSIZE / RLIMIT_NOFILE（软硬限制相同）
+func setProcessRlimits(pid int, limits *ResourceLimits) error {
+	var errs []error
+	if limits.MaxFileSizeMB > 0 {
+		errs = append(errs, prlimit(pid, syscall.RLIMIT_FSIZE, uint64(limits.MaxFileSizeMB)<<20))
+	}
+	if limits.MaxOpenFiles > 0 {
+		errs = append(errs, prlimit(pid, syscall.RLIMIT_NOFILE, uint64(limits.MaxOpenFiles)))
+	}
+	return errors.Join(errs...)
+}
+
+func prlimit(pid int, resource int, value uint64) error {
+	limit := syscall.Rlimit{Cur: value, Max: value}
+	_, _, errno := syscall.RawSyscall6(syscall.SYS_PRLIMIT64, uintptr(pid), uintptr(resource), uintptr(unsafe.Pointer(&limit)), 0, 0, 0)
+	if errno != 0 {
+		return fmt.Errorf("prlimit(%d): %v", resource, errno)
+	}
+	return nil
+}
+
+// maxRSSBytes CLI 主进程的峰值 RSS（Linux 的 ru_maxrss 单位为 KB）
+func maxRSSBytes(state *os.ProcessState) int64 {
+	if rusage, ok := state.SysUsage().(*syscall.Rusage); ok {
+		return rusage.Maxrss * 1024
+	}
+	return 0
+}
@@ -0,0 +1,26 @@
+//go:build !linux
+
+package cli
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+)
+
+// cgroupHandle 非 Linux 平台没有 cgroup
+type cgroupHandle struct{}
+
+func newCgroup(tag string, limits *ResourceLimits) (*cgroupHandle, error) {
+	return nil, fmt.Errorf("cgroups are only supported on Linux")
+}
+
+func (h *cgroupHandle) attach(cmd *exec.Cmd) bool      { return false }
+func (h *cgroupHandle) afterStart(pid int)             {}
+func (h *cgroupHandle) readUsage(usage *ResourceUsage) {}
+func (h *cgroupHandle) remove()                        {}
+func maxRSSBytes(state *os.ProcessState) int64         { return 0 }
+
+func setProcessRlimits(pid int, limits *ResourceLimits) error {
+	return fmt.Errorf("rlimits are only supported on Linux")
+}
@@ -0,0 +1,148 @@
+package cli
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestPrepareCommand_FallsBackToRlimits(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("resource limits are Linux only")
+	}
+	// 没有 cgroup.controllers 的目录等同于 cgroup v2 不可用
+	previous := cgroupMountRoot
+	cgroupMountRoot = t.TempDir()
+	defer func() { cgroupMountRoot = previous }()
+
+	var usage *ResourceUsage
+	cmd := exec.Command("sh", "-c", "sleep 0.2; ulimit -n")
+	cmd.Env = []string{"PATH=" + os.Getenv("PATH")}
+	// 找不到 prlimit(1) 时退化为启动后按 pid 设置
+	t.Setenv("PATH", t.TempDir())
+	finish, err := prepareCommand("Test", cmd, &RunOptions{
+		Resources:       &ResourceLimits{MemoryMB: 256, MaxOpenFiles: 64},
+		OnResourceUsage: func(u ResourceUsage) { usage = &u },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	output, err := combinedOutput(cmd)
+	finish()
+	if err != nil {
+		t.Fatalf("%v: %s", err, output)
+	}
+	if strings.TrimSpace(string(output)) != "64" {
+		t.Errorf("RLIMIT_NOFILE should be applied to the child, got %q", output)
+	}
+	if usage == nil || usage.Mode != ResourceModeRlimit || usage.PeakMemoryBytes <= 0 {
+		t.Fatalf("expected rlimit usage with rusage peak memory, got %+v", usage)
+	}
+	if meta := usage.Metadata(); meta["resource_mode"] != "rlimit" || meta["resource_peak_memory_bytes"] == "" || meta["resource_oom_killed"] != "" {
+		t.Errorf("unexpected metadata: %v", meta)
+	}
+}
+
+func TestPrepareCommand_AppliesRlimitsBeforeExec(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("resource limits are Linux only")
+	}
+	if _, err := exec.LookPath("prlimit"); err != nil {
+		t.Skip("prlimit not installed")
+	}
+	// 立即 fork 的子进程也必须受限
+	cmd := exec.Command("sh", "-c", "sh -c 'ulimit -n'")
+	finish, err := prepareCommand("Test", cmd, &RunOptions{Resources: &ResourceLimits{MaxOpenFiles: 64, MaxFileSizeMB: 1}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	output, err := combinedOutput(cmd)
+	finish()
+	if err != nil {
+		t.Fatalf("%v: %s", err, output)
+	}
+	if filepath.Base(cmd.Args[0]) != "prlimit" || !strings.Contains(strings.Join(cmd.Args, " "), "--fsize=1048576 --nofile=64 -- ") {
+		t.Errorf("command should be wrapped with prlimit: %v", cmd.Args)
+	}
+	if strings.TrimSpace(string(output)) != "64" {
+		t.Errorf("RLIMIT_NOFILE should be inherited by forked children, got %q", output)
+	}
+}
+
+func TestNewCgroup_WritesLimitsAndReadsUsage(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("cgroups are Linux only")
+	}
+	root := t.TempDir()
+	os.WriteFile(filepath.Join(root, "cgroup.controllers"), []byte("cpuset cpu memory pids"), 0o644)
+	previous := cgroupMountRoot
+	cgroupMountRoot = root
+	defer func() { cgroupMountRoot = previous }()
+
+	if _, err := newCgroup("Test", &ResourceLimits{MemoryMB: 1, CgroupParent: "/elsewhere"}); err == nil {
+		t.Error("cgroup_parent outside the cgroup mount should be rejected")
+	}
+
+	group, err := newCgroup("Test", &ResourceLimits{MemoryMB: 512, CPUs: 1.5, MaxPids: 64})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer group.dir.Close()
+	if filepath.Dir(group.path) != filepath.Join(root, "cli-gateway") {
+		t.Errorf("unexpected cgroup path %s", group.path)
+	}
+	if data, _ := os.ReadFile(filepath.Join(root, "cgroup.subtree_control")); !strings.Contains(string(data), "+") {
+		t.Errorf("controllers should be enabled from the mount root, got %q", data)
+	}
+	for file, want := range map[string]string{"memory.max": "536870912", "cpu.max": "150000 100000", "pids.max": "64"} {
+		if data, _ := os.ReadFile(filepath.Join(group.path, file)); string(data) != want {
+			t.Errorf("%s = %q, want %q", file, data, want)
+		}
+	}
+
+	os.WriteFile(filepath.Join(group.path, "memory.peak"), []byte("4096\n"), 0o644)
+	os.WriteFile(filepath.Join(group.path, "pids.peak"), []byte("3\n"), 0o644)
+	os.WriteFile(filepath.Join(group.path, "cpu.stat"), []byte("usage_usec 25000\nuser_usec 20000\n"), 0o644)
+	os.WriteFile(filepath.Join(group.path, "memory.events"), []byte("low 0\noom 1\noom_kill 1\n"), 0o644)
+	usage := ResourceUsage{Mode: ResourceModeCgroup, PeakMemoryBytes: 1}
+	group.readUsage(&usage)
+	if usage.PeakMemoryBytes != 4096 || usage.PeakPids != 3 || usage.CPUTimeMS != 25 || !usage.OOMKilled {
+		t.Errorf("unexpected usage: %+v", usage)
+	}
+}
+
+func TestPipelineRecordsResourceUsage(t *testing.T) {
+	runner := &resourceReportingRunner{usage: ResourceUsage{Mode: ResourceModeCgroup, PeakMemoryBytes: 2048, CPUTimeMS: 7, OOMKilled: true}}
+	req := &PipelineRequest{CLI: "resource-test", Options: &RunOptions{Prompt: "hi"}}
+	if _, err := NewPipeline().Run(runner, req); err != nil {
+		t.Fatal(err)
+	}
+	if req.Meta("resource_mode") != "cgroup" || req.Meta("resource_peak_memory_bytes") != "2048" || req.Meta("resource_oom_killed") != "true" {
+		t.Errorf("resource usage should be written to metadata: %v", req.Metadata)
+	}
+	if req.Options.OnResourceUsage != nil {
+		t.Error("pipeline should not mutate the caller's options")
+	}
+	summary := GatewayMetrics().GetSummary()["resource-test"].(map[string]interface{})
+	if summary["peak_memory_bytes"] != int64(2048) || summary["oom_kills"] != 1 {
+		t.Errorf("unexpected metrics: %v", summary)
+	}
+}
+
+type resourceReportingRunner struct {
+	usage ResourceUsage
+}
+
+func (r *resourceReportingRunner) Name() string {
+	return "resource-test"
+}
+
+func (r *resourceReportingRunner) Run(opts *RunOptions) (string, error) {
+	if opts.OnResourceUsage != nil {
+		opts.OnResourceUsage(r.usage)
+	}
+	return `{"response":"ok"}`, nil
+}
@@ -0,0 +1,363 @@
+package cli
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"os/user"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// SandboxConfig CLI 子进程沙箱配置（仅 Linux，基于 bubblewrap）：只读根文件系统 + 可写工作目录
+type SandboxConfig struct {
+	Enabled          bool     `json:"enabled"`
+	Bwrap            string   `json:"bwrap,omitempty"`              // bubblewrap 可执行文件，默认从 PATH 查找 "bwrap"
+	User             string   `json:"user,omitempty"`               // 以指定用户运行（用户名或 uid[:gid]），需要网关具备 CAP_SETUID/CAP_SETGID
+	Network          string   `json:"network,omitempty"`            // host（默认）或 none（独立网络命名空间，无网络）
+	WritablePaths    []string `json:"writable_paths,omitempty"`     // 额外可写路径（工作目录与 HOME 默认可写）
+	HiddenPaths      []string `json:"hidden_paths,omitempty"`       // 额外隐藏的目录或文件（以空 tmpfs / 空文件覆盖）
+	ReadOnlyHome     bool     `json:"read_only_home,omitempty"`     // HOME 只读（CLI 无法写入会话记录与登录信息）
+	ExposeGatewayDir bool     `json:"expose_gateway_dir,omitempty"` // 不隐藏网关工作目录（默认隐藏，其中有配置、.env、日志与数据）
+	Seccomp          *bool    `json:"seccomp,omitempty"`            // 拦截 ptrace、mount、bpf 等危险系统调用，默认 true
+}
+
+const (
+	SandboxNetworkHost = "host"
+	SandboxNetworkNone = "none"
+)
+
+// sandboxHiddenHomeEntries HOME 中默认隐藏的凭据（CLI 自身的登录信息仍然可见）
+var sandboxHiddenHomeEntries = []string{".ssh", ".aws", ".netrc", ".git-credentials", ".docker/config.json", ".kube"}
+
+// sandboxSessionHomeEntries HOME 中各 CLI 的会话记录目录，其中有其他 profile / 租户的对话内容：
+// 沙箱内替换为 SandboxStateDir 下按 profile 隔离的目录，未提供时以空 tmpfs 隐藏
+var sandboxSessionHomeEntries = []string{".claude/projects", ".codex/sessions", ".gemini/tmp", ".qwen/tmp"}
+
+// gatewayTempFilePattern 网关为单次调用创建的临时文件（MCP 配置），需要在沙箱的 /tmp 中可见
+var gatewayTempFilePattern = regexp.MustCompile(regexp.QuoteMeta(os.TempDir()) + `/cli-gateway-[A-Za-z0-9._-]+`)
+
+// Validate 校验沙箱配置
+func (c *SandboxConfig) Validate() error {
+	if c == nil || !c.Enabled {
+		return nil
+	}
+	switch c.Network {
+	case "", SandboxNetworkHost, SandboxNetworkNone:
+	default:
+		return fmt.Errorf("sandbox.network must be host or none")
+	}
+	for _, path := range append(append([]string{}, c.WritablePaths...), c.HiddenPaths...) {
+		if !filepath.IsAbs(path) {
+			return fmt.Errorf("sandbox path must be absolute: %s", path)
+		}
+	}
+	return nil
+}
+
+// applySandbox 按 opts.Sandbox 将 cmd 改写为在 bubblewrap 中执行；需在设置 cmd.Env 之后、启动之前调用
+func applySandbox(tag string, cmd *exec.Cmd, opts *RunOptions) (func(), error) {
+	noop := func() {}
+	cfg := opts.Sandbox
+	if cfg == nil || !cfg.Enabled {
+		return noop, nil
+	}
+	if runtime.GOOS != "linux" {
+		return noop, fmt.Errorf("sandbox is only supported on Linux")
+	}
+	if err := cfg.Validate(); err != nil {
+		return noop, err
+	}
+	if cmd.Err != nil {
+		return noop, cmd.Err
+	}
+
+	bwrap := cfg.Bwrap
+	if bwrap == "" {
+		bwrap = "bwrap"
+	}
+	bwrapPath, err := exec.LookPath(bwrap)
+	if err != nil {
+		return noop, fmt.Errorf("sandbox enabled but bubblewrap not found: %v", err)
+	}
+
+	identity, err := lookupSandboxUser(cfg.User)
+	if err != nil {
+		return noop, err
+	}
+	// cmd.Env 中的 HOME 通常继承自网关；以其他用户运行时改用该用户的 HOME（profile env 显式设置的除外）
+	if identity != nil && identity.Home != "" && opts.Env["HOME"] == "" {
+		cmd.Env = mergeEnv(cmd.Env, "HOME="+identity.Home)
+	}
+
+	workspace := opts.WorkDir
+	if workspace == "" {
+		return noop, fmt.Errorf("sandbox requires a work_dir")
+	}
+	if opts.SandboxStateDir != "" {
+		if err := prepareSandboxStateDir(opts.SandboxStateDir, identity); err != nil {
+			return noop, err
+		}
+	}
+	gatewayDir, _ := os.Getwd()
+	plan := sandboxPlan{
+		Workspace:  workspace,
+		Home:       envValue(cmd.Env, "HOME"),
+		GatewayDir: gatewayDir,
+		StateDir:   opts.SandboxStateDir,
+		TempFiles:  sandboxTempFiles(cmd),
+	}
+	args := buildBwrapArgs(cfg, plan)
+
+	cleanup := noop
+	if cfg.Seccomp == nil || *cfg.Seccomp {
+		filter, err := seccompFilter()
+		if err != nil {
+			log.Printf("⚠️  [%s] Sandbox seccomp skipped: %v", tag, err)
+		} else {
+			reader, writer, err := os.Pipe()
+			if err != nil {
+				return noop, fmt.Errorf("failed to create seccomp pipe: %v", err)
+			}
+			// 过滤器很小，一次写入即可放进管道缓冲区
+			_, writeErr := writer.Write(filter)
+			writer.Close()
+			if writeErr != nil {
+				reader.Close()
+				return noop, fmt.Errorf("failed to write seccomp filter: %v", writeErr)
+			}
+			args = append(args, "--seccomp", fmt.Sprint(3+len(cmd.ExtraFiles)))
+			cmd.ExtraFiles = append(cmd.ExtraFiles, reader)
+			cleanup = func() { reader.Close() }
+		}
+	}
+
+	args = append(args, "--", cmd.Path)
+	cmd.Args = append(append([]string{bwrapPath}, args...), cmd.Args[1:]...)
+	cmd.Path = bwrapPath
+	cmd.Dir = workspace
+	if identity != nil {
+		setSandboxCredential(cmd, identity.UID, identity.GID)
+		// 只移交网关创建的工作目录，配置的 work_dir 由运维自行授权
+		if opts.SandboxOwnsWorkDir {
+			handOverSandboxDir(workspace, identity.UID, identity.GID)
+		}
+	}
+
+	log.Printf("🔒 [%s] Sandbox enabled: workspace=%s network=%s user=%s", tag, workspace, sandboxNetwork(cfg), cfg.User)
+	return cleanup, nil
+}
+
+// prepareSandboxStateDir 创建各 CLI 的会话记录目录；以其他用户运行时移交这些网关创建的目录
+func prepareSandboxStateDir(stateDir string, identity *sandboxIdentity) error {
+	for _, name := range sandboxSessionHomeEntries {
+		dir := filepath.Join(stateDir, name)
+		if err := os.MkdirAll(dir, 0o700); err != nil {
+			return fmt.Errorf("failed to create sandbox state dir: %v", err)
+		}
+		if identity == nil {
+			continue
+		}
+		for ; ; dir = filepath.Dir(dir) {
+			handOverSandboxDir(dir, identity.UID, identity.GID)
+			if dir == stateDir || dir == filepath.Dir(dir) {
+				break
+			}
+		}
+	}
+	return nil
+}
+
+// sandboxPlan 构建 bubblewrap 参数所需的运行时路径
+type sandboxPlan struct {
+	Workspace  string
+	Home       string
+	GatewayDir string
+	StateDir   string
+	TempFiles  []string
+}
+
+type sandboxMount struct {
+	path   string
+	kind   string // hide / bind / ro-bind
+	source string // 挂载来源，为空时与 path 相同
+}
+
+// buildBwrapArgs 生成 bubblewrap 参数：浅层路径先挂载，深层路径覆盖其上（如在隐藏目录中开放工作目录）
+func buildBwrapArgs(cfg *SandboxConfig, plan sandboxPlan) []string {
+	args := []string{
+		"--ro-bind", "/", "/",
+		"--dev", "/dev",
+		"--proc", "/proc",
+		"--tmpfs", "/tmp",
+		"--unshare-pid", "--unshare-ipc", "--unshare-uts", "--unshare-cgroup-try",
+		"--die-with-parent", "--new-session",
+	}
+	if sandboxNetwork(cfg) == SandboxNetworkNone {
+		args = append(args, "--unshare-net")
+	}
+
+	var mounts []sandboxMount
+	if tmp := os.TempDir(); tmp != "/tmp" {
+		mounts = append(mounts, sandboxMount{path: tmp, kind: "hide"})
+	}
+	if !cfg.ExposeGatewayDir && plan.GatewayDir != "" {
+		mounts = append(mounts, sandboxMount{path: plan.GatewayDir, kind: "hide"})
+	}
+	for _, path := range cfg.HiddenPaths {
+		mounts = append(mounts, sandboxMount{path: filepath.Clean(path), kind: "hide"})
+	}
+	if plan.Home != "" {
+		if !cfg.ReadOnlyHome {
+			mounts = append(mounts, sandboxMount{path: filepath.Clean(plan.Home), kind: "bind"})
+		}
+		for _, name := range sandboxHiddenHomeEntries {
+			mounts = append(mounts, sandboxMount{path: filepath.Join(plan.Home, name), kind: "hide"})
+		}
+		for _, name := range sandboxSessionHomeEntries {
+			target := filepath.Join(plan.Home, name)
+			switch {
+			case plan.StateDir == "":
+				mounts = append(mounts, sandboxMount{path: target, kind: "hide"})
+			case cfg.ReadOnlyHome:
+				mounts = append(mounts, sandboxMount{path: target, kind: "ro-bind", source: filepath.Join(plan.StateDir, name)})
+			default:
+				// HOME 可写，bubblewrap 会在挂载前创建不存在的目标目录
+				mounts = append(mounts, sandboxMount{path: target, kind: "bind", source: filepath.Join(plan.StateDir, name)})
+			}
+		}
+	}
+	for _, path := range cfg.WritablePaths {
+		mounts = append(mounts, sandboxMount{path: filepath.Clean(path), kind: "bind"})
+	}
+	if plan.Workspace != "" {
+		mounts = append(mounts, sandboxMount{path: filepath.Clean(plan.Workspace), kind: "bind"})
+	}
+	for _, path := range plan.TempFiles {
+		mounts = append(mounts, sandboxMount{path: path, kind: "ro-bind"})
+	}
+
+	// 同一深度下隐藏规则最后生效，避免 writable_paths 意外重新暴露被隐藏的路径
+	order := map[string]int{"bind": 0, "ro-bind": 0, "hide": 1}
+	sort.SliceStable(mounts, func(i, j int) bool {
+		di, dj := strings.Count(mounts[i].path, "/"), strings.Count(mounts[j].path, "/")
+		if di != dj {
+			return di < dj
+		}
+		return order[mounts[i].kind] < order[mounts[j].kind]
+	})
+
+	seen := make(map[string]bool, len(mounts))
+	for _, mount := range mounts {
+		key := mount.kind + ":" + mount.path
+		if seen[key] || mount.path == "/" {
+			continue
+		}
+		seen[key] = true
+		source := mount.source
+		if source == "" {
+			source = mount.path
+		}
+		info, err := os.Stat(source)
+		if err != nil {
+			continue
+		}
+		if mount.source != "" && cfg.ReadOnlyHome {
+			// 只读 HOME 中无法创建挂载点；目标不存在时其中也没有可读取的内容
+			if _, err := os.Stat(mount.path); err != nil {
+				continue
+			}
+		}
+		switch {
+		case mount.kind == "hide" && info.IsDir():
+			args = append(args, "--tmpfs", mount.path)
+		case mount.kind == "hide":
+			args = append(args, "--ro-bind", os.DevNull, mount.path)
+		case mount.kind == "ro-bind":
+			args = append(args, "--ro-bind", source, mount.path)
+		default:
+			args = append(args, "--bind", source, mount.path)
+		}
+	}
+	if plan.Workspace != "" {
+		args = append(args, "--chdir", plan.Workspace)
+	}
+	return args
+}
+
+func sandboxNetwork(cfg *SandboxConfig) string {
+	if cfg.Network == "" {
+		return SandboxNetworkHost
+	}
+	return cfg.Network
+}
+
+// sandboxTempFiles 找出参数与环境变量中引用的网关临时文件
+func sandboxTempFiles(cmd *exec.Cmd) []string {
+	seen := map[string]bool{}
+	var files []string
+	for _, value := range append(append([]string{}, cmd.Args...), cmd.Env...) {
+		for _, match := range gatewayTempFilePattern.FindAllString(value, -1) {
+			if !seen[match] {
+				seen[match] = true
+				files = append(files, match)
+			}
+		}
+	}
+	return files
+}
+
+// sandboxIdentity 沙箱内运行的用户
+type sandboxIdentity struct {
+	UID, GID uint32
+	Home     string
+}
+
+// lookupSandboxUser 解析 "name"、"uid" 或 "uid:gid"
+func lookupSandboxUser(spec string) (*sandboxIdentity, error) {
+	if spec == "" {
+		return nil, nil
+	}
+	name, group, hasGroup := strings.Cut(spec, ":")
+	identity := &sandboxIdentity{}
+	account, err := user.Lookup(name)
+	if err != nil {
+		account, err = user.LookupId(name)
+	}
+	switch {
+	case err == nil:
+		uid, _ := strconv.ParseUint(account.Uid, 10, 32)
+		gid, _ := strconv.ParseUint(account.Gid, 10, 32)
+		identity.UID, identity.GID, identity.Home = uint32(uid), uint32(gid), account.HomeDir
+	default:
+		uid, parseErr := strconv.ParseUint(name, 10, 32)
+		if parseErr != nil {
+			return nil, fmt.Errorf("sandbox user %q not found: %v", name, err)
+		}
+		identity.UID, identity.GID = uint32(uid), uint32(uid)
+	}
+	if hasGroup {
+		gid, err := strconv.ParseUint(group, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid sandbox gid %q", group)
+		}
+		identity.GID = uint32(gid)
+	}
+	return identity, nil
+}
+
+// envValue 返回 KEY=VALUE 列表中最后一个同名变量的值
+func envValue(env []string, key string) string {
+	value := ""
+	for _, entry := range env {
+		if k, v, ok := strings.Cut(entry, "="); ok && k == key {
+			value = v
+		}
+	}
+	return value
+}
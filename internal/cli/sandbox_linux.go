@@ -0,0 +1,33 @@
+//go:build linux
+
+package cli
+
+import (
+	"log"
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+// setSandboxCredential 以指定 uid/gid 启动 bubblewrap（清空附加组）
+func setSandboxCredential(cmd *exec.Cmd, uid, gid uint32) {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.Credential = &syscall.Credential{Uid: uid, Gid: gid, Groups: []uint32{}}
+}
+
+// handOverSandboxDir 网关创建的目录属于网关用户时移交给沙箱用户（仅目录本身），使其可写
+func handOverSandboxDir(dir string, uid, gid uint32) {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return
+	}
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok || stat.Uid == uid || int(stat.Uid) != os.Getuid() {
+		return
+	}
+	if err := os.Chown(dir, int(uid), int(gid)); err != nil {
+		log.Printf("⚠️  Sandbox directory %s not handed over to uid %d: %v", dir, uid, err)
+	}
+}
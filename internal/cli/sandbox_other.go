@@ -0,0 +1,11 @@
+//go:build !linux
+
+package cli
+
+import "os/exec"
+
+// setSandboxCredential 非 Linux 平台不支持沙箱（applySandbox 会提前返回错误）
+func setSandboxCredential(cmd *exec.Cmd, uid, gid uint32) {}
+
+// handOverSandboxDir 非 Linux 平台无需处理
+func handOverSandboxDir(dir string, uid, gid uint32) {}
@@ -0,0 +1,90 @@
+package cli
+
+import (
+	"encoding/binary"
+	"fmt"
+	"runtime"
+	"sort"
+)
+
+// seccomp BPF 常量（linux/filter.h、linux/seccomp.h、linux/audit.h）
+const (
+	bpfLdWAbs             = 0x20 // BPF_LD | BPF_W | BPF_ABS
+	bpfJeqK               = 0x15 // BPF_JMP | BPF_JEQ | BPF_K
+	bpfJsetK              = 0x45 // BPF_JMP | BPF_JSET | BPF_K
+	bpfRetK               = 0x06 // BPF_RET | BPF_K
+	seccompRetAllow       = 0x7fff0000
+	seccompRetErrno       = 0x00050000
+	seccompRetKillProcess = 0x80000000
+	seccompDataNr         = 0 // offsetof(struct seccomp_data, nr)
+	seccompDataArch       = 4 // offsetof(struct seccomp_data, arch)
+	errnoEPERM            = 1
+	x32SyscallBit         = 0x40000000
+)
+
+// seccompArch 各架构的 audit arch 与被拦截的系统调用号
+type seccompArch struct {
+	audit    uint32
+	syscalls map[string]uint32
+}
+
+// 拦截：调试其他进程、挂载与命名空间逃逸、内核模块与 kexec、bpf / perf、密钥环、userfaultfd 等
+var seccompArches = map[string]seccompArch{
+	"amd64": {audit: 0xc000003e, syscalls: map[string]uint32{
+		"ptrace": 101, "mount": 165, "umount2": 166, "pivot_root": 155, "swapon": 167, "swapoff": 168,
+		"reboot": 169, "acct": 163, "kexec_load": 246, "kexec_file_load": 320, "init_module": 175,
+		"finit_module": 313, "delete_module": 176, "bpf": 321, "perf_event_open": 298, "keyctl": 250,
+		"add_key": 248, "request_key": 249, "unshare": 272, "setns": 308, "userfaultfd": 323,
+		"process_vm_readv": 310, "process_vm_writev": 311, "open_by_handle_at": 304,
+	}},
+	"arm64": {audit: 0xc00000b7, syscalls: map[string]uint32{
+		"ptrace": 117, "mount": 40, "umount2": 39, "pivot_root": 41, "swapon": 224, "swapoff": 225,
+		"reboot": 142, "acct": 89, "kexec_load": 104, "kexec_file_load": 294, "init_module": 105,
+		"finit_module": 273, "delete_module": 106, "bpf": 280, "perf_event_open": 241, "keyctl": 219,
+		"add_key": 217, "request_key": 218, "unshare": 97, "setns": 268, "userfaultfd": 282,
+		"process_vm_readv": 270, "process_vm_writev": 271, "open_by_handle_at": 265,
+	}},
+}
+
+// seccompFilter 生成 bubblewrap --seccomp 使用的 BPF 程序（struct sock_filter 数组）：
+// 其他架构的系统调用直接结束进程，被拦截的调用返回 EPERM，其余放行
+func seccompFilter() ([]byte, error) {
+	arch, ok := seccompArches[runtime.GOARCH]
+	if !ok {
+		return nil, fmt.Errorf("seccomp filter not available for %s", runtime.GOARCH)
+	}
+
+	type insn struct {
+		code   uint16
+		jt, jf uint8
+		k      uint32
+	}
+	program := []insn{
+		{bpfLdWAbs, 0, 0, seccompDataArch},
+		{bpfJeqK, 1, 0, arch.audit},
+		{bpfRetK, 0, 0, seccompRetKillProcess},
+		{bpfLdWAbs, 0, 0, seccompDataNr},
+	}
+	if runtime.GOARCH == "amd64" {
+		// 拒绝 x32 ABI，避免用另一套调用号绕过过滤
+		program = append(program, insn{bpfJsetK, 0, 1, x32SyscallBit}, insn{bpfRetK, 0, 0, seccompRetErrno | errnoEPERM})
+	}
+	names := make([]string, 0, len(arch.syscalls))
+	for name := range arch.syscalls {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		nr := arch.syscalls[name]
+		program = append(program, insn{bpfJeqK, 0, 1, nr}, insn{bpfRetK, 0, 0, seccompRetErrno | errnoEPERM})
+	}
+	program = append(program, insn{bpfRetK, 0, 0, seccompRetAllow})
+
+	buf := make([]byte, 0, len(program)*8)
+	for _, ins := range program {
+		buf = binary.LittleEndian.AppendUint16(buf, ins.code)
+		buf = append(buf, ins.jt, ins.jf)
+		buf = binary.LittleEndian.AppendUint32(buf, ins.k)
+	}
+	return buf, nil
+}
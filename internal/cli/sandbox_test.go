@@ -0,0 +1,153 @@
+package cli
+
+import (
+	"os"
+	"os/exec"
+	"os/user"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestBuildBwrapArgs_MountOrder(t *testing.T) {
+	gateway := t.TempDir()
+	home := t.TempDir()
+	workspace := filepath.Join(gateway, "data", "workspaces", "reports")
+	os.MkdirAll(workspace, 0o755)
+	os.MkdirAll(filepath.Join(home, ".ssh"), 0o700)
+	os.MkdirAll(filepath.Join(home, ".claude", "projects"), 0o700)
+	state := filepath.Join(gateway, "data", "homes", "reports")
+	if err := prepareSandboxStateDir(state, nil); err != nil {
+		t.Fatal(err)
+	}
+	secret := filepath.Join(t.TempDir(), "token")
+	os.WriteFile(secret, []byte("x"), 0o600)
+
+	args := strings.Join(buildBwrapArgs(&SandboxConfig{Enabled: true, Network: SandboxNetworkNone, HiddenPaths: []string{secret}}, sandboxPlan{
+		Workspace:  workspace,
+		Home:       home,
+		GatewayDir: gateway,
+		StateDir:   state,
+	}), " ")
+
+	for _, want := range []string{
+		"--ro-bind / /",
+		"--unshare-net",
+		"--tmpfs " + gateway,
+		"--bind " + workspace + " " + workspace,
+		"--bind " + home + " " + home,
+		"--tmpfs " + filepath.Join(home, ".ssh"),
+		"--ro-bind " + os.DevNull + " " + secret,
+		"--bind " + filepath.Join(state, ".claude", "projects") + " " + filepath.Join(home, ".claude", "projects"),
+		"--bind " + filepath.Join(state, ".codex", "sessions") + " " + filepath.Join(home, ".codex", "sessions"),
+		"--chdir " + workspace,
+	} {
+		if !strings.Contains(args, want) {
+			t.Errorf("missing %q in %s", want, args)
+		}
+	}
+	if strings.Index(args, "--tmpfs "+gateway) > strings.Index(args, "--bind "+workspace) {
+		t.Errorf("workspace must be mounted after hiding the gateway dir: %s", args)
+	}
+	if strings.Index(args, "--bind "+home) > strings.Index(args, "--tmpfs "+filepath.Join(home, ".ssh")) {
+		t.Errorf("credentials inside HOME must be hidden after binding HOME: %s", args)
+	}
+
+	// 没有独立的会话记录目录时隐藏 HOME 中其他调用的对话记录
+	shared := strings.Join(buildBwrapArgs(&SandboxConfig{Enabled: true}, sandboxPlan{Home: home}), " ")
+	if !strings.Contains(shared, "--tmpfs "+filepath.Join(home, ".claude", "projects")) {
+		t.Errorf("session history should be hidden without a state dir: %s", shared)
+	}
+
+	exposed := strings.Join(buildBwrapArgs(&SandboxConfig{Enabled: true, ExposeGatewayDir: true}, sandboxPlan{GatewayDir: gateway}), " ")
+	if strings.Contains(exposed, "--tmpfs "+gateway) || strings.Contains(exposed, "--unshare-net") {
+		t.Errorf("expose_gateway_dir and host network should be honored: %s", exposed)
+	}
+}
+
+func TestApplySandbox_WrapsCommand(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("sandbox is Linux only")
+	}
+	fakeBwrap := filepath.Join(t.TempDir(), "bwrap")
+	// 记录参数，并确认 seccomp 过滤器可以从传入的 fd 读取
+	script := "#!/bin/sh\necho \"$@\"\nwc -c <&3\n"
+	if err := os.WriteFile(fakeBwrap, []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	workspace := t.TempDir()
+	promptFile, _ := os.CreateTemp("", "cli-gateway-prompt-*.md")
+	promptFile.Close()
+	defer os.Remove(promptFile.Name())
+
+	cmd := exec.Command("echo", "Read the file "+promptFile.Name())
+	cmd.Env = []string{"HOME=" + t.TempDir()}
+	cleanup, err := applySandbox("Test", cmd, &RunOptions{WorkDir: workspace, Sandbox: &SandboxConfig{Enabled: true, Bwrap: fakeBwrap}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cleanup()
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("%v: %s", err, output)
+	}
+	out := string(output)
+	echoPath, _ := exec.LookPath("echo")
+	for _, want := range []string{"--seccomp 3", "--ro-bind " + promptFile.Name(), "-- " + echoPath + " Read the file"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("missing %q in %s", want, out)
+		}
+	}
+	if filter, _ := seccompFilter(); filter != nil && !strings.Contains(out, "\n"+strconv.Itoa(len(filter))) {
+		t.Errorf("seccomp filter should be readable from fd 3 (%d bytes): %s", len(filter), out)
+	}
+	if cmd.Dir != workspace {
+		t.Errorf("sandboxed command should run in the workspace, got %q", cmd.Dir)
+	}
+
+	if os.Getuid() == 0 {
+		// 以其他用户运行时 HOME 改为该用户的 HOME，而不是继承自网关的 HOME
+		root, _ := user.LookupId("0")
+		cmd = exec.Command("echo")
+		cmd.Env = []string{"HOME=/gateway-home"}
+		if _, err := applySandbox("Test", cmd, &RunOptions{WorkDir: workspace, Sandbox: &SandboxConfig{Enabled: true, Bwrap: fakeBwrap, User: "0"}}); err != nil {
+			t.Fatal(err)
+		}
+		if envValue(cmd.Env, "HOME") != root.HomeDir {
+			t.Errorf("HOME should follow the sandbox user, got %v", cmd.Env)
+		}
+	}
+
+	if _, err := applySandbox("Test", exec.Command("echo"), &RunOptions{Sandbox: &SandboxConfig{Enabled: true, Bwrap: fakeBwrap}}); err == nil {
+		t.Error("sandbox without work_dir should be rejected")
+	}
+	if _, err := applySandbox("Test", exec.Command("echo"), &RunOptions{WorkDir: workspace, Sandbox: &SandboxConfig{Enabled: true, Bwrap: "/nonexistent/bwrap"}}); err == nil {
+		t.Error("missing bubblewrap should fail instead of running unsandboxed")
+	}
+}
+
+func TestSeccompFilterAndUser(t *testing.T) {
+	filter, err := seccompFilter()
+	if err != nil {
+		t.Skip(err)
+	}
+	if len(filter)%8 != 0 || filter[0] != bpfLdWAbs || filter[4] != seccompDataArch {
+		t.Errorf("unexpected BPF program header: % x", filter[:8])
+	}
+	if got := filter[len(filter)-4:]; got[2] != 0xff || got[3] != 0x7f {
+		t.Errorf("program should end with RET ALLOW: % x", got)
+	}
+
+	identity, err := lookupSandboxUser("12345:2000")
+	if err != nil || identity.UID != 12345 || identity.GID != 2000 {
+		t.Errorf("numeric uid:gid should be accepted: %+v %v", identity, err)
+	}
+	if _, err := lookupSandboxUser("no-such-user-xyz"); err == nil {
+		t.Error("unknown user should be rejected")
+	}
+	if err := (&SandboxConfig{Enabled: true, WritablePaths: []string{"relative"}}).Validate(); err == nil {
+		t.Error("relative sandbox paths should be rejected")
+	}
+}
@@ -0,0 +1,185 @@
+package cli
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os/exec"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// StepOutputExcerptBytes 步骤输出摘录上限
+const StepOutputExcerptBytes = 2000
+
+// 步骤状态
+const (
+	StepStatusOK      = "ok"
+	StepStatusError   = "error"
+	StepStatusRunning = "running" // CLI 结束时仍未收到结果
+)
+
+// Step 一次工具调用 / agent 步骤
+type Step struct {
+	ID         string          `json:"id,omitempty"`
+	Tool       string          `json:"tool"`
+	Input      json.RawMessage `json:"input,omitempty"`
+	Output     string          `json:"output,omitempty"` // 输出摘录（最多 StepOutputExcerptBytes）
+	DurationMS int64           `json:"duration_ms,omitempty"`
+	Status     string          `json:"status"`
+	Count      int             `json:"count,omitempty"` // 仅汇总统计（Gemini/Qwen stats）时使用
+}
+
+// stepTracker 按调用 ID 匹配开始/结束事件，按开始顺序输出步骤
+type stepTracker struct {
+	steps   []Step
+	started map[string]time.Time
+	index   map[string]int
+}
+
+func newStepTracker() *stepTracker {
+	return &stepTracker{started: map[string]time.Time{}, index: map[string]int{}}
+}
+
+func (t *stepTracker) start(id, tool string, input json.RawMessage, at time.Time) {
+	if id != "" {
+		if _, ok := t.index[id]; ok {
+			return
+		}
+		t.index[id] = len(t.steps)
+		t.started[id] = at
+	}
+	t.steps = append(t.steps, Step{ID: id, Tool: tool, Input: input, Status: StepStatusRunning})
+}
+
+// finish 记录结果；未见过开始事件时直接追加一个已完成的步骤
+func (t *stepTracker) finish(id, tool string, input json.RawMessage, output string, isError bool, at time.Time) {
+	i, ok := t.index[id]
+	if !ok || id == "" {
+		t.start(id, tool, input, at)
+		i = len(t.steps) - 1
+	}
+	step := &t.steps[i]
+	if step.Tool == "" {
+		step.Tool = tool
+	}
+	if len(step.Input) == 0 {
+		step.Input = input
+	}
+	step.Output = stepExcerpt(output)
+	step.Status = StepStatusOK
+	if isError {
+		step.Status = StepStatusError
+	}
+	if begin, ok := t.started[id]; ok && !at.Before(begin) {
+		step.DurationMS = at.Sub(begin).Milliseconds()
+	}
+}
+
+func (t *stepTracker) result() []Step {
+	if len(t.steps) == 0 {
+		return nil
+	}
+	return t.steps
+}
+
+// stepExcerpt 截断输出（保持 UTF-8 完整）
+func stepExcerpt(output string) string {
+	output = strings.TrimSpace(output)
+	if len(output) <= StepOutputExcerptBytes {
+		return output
+	}
+	cut := StepOutputExcerptBytes
+	for cut > 0 && !utf8.RuneStart(output[cut]) {
+		cut--
+	}
+	return fmt.Sprintf("%s… [%d bytes truncated]", output[:cut], len(output)-cut)
+}
+
+// toolCallStats Gemini / Qwen JSON 输出中的 stats.tools
+type toolCallStats struct {
+	TotalCalls int `json:"totalCalls"`
+	ByName     map[string]struct {
+		Count      int   `json:"count"`
+		Success    int   `json:"success"`
+		Fail       int   `json:"fail"`
+		DurationMS int64 `json:"durationMs"`
+	} `json:"byName"`
+}
+
+// steps 将按工具汇总的统计转换为步骤（按工具名排序）
+func (s *toolCallStats) steps() []Step {
+	if s == nil || len(s.ByName) == 0 {
+		return nil
+	}
+	names := make([]string, 0, len(s.ByName))
+	for name := range s.ByName {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	steps := make([]Step, 0, len(names))
+	for _, name := range names {
+		stat := s.ByName[name]
+		status := StepStatusOK
+		if stat.Fail > 0 {
+			status = StepStatusError
+		}
+		steps = append(steps, Step{
+			Tool:       name,
+			Output:     fmt.Sprintf("success=%d fail=%d", stat.Success, stat.Fail),
+			DurationMS: stat.DurationMS,
+			Status:     status,
+			Count:      stat.Count,
+		})
+	}
+	return steps
+}
+
+// runLines 执行命令并逐行回调 stdout（附带读取时间，用于计算步骤耗时），返回 stdout 与 stderr 原文
+func runLines(cmd *exec.Cmd, onLine func(line []byte, at time.Time)) (stdout string, stderr string, err error) {
+	pipe, err := cmd.StdoutPipe()
+	if err != nil {
+		return "", "", err
+	}
+	var errBuf strings.Builder
+	cmd.Stderr = &errBuf
+	if err := startCommand(cmd); err != nil {
+		return "", "", err
+	}
+
+	var outBuf strings.Builder
+	reader := bufio.NewReaderSize(pipe, 64*1024)
+	for {
+		line, readErr := reader.ReadBytes('\n')
+		if len(line) > 0 {
+			outBuf.Write(line)
+			onLine(line, time.Now())
+		}
+		if readErr != nil {
+			if readErr != io.EOF {
+				err = readErr
+			}
+			break
+		}
+	}
+	if waitErr := cmd.Wait(); waitErr != nil {
+		err = waitErr
+	}
+	return outBuf.String(), errBuf.String(), err
+}
+
+// jsonValue 将任意值编码为 RawMessage（失败时返回 nil）
+func jsonValue(value interface{}) json.RawMessage {
+	if value == nil {
+		return nil
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil
+	}
+	return data
+}
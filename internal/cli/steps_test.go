@@ -0,0 +1,74 @@
+package cli
+
+import (
+	"encoding/json"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStepTracker(t *testing.T) {
+	tracker := newStepTracker()
+	begin := time.Now()
+	tracker.start("a", "Bash", json.RawMessage(`{"command":"ls"}`), begin)
+	tracker.start("b", "Read", nil, begin)
+	tracker.finish("a", "", nil, strings.Repeat("x", StepOutputExcerptBytes+10), false, begin.Add(1500*time.Millisecond))
+	tracker.finish("c", "Write", nil, "denied", true, begin)
+
+	steps := tracker.result()
+	if len(steps) != 3 {
+		t.Fatalf("expected 3 steps, got %+v", steps)
+	}
+	if steps[0].Tool != "Bash" || steps[0].Status != StepStatusOK || steps[0].DurationMS != 1500 {
+		t.Errorf("unexpected first step: %+v", steps[0])
+	}
+	if !strings.HasSuffix(steps[0].Output, "[10 bytes truncated]") {
+		t.Errorf("output should be truncated, got %q", steps[0].Output[StepOutputExcerptBytes-5:])
+	}
+	if steps[1].Status != StepStatusRunning {
+		t.Errorf("unfinished step should stay running: %+v", steps[1])
+	}
+	if steps[2].Tool != "Write" || steps[2].Status != StepStatusError {
+		t.Errorf("result without start should be appended: %+v", steps[2])
+	}
+}
+
+func TestToolCallStatsSteps(t *testing.T) {
+	var out GeminiOutput
+	if err := json.Unmarshal([]byte(`{"response":"ok","stats":{"tools":{"totalCalls":3,"byName":{
+		"read_file":{"count":2,"success":2,"fail":0,"durationMs":40},
+		"run_shell_command":{"count":1,"success":0,"fail":1,"durationMs":900}}}}}`), &out); err != nil {
+		t.Fatal(err)
+	}
+	steps := out.Stats.Tools.steps()
+	if len(steps) != 2 || steps[0].Tool != "read_file" || steps[0].Count != 2 || steps[1].Status != StepStatusError {
+		t.Errorf("unexpected steps: %+v", steps)
+	}
+}
+
+func TestClaudeRunStreamSteps(t *testing.T) {
+	stream := strings.Join([]string{
+		`{"type":"system","subtype":"init","session_id":"s1"}`,
+		`{"type":"assistant","message":{"content":[{"type":"tool_use","id":"t1","name":"Bash","input":{"command":"ls"}}]}}`,
+		`{"type":"user","message":{"content":[{"type":"tool_result","tool_use_id":"t1","content":[{"type":"text","text":"a.go"}]}]}}`,
+		`{"type":"result","subtype":"success","result":"Found a.go","session_id":"s1","total_cost_usd":0.01}`,
+	}, "\n")
+	cmd := exec.Command("cat")
+	cmd.Stdin = strings.NewReader(stream)
+
+	output, err := NewClaudeCLI().runStream(cmd, "list")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var parsed CLIOutput
+	if err := json.Unmarshal([]byte(output), &parsed); err != nil {
+		t.Fatalf("invalid output %s: %v", output, err)
+	}
+	if parsed.SessionID != "s1" || parsed.Response != "Found a.go" {
+		t.Errorf("unexpected output: %+v", parsed)
+	}
+	if len(parsed.Steps) != 1 || parsed.Steps[0].Tool != "Bash" || parsed.Steps[0].Output != "a.go" || parsed.Steps[0].Status != StepStatusOK {
+		t.Errorf("unexpected steps: %+v", parsed.Steps)
+	}
+}
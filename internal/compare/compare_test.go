@@ -0,0 +1,102 @@
+package compare
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNormalizeTargets(t *testing.T) {
+	targets, err := NormalizeTargets([]Target{
+		{Profile: "claude"},
+		{CLI: "codex"},
+		{Profile: "claude"},
+		{Profile: "fast", CLI: "gemini"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []string{"claude", "codex", "claude#2", "fast/gemini"}
+	for i, target := range targets {
+		if target.Label != want[i] {
+			t.Errorf("target %d label = %q, want %q", i, target.Label, want[i])
+		}
+	}
+
+	if _, err := NormalizeTargets([]Target{{Label: "empty"}}); err == nil {
+		t.Error("expected error for target without profile or cli")
+	}
+}
+
+func TestJudgePromptAndVerdict(t *testing.T) {
+	answers := []Answer{
+		{Label: "claude", Response: "answer one"},
+		{Label: "codex", Error: "timeout"},
+		{Label: "gemini", Response: "answer two"},
+	}
+
+	prompt, err := BuildJudgePrompt("question?", answers, JudgeScore, "be strict")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if strings.Contains(prompt, "claude") || strings.Contains(prompt, "timeout") {
+		t.Errorf("judge prompt should be anonymized and skip failed answers:\n%s", prompt)
+	}
+	if !strings.Contains(prompt, "## Answer B\nanswer two") || !strings.Contains(prompt, "be strict") {
+		t.Errorf("judge prompt missing answers or criteria:\n%s", prompt)
+	}
+
+	raw := "Here is my verdict:\n```json\n{\"scores\":[{\"answer\":\"A\",\"score\":6},{\"answer\":\"B\",\"score\":9,\"reason\":\"clearer\"}]}\n```"
+	verdict, err := ParseVerdict(answers, JudgeScore, raw)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(verdict.Scores) != 2 || verdict.Scores[1].Label != "gemini" || verdict.Winner != "gemini" {
+		t.Errorf("unexpected verdict: %+v", verdict)
+	}
+
+	verdict, err = ParseVerdict(answers, JudgeRank, `{"ranking":["B","A"]}`)
+	if err != nil || verdict.Winner != "gemini" || verdict.Ranking[1] != "claude" {
+		t.Errorf("unexpected rank verdict: %+v, err=%v", verdict, err)
+	}
+
+	if _, err := ParseVerdict(answers, JudgeMerge, "no json here"); err == nil {
+		t.Error("expected error for non-JSON judge output")
+	}
+	if _, err := BuildJudgePrompt("q", []Answer{{Label: "x", Error: "boom"}}, JudgeScore, ""); err == nil {
+		t.Error("expected error when no answers succeeded")
+	}
+}
+
+func TestStore_PersistAndPrune(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewStore(dir, 2)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	base := time.Now()
+	for i, id := range []string{"cmp_a", "cmp_b", "cmp_c"} {
+		if err := store.Save(&Comparison{ID: id, Prompt: "p", CreatedAt: base.Add(time.Duration(i) * time.Second)}); err != nil {
+			t.Fatalf("save %s: %v", id, err)
+		}
+	}
+	if _, err := store.Get("cmp_a"); err != ErrNotFound {
+		t.Errorf("oldest record should be pruned, got err=%v", err)
+	}
+
+	reloaded, err := NewStore(dir, 2)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	list := reloaded.List()
+	if len(list) != 2 || list[0].ID != "cmp_c" {
+		t.Errorf("unexpected list after reload: %+v", list)
+	}
+	if err := reloaded.Delete("cmp_c"); err != nil {
+		t.Errorf("delete: %v", err)
+	}
+	if err := reloaded.Delete("cmp_c"); err != ErrNotFound {
+		t.Errorf("second delete err = %v, want ErrNotFound", err)
+	}
+}
@@ -0,0 +1,135 @@
+package compare
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// judgeAlias 提交给裁判的匿名标签（A、B、C...），避免裁判因 CLI 名称产生偏好
+func judgeAlias(index int) string {
+	if index < 26 {
+		return string(rune('A' + index))
+	}
+	return fmt.Sprintf("A%d", index)
+}
+
+// judgeCandidates 返回参与评审的回答（跳过出错或为空的回答）及匿名标签映射
+func judgeCandidates(answers []Answer) ([]Answer, map[string]string) {
+	candidates := make([]Answer, 0, len(answers))
+	aliases := make(map[string]string, len(answers))
+	for _, answer := range answers {
+		if answer.Error != "" || strings.TrimSpace(answer.Response) == "" {
+			continue
+		}
+		aliases[judgeAlias(len(candidates))] = answer.Label
+		candidates = append(candidates, answer)
+	}
+	return candidates, aliases
+}
+
+// BuildJudgePrompt 构建裁判 prompt，无可评审回答时返回错误
+func BuildJudgePrompt(question string, answers []Answer, mode JudgeMode, criteria string) (string, error) {
+	candidates, _ := judgeCandidates(answers)
+	if len(candidates) == 0 {
+		return "", fmt.Errorf("no successful answers to judge")
+	}
+
+	var b strings.Builder
+	b.WriteString("You are an impartial judge comparing answers from different AI assistants to the same question.\n\n")
+	b.WriteString("## Question\n")
+	b.WriteString(question)
+	b.WriteString("\n\n")
+	for i, answer := range candidates {
+		fmt.Fprintf(&b, "## Answer %s\n%s\n\n", judgeAlias(i), answer.Response)
+	}
+	if strings.TrimSpace(criteria) != "" {
+		b.WriteString("## Criteria\n")
+		b.WriteString(criteria)
+		b.WriteString("\n\n")
+	}
+
+	b.WriteString("## Output\nRespond with a single JSON object and nothing else.\n")
+	switch mode {
+	case JudgeRank:
+		b.WriteString(`Format: {"ranking": ["<best answer letter>", "..."], "reason": "<short explanation>"}`)
+	case JudgeMerge:
+		b.WriteString(`Combine the correct and useful parts of all answers into one consensus answer in the language of the question. Format: {"answer": "<merged answer>", "reason": "<short explanation>"}`)
+	default:
+		b.WriteString(`Score every answer from 0 to 10. Format: {"scores": [{"answer": "<letter>", "score": <number>, "reason": "<short explanation>"}], "winner": "<letter>"}`)
+	}
+	b.WriteString("\n")
+	return b.String(), nil
+}
+
+type judgeOutput struct {
+	Scores []struct {
+		Answer string  `json:"answer"`
+		Score  float64 `json:"score"`
+		Reason string  `json:"reason"`
+	} `json:"scores"`
+	Ranking []string `json:"ranking"`
+	Winner  string   `json:"winner"`
+	Answer  string   `json:"answer"`
+	Reason  string   `json:"reason"`
+}
+
+// ParseVerdict 解析裁判输出，并把匿名标签映射回目标标签
+func ParseVerdict(answers []Answer, mode JudgeMode, raw string) (*Verdict, error) {
+	verdict := &Verdict{Mode: mode, Raw: raw}
+	start := strings.Index(raw, "{")
+	end := strings.LastIndex(raw, "}")
+	if start == -1 || end <= start {
+		return verdict, fmt.Errorf("judge output is not JSON")
+	}
+
+	var output judgeOutput
+	if err := json.Unmarshal([]byte(raw[start:end+1]), &output); err != nil {
+		return verdict, fmt.Errorf("failed to parse judge output: %v", err)
+	}
+
+	_, aliases := judgeCandidates(answers)
+	resolve := func(alias string) string {
+		alias = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(alias), "Answer "))
+		if label, ok := aliases[strings.ToUpper(alias)]; ok {
+			return label
+		}
+		return alias
+	}
+
+	verdict.Reason = output.Reason
+	switch mode {
+	case JudgeRank:
+		for _, alias := range output.Ranking {
+			verdict.Ranking = append(verdict.Ranking, resolve(alias))
+		}
+		if len(verdict.Ranking) > 0 {
+			verdict.Winner = verdict.Ranking[0]
+		}
+	case JudgeMerge:
+		verdict.Merged = output.Answer
+		if verdict.Merged == "" {
+			return verdict, fmt.Errorf("judge output has no merged answer")
+		}
+	default:
+		for _, score := range output.Scores {
+			verdict.Scores = append(verdict.Scores, Score{
+				Label:  resolve(score.Answer),
+				Score:  score.Score,
+				Reason: score.Reason,
+			})
+		}
+		if output.Winner != "" {
+			verdict.Winner = resolve(output.Winner)
+		} else {
+			best := -1.0
+			for _, score := range verdict.Scores {
+				if score.Score > best {
+					best = score.Score
+					verdict.Winner = score.Label
+				}
+			}
+		}
+	}
+	return verdict, nil
+}
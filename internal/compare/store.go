@@ -0,0 +1,162 @@
+package compare
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const defaultMaxStored = 500
+
+// Store 保存对比记录，供后台回顾；超过上限时删除最旧的记录
+type Store struct {
+	mu        sync.Mutex
+	dir       string
+	maxStored int
+	items     map[string]*Comparison
+}
+
+// NewStore 创建存储并加载目录中的历史记录；dir 为空时仅保存在内存
+func NewStore(dir string, maxStored int) (*Store, error) {
+	if maxStored <= 0 {
+		maxStored = defaultMaxStored
+	}
+	s := &Store{
+		dir:       dir,
+		maxStored: maxStored,
+		items:     make(map[string]*Comparison),
+	}
+	if dir == "" {
+		return s, nil
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return s, nil
+		}
+		return nil, fmt.Errorf("failed to read comparison storage: %v", err)
+	}
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".json") {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			continue
+		}
+		var c Comparison
+		if err := json.Unmarshal(data, &c); err != nil || c.ID == "" {
+			log.Printf("⚠️  [Compare] Skipping invalid record %s", entry.Name())
+			continue
+		}
+		s.items[c.ID] = &c
+	}
+	return s, nil
+}
+
+// NewID 生成对比记录 ID
+func NewID() string {
+	buf := make([]byte, 8)
+	if _, err := rand.Read(buf); err != nil {
+		return fmt.Sprintf("cmp_%d", time.Now().UnixNano())
+	}
+	return "cmp_" + hex.EncodeToString(buf)
+}
+
+// Save 保存记录
+func (s *Store) Save(c *Comparison) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.items[c.ID] = c
+	if s.dir != "" {
+		if err := os.MkdirAll(s.dir, 0755); err != nil {
+			return fmt.Errorf("failed to create comparison storage: %v", err)
+		}
+		data, err := json.MarshalIndent(c, "", "  ")
+		if err != nil {
+			return err
+		}
+		path := filepath.Join(s.dir, c.ID+".json")
+		tmp := path + ".tmp"
+		if err := os.WriteFile(tmp, data, 0644); err != nil {
+			return fmt.Errorf("failed to write comparison: %v", err)
+		}
+		if err := os.Rename(tmp, path); err != nil {
+			os.Remove(tmp)
+			return fmt.Errorf("failed to write comparison: %v", err)
+		}
+	}
+	s.pruneLocked()
+	return nil
+}
+
+// Get 返回记录
+func (s *Store) Get(id string) (*Comparison, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c, ok := s.items[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return c, nil
+}
+
+// List 按时间倒序返回记录概要
+func (s *Store) List() []Summary {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	summaries := make([]Summary, 0, len(s.items))
+	for _, c := range s.items {
+		summaries = append(summaries, c.summary())
+	}
+	sort.Slice(summaries, func(i, j int) bool {
+		return summaries[i].CreatedAt.After(summaries[j].CreatedAt)
+	})
+	return summaries
+}
+
+// Delete 删除记录
+func (s *Store) Delete(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.items[id]; !ok {
+		return ErrNotFound
+	}
+	s.deleteLocked(id)
+	return nil
+}
+
+func (s *Store) pruneLocked() {
+	if len(s.items) <= s.maxStored {
+		return
+	}
+	ordered := make([]*Comparison, 0, len(s.items))
+	for _, c := range s.items {
+		ordered = append(ordered, c)
+	}
+	sort.Slice(ordered, func(i, j int) bool {
+		return ordered[i].CreatedAt.Before(ordered[j].CreatedAt)
+	})
+	for _, c := range ordered[:len(ordered)-s.maxStored] {
+		s.deleteLocked(c.ID)
+	}
+}
+
+func (s *Store) deleteLocked(id string) {
+	delete(s.items, id)
+	if s.dir != "" {
+		if err := os.Remove(filepath.Join(s.dir, id+".json")); err != nil && !os.IsNotExist(err) {
+			log.Printf("⚠️  [Compare] Failed to remove %s: %v", id, err)
+		}
+	}
+}
@@ -0,0 +1,149 @@
+package compare
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// JudgeMode 裁判模式
+type JudgeMode string
+
+const (
+	JudgeScore JudgeMode = "score" // 逐个打分并给出最佳答案
+	JudgeRank  JudgeMode = "rank"  // 从好到差排序
+	JudgeMerge JudgeMode = "merge" // 合并为一个共识答案
+)
+
+var ErrNotFound = errors.New("comparison not found")
+
+// ParseJudgeMode 解析裁判模式，空值默认为 score
+func ParseJudgeMode(value string) (JudgeMode, error) {
+	switch JudgeMode(strings.ToLower(strings.TrimSpace(value))) {
+	case "", JudgeScore:
+		return JudgeScore, nil
+	case JudgeRank:
+		return JudgeRank, nil
+	case JudgeMerge:
+		return JudgeMerge, nil
+	default:
+		return "", fmt.Errorf("unsupported judge mode: %s", value)
+	}
+}
+
+// Target 对比目标：profile 或 CLI（两者可同时指定）
+type Target struct {
+	Label   string `json:"label,omitempty"`
+	Profile string `json:"profile,omitempty"`
+	CLI     string `json:"cli,omitempty"`
+}
+
+// Answer 单个目标的回答
+type Answer struct {
+	Label     string  `json:"label"`
+	Profile   string  `json:"profile,omitempty"`
+	CLI       string  `json:"cli,omitempty"`
+	Response  string  `json:"response,omitempty"`
+	SessionID string  `json:"session_id,omitempty"`
+	LatencyMS int64   `json:"latency_ms"`
+	CostUSD   float64 `json:"cost_usd,omitempty"`
+	Error     string  `json:"error,omitempty"`
+}
+
+// Score 裁判对单个回答的评分
+type Score struct {
+	Label  string  `json:"label"`
+	Score  float64 `json:"score"`
+	Reason string  `json:"reason,omitempty"`
+}
+
+// Verdict 裁判结果
+type Verdict struct {
+	Profile   string    `json:"profile,omitempty"`
+	CLI       string    `json:"cli,omitempty"`
+	Mode      JudgeMode `json:"mode"`
+	Scores    []Score   `json:"scores,omitempty"`
+	Ranking   []string  `json:"ranking,omitempty"`
+	Winner    string    `json:"winner,omitempty"`
+	Merged    string    `json:"merged,omitempty"`
+	Reason    string    `json:"reason,omitempty"`
+	Raw       string    `json:"raw,omitempty"`
+	LatencyMS int64     `json:"latency_ms"`
+	CostUSD   float64   `json:"cost_usd,omitempty"`
+	Error     string    `json:"error,omitempty"`
+}
+
+// Comparison 一次对比的完整记录
+type Comparison struct {
+	ID         string    `json:"id"`
+	Prompt     string    `json:"prompt"`
+	System     string    `json:"system,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+	DurationMS int64     `json:"duration_ms"`
+	Answers    []Answer  `json:"answers"`
+	Judge      *Verdict  `json:"judge,omitempty"`
+}
+
+// Summary 对比记录概要（用于列表）
+type Summary struct {
+	ID         string    `json:"id"`
+	Prompt     string    `json:"prompt"`
+	CreatedAt  time.Time `json:"created_at"`
+	DurationMS int64     `json:"duration_ms"`
+	Labels     []string  `json:"labels"`
+	Errors     int       `json:"errors"`
+	JudgeMode  JudgeMode `json:"judge_mode,omitempty"`
+	Winner     string    `json:"winner,omitempty"`
+}
+
+// NormalizeTargets 为目标补全并去重标签（profile > cli，重复时追加序号）
+func NormalizeTargets(targets []Target) ([]Target, error) {
+	seen := make(map[string]int, len(targets))
+	result := make([]Target, 0, len(targets))
+	for i, target := range targets {
+		if target.Profile == "" && target.CLI == "" {
+			return nil, fmt.Errorf("target %d: profile or cli is required", i)
+		}
+		label := strings.TrimSpace(target.Label)
+		if label == "" {
+			label = target.Profile
+			if label == "" {
+				label = target.CLI
+			} else if target.CLI != "" {
+				label = target.Profile + "/" + target.CLI
+			}
+		}
+		seen[label]++
+		if seen[label] > 1 {
+			label = fmt.Sprintf("%s#%d", label, seen[label])
+		}
+		target.Label = label
+		result = append(result, target)
+	}
+	return result, nil
+}
+
+func (c *Comparison) summary() Summary {
+	summary := Summary{
+		ID:         c.ID,
+		Prompt:     c.Prompt,
+		CreatedAt:  c.CreatedAt,
+		DurationMS: c.DurationMS,
+		Labels:     make([]string, 0, len(c.Answers)),
+	}
+	if len(summary.Prompt) > 200 {
+		summary.Prompt = summary.Prompt[:200] + "..."
+	}
+	for _, answer := range c.Answers {
+		summary.Labels = append(summary.Labels, answer.Label)
+		if answer.Error != "" {
+			summary.Errors++
+		}
+	}
+	if c.Judge != nil {
+		summary.JudgeMode = c.Judge.Mode
+		summary.Winner = c.Judge.Winner
+	}
+	return summary
+}
@@ -0,0 +1,52 @@
+package fs_policy
+
+import "sync"
+
+// AuditLog 保存最近的策略判定（环形缓冲，最新的在前）
+type AuditLog struct {
+	mu      sync.Mutex
+	entries []Decision
+	next    int
+	full    bool
+}
+
+// NewAuditLog 创建审计日志，size <= 0 时默认 200
+func NewAuditLog(size int) *AuditLog {
+	if size <= 0 {
+		size = 200
+	}
+	return &AuditLog{entries: make([]Decision, size)}
+}
+
+// Record 追加一条判定
+func (a *AuditLog) Record(decision Decision) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.entries[a.next] = decision
+	a.next = (a.next + 1) % len(a.entries)
+	if a.next == 0 {
+		a.full = true
+	}
+}
+
+// Recent 返回最近的判定，deniedOnly 为 true 时只返回拒绝记录
+func (a *AuditLog) Recent(limit int, deniedOnly bool) []Decision {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	count := a.next
+	if a.full {
+		count = len(a.entries)
+	}
+	if limit <= 0 || limit > count {
+		limit = count
+	}
+	result := make([]Decision, 0, limit)
+	for i := 1; i <= count && len(result) < limit; i++ {
+		decision := a.entries[(a.next-i+len(a.entries))%len(a.entries)]
+		if deniedOnly && decision.Allowed {
+			continue
+		}
+		result = append(result, decision)
+	}
+	return result
+}
@@ -0,0 +1,280 @@
+package fs_policy
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Purpose 访问路径的用途
+type Purpose string
+
+const (
+	PurposeSkill    Purpose = "skill"    // profile skills / skill_refs 目录
+	PurposeWorkDir  Purpose = "work_dir" // CLI 工作目录
+	PurposeUpload   Purpose = "upload"   // 上传内容的落盘目录
+	PurposeArtifact Purpose = "artifact" // 通过 API 下载的文件（会话记录导出等）
+)
+
+// Rules 声明式访问策略
+type Rules struct {
+	AllowRoots []string // 允许访问的根目录，空表示不限制
+	DenyGlobs  []string // 拒绝规则，支持 *、?、[...]、**（任意层目录）与 ~ 前缀
+	BaseDir    string   // 相对路径的基准目录，默认当前工作目录
+}
+
+// Decision 一次策略判定
+type Decision struct {
+	Time     time.Time `json:"time"`
+	Purpose  Purpose   `json:"purpose"`
+	Path     string    `json:"path"`               // 调用方给出的路径
+	Resolved string    `json:"resolved,omitempty"` // 解析符号链接后的绝对路径
+	Allowed  bool      `json:"allowed"`
+	Rule     string    `json:"rule,omitempty"` // 命中的规则
+	Reason   string    `json:"reason,omitempty"`
+	Subject  string    `json:"subject,omitempty"` // 发起方（profile、上传者等）
+}
+
+// Policy 编译后的访问策略（只读，可并发使用）
+type Policy struct {
+	allowRoots []string
+	denyGlobs  []glob
+}
+
+type glob struct {
+	raw      string
+	segments []string
+}
+
+// New 编译策略：展开 ~ 与相对路径，并解析 allow_roots 中的符号链接
+func New(rules Rules) (*Policy, error) {
+	base := rules.BaseDir
+	if base == "" {
+		base, _ = os.Getwd()
+	}
+	home, _ := os.UserHomeDir()
+
+	p := &Policy{}
+	for _, root := range rules.AllowRoots {
+		if strings.TrimSpace(root) == "" {
+			continue
+		}
+		resolved, err := Resolve(expandPath(root, base, home))
+		if err != nil {
+			return nil, fmt.Errorf("invalid allow root %q: %v", root, err)
+		}
+		p.allowRoots = append(p.allowRoots, resolved)
+	}
+	for _, pattern := range rules.DenyGlobs {
+		if strings.TrimSpace(pattern) == "" {
+			continue
+		}
+		expanded := expandPath(pattern, base, home)
+		g := glob{raw: pattern, segments: splitPath(expanded)}
+		for _, segment := range g.segments {
+			if _, err := filepath.Match(segment, ""); err != nil {
+				return nil, fmt.Errorf("invalid deny glob %q: %v", pattern, err)
+			}
+		}
+		p.denyGlobs = append(p.denyGlobs, g)
+		// 规则的固定前缀是符号链接时，同时拒绝其真实位置（如 logs -> /var/log/gateway）
+		prefix := literalPrefix(g.segments)
+		if prefix == "" {
+			continue
+		}
+		if resolved, err := Resolve(prefix); err == nil && resolved != prefix {
+			segments := append(splitPath(resolved), g.segments[len(splitPath(prefix)):]...)
+			p.denyGlobs = append(p.denyGlobs, glob{raw: pattern, segments: segments})
+		}
+	}
+	return p, nil
+}
+
+// AllowRoots 返回解析后的允许根目录
+func (p *Policy) AllowRoots() []string {
+	return append([]string(nil), p.allowRoots...)
+}
+
+// DenyGlobs 返回原始拒绝规则（去重）
+func (p *Policy) DenyGlobs() []string {
+	seen := make(map[string]bool, len(p.denyGlobs))
+	globs := make([]string, 0, len(p.denyGlobs))
+	for _, g := range p.denyGlobs {
+		if !seen[g.raw] {
+			seen[g.raw] = true
+			globs = append(globs, g.raw)
+		}
+	}
+	return globs
+}
+
+// Check 判定路径能否用于指定用途：先解析符号链接，再依次检查拒绝规则与允许根目录。
+// 目录会整体暴露给 CLI 或调用方（work_dir 下的文件 CLI 都能读取），因此包含被拒绝路径的目录同样拒绝。
+func (p *Policy) Check(purpose Purpose, path string) Decision {
+	decision := Decision{Time: time.Now(), Purpose: purpose, Path: path}
+	if strings.TrimSpace(path) == "" {
+		decision.Reason = "empty path"
+		return decision
+	}
+	resolved, err := Resolve(path)
+	if err != nil {
+		decision.Reason = err.Error()
+		return decision
+	}
+	decision.Resolved = resolved
+
+	info, statErr := os.Stat(resolved)
+	exposesTree := statErr == nil && info.IsDir()
+	for _, g := range p.denyGlobs {
+		if g.matchesSelfOrParent(resolved) {
+			decision.Rule, decision.Reason = "deny:"+g.raw, "path matches a deny rule"
+			return decision
+		}
+		if exposesTree && couldContain(g.segments, splitPath(resolved)) {
+			decision.Rule, decision.Reason = "deny:"+g.raw, "directory contains a denied path"
+			return decision
+		}
+	}
+
+	if len(p.allowRoots) > 0 {
+		for _, root := range p.allowRoots {
+			if isUnder(root, resolved) {
+				decision.Allowed, decision.Rule = true, "allow:"+root
+				return decision
+			}
+		}
+		decision.Reason = "path is outside allow_roots"
+		return decision
+	}
+	decision.Allowed = true
+	return decision
+}
+
+// Resolve 返回绝对路径并解析符号链接；路径不存在时解析最近的已存在上级目录
+func Resolve(path string) (string, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	var missing []string
+	current := abs
+	for {
+		resolved, err := filepath.EvalSymlinks(current)
+		if err == nil {
+			for i := len(missing) - 1; i >= 0; i-- {
+				resolved = filepath.Join(resolved, missing[i])
+			}
+			return resolved, nil
+		}
+		if !os.IsNotExist(err) {
+			return "", fmt.Errorf("failed to resolve %s: %v", path, err)
+		}
+		parent := filepath.Dir(current)
+		if parent == current {
+			return abs, nil
+		}
+		missing = append(missing, filepath.Base(current))
+		current = parent
+	}
+}
+
+func (g glob) matchesSelfOrParent(path string) bool {
+	segments := splitPath(path)
+	for n := len(segments); n > 0; n-- {
+		if matchSegments(g.segments, segments[:n]) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchSegments 按路径段匹配，** 匹配任意多层（含零层）
+func matchSegments(pattern, segments []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(segments); i++ {
+				if matchSegments(pattern[1:], segments[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(segments) == 0 {
+			return false
+		}
+		if ok, _ := filepath.Match(pattern[0], segments[0]); !ok {
+			return false
+		}
+		pattern, segments = pattern[1:], segments[1:]
+	}
+	return len(segments) == 0
+}
+
+// couldContain 判断目录下是否可能存在匹配 pattern 的路径
+func couldContain(pattern, segments []string) bool {
+	for len(segments) > 0 {
+		if len(pattern) == 0 {
+			return false
+		}
+		if pattern[0] == "**" {
+			return true
+		}
+		if ok, _ := filepath.Match(pattern[0], segments[0]); !ok {
+			return false
+		}
+		pattern, segments = pattern[1:], segments[1:]
+	}
+	return len(pattern) > 0
+}
+
+func expandPath(path, base, home string) string {
+	path = strings.TrimSpace(path)
+	if home != "" && (path == "~" || strings.HasPrefix(path, "~/")) {
+		path = filepath.Join(home, strings.TrimPrefix(path, "~"))
+	}
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(base, path)
+	}
+	return filepath.Clean(path)
+}
+
+func splitPath(path string) []string {
+	parts := strings.Split(filepath.ToSlash(filepath.Clean(path)), "/")
+	segments := make([]string, 0, len(parts))
+	for _, part := range parts {
+		if part != "" {
+			segments = append(segments, part)
+		}
+	}
+	return segments
+}
+
+// literalPrefix 返回第一个通配段之前的目录
+func literalPrefix(segments []string) string {
+	literal := make([]string, 0, len(segments))
+	for _, segment := range segments {
+		if hasMeta(segment) {
+			break
+		}
+		literal = append(literal, segment)
+	}
+	if len(literal) == 0 {
+		return ""
+	}
+	return string(filepath.Separator) + filepath.Join(literal...)
+}
+
+func hasMeta(path string) bool {
+	return strings.ContainsAny(path, "*?[")
+}
+
+// isUnder 判断 path 是否等于 dir 或位于其下
+func isUnder(dir, path string) bool {
+	rel, err := filepath.Rel(dir, path)
+	if err != nil {
+		return false
+	}
+	return rel == "." || (rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)))
+}
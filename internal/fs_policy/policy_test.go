@@ -0,0 +1,114 @@
+package fs_policy
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeFile(t *testing.T, path string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte("x"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPolicy_DenyGlobsAndSymlinks(t *testing.T) {
+	base := t.TempDir()
+	writeFile(t, filepath.Join(base, "configs.json"))
+	writeFile(t, filepath.Join(base, ".env"))
+	writeFile(t, filepath.Join(base, "logs", "app.log"))
+	writeFile(t, filepath.Join(base, "home", ".iflow", "oauth_creds.json"))
+	writeFile(t, filepath.Join(base, "home", ".iflow", "skills", "a", "SKILL.md"))
+	writeFile(t, filepath.Join(base, "skills", "pdf", "SKILL.md"))
+	writeFile(t, filepath.Join(base, "repo", "a", "b", "secret.pem"))
+
+	policy, err := New(Rules{BaseDir: base, DenyGlobs: []string{"configs.json", ".env", "logs", "home/.iflow/*creds*", "repo/**/*.pem"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		purpose Purpose
+		path    string
+		allowed bool
+	}{
+		{PurposeSkill, filepath.Join(base, "skills", "pdf"), true},
+		{PurposeSkill, filepath.Join(base, ".env"), false},
+		{PurposeSkill, filepath.Join(base, "logs", "app.log"), false},
+		{PurposeSkill, filepath.Join(base, "logs", "new", "later.log"), false},
+		{PurposeSkill, base, false}, // 目录中包含 configs.json
+		{PurposeWorkDir, base, false}, // CLI 可以读取 work_dir 下的 .env
+		{PurposeWorkDir, filepath.Join(base, "skills"), true},
+		{PurposeSkill, filepath.Join(base, "home", ".iflow", "skills", "a"), true},
+		{PurposeArtifact, filepath.Join(base, "home", ".iflow", "oauth_creds.json"), false},
+		{PurposeArtifact, filepath.Join(base, "repo", "a", "b", "secret.pem"), false},
+		{PurposeSkill, filepath.Join(base, "repo", "a"), false}, // 可能包含 **/*.pem
+		{PurposeSkill, filepath.Join(base, "skills"), true},
+		{PurposeSkill, "", false},
+	}
+	for _, tc := range cases {
+		if got := policy.Check(tc.purpose, tc.path); got.Allowed != tc.allowed {
+			t.Errorf("%s %s: expected allowed=%v, got %+v", tc.purpose, tc.path, tc.allowed, got)
+		}
+	}
+
+	link := filepath.Join(base, "skills", "innocent")
+	if err := os.Symlink(filepath.Join(base, "logs"), link); err != nil {
+		t.Skip(err)
+	}
+	got := policy.Check(PurposeSkill, link)
+	if got.Allowed || got.Rule != "deny:logs" {
+		t.Errorf("symlinks must be resolved before checking: %+v", got)
+	}
+	if resolved, _ := filepath.EvalSymlinks(filepath.Join(base, "logs")); got.Resolved != resolved {
+		t.Errorf("decision should record the resolved path, got %s", got.Resolved)
+	}
+}
+
+func TestPolicy_AllowRoots(t *testing.T) {
+	base := t.TempDir()
+	writeFile(t, filepath.Join(base, "work", "project", "main.go"))
+	writeFile(t, filepath.Join(base, "outside", "x"))
+	if err := os.Symlink(filepath.Join(base, "outside"), filepath.Join(base, "work", "escape")); err != nil {
+		t.Skip(err)
+	}
+
+	policy, err := New(Rules{BaseDir: base, AllowRoots: []string{"work"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := policy.Check(PurposeWorkDir, filepath.Join(base, "work", "project")); !got.Allowed {
+		t.Errorf("path under allow root should be allowed: %+v", got)
+	}
+	if got := policy.Check(PurposeWorkDir, filepath.Join(base, "work", "not-created-yet")); !got.Allowed {
+		t.Errorf("missing path should be resolved through its existing parent: %+v", got)
+	}
+	if got := policy.Check(PurposeWorkDir, filepath.Join(base, "work", "escape")); got.Allowed {
+		t.Errorf("symlink escaping the allow root must be denied: %+v", got)
+	}
+	if got := policy.Check(PurposeWorkDir, filepath.Join(base, "work", "..", "outside")); got.Allowed {
+		t.Errorf("path outside allow roots must be denied: %+v", got)
+	}
+
+	if _, err := New(Rules{DenyGlobs: []string{"logs/[bad"}}); err == nil {
+		t.Error("malformed deny glob should be rejected")
+	}
+}
+
+func TestAuditLog_Recent(t *testing.T) {
+	audit := NewAuditLog(3)
+	for i, allowed := range []bool{true, false, true, false} {
+		audit.Record(Decision{Path: string(rune('a' + i)), Allowed: allowed})
+	}
+	recent := audit.Recent(0, false)
+	if len(recent) != 3 || recent[0].Path != "d" || recent[2].Path != "b" {
+		t.Errorf("audit log should keep the newest entries first: %+v", recent)
+	}
+	if denied := audit.Recent(10, true); len(denied) != 2 || denied[0].Path != "d" {
+		t.Errorf("denied filter failed: %+v", denied)
+	}
+}
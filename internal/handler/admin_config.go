@@ -45,6 +45,8 @@ func handleAdminConfigUpdate(w http.ResponseWriter, r *http.Request) {
 	}
 
 	InitWorkflowSessionManager()
+	InitResponseCache()
+	InitAdmission()
 	response, err := buildAdminConfigResponseWithConfig(updated, warnings)
 	if err != nil {
 		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
@@ -62,6 +64,8 @@ func handleAdminConfigReload(w http.ResponseWriter, _ *http.Request) {
 	}
 
 	InitWorkflowSessionManager()
+	InitResponseCache()
+	InitAdmission()
 	warnings := buildConfigWarnings(before, updated)
 	response, err := buildAdminConfigResponseWithConfig(updated, warnings)
 	if err != nil {
@@ -172,6 +176,26 @@ func mergeRedactedConfig(existing *Config, incoming *Config) *Config {
 			merged.WorkflowSession.Redis.Password = existing.WorkflowSession.Redis.Password
 		}
 	}
+	if merged.ResponseCache != nil && merged.ResponseCache.Redis != nil && existing.ResponseCache != nil && existing.ResponseCache.Redis != nil {
+		if merged.ResponseCache.Redis.Password == redactedValue {
+			merged.ResponseCache.Redis.Password = existing.ResponseCache.Redis.Password
+		}
+	}
+	if merged.Admission != nil && existing.Admission != nil {
+		// API Key 已脱敏时按租户名匹配恢复
+		for i, item := range merged.Admission.APIKeys {
+			if item.Key != redactedValue {
+				continue
+			}
+			merged.Admission.APIKeys[i].Key = ""
+			for _, previous := range existing.Admission.APIKeys {
+				if previous.Tenant == item.Tenant {
+					merged.Admission.APIKeys[i].Key = previous.Key
+					break
+				}
+			}
+		}
+	}
 
 	if merged.Profiles != nil {
 		for name, profile := range merged.Profiles {
@@ -3,12 +3,17 @@ package handler
 import (
 	"encoding/json"
 	"fmt"
+	"io"
+	"log"
 	"net/http"
 	"os"
 	"path/filepath"
 	"sort"
 	"strings"
 	"time"
+
+	"dify-cli-gateway/internal/cli"
+	"dify-cli-gateway/internal/mcp_client"
 )
 
 type AdminMCPMeta struct {
@@ -26,9 +31,12 @@ type AdminMCPEnvItem struct {
 
 type AdminMCPServer struct {
 	Name    string            `json:"name"`
+	Type    string            `json:"type,omitempty"` // stdio（默认）/ http / sse
 	Command string            `json:"command"`
 	Args    []string          `json:"args,omitempty"`
 	Env     []AdminMCPEnvItem `json:"env,omitempty"`
+	URL     string            `json:"url,omitempty"`
+	Headers []AdminMCPEnvItem `json:"headers,omitempty"`
 }
 
 type AdminMCPResponse struct {
@@ -36,101 +44,133 @@ type AdminMCPResponse struct {
 	Meta    AdminMCPMeta     `json:"meta"`
 }
 
-type cursorMCPConfig struct {
-	MCPServers map[string]cursorMCPServer `json:"mcpServers"`
+// cursorMCPServer 配置文件中的单个 MCP server；未识别的字段保存在 Extra 中原样写回
+type cursorMCPServer struct {
+	Type    string
+	Command string
+	Args    []string
+	Env     map[string]string
+	URL     string
+	Headers map[string]string
+	Extra   map[string]json.RawMessage
 }
 
-type cursorMCPServer struct {
-	Command string            `json:"command,omitempty"`
-	Args    []string          `json:"args,omitempty"`
-	Env     map[string]string `json:"env,omitempty"`
+// AdminMCPCopyRequest 将 server 定义复制到其他 CLI 的配置文件
+type AdminMCPCopyRequest struct {
+	Targets   []string `json:"targets"`
+	Overwrite bool     `json:"overwrite"`
+}
+
+// AdminMCPCopyResult 单个目标 CLI 的复制结果（copied / skipped / error）
+type AdminMCPCopyResult struct {
+	CLI    string `json:"cli"`
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// MCP server 连通性测试超时
+const (
+	defaultMCPTestTimeout = 20 * time.Second
+	maxMCPTestTimeout     = 2 * time.Minute
+)
+
+// mcpConfigTarget 描述一个 CLI 的 MCP 配置文件位置与格式
+type mcpConfigTarget struct {
+	CLI      string
+	EnvKeys  []string // 路径覆盖环境变量，按顺序优先
+	HomePath string   // 相对用户目录的默认路径
+	TOML     bool
+	Gemini   bool // Gemini 系 settings.json：Streamable HTTP 使用 httpUrl，url 表示 SSE，无 type 字段
+}
+
+// adminMCPTargets 支持管理的 CLI（iFlow 与 Gemini 同为 settings.json 的 mcpServers）
+var adminMCPTargets = map[string]mcpConfigTarget{
+	"claude": {CLI: "claude", EnvKeys: []string{"ADMIN_MCP_CLAUDE_PATH", "CLAUDE_MCP_PATH"}, HomePath: ".claude/settings.json"},
+	"cursor": {CLI: "cursor", EnvKeys: []string{"ADMIN_MCP_CURSOR_PATH", "CURSOR_MCP_PATH"}, HomePath: ".cursor/mcp.json"},
+	"gemini": {CLI: "gemini", EnvKeys: []string{"ADMIN_MCP_GEMINI_PATH", "GEMINI_MCP_PATH"}, HomePath: ".gemini/settings.json", Gemini: true},
+	"qwen":   {CLI: "qwen", EnvKeys: []string{"ADMIN_MCP_QWEN_PATH", "QWEN_MCP_PATH"}, HomePath: ".qwen/settings.json", Gemini: true},
+	"iflow":  {CLI: "iflow", EnvKeys: []string{"ADMIN_MCP_IFLOW_PATH", "IFLOW_MCP_PATH"}, HomePath: ".iflow/settings.json", Gemini: true},
+	"codex":  {CLI: "codex", EnvKeys: []string{"ADMIN_MCP_CODEX_PATH", "CODEX_MCP_PATH"}, HomePath: ".codex/config.toml", TOML: true},
+}
+
+// mcpConfigFile 已加载的 MCP 配置文件；写回时保留与 MCP 无关的内容
+type mcpConfigFile interface {
+	Servers() map[string]cursorMCPServer
+	Write(path string) error
 }
 
-type claudeSettings struct {
+// jsonMCPSettings JSON 配置文件（保留顶层其他字段）
+type jsonMCPSettings struct {
 	Raw        map[string]json.RawMessage
 	MCPServers map[string]cursorMCPServer
 	ServersKey string
+	Gemini     bool
+}
+
+func (s *jsonMCPSettings) Servers() map[string]cursorMCPServer {
+	return s.MCPServers
 }
 
 func handleAdminMCP(w http.ResponseWriter, r *http.Request, relativePath string) {
-	claudeBase := "/api/mcp/claude"
-	if relativePath == claudeBase {
+	parts := strings.Split(strings.TrimPrefix(relativePath, "/api/mcp/"), "/")
+	target, ok := adminMCPTargets[parts[0]]
+	if !ok {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "not found"})
+		return
+	}
+
+	switch {
+	case len(parts) == 1:
 		switch r.Method {
 		case http.MethodGet:
-			handleClaudeMCPList(w)
+			handleAdminMCPList(w, target)
 		case http.MethodPost:
-			handleClaudeMCPCreate(w, r)
+			handleAdminMCPCreate(w, r, target)
 		default:
 			writeMethodNotAllowed(w)
 		}
-		return
-	}
-	if strings.HasPrefix(relativePath, claudeBase+"/") {
-		name := strings.TrimPrefix(relativePath, claudeBase+"/")
-		if name == "" || strings.Contains(name, "/") {
-			writeJSON(w, http.StatusNotFound, map[string]string{"error": "not found"})
-			return
-		}
+	case len(parts) == 2 && parts[1] != "":
 		switch r.Method {
 		case http.MethodPut:
-			handleClaudeMCPUpdate(w, r, name)
+			handleAdminMCPUpdate(w, r, target, parts[1])
 		case http.MethodDelete:
-			handleClaudeMCPDelete(w, name)
+			handleAdminMCPDelete(w, target, parts[1])
 		default:
 			writeMethodNotAllowed(w)
 		}
-		return
-	}
-
-	base := "/api/mcp/cursor"
-	if relativePath == base {
-		switch r.Method {
-		case http.MethodGet:
-			handleCursorMCPList(w)
-		case http.MethodPost:
-			handleCursorMCPCreate(w, r)
-		default:
+	case len(parts) == 3 && parts[1] != "" && parts[2] == "copy":
+		if r.Method != http.MethodPost {
 			writeMethodNotAllowed(w)
-		}
-		return
-	}
-
-	if strings.HasPrefix(relativePath, base+"/") {
-		name := strings.TrimPrefix(relativePath, base+"/")
-		if name == "" || strings.Contains(name, "/") {
-			writeJSON(w, http.StatusNotFound, map[string]string{"error": "not found"})
 			return
 		}
-		switch r.Method {
-		case http.MethodPut:
-			handleCursorMCPUpdate(w, r, name)
-		case http.MethodDelete:
-			handleCursorMCPDelete(w, name)
-		default:
+		handleAdminMCPCopy(w, r, target, parts[1])
+	case len(parts) == 3 && parts[1] != "" && parts[2] == "test":
+		if r.Method != http.MethodPost {
 			writeMethodNotAllowed(w)
+			return
 		}
-		return
+		handleAdminMCPTest(w, r, target, parts[1])
+	default:
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "not found"})
 	}
-
-	writeJSON(w, http.StatusNotFound, map[string]string{"error": "not found"})
 }
 
-func handleCursorMCPList(w http.ResponseWriter) {
-	cfg, meta, err := loadCursorMCPConfig()
+func handleAdminMCPList(w http.ResponseWriter, target mcpConfigTarget) {
+	file, meta, err := loadMCPConfig(target)
 	if err != nil {
 		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
 		return
 	}
 
 	servers := make([]AdminMCPServer, 0)
-	keys := make([]string, 0, len(cfg.MCPServers))
-	for name := range cfg.MCPServers {
+	keys := make([]string, 0, len(file.Servers()))
+	for name := range file.Servers() {
 		keys = append(keys, name)
 	}
 	sort.Strings(keys)
 
 	for _, name := range keys {
-		servers = append(servers, buildAdminMCPServer(name, cfg.MCPServers[name]))
+		servers = append(servers, buildAdminMCPServer(name, file.Servers()[name]))
 	}
 
 	writeJSON(w, http.StatusOK, AdminMCPResponse{
@@ -139,190 +179,193 @@ func handleCursorMCPList(w http.ResponseWriter) {
 	})
 }
 
-func handleClaudeMCPList(w http.ResponseWriter) {
-	settings, meta, err := loadClaudeMCPConfig()
-	if err != nil {
-		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
-		return
-	}
-
-	servers := make([]AdminMCPServer, 0)
-	keys := make([]string, 0, len(settings.MCPServers))
-	for name := range settings.MCPServers {
-		keys = append(keys, name)
-	}
-	sort.Strings(keys)
-
-	for _, name := range keys {
-		servers = append(servers, buildAdminMCPServer(name, settings.MCPServers[name]))
-	}
-
-	writeJSON(w, http.StatusOK, AdminMCPResponse{
-		Servers: servers,
-		Meta:    meta,
-	})
-}
-
-func handleCursorMCPCreate(w http.ResponseWriter, r *http.Request) {
+func handleAdminMCPCreate(w http.ResponseWriter, r *http.Request, target mcpConfigTarget) {
 	var payload AdminMCPServer
 	if err := decodeAdminMCPPayload(w, r, &payload); err != nil {
 		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
 		return
 	}
-	if payload.Name == "" {
+	if payload.Name == "" || strings.Contains(payload.Name, "/") {
 		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "server name is required"})
 		return
 	}
-	if payload.Command == "" {
-		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "command is required"})
+	if payload.Command == "" && payload.URL == "" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "command or url is required"})
 		return
 	}
 
-	cfg, meta, err := loadCursorMCPConfig()
+	file, meta, err := loadMCPConfig(target)
 	if err != nil {
 		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
 		return
 	}
-	if _, exists := cfg.MCPServers[payload.Name]; exists {
+	servers := file.Servers()
+	if _, exists := servers[payload.Name]; exists {
 		writeJSON(w, http.StatusConflict, map[string]string{"error": "server already exists"})
 		return
 	}
 
-	cfg.MCPServers[payload.Name] = buildCursorMCPServer(payload, cursorMCPServer{})
-	if err := writeCursorMCPConfig(cfg, meta.Path); err != nil {
+	servers[payload.Name] = buildCursorMCPServer(payload, cursorMCPServer{})
+	if err := file.Write(meta.Path); err != nil {
 		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
 		return
 	}
 
-	writeJSON(w, http.StatusOK, buildAdminMCPServer(payload.Name, cfg.MCPServers[payload.Name]))
+	writeJSON(w, http.StatusOK, buildAdminMCPServer(payload.Name, servers[payload.Name]))
 }
 
-func handleClaudeMCPCreate(w http.ResponseWriter, r *http.Request) {
+func handleAdminMCPUpdate(w http.ResponseWriter, r *http.Request, target mcpConfigTarget, name string) {
 	var payload AdminMCPServer
 	if err := decodeAdminMCPPayload(w, r, &payload); err != nil {
 		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
 		return
 	}
-	if payload.Name == "" {
-		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "server name is required"})
-		return
-	}
-	if payload.Command == "" {
-		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "command is required"})
-		return
-	}
 
-	settings, meta, err := loadClaudeMCPConfig()
+	file, meta, err := loadMCPConfig(target)
 	if err != nil {
 		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
 		return
 	}
-	if _, exists := settings.MCPServers[payload.Name]; exists {
-		writeJSON(w, http.StatusConflict, map[string]string{"error": "server already exists"})
+	servers := file.Servers()
+	existing, exists := servers[name]
+	if !exists {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "server not found"})
 		return
 	}
 
-	settings.MCPServers[payload.Name] = buildCursorMCPServer(payload, cursorMCPServer{})
-	if err := writeClaudeMCPConfig(settings, meta.Path); err != nil {
+	payload.Name = name
+	servers[name] = buildCursorMCPServer(payload, existing)
+	if err := file.Write(meta.Path); err != nil {
 		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
 		return
 	}
 
-	writeJSON(w, http.StatusOK, buildAdminMCPServer(payload.Name, settings.MCPServers[payload.Name]))
+	writeJSON(w, http.StatusOK, buildAdminMCPServer(name, servers[name]))
 }
 
-func handleCursorMCPUpdate(w http.ResponseWriter, r *http.Request, name string) {
-	var payload AdminMCPServer
-	if err := decodeAdminMCPPayload(w, r, &payload); err != nil {
-		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
-		return
-	}
-
-	cfg, meta, err := loadCursorMCPConfig()
+func handleAdminMCPDelete(w http.ResponseWriter, target mcpConfigTarget, name string) {
+	file, meta, err := loadMCPConfig(target)
 	if err != nil {
 		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
 		return
 	}
-	existing, exists := cfg.MCPServers[name]
-	if !exists {
+	if _, exists := file.Servers()[name]; !exists {
 		writeJSON(w, http.StatusNotFound, map[string]string{"error": "server not found"})
 		return
 	}
-
-	payload.Name = name
-	cfg.MCPServers[name] = buildCursorMCPServer(payload, existing)
-	if err := writeCursorMCPConfig(cfg, meta.Path); err != nil {
+	delete(file.Servers(), name)
+	if err := file.Write(meta.Path); err != nil {
 		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
 		return
 	}
 
-	writeJSON(w, http.StatusOK, buildAdminMCPServer(name, cfg.MCPServers[name]))
+	writeJSON(w, http.StatusOK, map[string]string{"status": "deleted"})
 }
 
-func handleClaudeMCPUpdate(w http.ResponseWriter, r *http.Request, name string) {
-	var payload AdminMCPServer
-	if err := decodeAdminMCPPayload(w, r, &payload); err != nil {
-		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+// handleAdminMCPCopy 将 server 定义（含未脱敏的 env）复制到其他 CLI；已存在的同名 server 仅在 overwrite 时覆盖
+func handleAdminMCPCopy(w http.ResponseWriter, r *http.Request, source mcpConfigTarget, name string) {
+	var payload AdminMCPCopyRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&payload); err != nil || len(payload.Targets) == 0 {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "targets is required"})
 		return
 	}
 
-	settings, meta, err := loadClaudeMCPConfig()
+	file, _, err := loadMCPConfig(source)
 	if err != nil {
 		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
 		return
 	}
-	existing, exists := settings.MCPServers[name]
+	server, exists := file.Servers()[name]
 	if !exists {
 		writeJSON(w, http.StatusNotFound, map[string]string{"error": "server not found"})
 		return
 	}
 
-	payload.Name = name
-	settings.MCPServers[name] = buildCursorMCPServer(payload, existing)
-	if err := writeClaudeMCPConfig(settings, meta.Path); err != nil {
-		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
-		return
+	results := make([]AdminMCPCopyResult, 0, len(payload.Targets))
+	for _, cliName := range payload.Targets {
+		results = append(results, copyMCPServer(cliName, name, server, payload.Overwrite, source.CLI))
 	}
-
-	writeJSON(w, http.StatusOK, buildAdminMCPServer(name, settings.MCPServers[name]))
+	writeJSON(w, http.StatusOK, map[string]interface{}{"results": results})
 }
 
-func handleCursorMCPDelete(w http.ResponseWriter, name string) {
-	cfg, meta, err := loadCursorMCPConfig()
-	if err != nil {
-		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
-		return
+// handleAdminMCPTest 按配置启动（stdio）或连接 MCP server，完成 initialize 与 tools/list 后返回工具列表
+func handleAdminMCPTest(w http.ResponseWriter, r *http.Request, target mcpConfigTarget, name string) {
+	var payload struct {
+		TimeoutSeconds int `json:"timeout_seconds"`
 	}
-	if _, exists := cfg.MCPServers[name]; !exists {
-		writeJSON(w, http.StatusNotFound, map[string]string{"error": "server not found"})
-		return
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&payload); err != nil && err != io.EOF {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid test payload"})
+			return
+		}
 	}
-	delete(cfg.MCPServers, name)
-	if err := writeCursorMCPConfig(cfg, meta.Path); err != nil {
-		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
-		return
+	timeout := defaultMCPTestTimeout
+	if payload.TimeoutSeconds > 0 {
+		timeout = time.Duration(payload.TimeoutSeconds) * time.Second
+	}
+	if timeout > maxMCPTestTimeout {
+		timeout = maxMCPTestTimeout
 	}
 
-	writeJSON(w, http.StatusOK, map[string]string{"status": "deleted"})
-}
-
-func handleClaudeMCPDelete(w http.ResponseWriter, name string) {
-	settings, meta, err := loadClaudeMCPConfig()
+	file, _, err := loadMCPConfig(target)
 	if err != nil {
 		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
 		return
 	}
-	if _, exists := settings.MCPServers[name]; !exists {
+	server, exists := file.Servers()[name]
+	if !exists {
 		writeJSON(w, http.StatusNotFound, map[string]string{"error": "server not found"})
 		return
 	}
-	delete(settings.MCPServers, name)
-	if err := writeClaudeMCPConfig(settings, meta.Path); err != nil {
-		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
-		return
-	}
 
-	writeJSON(w, http.StatusOK, map[string]string{"status": "deleted"})
+	log.Printf("🔌 [Admin] Testing MCP server %s/%s (timeout=%v)", target.CLI, name, timeout)
+	result := mcp_client.Probe(r.Context(), mcp_client.Target{
+		Type:    server.Type,
+		Command: server.Command,
+		Args:    server.Args,
+		Env:     server.Env,
+		Environ: cli.BuildEnv(GetEnvPolicyConfig(ProfileConfig{}), nil),
+		URL:     server.URL,
+		Headers: server.Headers,
+	}, timeout)
+	if result.OK {
+		log.Printf("✅ [Admin] MCP server %s/%s OK: %d tools in %dms", target.CLI, name, len(result.Tools), result.LatencyMs)
+	} else {
+		log.Printf("⚠️  [Admin] MCP server %s/%s test failed: %s", target.CLI, name, result.Error)
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+func copyMCPServer(cliName, name string, server cursorMCPServer, overwrite bool, sourceCLI string) AdminMCPCopyResult {
+	result := AdminMCPCopyResult{CLI: cliName}
+	target, ok := adminMCPTargets[cliName]
+	if !ok || cliName == sourceCLI {
+		result.Status = "error"
+		result.Error = "unknown target"
+		return result
+	}
+	file, meta, err := loadMCPConfig(target)
+	if err != nil {
+		result.Status = "error"
+		result.Error = err.Error()
+		return result
+	}
+	if _, exists := file.Servers()[name]; exists && !overwrite {
+		result.Status = "skipped"
+		result.Error = "server already exists"
+		return result
+	}
+	// 各 CLI 的私有字段含义不同，只复制通用字段
+	server.Extra = nil
+	file.Servers()[name] = server
+	if err := file.Write(meta.Path); err != nil {
+		result.Status = "error"
+		result.Error = err.Error()
+		return result
+	}
+	log.Printf("🔌 [Admin] Copied MCP server %s from %s to %s", name, sourceCLI, cliName)
+	result.Status = "copied"
+	return result
 }
 
 func decodeAdminMCPPayload(w http.ResponseWriter, r *http.Request, payload *AdminMCPServer) error {
@@ -333,80 +376,49 @@ func decodeAdminMCPPayload(w http.ResponseWriter, r *http.Request, payload *Admi
 	return nil
 }
 
-func loadCursorMCPConfig() (cursorMCPConfig, AdminMCPMeta, error) {
-	path, display := resolveCursorMCPPath()
+// loadMCPConfig 读取 CLI 的 MCP 配置文件；文件不存在时返回空配置
+func loadMCPConfig(target mcpConfigTarget) (mcpConfigFile, AdminMCPMeta, error) {
+	path, display := resolveMCPPath(target)
 	meta := AdminMCPMeta{
 		Path:        path,
 		DisplayPath: display,
 		Exists:      fileExists(path),
 	}
-	cfg := cursorMCPConfig{
-		MCPServers: map[string]cursorMCPServer{},
-	}
-	if !meta.Exists {
-		return cfg, meta, nil
-	}
-	data, err := os.ReadFile(path)
-	if err != nil {
-		return cfg, meta, err
-	}
-	if err := json.Unmarshal(data, &cfg); err != nil {
-		return cfg, meta, err
-	}
-	if cfg.MCPServers == nil {
-		cfg.MCPServers = map[string]cursorMCPServer{}
-	}
-
-	if stat, err := os.Stat(path); err == nil {
-		meta.LastModified = stat.ModTime().Format(time.RFC3339)
+	var data []byte
+	if meta.Exists {
+		var err error
+		if data, err = os.ReadFile(path); err != nil {
+			return nil, meta, err
+		}
+		if stat, err := os.Stat(path); err == nil {
+			meta.LastModified = stat.ModTime().Format(time.RFC3339)
+		}
 	}
-	return cfg, meta, nil
-}
 
-func writeCursorMCPConfig(cfg cursorMCPConfig, path string) error {
-	if path == "" {
-		return fmt.Errorf("mcp path not resolved")
-	}
-	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
-		return err
-	}
-	data, err := json.MarshalIndent(cfg, "", "  ")
-	if err != nil {
-		return err
-	}
-	tmpPath := path + ".tmp"
-	if err := os.WriteFile(tmpPath, data, 0o600); err != nil {
-		return err
+	if target.TOML {
+		cfg, err := parseCodexMCPConfig(string(data))
+		if err != nil {
+			return nil, meta, fmt.Errorf("invalid %s: %v", display, err)
+		}
+		return cfg, meta, nil
 	}
-	return os.Rename(tmpPath, path)
-}
 
-func loadClaudeMCPConfig() (claudeSettings, AdminMCPMeta, error) {
-	path, display := resolveClaudeMCPPath()
-	meta := AdminMCPMeta{
-		Path:        path,
-		DisplayPath: display,
-		Exists:      fileExists(path),
-	}
-	settings := claudeSettings{
+	settings := &jsonMCPSettings{
 		Raw:        map[string]json.RawMessage{},
 		MCPServers: map[string]cursorMCPServer{},
 		ServersKey: "mcpServers",
+		Gemini:     target.Gemini,
 	}
-	if !meta.Exists {
+	if len(data) == 0 {
 		return settings, meta, nil
 	}
-	data, err := os.ReadFile(path)
-	if err != nil {
-		return settings, meta, err
-	}
 	if err := json.Unmarshal(data, &settings.Raw); err != nil {
-		return settings, meta, err
+		return nil, meta, err
 	}
 	for _, key := range []string{"mcpServers", "mcp_servers"} {
 		if raw, ok := settings.Raw[key]; ok && len(raw) > 0 {
 			if err := json.Unmarshal(raw, &settings.MCPServers); err != nil {
-				return settings, meta, err
+				return nil, meta, err
 			}
 			settings.ServersKey = key
 			break
@@ -415,34 +427,48 @@ func loadClaudeMCPConfig() (claudeSettings, AdminMCPMeta, error) {
 	if settings.MCPServers == nil {
 		settings.MCPServers = map[string]cursorMCPServer{}
 	}
-	if stat, err := os.Stat(path); err == nil {
-		meta.LastModified = stat.ModTime().Format(time.RFC3339)
+	if settings.Gemini {
+		for name, server := range settings.MCPServers {
+			settings.MCPServers[name] = fromGeminiMCPServer(server)
+		}
 	}
 	return settings, meta, nil
 }
 
-func writeClaudeMCPConfig(settings claudeSettings, path string) error {
-	if path == "" {
-		return fmt.Errorf("mcp path not resolved")
+func (s *jsonMCPSettings) Write(path string) error {
+	if s.Raw == nil {
+		s.Raw = map[string]json.RawMessage{}
 	}
-	if settings.Raw == nil {
-		settings.Raw = map[string]json.RawMessage{}
+	servers := s.MCPServers
+	if s.Gemini {
+		servers = make(map[string]cursorMCPServer, len(s.MCPServers))
+		for name, server := range s.MCPServers {
+			servers[name] = toGeminiMCPServer(server)
+		}
 	}
-	mcpRaw, err := json.Marshal(settings.MCPServers)
+	mcpRaw, err := json.Marshal(servers)
 	if err != nil {
 		return err
 	}
-	key := settings.ServersKey
+	key := s.ServersKey
 	if key == "" {
 		key = "mcpServers"
 	}
-	settings.Raw[key] = mcpRaw
+	s.Raw[key] = mcpRaw
 
-	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+	data, err := json.MarshalIndent(s.Raw, "", "  ")
+	if err != nil {
 		return err
 	}
-	data, err := json.MarshalIndent(settings.Raw, "", "  ")
-	if err != nil {
+	return writeMCPConfigFile(path, data)
+}
+
+// writeMCPConfigFile 原子写入配置文件（0600，可能包含 token）
+func writeMCPConfigFile(path string, data []byte) error {
+	if path == "" {
+		return fmt.Errorf("mcp path not resolved")
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
 		return err
 	}
 	tmpPath := path + ".tmp"
@@ -452,32 +478,17 @@ func writeClaudeMCPConfig(settings claudeSettings, path string) error {
 	return os.Rename(tmpPath, path)
 }
 
-func resolveCursorMCPPath() (string, string) {
-	if value := os.Getenv("ADMIN_MCP_CURSOR_PATH"); value != "" {
-		return absPath(value)
-	}
-	if value := os.Getenv("CURSOR_MCP_PATH"); value != "" {
-		return absPath(value)
-	}
-	home, err := os.UserHomeDir()
-	if err != nil || home == "" {
-		return absPath(".cursor/mcp.json")
-	}
-	return absPath(filepath.Join(home, ".cursor", "mcp.json"))
-}
-
-func resolveClaudeMCPPath() (string, string) {
-	if value := os.Getenv("ADMIN_MCP_CLAUDE_PATH"); value != "" {
-		return absPath(value)
-	}
-	if value := os.Getenv("CLAUDE_MCP_PATH"); value != "" {
-		return absPath(value)
+func resolveMCPPath(target mcpConfigTarget) (string, string) {
+	for _, key := range target.EnvKeys {
+		if value := os.Getenv(key); value != "" {
+			return absPath(value)
+		}
 	}
 	home, err := os.UserHomeDir()
 	if err != nil || home == "" {
-		return absPath(".claude/settings.json")
+		return absPath(filepath.FromSlash(target.HomePath))
 	}
-	return absPath(filepath.Join(home, ".claude", "settings.json"))
+	return absPath(filepath.Join(home, filepath.FromSlash(target.HomePath)))
 }
 
 func absPath(path string) (string, string) {
@@ -493,50 +504,169 @@ func absPath(path string) (string, string) {
 }
 
 func buildAdminMCPServer(name string, server cursorMCPServer) AdminMCPServer {
-	envItems := make([]AdminMCPEnvItem, 0)
-	keys := make([]string, 0, len(server.Env))
-	for key := range server.Env {
+	return AdminMCPServer{
+		Name:    name,
+		Type:    server.Type,
+		Command: server.Command,
+		Args:    server.Args,
+		Env:     buildAdminMCPItems(server.Env, isSensitiveEnvKey),
+		URL:     server.URL,
+		Headers: buildAdminMCPItems(server.Headers, isSensitiveHeaderKey),
+	}
+}
+
+// buildAdminMCPItems 按键排序并对敏感值脱敏
+func buildAdminMCPItems(values map[string]string, sensitive func(string) bool) []AdminMCPEnvItem {
+	items := make([]AdminMCPEnvItem, 0)
+	keys := make([]string, 0, len(values))
+	for key := range values {
 		keys = append(keys, key)
 	}
 	sort.Strings(keys)
 	for _, key := range keys {
-		value := server.Env[key]
-		masked := isSensitiveEnvKey(key)
+		value := values[key]
+		masked := sensitive(key)
 		if masked {
 			value = maskValue(value)
 		}
-		envItems = append(envItems, AdminMCPEnvItem{
+		items = append(items, AdminMCPEnvItem{
 			Key:    key,
 			Value:  value,
 			Masked: masked,
 		})
 	}
-	return AdminMCPServer{
-		Name:    name,
-		Command: server.Command,
-		Args:    server.Args,
-		Env:     envItems,
-	}
+	return items
 }
 
 func buildCursorMCPServer(payload AdminMCPServer, existing cursorMCPServer) cursorMCPServer {
-	env := map[string]string{}
-	for _, item := range payload.Env {
+	server := cursorMCPServer{
+		Type:    payload.Type,
+		Command: payload.Command,
+		Args:    payload.Args,
+		Env:     mergeAdminMCPItems(payload.Env, existing.Env),
+		URL:     payload.URL,
+		Extra:   existing.Extra,
+	}
+	if headers := mergeAdminMCPItems(payload.Headers, existing.Headers); len(headers) > 0 {
+		server.Headers = headers
+	}
+	return server
+}
+
+// mergeAdminMCPItems 还原提交的键值；masked 项沿用原值
+func mergeAdminMCPItems(items []AdminMCPEnvItem, existing map[string]string) map[string]string {
+	values := map[string]string{}
+	for _, item := range items {
 		if item.Key == "" {
 			continue
 		}
 		if item.Masked {
-			if existingValue, ok := existing.Env[item.Key]; ok {
-				env[item.Key] = existingValue
+			if existingValue, ok := existing[item.Key]; ok {
+				values[item.Key] = existingValue
 			}
 			continue
 		}
-		env[item.Key] = item.Value
+		values[item.Key] = item.Value
 	}
+	return values
+}
 
-	return cursorMCPServer{
-		Command: payload.Command,
-		Args:    payload.Args,
-		Env:     env,
+// isSensitiveHeaderKey 判断请求头是否需要脱敏（Authorization、Cookie、*-Key、*-Token 等）
+func isSensitiveHeaderKey(key string) bool {
+	upper := strings.ToUpper(strings.ReplaceAll(key, "-", "_"))
+	return isSensitiveEnvKey(upper) || strings.Contains(upper, "COOKIE") || strings.HasSuffix(upper, "KEY")
+}
+
+// UnmarshalJSON 解析已知字段，其余字段保存在 Extra
+func (s *cursorMCPServer) UnmarshalJSON(data []byte) error {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+	known := map[string]interface{}{
+		"type":    &s.Type,
+		"command": &s.Command,
+		"args":    &s.Args,
+		"env":     &s.Env,
+		"url":     &s.URL,
+		"headers": &s.Headers,
+	}
+	for key, raw := range fields {
+		target, ok := known[key]
+		if !ok {
+			if s.Extra == nil {
+				s.Extra = map[string]json.RawMessage{}
+			}
+			s.Extra[key] = raw
+			continue
+		}
+		if err := json.Unmarshal(raw, target); err != nil {
+			return fmt.Errorf("invalid %s: %v", key, err)
+		}
+	}
+	return nil
+}
+
+// MarshalJSON 写出非空的已知字段与原样保留的 Extra
+func (s cursorMCPServer) MarshalJSON() ([]byte, error) {
+	fields := make(map[string]interface{}, len(s.Extra)+6)
+	for key, raw := range s.Extra {
+		fields[key] = raw
+	}
+	if s.Type != "" {
+		fields["type"] = s.Type
+	}
+	if s.Command != "" {
+		fields["command"] = s.Command
+	}
+	if len(s.Args) > 0 {
+		fields["args"] = s.Args
+	}
+	if len(s.Env) > 0 {
+		fields["env"] = s.Env
+	}
+	if s.URL != "" {
+		fields["url"] = s.URL
+	}
+	if len(s.Headers) > 0 {
+		fields["headers"] = s.Headers
+	}
+	return json.Marshal(fields)
+}
+
+// fromGeminiMCPServer 将 Gemini 系的 httpUrl / url 转为统一的 type + url
+func fromGeminiMCPServer(server cursorMCPServer) cursorMCPServer {
+	if raw, ok := server.Extra["httpUrl"]; ok {
+		var httpURL string
+		if json.Unmarshal(raw, &httpURL) == nil {
+			server.URL, server.Type = httpURL, "http"
+			server.Extra = withoutExtra(server.Extra, "httpUrl")
+		}
+	} else if server.URL != "" && server.Type == "" {
+		server.Type = "sse"
+	}
+	return server
+}
+
+// toGeminiMCPServer 写回 Gemini 系格式（Streamable HTTP 写入 httpUrl，不写 type）
+func toGeminiMCPServer(server cursorMCPServer) cursorMCPServer {
+	if server.URL != "" && server.Type != "sse" {
+		raw, _ := json.Marshal(server.URL)
+		extra := withoutExtra(server.Extra, "")
+		extra["httpUrl"] = raw
+		server.Extra, server.URL = extra, ""
+	}
+	server.Type = ""
+	return server
+}
+
+// withoutExtra 复制 Extra 并去掉指定字段（不修改原 map）
+func withoutExtra(extra map[string]json.RawMessage, key string) map[string]json.RawMessage {
+	copied := make(map[string]json.RawMessage, len(extra))
+	for k, v := range extra {
+		if k != key {
+			copied[k] = v
+		}
 	}
+	return copied
 }
@@ -0,0 +1,527 @@
+package handler
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var bareTOMLKey = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// codexTableKeys server 中由网关管理的表（其余键原样保留）
+var codexTableKeys = map[string]bool{"env": true, "http_headers": true}
+
+// codexMCPConfig Codex config.toml：只解析 [mcp_servers.<name>] 及其 env / http_headers 子表，其余内容按原文写回
+type codexMCPConfig struct {
+	blocks  []codexTOMLBlock
+	servers map[string]cursorMCPServer
+	extra   map[string][]string // server 主表中未识别的行（如 startup_timeout_sec、注释），写回时保留
+}
+
+// codexTOMLBlock 以表头分隔的一段原文；server 非空表示属于该 server
+type codexTOMLBlock struct {
+	server string
+	known  bool // 主表或 env / http_headers 子表，写回时重新生成；其他子表原样保留
+	lines  []string
+}
+
+func (c *codexMCPConfig) Servers() map[string]cursorMCPServer {
+	return c.servers
+}
+
+// parseCodexMCPConfig 解析 config.toml 中的 MCP servers
+func parseCodexMCPConfig(content string) (*codexMCPConfig, error) {
+	cfg := &codexMCPConfig{
+		servers: map[string]cursorMCPServer{},
+		extra:   map[string][]string{},
+	}
+	if content == "" {
+		return cfg, nil
+	}
+	lines := strings.Split(strings.TrimSuffix(content, "\n"), "\n")
+
+	current := codexTOMLBlock{}
+	var sub []string
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		trimmed := strings.TrimSpace(line)
+
+		if strings.HasPrefix(trimmed, "[") {
+			cfg.blocks = append(cfg.blocks, current)
+			current = codexTOMLBlock{lines: []string{line}}
+			sub = nil
+			if strings.HasPrefix(trimmed, "[[") {
+				continue
+			}
+			end := strings.Index(trimmed, "]")
+			if end < 0 {
+				return nil, fmt.Errorf("line %d: invalid table header", i+1)
+			}
+			path, err := parseTOMLKeyPath(trimmed[1:end])
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %v", i+1, err)
+			}
+			if len(path) >= 2 && path[0] == "mcp_servers" {
+				current.server = path[1]
+				sub = path[2:]
+				current.known = len(sub) == 0 || (len(sub) == 1 && codexTableKeys[sub[0]])
+				if _, ok := cfg.servers[current.server]; !ok {
+					cfg.servers[current.server] = cursorMCPServer{}
+				}
+			}
+			continue
+		}
+
+		// 多行数组等跨行的值合并为一条语句
+		statement := []string{line}
+		for !tomlBalanced(strings.Join(statement, "\n")) && i+1 < len(lines) {
+			i++
+			statement = append(statement, lines[i])
+		}
+		if current.server == "" || !current.known {
+			current.lines = append(current.lines, statement...)
+			continue
+		}
+		if trimmed == "" {
+			continue
+		}
+
+		server := cfg.servers[current.server]
+		handled, err := applyCodexServerKey(&server, sub, strings.Join(statement, "\n"))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", i+1, err)
+		}
+		cfg.servers[current.server] = server
+		if !handled {
+			cfg.extra[current.server] = append(cfg.extra[current.server], statement...)
+		}
+	}
+	cfg.blocks = append(cfg.blocks, current)
+	return cfg, nil
+}
+
+// applyCodexServerKey 解析 server 表中的 command / args / env / url / http_headers；返回 false 表示未识别
+func applyCodexServerKey(server *cursorMCPServer, sub []string, statement string) (bool, error) {
+	key, raw, ok := splitTOMLKeyValue(statement)
+	if !ok {
+		return false, nil
+	}
+	path, err := parseTOMLKeyPath(key)
+	if err != nil {
+		return false, err
+	}
+	path = append(append([]string{}, sub...), path...)
+	if !(len(path) == 1 && (path[0] == "command" || path[0] == "args" || path[0] == "url" || codexTableKeys[path[0]])) &&
+		!(len(path) == 2 && codexTableKeys[path[0]]) {
+		return false, nil
+	}
+
+	value, err := parseTOMLValue(raw)
+	if err != nil {
+		return false, fmt.Errorf("%s: %v", key, err)
+	}
+	switch {
+	case len(path) == 1 && (path[0] == "command" || path[0] == "url"):
+		str, isString := value.(string)
+		if !isString {
+			return false, fmt.Errorf("%s must be a string", path[0])
+		}
+		if path[0] == "url" {
+			server.URL = str
+		} else {
+			server.Command = str
+		}
+	case len(path) == 1 && path[0] == "args":
+		args, isArray := value.([]string)
+		if !isArray {
+			return false, fmt.Errorf("args must be an array of strings")
+		}
+		server.Args = args
+	case len(path) == 1:
+		table, isTable := value.(map[string]string)
+		if !isTable {
+			return false, fmt.Errorf("%s must be a table of strings", path[0])
+		}
+		for k, v := range table {
+			setCodexTableValue(server, path[0], k, v)
+		}
+	default:
+		str, isString := value.(string)
+		if !isString {
+			return false, fmt.Errorf("%s.%s must be a string", path[0], path[1])
+		}
+		setCodexTableValue(server, path[0], path[1], str)
+	}
+	return true, nil
+}
+
+func setCodexTableValue(server *cursorMCPServer, table, key, value string) {
+	target := &server.Env
+	if table == "http_headers" {
+		target = &server.Headers
+	}
+	if *target == nil {
+		*target = map[string]string{}
+	}
+	(*target)[key] = value
+}
+
+func (c *codexMCPConfig) Write(path string) error {
+	return writeMCPConfigFile(path, []byte(c.render()))
+}
+
+// render 写回原文，已删除的 server 被移除，修改的 server 在原位置重新生成，新增的 server 追加到末尾
+func (c *codexMCPConfig) render() string {
+	var out []string
+	emitted := map[string]bool{}
+	for _, block := range c.blocks {
+		if block.server == "" {
+			out = append(out, block.lines...)
+			continue
+		}
+		server, exists := c.servers[block.server]
+		if !exists {
+			continue
+		}
+		if !block.known {
+			out = append(out, block.lines...)
+			continue
+		}
+		if !emitted[block.server] {
+			out = append(out, renderCodexServer(block.server, server, c.extra[block.server])...)
+			emitted[block.server] = true
+		}
+	}
+
+	names := make([]string, 0, len(c.servers))
+	for name := range c.servers {
+		if !emitted[name] {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if len(out) > 0 && strings.TrimSpace(out[len(out)-1]) != "" {
+			out = append(out, "")
+		}
+		out = append(out, renderCodexServer(name, c.servers[name], nil)...)
+	}
+	for len(out) > 0 && out[len(out)-1] == "" {
+		out = out[:len(out)-1]
+	}
+	if len(out) == 0 {
+		return ""
+	}
+	return strings.Join(out, "\n") + "\n"
+}
+
+func renderCodexServer(name string, server cursorMCPServer, extra []string) []string {
+	table := "mcp_servers." + tomlKey(name)
+	lines := []string{"[" + table + "]"}
+	if server.Command != "" {
+		lines = append(lines, "command = "+tomlString(server.Command))
+	}
+	if len(server.Args) > 0 {
+		args := make([]string, len(server.Args))
+		for i, arg := range server.Args {
+			args[i] = tomlString(arg)
+		}
+		lines = append(lines, "args = ["+strings.Join(args, ", ")+"]")
+	}
+	if server.URL != "" {
+		lines = append(lines, "url = "+tomlString(server.URL))
+	}
+	lines = append(lines, extra...)
+	lines = append(lines, "")
+
+	lines = append(lines, renderCodexTable(table+".env", server.Env)...)
+	lines = append(lines, renderCodexTable(table+".http_headers", server.Headers)...)
+	return lines
+}
+
+func renderCodexTable(table string, values map[string]string) []string {
+	if len(values) == 0 {
+		return nil
+	}
+	lines := []string{"[" + table + "]"}
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		lines = append(lines, tomlKey(key)+" = "+tomlString(values[key]))
+	}
+	return append(lines, "")
+}
+
+func tomlKey(key string) string {
+	if bareTOMLKey.MatchString(key) {
+		return key
+	}
+	return tomlString(key)
+}
+
+// tomlString 编码为 TOML basic string（控制字符使用 \uXXXX，TOML 不支持 \x）
+func tomlString(value string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for _, r := range value {
+		switch r {
+		case '"':
+			b.WriteString(`\"`)
+		case '\\':
+			b.WriteString(`\\`)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\r':
+			b.WriteString(`\r`)
+		case '\t':
+			b.WriteString(`\t`)
+		default:
+			if r < 0x20 || r == 0x7f {
+				fmt.Fprintf(&b, `\u%04X`, r)
+			} else {
+				b.WriteRune(r)
+			}
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+// tomlBalanced 判断语句中字符串外的括号是否闭合
+func tomlBalanced(statement string) bool {
+	depth := 0
+	scanTOML(statement, func(i int, c byte) bool {
+		switch c {
+		case '[', '{':
+			depth++
+		case ']', '}':
+			depth--
+		}
+		return true
+	})
+	return depth <= 0
+}
+
+// scanTOML 遍历字符串与注释之外的字符；fn 返回 false 时停止
+func scanTOML(s string, fn func(i int, c byte) bool) {
+	var quote byte
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case quote == '"' && c == '\\':
+			i++
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '#':
+			for i < len(s) && s[i] != '\n' {
+				i++
+			}
+		default:
+			if !fn(i, c) {
+				return
+			}
+		}
+	}
+}
+
+func splitTOMLKeyValue(statement string) (string, string, bool) {
+	eq := -1
+	scanTOML(statement, func(i int, c byte) bool {
+		if c == '=' {
+			eq = i
+			return false
+		}
+		return true
+	})
+	if eq < 0 {
+		return "", "", false
+	}
+	return strings.TrimSpace(statement[:eq]), statement[eq+1:], true
+}
+
+// parseTOMLKeyPath 解析点分键（支持引号键）
+func parseTOMLKeyPath(key string) ([]string, error) {
+	p := &tomlParser{s: key}
+	var path []string
+	for {
+		p.skipSpace()
+		var part string
+		if p.peek() == '"' || p.peek() == '\'' {
+			value, err := p.parseString()
+			if err != nil {
+				return nil, err
+			}
+			part = value
+		} else {
+			start := p.pos
+			for p.pos < len(p.s) && p.s[p.pos] != '.' && p.s[p.pos] != ' ' && p.s[p.pos] != '\t' {
+				p.pos++
+			}
+			part = p.s[start:p.pos]
+			if !bareTOMLKey.MatchString(part) {
+				return nil, fmt.Errorf("invalid key %q", key)
+			}
+		}
+		path = append(path, part)
+		p.skipSpace()
+		if p.pos >= len(p.s) {
+			return path, nil
+		}
+		if p.s[p.pos] != '.' {
+			return nil, fmt.Errorf("invalid key %q", key)
+		}
+		p.pos++
+	}
+}
+
+// parseTOMLValue 解析字符串、字符串数组或字符串内联表；其他类型返回原文
+func parseTOMLValue(raw string) (interface{}, error) {
+	p := &tomlParser{s: raw}
+	p.skipSpace()
+	switch p.peek() {
+	case '"', '\'':
+		return p.parseString()
+	case '[':
+		return p.parseStringArray()
+	case '{':
+		return p.parseStringTable()
+	default:
+		return strings.TrimSpace(raw), nil
+	}
+}
+
+type tomlParser struct {
+	s   string
+	pos int
+}
+
+func (p *tomlParser) peek() byte {
+	if p.pos >= len(p.s) {
+		return 0
+	}
+	return p.s[p.pos]
+}
+
+// skipSpace 跳过空白、换行与注释
+func (p *tomlParser) skipSpace() {
+	for p.pos < len(p.s) {
+		switch p.s[p.pos] {
+		case ' ', '\t', '\r', '\n':
+			p.pos++
+		case '#':
+			for p.pos < len(p.s) && p.s[p.pos] != '\n' {
+				p.pos++
+			}
+		default:
+			return
+		}
+	}
+}
+
+func (p *tomlParser) parseString() (string, error) {
+	quote := p.peek()
+	if strings.HasPrefix(p.s[p.pos:], strings.Repeat(string(quote), 3)) {
+		return "", fmt.Errorf("multi-line strings are not supported")
+	}
+	start := p.pos
+	p.pos++
+	for p.pos < len(p.s) {
+		c := p.s[p.pos]
+		if quote == '"' && c == '\\' {
+			p.pos += 2
+			continue
+		}
+		p.pos++
+		if c == quote {
+			if quote == '\'' {
+				return p.s[start+1 : p.pos-1], nil
+			}
+			return strconv.Unquote(p.s[start:p.pos])
+		}
+	}
+	return "", fmt.Errorf("unterminated string")
+}
+
+func (p *tomlParser) parseStringArray() ([]string, error) {
+	p.pos++
+	values := []string{}
+	for {
+		p.skipSpace()
+		if p.peek() == ']' {
+			p.pos++
+			return values, nil
+		}
+		if p.peek() != '"' && p.peek() != '\'' {
+			return nil, fmt.Errorf("expected string in array")
+		}
+		value, err := p.parseString()
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+		p.skipSpace()
+		if p.peek() == ',' {
+			p.pos++
+		} else if p.peek() != ']' {
+			return nil, fmt.Errorf("expected ',' or ']' in array")
+		}
+	}
+}
+
+func (p *tomlParser) parseStringTable() (map[string]string, error) {
+	p.pos++
+	table := map[string]string{}
+	for {
+		p.skipSpace()
+		if p.peek() == '}' {
+			p.pos++
+			return table, nil
+		}
+		var key string
+		if p.peek() == '"' || p.peek() == '\'' {
+			value, err := p.parseString()
+			if err != nil {
+				return nil, err
+			}
+			key = value
+		} else {
+			start := p.pos
+			for p.pos < len(p.s) && strings.IndexByte(" \t=", p.s[p.pos]) < 0 {
+				p.pos++
+			}
+			key = p.s[start:p.pos]
+			if !bareTOMLKey.MatchString(key) {
+				return nil, fmt.Errorf("invalid key %q", key)
+			}
+		}
+		p.skipSpace()
+		if p.peek() != '=' {
+			return nil, fmt.Errorf("expected '=' in inline table")
+		}
+		p.pos++
+		p.skipSpace()
+		if p.peek() != '"' && p.peek() != '\'' {
+			return nil, fmt.Errorf("expected string value for %s", key)
+		}
+		value, err := p.parseString()
+		if err != nil {
+			return nil, err
+		}
+		table[key] = value
+		p.skipSpace()
+		if p.peek() == ',' {
+			p.pos++
+		} else if p.peek() != '}' {
+			return nil, fmt.Errorf("expected ',' or '}' in inline table")
+		}
+	}
+}
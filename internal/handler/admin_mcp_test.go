@@ -0,0 +1,238 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const codexConfigFixture = `model = "o3"  # 默认模型
+
+[mcp]
+enabled = true
+
+[mcp_servers.playwright]
+command = "npx"
+args = [
+  "@playwright/mcp@latest", # 多行数组
+]
+startup_timeout_sec = 30
+
+[mcp_servers.playwright.env]
+DEBUG = "pw:*"
+
+[mcp_servers."docs.search"]
+command = 'uvx'
+env = { API_TOKEN = "secret" }
+
+[profiles.fast]
+model = "gpt-4.1"
+`
+
+func TestCodexMCPConfig_RoundTrip(t *testing.T) {
+	cfg, err := parseCodexMCPConfig(codexConfigFixture)
+	if err != nil {
+		t.Fatal(err)
+	}
+	playwright := cfg.Servers()["playwright"]
+	if playwright.Command != "npx" || len(playwright.Args) != 1 || playwright.Env["DEBUG"] != "pw:*" {
+		t.Errorf("unexpected playwright server: %+v", playwright)
+	}
+	if docs := cfg.Servers()["docs.search"]; docs.Command != "uvx" || docs.Env["API_TOKEN"] != "secret" {
+		t.Errorf("unexpected docs server: %+v", docs)
+	}
+
+	delete(cfg.Servers(), "docs.search")
+	cfg.Servers()["github"] = cursorMCPServer{Command: "gh-mcp", Env: map[string]string{"GITHUB_TOKEN": "a\"b"}}
+	out := cfg.render()
+
+	for _, keep := range []string{`model = "o3"  # 默认模型`, "[mcp]\nenabled = true", "startup_timeout_sec = 30", "[profiles.fast]\nmodel = \"gpt-4.1\""} {
+		if !strings.Contains(out, keep) {
+			t.Errorf("unrelated content %q should be preserved:\n%s", keep, out)
+		}
+	}
+	if strings.Contains(out, "docs.search") {
+		t.Errorf("deleted server should be removed:\n%s", out)
+	}
+
+	again, err := parseCodexMCPConfig(out)
+	if err != nil {
+		t.Fatalf("rendered config should parse: %v\n%s", err, out)
+	}
+	if again.Servers()["github"].Env["GITHUB_TOKEN"] != "a\"b" || again.Servers()["playwright"].Args[0] != "@playwright/mcp@latest" {
+		t.Errorf("servers should survive a round trip: %+v", again.Servers())
+	}
+	if again.render() != out {
+		t.Errorf("rendering should be stable:\n%s\n---\n%s", out, again.render())
+	}
+}
+
+func TestAdminMCP_GenericTargetsAndCopy(t *testing.T) {
+	dir := t.TempDir()
+	geminiPath := filepath.Join(dir, "gemini.json")
+	codexPath := filepath.Join(dir, "config.toml")
+	t.Setenv("ADMIN_MCP_GEMINI_PATH", geminiPath)
+	t.Setenv("ADMIN_MCP_CODEX_PATH", codexPath)
+	t.Setenv("ADMIN_MCP_QWEN_PATH", filepath.Join(dir, "qwen.json"))
+	os.WriteFile(geminiPath, []byte(`{"theme":"dark","mcpServers":{}}`), 0o600)
+	os.WriteFile(codexPath, []byte("model = \"o3\"\n"), 0o600)
+
+	call := func(method, path, body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		handleAdminMCP(rec, httptest.NewRequest(method, "/v1/admin"+path, strings.NewReader(body)), path)
+		return rec
+	}
+
+	rec := call(http.MethodPost, "/api/mcp/gemini", `{"name":"fetch","command":"uvx","args":["mcp-server-fetch"],"env":[{"key":"API_KEY","value":"k1"}]}`)
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"masked":true`) {
+		t.Fatalf("create should mask secrets: %d %s", rec.Code, rec.Body.String())
+	}
+	data, _ := os.ReadFile(geminiPath)
+	if !strings.Contains(string(data), `"theme": "dark"`) {
+		t.Errorf("unrelated settings should be preserved: %s", data)
+	}
+
+	rec = call(http.MethodPost, "/api/mcp/gemini/fetch/copy", `{"targets":["codex","qwen","nope"]}`)
+	var copied struct {
+		Results []AdminMCPCopyResult `json:"results"`
+	}
+	json.Unmarshal(rec.Body.Bytes(), &copied)
+	if len(copied.Results) != 3 || copied.Results[0].Status != "copied" || copied.Results[1].Status != "copied" || copied.Results[2].Status != "error" {
+		t.Fatalf("unexpected copy results: %s", rec.Body.String())
+	}
+	data, _ = os.ReadFile(codexPath)
+	if !strings.Contains(string(data), "model = \"o3\"") || !strings.Contains(string(data), "API_KEY = \"k1\"") {
+		t.Errorf("copy should carry unmasked env and keep unrelated keys: %s", data)
+	}
+
+	rec = call(http.MethodPost, "/api/mcp/gemini/fetch/copy", `{"targets":["codex"]}`)
+	if !strings.Contains(rec.Body.String(), `"status":"skipped"`) {
+		t.Errorf("existing servers should be skipped without overwrite: %s", rec.Body.String())
+	}
+
+	rec = call(http.MethodPut, "/api/mcp/codex/fetch", `{"command":"uvx","args":["mcp-server-fetch","--raw"],"env":[{"key":"API_KEY","value":"***","masked":true}]}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("update failed: %d %s", rec.Code, rec.Body.String())
+	}
+	data, _ = os.ReadFile(codexPath)
+	if !strings.Contains(string(data), `"--raw"`) || !strings.Contains(string(data), "API_KEY = \"k1\"") {
+		t.Errorf("update should keep masked values: %s", data)
+	}
+
+	if rec = call(http.MethodDelete, "/api/mcp/codex/fetch", ""); rec.Code != http.StatusOK {
+		t.Fatalf("delete failed: %d", rec.Code)
+	}
+	if rec = call(http.MethodGet, "/api/mcp/codex", ""); strings.Contains(rec.Body.String(), "fetch") {
+		t.Errorf("deleted server should not be listed: %s", rec.Body.String())
+	}
+	if rec = call(http.MethodGet, "/api/mcp/unknown", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("unknown cli should be 404, got %d", rec.Code)
+	}
+}
+
+func TestAdminMCP_TestServer(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "mcp.json")
+	t.Setenv("ADMIN_MCP_CURSOR_PATH", path)
+	os.WriteFile(path, []byte(`{"mcpServers":{"broken":{"command":"sh","args":["-c","echo \"token expired\" >&2; exit 1"]}}}`), 0o600)
+
+	rec := httptest.NewRecorder()
+	handleAdminMCP(rec, httptest.NewRequest(http.MethodPost, "/v1/admin/api/mcp/cursor/broken/test", strings.NewReader(`{"timeout_seconds":5}`)), "/api/mcp/cursor/broken/test")
+	var result struct {
+		OK     bool   `json:"ok"`
+		Error  string `json:"error"`
+		Stderr string `json:"stderr"`
+	}
+	json.Unmarshal(rec.Body.Bytes(), &result)
+	if rec.Code != http.StatusOK || result.OK || result.Error == "" || result.Stderr != "token expired" {
+		t.Errorf("failed servers should report the error and stderr: %d %s", rec.Code, rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	handleAdminMCP(rec, httptest.NewRequest(http.MethodPost, "/v1/admin/api/mcp/cursor/missing/test", nil), "/api/mcp/cursor/missing/test")
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("unknown server should be 404, got %d", rec.Code)
+	}
+}
+
+func TestAdminMCP_RemoteServersAndUnknownFields(t *testing.T) {
+	dir := t.TempDir()
+	claudePath := filepath.Join(dir, "settings.json")
+	geminiPath := filepath.Join(dir, "gemini.json")
+	t.Setenv("ADMIN_MCP_CLAUDE_PATH", claudePath)
+	t.Setenv("ADMIN_MCP_GEMINI_PATH", geminiPath)
+	os.WriteFile(claudePath, []byte(`{"mcpServers":{
+		"docs":{"type":"http","url":"https://docs.example.com/mcp","headers":{"Authorization":"Bearer secret-token","X-Team":"infra"}},
+		"local":{"command":"node","args":["server.js"],"timeout":30000,"alwaysAllow":["read"]}
+	}}`), 0o600)
+	os.WriteFile(geminiPath, []byte(`{"mcpServers":{"search":{"httpUrl":"https://search.example.com/mcp","trust":true},"events":{"url":"https://events.example.com/sse"}}}`), 0o600)
+
+	call := func(method, path, body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		handleAdminMCP(rec, httptest.NewRequest(method, "/v1/admin"+path, strings.NewReader(body)), path)
+		return rec
+	}
+
+	var list AdminMCPResponse
+	json.Unmarshal(call(http.MethodGet, "/api/mcp/claude", "").Body.Bytes(), &list)
+	docs := list.Servers[0]
+	if docs.Name != "docs" || docs.Type != "http" || docs.URL != "https://docs.example.com/mcp" || len(docs.Headers) != 2 {
+		t.Fatalf("remote server should be listed: %+v", docs)
+	}
+	if !docs.Headers[0].Masked || strings.Contains(docs.Headers[0].Value, "secret-token") || docs.Headers[1].Masked {
+		t.Errorf("only secret headers should be masked: %+v", docs.Headers)
+	}
+
+	docs.Headers[1].Value = "platform"
+	body, _ := json.Marshal(docs)
+	if rec := call(http.MethodPut, "/api/mcp/claude/docs", string(body)); rec.Code != http.StatusOK {
+		t.Fatalf("update failed: %d %s", rec.Code, rec.Body.String())
+	}
+	var saved struct {
+		MCPServers map[string]map[string]interface{} `json:"mcpServers"`
+	}
+	data, _ := os.ReadFile(claudePath)
+	json.Unmarshal(data, &saved)
+	headers, _ := saved.MCPServers["docs"]["headers"].(map[string]interface{})
+	if headers["Authorization"] != "Bearer secret-token" || headers["X-Team"] != "platform" || saved.MCPServers["docs"]["type"] != "http" {
+		t.Errorf("masked headers should keep their values: %s", data)
+	}
+	if saved.MCPServers["local"]["timeout"] != float64(30000) || saved.MCPServers["local"]["alwaysAllow"] == nil {
+		t.Errorf("unknown fields of other servers must be preserved: %s", data)
+	}
+
+	json.Unmarshal(call(http.MethodGet, "/api/mcp/gemini", "").Body.Bytes(), &list)
+	if list.Servers[0].Name != "events" || list.Servers[0].Type != "sse" || list.Servers[1].Type != "http" || list.Servers[1].URL != "https://search.example.com/mcp" {
+		t.Fatalf("gemini httpUrl/url should map to http/sse: %+v", list.Servers)
+	}
+	if rec := call(http.MethodPost, "/api/mcp/gemini", `{"name":"wiki","type":"http","url":"https://wiki.example.com/mcp"}`); rec.Code != http.StatusOK {
+		t.Fatalf("create remote server failed: %d %s", rec.Code, rec.Body.String())
+	}
+	data, _ = os.ReadFile(geminiPath)
+	for _, want := range []string{`"httpUrl": "https://wiki.example.com/mcp"`, `"trust": true`, `"url": "https://events.example.com/sse"`} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("gemini settings should contain %s: %s", want, data)
+		}
+	}
+	if strings.Contains(string(data), `"type"`) {
+		t.Errorf("gemini settings have no type field: %s", data)
+	}
+}
+
+func TestCodexMCPConfig_RemoteServer(t *testing.T) {
+	cfg, err := parseCodexMCPConfig("[mcp_servers.figma]\nurl = \"https://mcp.figma.com/mcp\"\nbearer_token_env_var = \"FIGMA_TOKEN\"\nhttp_headers = { \"X-Region\" = \"eu\" }\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	figma := cfg.Servers()["figma"]
+	if figma.URL != "https://mcp.figma.com/mcp" || figma.Headers["X-Region"] != "eu" {
+		t.Errorf("unexpected remote server: %+v", figma)
+	}
+	out := cfg.render()
+	if !strings.Contains(out, `bearer_token_env_var = "FIGMA_TOKEN"`) || !strings.Contains(out, "[mcp_servers.figma.http_headers]\nX-Region = \"eu\"") {
+		t.Errorf("unexpected rendering:\n%s", out)
+	}
+}
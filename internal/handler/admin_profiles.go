@@ -7,6 +7,8 @@ import (
 	"sort"
 	"strings"
 	"time"
+
+	"dify-cli-gateway/internal/skill_store"
 )
 
 type AdminProfileSummary struct {
@@ -38,6 +40,7 @@ type AdminProfilePayload struct {
 	Model              string                `json:"model,omitempty"`
 	AllowedTools       []string              `json:"allowed_tools,omitempty"`
 	Skills             []string              `json:"skills,omitempty"`
+	SkillRefs          []string              `json:"skill_refs,omitempty"`
 	SystemPrompt       string                `json:"system_prompt,omitempty"`
 	SystemPromptMasked bool                  `json:"system_prompt_masked,omitempty"`
 	Env                []AdminProfileEnvItem `json:"env,omitempty"`
@@ -232,6 +235,11 @@ func decodeAdminProfilePayload(w http.ResponseWriter, r *http.Request, payload *
 	if err := decoder.Decode(payload); err != nil {
 		return fmt.Errorf("invalid profile payload")
 	}
+	for _, ref := range payload.SkillRefs {
+		if _, err := skill_store.ParseRef(ref); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
@@ -278,7 +286,7 @@ func buildAdminProfilePayload(key string, profile ProfileConfig, defaultKey stri
 	sort.Strings(keys)
 	for _, envKey := range keys {
 		value := profile.Env[envKey]
-		masked := isSensitiveEnvKey(envKey)
+		masked := isSensitiveEnvKey(envKey) && !isSecretReference(value)
 		if masked {
 			value = maskValue(value)
 		}
@@ -296,6 +304,7 @@ func buildAdminProfilePayload(key string, profile ProfileConfig, defaultKey stri
 		Model:              profile.Model,
 		AllowedTools:       profile.AllowedTools,
 		Skills:             profile.Skills,
+		SkillRefs:          profile.SkillRefs,
 		SystemPrompt:       profile.SystemPrompt,
 		SystemPromptMasked: false,
 		Env:                envItems,
@@ -310,7 +319,23 @@ func buildProfileFromPayload(payload AdminProfilePayload, existing ProfileConfig
 		Model:        payload.Model,
 		AllowedTools: payload.AllowedTools,
 		Skills:       payload.Skills,
+		SkillRefs:    payload.SkillRefs,
+		Middleware:   existing.Middleware,
+		Cache:        existing.Cache,
+		Coalesce:     existing.Coalesce,
+		Priority:     existing.Priority,
 		Env:          map[string]string{},
+
+		PromptDelivery:    existing.PromptDelivery,
+		PromptArgMaxBytes: existing.PromptArgMaxBytes,
+		WorkDir:           existing.WorkDir,
+		Codex:             existing.Codex,
+		CaptureSteps:      existing.CaptureSteps,
+		Approval:          existing.Approval,
+		MCPServers:        existing.MCPServers,
+		Sandbox:           existing.Sandbox,
+		Resources:         existing.Resources,
+		EnvPolicy:         existing.EnvPolicy,
 	}
 
 	updated.SystemPrompt = payload.SystemPrompt
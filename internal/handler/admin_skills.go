@@ -0,0 +1,246 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	"dify-cli-gateway/internal/fs_policy"
+	"dify-cli-gateway/internal/skill_store"
+)
+
+var (
+	skillStore   *skill_store.Store
+	skillStoreMu sync.RWMutex
+)
+
+// AdminSkill 后台展示的 skill（附带当前版本路径与引用它的 profile）
+type AdminSkill struct {
+	skill_store.Skill
+	Path   string   `json:"path,omitempty"`
+	UsedBy []string `json:"used_by"`
+}
+
+// InitSkillStore 初始化受管 skills 存储
+func InitSkillStore() {
+	cfg := GetSkillsConfig()
+	store, err := skill_store.NewStore(cfg.Root, skill_store.Limits{
+		MaxBundleBytes:    int64(cfg.MaxBundleMB) << 20,
+		MaxExtractedBytes: int64(cfg.MaxExtractedMB) << 20,
+		MaxFiles:          cfg.MaxFiles,
+	}, cfg.MaxVersions)
+	if err != nil {
+		log.Printf("⚠️  Skills store unavailable, skill_refs will be ignored: %v", err)
+		return
+	}
+	store.SetRefsFunc(skillRefsTo)
+
+	skillStoreMu.Lock()
+	skillStore = store
+	skillStoreMu.Unlock()
+	log.Printf("✅ Skills store initialized (root=%s, skills=%d)", store.Root(), len(store.List()))
+}
+
+func getSkillStore() *skill_store.Store {
+	skillStoreMu.RLock()
+	store := skillStore
+	skillStoreMu.RUnlock()
+	if store == nil {
+		InitSkillStore()
+		skillStoreMu.RLock()
+		store = skillStore
+		skillStoreMu.RUnlock()
+	}
+	return store
+}
+
+// resolveSkillRefs 将 profile 的 skill_refs 解析为版本目录；无法解析的引用记录警告后跳过
+func resolveSkillRefs(refs []string) []string {
+	if len(refs) == 0 {
+		return nil
+	}
+	store := getSkillStore()
+	if store == nil {
+		log.Printf("⚠️  Skills store unavailable, ignoring skill_refs %v", refs)
+		return nil
+	}
+	paths := make([]string, 0, len(refs))
+	for _, ref := range refs {
+		path, err := store.Resolve(ref)
+		if err != nil {
+			log.Printf("⚠️  Skill %s not resolved: %v", ref, err)
+			continue
+		}
+		paths = append(paths, path)
+	}
+	return paths
+}
+
+// handleAdminSkills 处理 /api/skills：上传、列表、详情、版本切换（回滚）与删除
+func handleAdminSkills(w http.ResponseWriter, r *http.Request, relativePath string) {
+	store := getSkillStore()
+	if store == nil {
+		writeJSON(w, http.StatusServiceUnavailable, map[string]string{"error": "skills store unavailable"})
+		return
+	}
+
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(relativePath, "/api/skills"), "/"), "/")
+	switch {
+	case parts[0] == "":
+		switch r.Method {
+		case http.MethodGet:
+			skills := store.List()
+			items := make([]AdminSkill, 0, len(skills))
+			for _, skill := range skills {
+				items = append(items, buildAdminSkill(store, skill))
+			}
+			writeJSON(w, http.StatusOK, map[string]interface{}{"skills": items, "root": store.Root()})
+		case http.MethodPost:
+			handleAdminSkillUpload(w, r, store)
+		default:
+			writeMethodNotAllowed(w)
+		}
+	case len(parts) == 1:
+		switch r.Method {
+		case http.MethodGet:
+			skill, err := store.Get(parts[0])
+			if err != nil {
+				writeSkillError(w, err)
+				return
+			}
+			writeJSON(w, http.StatusOK, buildAdminSkill(store, skill))
+		case http.MethodDelete:
+			if usedBy := skillUsedBy(parts[0]); len(usedBy) > 0 {
+				writeJSON(w, http.StatusConflict, map[string]interface{}{"error": "skill is referenced by profiles", "used_by": usedBy})
+				return
+			}
+			if err := store.Delete(parts[0]); err != nil {
+				writeSkillError(w, err)
+				return
+			}
+			writeJSON(w, http.StatusOK, map[string]string{"status": "deleted"})
+		default:
+			writeMethodNotAllowed(w)
+		}
+	case len(parts) == 2 && parts[1] == "activate":
+		if r.Method != http.MethodPost {
+			writeMethodNotAllowed(w)
+			return
+		}
+		var payload struct {
+			Version int `json:"version"`
+		}
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&payload); err != nil || payload.Version <= 0 {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "version is required"})
+			return
+		}
+		skill, err := store.Activate(parts[0], payload.Version)
+		if err != nil {
+			writeSkillError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, buildAdminSkill(store, skill))
+	default:
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "not found"})
+	}
+}
+
+// handleAdminSkillUpload 接收 multipart 的 file 字段或直接上传的 zip / tar / tar.gz 请求体
+func handleAdminSkillUpload(w http.ResponseWriter, r *http.Request, store *skill_store.Store) {
+	if decision := checkFSPath(fs_policy.PurposeUpload, store.Root(), "upload:"+r.RemoteAddr); !decision.Allowed {
+		writeJSON(w, http.StatusForbidden, map[string]string{"error": "skills root denied by fs_policy: " + decision.Reason})
+		return
+	}
+
+	cfg := GetSkillsConfig()
+	// 预留 1MB 给 multipart 边界与其他字段，实际包大小由 store 校验
+	r.Body = http.MaxBytesReader(w, r.Body, int64(cfg.MaxBundleMB+1)<<20)
+
+	var bundle io.Reader = r.Body
+	if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType == "multipart/form-data" {
+		file, _, err := r.FormFile("file")
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "file field is required"})
+			return
+		}
+		defer file.Close()
+		bundle = file
+	}
+
+	skill, version, err := store.Install(bundle, r.RemoteAddr)
+	if err != nil {
+		writeSkillError(w, err)
+		return
+	}
+	log.Printf("📚 [Admin] Skill %s v%d uploaded from %s", skill.Name, version.Version, r.RemoteAddr)
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"skill":   buildAdminSkill(store, skill),
+		"version": version,
+	})
+}
+
+func buildAdminSkill(store *skill_store.Store, skill skill_store.Skill) AdminSkill {
+	item := AdminSkill{Skill: skill, UsedBy: skillUsedBy(skill.Name)}
+	if path, err := store.Resolve(skill.Name); err == nil {
+		item.Path = path
+	}
+	return item
+}
+
+// skillRefsTo 返回所有 profile 的 skill_refs 中指向该 skill 的引用（用于保留固定版本、阻止删除）
+func skillRefsTo(name string) []skill_store.Ref {
+	var refs []skill_store.Ref
+	cfg := getGlobalConfig()
+	if cfg == nil {
+		return refs
+	}
+	for _, profile := range cfg.Profiles {
+		for _, value := range profile.SkillRefs {
+			if ref, err := skill_store.ParseRef(value); err == nil && ref.Name == name {
+				refs = append(refs, ref)
+			}
+		}
+	}
+	return refs
+}
+
+// skillUsedBy 返回 skill_refs 引用该 skill 的 profile
+func skillUsedBy(name string) []string {
+	usedBy := []string{}
+	cfg := getGlobalConfig()
+	if cfg == nil {
+		return usedBy
+	}
+	for key, profile := range cfg.Profiles {
+		for _, value := range profile.SkillRefs {
+			if ref, err := skill_store.ParseRef(value); err == nil && ref.Name == name {
+				usedBy = append(usedBy, key)
+				break
+			}
+		}
+	}
+	sort.Strings(usedBy)
+	return usedBy
+}
+
+func writeSkillError(w http.ResponseWriter, err error) {
+	var maxBytesErr *http.MaxBytesError
+	switch {
+	case errors.Is(err, skill_store.ErrNotFound):
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": err.Error()})
+	case errors.Is(err, skill_store.ErrInvalidBundle), errors.Is(err, skill_store.ErrInvalidRef):
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+	case errors.Is(err, skill_store.ErrInUse):
+		writeJSON(w, http.StatusConflict, map[string]string{"error": err.Error()})
+	case errors.As(err, &maxBytesErr):
+		writeJSON(w, http.StatusRequestEntityTooLarge, map[string]string{"error": "skill bundle too large"})
+	default:
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+}
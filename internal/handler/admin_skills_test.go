@@ -0,0 +1,92 @@
+package handler
+
+import (
+	"archive/zip"
+	"bytes"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func skillZip(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, body := range files {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte(body))
+	}
+	zw.Close()
+	return buf.Bytes()
+}
+
+func TestAdminSkills_UploadAssignAndRollback(t *testing.T) {
+	root := t.TempDir()
+	withGlobalConfig(t, &Config{
+		Profiles: map[string]ProfileConfig{
+			"reports": {CLI: "claude", SkillRefs: []string{"pdf-tools", "missing"}},
+			"legacy":  {CLI: "claude", SkillRefs: []string{"pdf-tools@1"}},
+		},
+		Skills: &SkillsConfig{Root: root, MaxVersions: 1},
+	})
+	InitSkillStore()
+	t.Cleanup(func() {
+		skillStoreMu.Lock()
+		skillStore = nil
+		skillStoreMu.Unlock()
+	})
+
+	call := func(method, path string, body []byte, contentType string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/v1/admin"+path, bytes.NewReader(body))
+		if contentType != "" {
+			req.Header.Set("Content-Type", contentType)
+		}
+		rec := httptest.NewRecorder()
+		handleAdminSkills(rec, req, path)
+		return rec
+	}
+
+	v1 := skillZip(t, map[string]string{"SKILL.md": "---\nname: pdf-tools\ndescription: Extract text\n---\n"})
+	if rec := call(http.MethodPost, "/api/skills", v1, "application/zip"); rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"used_by":["legacy","reports"]`) {
+		t.Fatalf("raw upload failed: %d %s", rec.Code, rec.Body.String())
+	}
+
+	var form bytes.Buffer
+	mw := multipart.NewWriter(&form)
+	part, _ := mw.CreateFormFile("file", "pdf-tools.zip")
+	part.Write(skillZip(t, map[string]string{"SKILL.md": "---\nname: pdf-tools\ndescription: Extract and summarize\n---\n"}))
+	mw.Close()
+	if rec := call(http.MethodPost, "/api/skills", form.Bytes(), mw.FormDataContentType()); rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"active_version":2`) {
+		t.Fatalf("multipart upload failed: %d %s", rec.Code, rec.Body.String())
+	}
+
+	paths := resolveSkillRefs(getGlobalConfig().Profiles["reports"].SkillRefs)
+	if len(paths) != 1 || paths[0] != filepath.Join(getSkillStore().Root(), "pdf-tools", "v2") {
+		t.Errorf("skill_refs should resolve to the active version and skip unknown skills: %v", paths)
+	}
+
+	// v1 被 legacy 固定，超过 max_versions 也不会被清理
+	if rec := call(http.MethodPost, "/api/skills/pdf-tools/activate", []byte(`{"version":1}`), ""); rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"description":"Extract text"`) {
+		t.Fatalf("rollback failed: %d %s", rec.Code, rec.Body.String())
+	}
+	if paths = resolveSkillRefs([]string{"pdf-tools"}); len(paths) != 1 || filepath.Base(paths[0]) != "v1" {
+		t.Errorf("rollback should switch the resolved version: %v", paths)
+	}
+
+	evil := skillZip(t, map[string]string{"SKILL.md": "---\nname: evil\ndescription: x\n---\n", "../../configs.json": "{}"})
+	if rec := call(http.MethodPost, "/api/skills", evil, "application/zip"); rec.Code != http.StatusBadRequest {
+		t.Errorf("path traversal should be rejected with 400, got %d %s", rec.Code, rec.Body.String())
+	}
+	if rec := call(http.MethodDelete, "/api/skills/pdf-tools", nil, ""); rec.Code != http.StatusConflict {
+		t.Errorf("skills referenced by profiles cannot be deleted, got %d", rec.Code)
+	}
+	if rec := call(http.MethodGet, "/api/skills/unknown", nil, ""); rec.Code != http.StatusNotFound {
+		t.Errorf("unknown skill should be 404, got %d", rec.Code)
+	}
+}
@@ -13,6 +13,8 @@ import (
 	"path"
 	"strings"
 	"time"
+
+	"dify-cli-gateway/internal/cli"
 )
 
 //go:embed admin_ui_static/**
@@ -95,8 +97,31 @@ func (h *adminUIHandler) serveAPI(w http.ResponseWriter, r *http.Request, relati
 			"status": "ok",
 			"time":   time.Now().Format(time.RFC3339),
 		})
+	case relativePath == "/api/metrics":
+		if r.Method != http.MethodGet {
+			writeMethodNotAllowed(w)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"clis":               cli.GatewayMetrics().GetSummary(),
+			"coalesce_in_flight": cli.InFlightCoalesced(),
+		})
+	case relativePath == "/api/queue":
+		handleAdminQueue(w, r)
+	case relativePath == "/api/cache":
+		handleAdminCache(w, r)
+	case relativePath == "/api/sessions" || strings.HasPrefix(relativePath, "/api/sessions/"):
+		handleAdminSessions(w, r, relativePath)
+	case relativePath == "/api/approvals" || strings.HasPrefix(relativePath, "/api/approvals/"):
+		handleAdminApprovals(w, r, relativePath)
+	case relativePath == "/api/compare" || strings.HasPrefix(relativePath, "/api/compare/"):
+		handleAdminCompare(w, r, relativePath)
 	case strings.HasPrefix(relativePath, "/api/mcp/"):
 		handleAdminMCP(w, r, relativePath)
+	case relativePath == "/api/skills" || strings.HasPrefix(relativePath, "/api/skills/"):
+		handleAdminSkills(w, r, relativePath)
+	case relativePath == "/api/fs-policy":
+		handleAdminFSPolicy(w, r)
 	case strings.HasPrefix(relativePath, "/api/config/profiles"):
 		handleAdminProfiles(w, r, relativePath)
 	case relativePath == "/api/config":
@@ -0,0 +1,192 @@
+package handler
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"dify-cli-gateway/internal/admission"
+	"dify-cli-gateway/internal/cli"
+)
+
+var (
+	admissionMu        sync.RWMutex
+	admissionScheduler *admission.Scheduler
+)
+
+// InitAdmission 根据配置初始化（或热更新）准入队列
+func InitAdmission() {
+	cfg := GetAdmissionConfig()
+
+	admissionMu.Lock()
+	defer admissionMu.Unlock()
+
+	if !cfg.Enabled {
+		if admissionScheduler != nil {
+			log.Printf("🚦 Admission queue disabled")
+		}
+		admissionScheduler = nil
+		return
+	}
+
+	schedulerCfg := admission.Config{
+		MaxConcurrent: cfg.MaxConcurrent,
+		MaxQueueDepth: cfg.MaxQueueDepth,
+		MaxWait:       time.Duration(cfg.MaxWaitMS) * time.Millisecond,
+		TenantWeights: cfg.TenantWeights,
+	}
+	if admissionScheduler != nil {
+		admissionScheduler.Configure(schedulerCfg)
+	} else {
+		admissionScheduler = admission.NewScheduler(schedulerCfg)
+	}
+	log.Printf("🚦 Admission queue initialized (max_concurrent=%d, max_queue_depth=%d, max_wait=%dms)",
+		cfg.MaxConcurrent, cfg.MaxQueueDepth, cfg.MaxWaitMS)
+}
+
+func getAdmissionScheduler() *admission.Scheduler {
+	admissionMu.RLock()
+	defer admissionMu.RUnlock()
+	return admissionScheduler
+}
+
+// admissionMetadata 从请求中解析租户与优先级，写入请求元数据
+// tenant 由 API Key 决定；priority_max 来自 API Key 配置；priority_requested 来自请求头
+func admissionMetadata(r *http.Request, metadata map[string]string) {
+	cfg := GetAdmissionConfig()
+	apiKey := extractAPIKey(r)
+	tenant := "anonymous"
+	if apiKey != "" {
+		tenant = "key:" + shortHash(apiKey)
+		for _, item := range cfg.APIKeys {
+			if item.Key != "" && tokensMatch(item.Key, apiKey) {
+				if item.Tenant != "" {
+					tenant = item.Tenant
+				}
+				if item.Priority != "" {
+					metadata["priority_max"] = item.Priority
+				}
+				break
+			}
+		}
+	}
+	metadata["tenant"] = tenant
+	if value := r.Header.Get(cfg.PriorityHeader); value != "" {
+		metadata["priority_requested"] = value
+	}
+}
+
+// resolveAdmissionClass 计算最终优先级：API Key > profile > 默认值；请求头只能降低优先级
+func resolveAdmissionClass(metadata map[string]string, profile *ProfileConfig) admission.Class {
+	base := GetAdmissionConfig().DefaultPriority
+	if profile != nil && profile.Priority != "" {
+		base = profile.Priority
+	}
+	if value := metadata["priority_max"]; value != "" {
+		base = value
+	}
+	class, err := admission.ParseClass(base)
+	if err != nil {
+		log.Printf("⚠️  %v, using interactive", err)
+		class = admission.ClassInteractive
+	}
+	if value := metadata["priority_requested"]; value != "" {
+		requested, err := admission.ParseClass(value)
+		if err != nil {
+			log.Printf("⚠️  Ignoring priority header: %v", err)
+		} else if requested.Rank() > class.Rank() {
+			class = requested
+		}
+	}
+	return class
+}
+
+// extractAPIKey 读取 X-API-Key 或 Authorization: Bearer
+func extractAPIKey(r *http.Request) string {
+	if key := strings.TrimSpace(r.Header.Get("X-API-Key")); key != "" {
+		return key
+	}
+	auth := r.Header.Get("Authorization")
+	if strings.HasPrefix(strings.ToLower(auth), "bearer ") {
+		return strings.TrimSpace(auth[len("bearer "):])
+	}
+	return ""
+}
+
+func shortHash(value string) string {
+	sum := sha256.Sum256([]byte(value))
+	return hex.EncodeToString(sum[:])[:8]
+}
+
+// admissionMiddleware 管道最内层：排队等待执行槽位后再启动 CLI
+// 放在 cache / coalesce 之后，命中缓存或合并的请求不占用槽位
+type admissionMiddleware struct {
+	scheduler *admission.Scheduler
+}
+
+func (m *admissionMiddleware) Name() string {
+	return "admission"
+}
+
+func (m *admissionMiddleware) Handle(req *cli.PipelineRequest, next cli.PipelineHandler) (string, error) {
+	class := admission.Class(req.Meta("priority"))
+	tenant := req.Meta("tenant")
+	// 使用请求上的上下文：合并请求时为共享执行的上下文，所有调用方都断开后才取消排队
+	ctx := req.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	release, waited, err := m.scheduler.Acquire(ctx, admission.Request{
+		Tenant:  tenant,
+		Class:   class,
+		Profile: req.Profile,
+	})
+	req.SetMeta("queue_wait_ms", strconv.FormatInt(waited.Milliseconds(), 10))
+	if err != nil {
+		log.Printf("🚦 [Admission] Not admitted: tenant=%s class=%s profile=%s waited=%v: %v", tenant, class, req.Profile, waited, err)
+		return "", err
+	}
+	defer release()
+	if waited > 0 {
+		log.Printf("🚦 [Admission] Admitted after %v: tenant=%s class=%s profile=%s", waited, tenant, class, req.Profile)
+	}
+	return next(req)
+}
+
+// cliErrorStatus 返回 CLI 调用错误对应的 HTTP 状态码
+func cliErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, admission.ErrQueueFull):
+		return http.StatusTooManyRequests
+	case errors.Is(err, admission.ErrWaitTimeout), errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return http.StatusServiceUnavailable
+	}
+	if status, ok := sessionErrorStatus(err); ok {
+		return status
+	}
+	return http.StatusInternalServerError
+}
+
+// handleAdminQueue 返回准入队列状态
+func handleAdminQueue(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeMethodNotAllowed(w)
+		return
+	}
+	scheduler := getAdmissionScheduler()
+	if scheduler == nil {
+		writeJSON(w, http.StatusOK, map[string]interface{}{"enabled": false})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"enabled": true,
+		"state":   scheduler.Snapshot(),
+	})
+}
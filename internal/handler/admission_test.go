@@ -0,0 +1,47 @@
+package handler
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"dify-cli-gateway/internal/admission"
+)
+
+func TestResolveAdmissionClass(t *testing.T) {
+	withGlobalConfig(t, &Config{
+		Admission: &AdmissionConfig{
+			Enabled: true,
+			APIKeys: []AdmissionAPIKey{{Key: "batch-key", Tenant: "etl", Priority: "batch"}},
+		},
+	})
+
+	req := httptest.NewRequest("POST", "/chat", nil)
+	req.Header.Set("Authorization", "Bearer batch-key")
+	req.Header.Set("X-Priority", "interactive")
+	metadata := map[string]string{}
+	admissionMetadata(req, metadata)
+
+	if metadata["tenant"] != "etl" {
+		t.Fatalf("expected tenant etl, got %q", metadata["tenant"])
+	}
+	// 请求头不能提升 API Key 限定的优先级
+	if class := resolveAdmissionClass(metadata, nil); class != admission.ClassBatch {
+		t.Fatalf("expected batch, got %s", class)
+	}
+
+	// 请求头可以降低优先级
+	metadata["priority_requested"] = "background"
+	if class := resolveAdmissionClass(metadata, nil); class != admission.ClassBackground {
+		t.Fatalf("expected background, got %s", class)
+	}
+
+	// 无 API Key 时使用 profile 优先级
+	anonymous := map[string]string{}
+	admissionMetadata(httptest.NewRequest("POST", "/chat", nil), anonymous)
+	if anonymous["tenant"] != "anonymous" {
+		t.Fatalf("expected anonymous tenant, got %q", anonymous["tenant"])
+	}
+	if class := resolveAdmissionClass(anonymous, &ProfileConfig{Priority: "batch"}); class != admission.ClassBatch {
+		t.Fatalf("expected profile batch priority, got %s", class)
+	}
+}
@@ -0,0 +1,264 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"dify-cli-gateway/internal/approval"
+	"dify-cli-gateway/internal/cli"
+)
+
+var (
+	approvalQueueMu sync.RWMutex
+	approvalQueue   *approval.Queue
+
+	approvalKeepAlive = 15 * time.Second
+)
+
+// InitApprovals 初始化工具调用审批队列
+func InitApprovals() {
+	cfg := GetApprovalConfig()
+	queue := approval.NewQueue(approval.Config{
+		Timeout:     time.Duration(cfg.TimeoutSeconds) * time.Second,
+		HistorySize: cfg.HistorySize,
+		Notify:      approval.NewWebhookNotifier(cfg.Webhooks, cfg.WebhookSecret, 0),
+	})
+
+	approvalQueueMu.Lock()
+	approvalQueue = queue
+	approvalQueueMu.Unlock()
+	log.Printf("✅ Approval queue initialized (callback=%s, timeout=%ds, webhooks=%d)", cfg.CallbackURL, cfg.TimeoutSeconds, len(cfg.Webhooks))
+}
+
+func getApprovalQueue() *approval.Queue {
+	approvalQueueMu.RLock()
+	queue := approvalQueue
+	approvalQueueMu.RUnlock()
+	if queue == nil {
+		InitApprovals()
+		approvalQueueMu.RLock()
+		queue = approvalQueue
+		approvalQueueMu.RUnlock()
+	}
+	return queue
+}
+
+// beginApproval profile 启用审批时为本次调用注入 permission-prompt MCP server，返回结束时的清理函数
+func beginApproval(profile *ProfileConfig, profileKey, cliName string, opts *cli.RunOptions, metadata map[string]string) func() {
+	if profile == nil || profile.Approval == nil || !profile.Approval.Enabled {
+		return func() {}
+	}
+	if cliName != "claude" {
+		log.Printf("⚠️  [Approval] %s does not support --permission-prompt-tool, approval is skipped", cliName)
+		return func() {}
+	}
+	if opts.PermissionMode == "bypassPermissions" {
+		log.Printf("⚠️  [Approval] permission_mode=bypassPermissions, tool calls will not be sent for approval")
+	}
+
+	cfg := GetApprovalConfig()
+	timeout := time.Duration(cfg.TimeoutSeconds) * time.Second
+	if profile.Approval.TimeoutSeconds > 0 {
+		timeout = time.Duration(profile.Approval.TimeoutSeconds) * time.Second
+	}
+	queue := getApprovalQueue()
+	token := queue.Open(approval.Scope{
+		Profile:         profileKey,
+		CLI:             cliName,
+		Owner:           metadata["api_key_id"],
+		Tenant:          metadata["tenant"],
+		Rules:           profile.Approval.Rules,
+		Timeout:         timeout,
+		TimeoutDecision: profile.Approval.TimeoutDecision,
+	})
+
+	if opts.MCPServers == nil {
+		opts.MCPServers = make(map[string]cli.MCPServerConfig)
+	}
+	opts.MCPServers[approval.ServerName] = cli.MCPServerConfig{
+		Type: "http",
+		URL:  cfg.CallbackURL + "/approvals/mcp/" + token,
+	}
+	opts.PermissionPromptTool = approval.PermissionPromptTool
+	// 等待审批期间 MCP 调用不能先于审批超时
+	if _, ok := opts.Env["MCP_TOOL_TIMEOUT"]; !ok {
+		opts.Env["MCP_TOOL_TIMEOUT"] = strconv.FormatInt((timeout + time.Minute).Milliseconds(), 10)
+	}
+	return func() { queue.Close(token) }
+}
+
+// ApprovalDecisionRequest 审批决定请求体
+type ApprovalDecisionRequest struct {
+	Decision     string          `json:"decision"`                // allow / deny
+	Message      string          `json:"message,omitempty"`       // 拒绝原因（返回给 CLI）
+	UpdatedInput json.RawMessage `json:"updated_input,omitempty"` // 允许时替换工具参数
+}
+
+// HandleApprovals 处理 /approvals（调用方只能查看与决定自己发起的审批）以及 CLI 访问的 /approvals/mcp/{token}
+func HandleApprovals(w http.ResponseWriter, r *http.Request) {
+	queue := getApprovalQueue()
+	path := strings.Trim(strings.TrimPrefix(r.URL.Path, "/approvals"), "/")
+
+	if strings.HasPrefix(path, "mcp/") {
+		// token 即凭证，仅在本次 CLI 调用期间有效
+		token := strings.TrimPrefix(path, "mcp/")
+		approval.NewPermissionServer(queue, token).ServeHTTP(w, r)
+		return
+	}
+
+	log.Printf("📥 Received request: %s %s from %s", r.Method, r.URL.Path, r.RemoteAddr)
+	// 匿名调用方之间无法区分，只能由运维在后台处理其审批
+	owner := requestOwner(r)
+	if owner == "" {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "approvals require an API key (X-API-Key or Authorization: Bearer)"})
+		return
+	}
+	ownedBy := func(req approval.Request) bool { return req.Owner != "" && req.Owner == owner }
+
+	switch {
+	case path == "" && r.Method == http.MethodGet:
+		writeJSON(w, http.StatusOK, map[string]interface{}{"approvals": filterApprovals(queue.Pending(), ownedBy)})
+	case path == "events" && r.Method == http.MethodGet:
+		streamApprovalEvents(w, r, queue, ownedBy)
+	case path == "" || path == "events":
+		writeMethodNotAllowed(w)
+	default:
+		req, ok := queue.Get(path)
+		if !ok || !ownedBy(req) {
+			writeJSON(w, http.StatusNotFound, map[string]string{"error": approval.ErrNotFound.Error()})
+			return
+		}
+		serveApproval(w, r, queue, req, approval.DecidedByUser)
+	}
+}
+
+// handleAdminApprovals 后台审批：GET /api/approvals、GET /api/approvals/events、GET|POST /api/approvals/{id}
+func handleAdminApprovals(w http.ResponseWriter, r *http.Request, relativePath string) {
+	queue := getApprovalQueue()
+	id := strings.Trim(strings.TrimPrefix(relativePath, "/api/approvals"), "/")
+	anyOwner := func(approval.Request) bool { return true }
+
+	switch {
+	case id == "" && r.Method == http.MethodGet:
+		writeJSON(w, http.StatusOK, map[string]interface{}{"approvals": queue.Pending()})
+	case id == "events" && r.Method == http.MethodGet:
+		streamApprovalEvents(w, r, queue, anyOwner)
+	case id == "" || id == "events":
+		writeMethodNotAllowed(w)
+	default:
+		req, ok := queue.Get(id)
+		if !ok {
+			writeJSON(w, http.StatusNotFound, map[string]string{"error": approval.ErrNotFound.Error()})
+			return
+		}
+		serveApproval(w, r, queue, req, approval.DecidedByOperator)
+	}
+}
+
+// serveApproval GET 查询单个审批，POST 给出决定
+func serveApproval(w http.ResponseWriter, r *http.Request, queue *approval.Queue, req approval.Request, by string) {
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, req)
+	case http.MethodPost:
+		var body ApprovalDecisionRequest
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid JSON request body"})
+			return
+		}
+		decision, err := parseApprovalDecision(body)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+		decided, err := queue.Decide(req.ID, decision, by)
+		switch {
+		case errors.Is(err, approval.ErrAlreadyDecided):
+			writeJSON(w, http.StatusConflict, map[string]interface{}{"error": err.Error(), "approval": decided})
+		case err != nil:
+			writeJSON(w, http.StatusNotFound, map[string]string{"error": err.Error()})
+		default:
+			writeJSON(w, http.StatusOK, decided)
+		}
+	default:
+		writeMethodNotAllowed(w)
+	}
+}
+
+func parseApprovalDecision(body ApprovalDecisionRequest) (approval.Decision, error) {
+	switch strings.ToLower(strings.TrimSpace(body.Decision)) {
+	case "allow", "approve", "approved":
+		if len(body.UpdatedInput) > 0 && !json.Valid(body.UpdatedInput) {
+			return approval.Decision{}, fmt.Errorf("updated_input must be valid JSON")
+		}
+		return approval.Decision{Allow: true, Message: body.Message, UpdatedInput: body.UpdatedInput}, nil
+	case "deny", "denied", "reject":
+		return approval.Decision{Message: body.Message}, nil
+	}
+	return approval.Decision{}, fmt.Errorf("decision must be allow or deny")
+}
+
+func filterApprovals(list []approval.Request, keep func(approval.Request) bool) []approval.Request {
+	filtered := make([]approval.Request, 0, len(list))
+	for _, req := range list {
+		if keep(req) {
+			filtered = append(filtered, req)
+		}
+	}
+	return filtered
+}
+
+// streamApprovalEvents 以 SSE 推送审批事件；连接建立时先推送当前等待中的审批
+func streamApprovalEvents(w http.ResponseWriter, r *http.Request, queue *approval.Queue, keep func(approval.Request) bool) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "streaming unsupported"})
+		return
+	}
+	events, cancel := queue.Subscribe()
+	defer cancel()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	for _, req := range filterApprovals(queue.Pending(), keep) {
+		writeApprovalEvent(w, approval.Event{Type: approval.EventPending, Approval: req})
+	}
+	flusher.Flush()
+
+	ticker := time.NewTicker(approvalKeepAlive)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-ticker.C:
+			fmt.Fprint(w, ": keep-alive\n\n")
+			flusher.Flush()
+		case event, ok := <-events:
+			if !ok {
+				return
+			}
+			if keep(event.Approval) {
+				writeApprovalEvent(w, event)
+				flusher.Flush()
+			}
+		}
+	}
+}
+
+func writeApprovalEvent(w http.ResponseWriter, event approval.Event) {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return
+	}
+	fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Type, data)
+}
@@ -0,0 +1,88 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"dify-cli-gateway/internal/approval"
+	"dify-cli-gateway/internal/cli"
+)
+
+func TestApprovals_PermissionPromptRoundTrip(t *testing.T) {
+	InitApprovals()
+	profile := &ProfileConfig{Approval: &ProfileApprovalConfig{Enabled: true, Rules: []approval.Rule{{Tool: "Read"}}}}
+	opts := &cli.RunOptions{Env: map[string]string{}}
+	closeApproval := beginApproval(profile, "p", "claude", opts, map[string]string{"api_key_id": apiKeyFingerprint("k1")})
+	defer closeApproval()
+
+	server, ok := opts.MCPServers[approval.ServerName]
+	if !ok || opts.PermissionPromptTool != approval.PermissionPromptTool || opts.Env["MCP_TOOL_TIMEOUT"] == "" {
+		t.Fatalf("approval server not injected: %+v", opts)
+	}
+	mcpPath := server.URL[strings.Index(server.URL, "/approvals/"):]
+
+	call := func(tool string) chan string {
+		done := make(chan string, 1)
+		go func() {
+			body := `{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"approve","arguments":{"tool_name":"` + tool + `","input":{"command":"make"}}}}`
+			rec := httptest.NewRecorder()
+			HandleApprovals(rec, httptest.NewRequest(http.MethodPost, mcpPath, strings.NewReader(body)))
+			done <- rec.Body.String()
+		}()
+		return done
+	}
+
+	if resp := <-call("Read"); !strings.Contains(resp, `\"behavior\":\"allow\"`) {
+		t.Errorf("rule should approve immediately: %s", resp)
+	}
+
+	done := call("Bash")
+	list := func(apiKey string) []approval.Request {
+		req := httptest.NewRequest(http.MethodGet, "/approvals", nil)
+		req.Header.Set("X-API-Key", apiKey)
+		rec := httptest.NewRecorder()
+		HandleApprovals(rec, req)
+		var body struct {
+			Approvals []approval.Request `json:"approvals"`
+		}
+		json.Unmarshal(rec.Body.Bytes(), &body)
+		return body.Approvals
+	}
+	var pending []approval.Request
+	for deadline := time.Now().Add(2 * time.Second); len(pending) == 0 && time.Now().Before(deadline); {
+		pending = list("k1")
+	}
+	if len(pending) != 1 || pending[0].Tool != "Bash" {
+		t.Fatalf("expected one pending approval, got %+v", pending)
+	}
+	if other := list("k2"); len(other) != 0 {
+		t.Errorf("other API keys must not see the approval: %+v", other)
+	}
+
+	decide := func(apiKey string) int {
+		req := httptest.NewRequest(http.MethodPost, "/approvals/"+pending[0].ID, strings.NewReader(`{"decision":"deny","message":"not now"}`))
+		req.Header.Set("X-API-Key", apiKey)
+		rec := httptest.NewRecorder()
+		HandleApprovals(rec, req)
+		return rec.Code
+	}
+	if code := decide(""); code != http.StatusUnauthorized {
+		t.Errorf("anonymous callers must not decide approvals, got %d", code)
+	}
+	if code := decide("k2"); code != http.StatusNotFound {
+		t.Errorf("expected 404 for another owner, got %d", code)
+	}
+	if code := decide("k1"); code != http.StatusOK {
+		t.Errorf("expected 200, got %d", code)
+	}
+	if resp := <-done; !strings.Contains(resp, `\"behavior\":\"deny\"`) || !strings.Contains(resp, "not now") {
+		t.Errorf("unexpected permission response: %s", resp)
+	}
+	if code := decide("k1"); code != http.StatusConflict {
+		t.Errorf("expected 409 for a decided approval, got %d", code)
+	}
+}
@@ -0,0 +1,242 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"dify-cli-gateway/internal/batch"
+)
+
+var (
+	batchManagerMu sync.RWMutex
+	batchManager   *batch.Manager
+)
+
+// InitBatchManager 初始化批任务管理器（存储目录不可用时退化为内存模式）
+func InitBatchManager() {
+	cfg := GetBatchConfig()
+	manager, err := batch.NewManager(batch.ManagerConfig{
+		StorageDir:     cfg.StorageDir,
+		MaxConcurrency: cfg.MaxConcurrency,
+	}, executeBatchItem)
+	if err != nil {
+		log.Printf("⚠️  Batch storage unavailable, progress will not survive restart: %v", err)
+		manager, err = batch.NewManager(batch.ManagerConfig{MaxConcurrency: cfg.MaxConcurrency}, executeBatchItem)
+		if err != nil {
+			log.Printf("❌ Batch manager unavailable: %v", err)
+			return
+		}
+	}
+
+	batchManagerMu.Lock()
+	batchManager = manager
+	batchManagerMu.Unlock()
+	log.Printf("✅ Batch manager initialized (storage=%s, max_concurrency=%d, max_items=%d)", cfg.StorageDir, cfg.MaxConcurrency, cfg.MaxItems)
+}
+
+func getBatchManager() *batch.Manager {
+	batchManagerMu.RLock()
+	manager := batchManager
+	batchManagerMu.RUnlock()
+	if manager == nil {
+		InitBatchManager()
+		batchManagerMu.RLock()
+		manager = batchManager
+		batchManagerMu.RUnlock()
+	}
+	return manager
+}
+
+// executeBatchItem 通过 runCLI 执行单个批任务条目
+func executeBatchItem(ctx context.Context, item batch.Item, labels map[string]string) (batch.Result, error) {
+	if shouldGuardPrompt(item.Prompt) {
+		return parseBatchOutput(guardedOutput(item.Prompt)), nil
+	}
+
+	metadata := map[string]string{
+		"endpoint":   "/batch",
+		"batch_item": strconv.Itoa(item.Index),
+	}
+	for key, value := range labels {
+		metadata[key] = value
+	}
+	// 批任务默认以 batch 优先级排队（请求头只能进一步降低）
+	if metadata["priority_requested"] == "" {
+		metadata["priority_requested"] = "batch"
+	}
+
+	output, err := runCLI(cliRequest{
+		Context:  ctx,
+		CLI:      item.CLI,
+		Prompt:   item.Prompt,
+		System:   item.System,
+		Profile:  item.Profile,
+		Metadata: metadata,
+	})
+	if err != nil {
+		return batch.Result{}, err
+	}
+	return parseBatchOutput(output), nil
+}
+
+func parseBatchOutput(output string) batch.Result {
+	var cliOut CLIOutput
+	if err := json.Unmarshal([]byte(output), &cliOut); err != nil {
+		return batch.Result{Response: output}
+	}
+	response := cliOut.Response
+	if response == "" {
+		response = cliOut.Codex
+	}
+	return batch.Result{Response: response, SessionID: cliOut.SessionID}
+}
+
+// HandleBatch 处理 /batch 与 /batch/{id}[/cancel|/resume|/results]
+func HandleBatch(w http.ResponseWriter, r *http.Request) {
+	log.Printf("📥 Received request: %s %s from %s", r.Method, r.URL.Path, r.RemoteAddr)
+
+	manager := getBatchManager()
+	if manager == nil {
+		writeJSON(w, http.StatusServiceUnavailable, map[string]string{"error": "batch manager unavailable"})
+		return
+	}
+
+	owner := requestOwner(r)
+	path := strings.Trim(strings.TrimPrefix(r.URL.Path, "/batch"), "/")
+	if path == "" {
+		switch r.Method {
+		case http.MethodPost:
+			handleBatchSubmit(w, r, manager)
+		case http.MethodGet:
+			writeJSON(w, http.StatusOK, map[string]interface{}{"batches": manager.List(func(labels map[string]string) bool {
+				return labels["api_key_id"] == owner
+			})})
+		default:
+			writeMethodNotAllowed(w)
+		}
+		return
+	}
+
+	parts := strings.Split(path, "/")
+	id := parts[0]
+	action := ""
+	if len(parts) == 2 {
+		action = parts[1]
+	} else if len(parts) > 2 {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "not found"})
+		return
+	}
+
+	b, err := manager.Get(id)
+	if err != nil || b.Labels["api_key_id"] != owner {
+		// 不区分「不存在」与「不属于调用方」，避免泄露批任务 ID
+		writeBatchError(w, batch.ErrNotFound)
+		return
+	}
+
+	switch {
+	case action == "" && r.Method == http.MethodGet:
+		summary, _ := manager.Summary(id)
+		writeJSON(w, http.StatusOK, map[string]interface{}{"batch": summary, "items": b.Items})
+	case action == "" && r.Method == http.MethodDelete:
+		if err := manager.Delete(id); err != nil {
+			writeBatchError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]string{"status": "deleted", "id": id})
+	case action == "cancel" && r.Method == http.MethodPost:
+		if err := manager.Cancel(id); err != nil {
+			writeBatchError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusAccepted, map[string]string{"status": "canceling", "id": id})
+	case action == "resume" && r.Method == http.MethodPost:
+		retryFailed, _ := strconv.ParseBool(r.URL.Query().Get("retry_failed"))
+		summary, err := manager.Resume(id, retryFailed)
+		if err != nil {
+			writeBatchError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusAccepted, summary)
+	case action == "results" && r.Method == http.MethodGet:
+		handleBatchResults(w, r, b)
+	case action == "" || action == "cancel" || action == "resume" || action == "results":
+		writeMethodNotAllowed(w)
+	default:
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "not found"})
+	}
+}
+
+func handleBatchSubmit(w http.ResponseWriter, r *http.Request, manager *batch.Manager) {
+	cfg := GetBatchConfig()
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, int64(cfg.MaxBodyMB)<<20))
+	if err != nil {
+		writeJSON(w, http.StatusRequestEntityTooLarge, map[string]string{"error": fmt.Sprintf("batch body exceeds %dMB", cfg.MaxBodyMB)})
+		return
+	}
+
+	input, err := batch.ParseInput(body)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	if len(input.Items) > cfg.MaxItems {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("batch has %d items, max %d", len(input.Items), cfg.MaxItems)})
+		return
+	}
+
+	labels := map[string]string{}
+	if owner := requestOwner(r); owner != "" {
+		labels["api_key_id"] = owner
+	}
+	admissionMetadata(r, labels)
+	input.Labels = labels
+
+	summary, err := manager.Submit(input)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusAccepted, summary)
+}
+
+func handleBatchResults(w http.ResponseWriter, r *http.Request, b *batch.Batch) {
+	id := b.ID
+	var err error
+	format := strings.ToLower(r.URL.Query().Get("format"))
+	switch format {
+	case "", "jsonl":
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", id+".jsonl"))
+		err = batch.WriteJSONL(w, b)
+	case "csv":
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", id+".csv"))
+		err = batch.WriteCSV(w, b)
+	default:
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "format must be jsonl or csv"})
+		return
+	}
+	if err != nil {
+		log.Printf("❌ Failed to export batch %s: %v", id, err)
+	}
+}
+
+func writeBatchError(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, batch.ErrNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, batch.ErrAlreadyRunning), errors.Is(err, batch.ErrNotRunning):
+		status = http.StatusConflict
+	}
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
@@ -0,0 +1,78 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"dify-cli-gateway/internal/batch"
+)
+
+func TestHandleBatch_OwnerIsolation(t *testing.T) {
+	manager, err := batch.NewManager(batch.ManagerConfig{MaxConcurrency: 1}, func(ctx context.Context, item batch.Item, labels map[string]string) (batch.Result, error) {
+		return batch.Result{Response: "ok " + item.Prompt}, nil
+	})
+	if err != nil {
+		t.Fatalf("NewManager failed: %v", err)
+	}
+	batchManagerMu.Lock()
+	previous := batchManager
+	batchManager = manager
+	batchManagerMu.Unlock()
+	t.Cleanup(func() {
+		batchManagerMu.Lock()
+		batchManager = previous
+		batchManagerMu.Unlock()
+	})
+
+	call := func(method, path, key, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		if key != "" {
+			req.Header.Set("X-API-Key", key)
+		}
+		rec := httptest.NewRecorder()
+		HandleBatch(rec, req)
+		return rec
+	}
+
+	rec := call(http.MethodPost, "/batch", "k1", `{"prompt":"a"}`+"\n")
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("submit failed: %d %s", rec.Code, rec.Body.String())
+	}
+	var summary batch.Summary
+	json.Unmarshal(rec.Body.Bytes(), &summary)
+	manager.Wait(summary.ID)
+
+	// 提交方可以看到并下载自己的批任务
+	if rec := call(http.MethodGet, "/batch", "k1", ""); !strings.Contains(rec.Body.String(), summary.ID) {
+		t.Errorf("owner should list its batch: %s", rec.Body.String())
+	}
+	if rec := call(http.MethodGet, "/batch/"+summary.ID+"/results", "k1", ""); rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "ok a") {
+		t.Errorf("owner should download results: %d %s", rec.Code, rec.Body.String())
+	}
+
+	// 其他 API Key 与匿名调用方既看不到，也无法操作
+	for _, key := range []string{"k2", ""} {
+		if rec := call(http.MethodGet, "/batch", key, ""); strings.Contains(rec.Body.String(), summary.ID) {
+			t.Errorf("key %q should not list a foreign batch: %s", key, rec.Body.String())
+		}
+		for _, route := range []struct{ method, path string }{
+			{http.MethodGet, "/batch/" + summary.ID},
+			{http.MethodGet, "/batch/" + summary.ID + "/results"},
+			{http.MethodPost, "/batch/" + summary.ID + "/cancel"},
+			{http.MethodPost, "/batch/" + summary.ID + "/resume"},
+			{http.MethodDelete, "/batch/" + summary.ID},
+		} {
+			if rec := call(route.method, route.path, key, ""); rec.Code != http.StatusNotFound {
+				t.Errorf("key %q %s %s: expected 404, got %d", key, route.method, route.path, rec.Code)
+			}
+		}
+	}
+
+	if _, err := manager.Get(summary.ID); err != nil {
+		t.Errorf("foreign DELETE must not remove the batch: %v", err)
+	}
+}
@@ -1,11 +1,17 @@
 package handler
 
 import (
+	"context"
 	"fmt"
 	"log"
+	"os"
+	"path/filepath"
+	"sort"
 	"strings"
+	"sync"
 
 	"dify-cli-gateway/internal/cli"
+	"dify-cli-gateway/internal/fs_policy"
 )
 
 // buildPrompt 将 messages 拼接成单个 prompt 字符串
@@ -33,15 +39,31 @@ func truncate(s string, maxLen int) string {
 	return s[:maxLen] + "..."
 }
 
+// cliRequest 描述一次网关 CLI 调用
+type cliRequest struct {
+	Context        context.Context // 请求上下文（客户端断开时取消排队）
+	CLI            string
+	Prompt         string
+	System         string
+	Profile        string
+	SessionID      string
+	NewSession     bool
+	AllowedTools   []string
+	PermissionMode string
+	CaptureSteps   bool              // 记录工具调用步骤（请求 include_steps 或 profile capture_steps）
+	Metadata       map[string]string // 请求元数据（与网关中间件共享，可读取中间件写回的值）
+}
+
 // runCLI 执行指定的 CLI 工具并返回结果
-func runCLI(cliName string, prompt string, systemPrompt string, profileName string, sessionID string, newSession bool, allowedTools []string, permissionMode string) (string, error) {
+func runCLI(req cliRequest) (string, error) {
+	cliName := req.CLI
 	var cliSource string
 
 	// 确定使用的 CLI 工具
 	if cliName != "" {
 		cliSource = "request"
 	} else {
-		profile, err := GetProfile(profileName)
+		profile, err := GetProfile(req.Profile)
 		if err == nil && profile.CLI != "" {
 			cliName = profile.CLI
 			cliSource = "profile"
@@ -63,22 +85,80 @@ func runCLI(cliName string, prompt string, systemPrompt string, profileName stri
 
 	// 构建执行选项
 	opts := &cli.RunOptions{
-		Prompt:         prompt,
-		SystemPrompt:   systemPrompt,
-		SessionID:      sessionID,
-		NewSession:     newSession,
-		AllowedTools:   allowedTools,
-		PermissionMode: permissionMode,
+		Prompt:         req.Prompt,
+		SystemPrompt:   req.System,
+		SessionID:      req.SessionID,
+		NewSession:     req.NewSession,
+		AllowedTools:   req.AllowedTools,
+		PermissionMode: req.PermissionMode,
+		CaptureSteps:   req.CaptureSteps,
 	}
 
 	// 从配置中获取额外选项
-	profile, err := GetProfile(profileName)
+	profile, err := GetProfile(req.Profile)
 	if err == nil {
 		log.Printf("📋 Profile loaded: name=%s cli=%s model=%s skills=%d", profile.Name, profile.CLI, profile.Model, len(profile.Skills))
-		opts.Skills = profile.Skills
-		opts.Skills = filterSkillPaths(opts.Skills)
-		opts.Env = profile.Env
+		opts.Skills = append(append([]string{}, profile.Skills...), resolveSkillRefs(profile.SkillRefs)...)
+		opts.Skills = filterSkillPaths(opts.Skills, "profile:"+resolveProfileKey(req.Profile))
+		env, err := resolveProfileEnv(profile.Env)
+		if err != nil {
+			return "", err
+		}
+		opts.Env = env
+		opts.EnvPolicy = GetEnvPolicyConfig(*profile)
+		if err := opts.EnvPolicy.Validate(); err != nil {
+			return "", err
+		}
 		opts.Model = profile.Model
+		if _, err := cli.ParsePromptDelivery(profile.PromptDelivery); err != nil {
+			return "", fmt.Errorf("profile prompt_delivery: %v", err)
+		}
+		opts.PromptDelivery = profile.PromptDelivery
+		opts.PromptArgMaxBytes = profile.PromptArgMaxBytes
+		if profile.WorkDir != "" {
+			decision := checkFSPath(fs_policy.PurposeWorkDir, profile.WorkDir, "profile:"+resolveProfileKey(req.Profile))
+			if !decision.Allowed {
+				return "", fmt.Errorf("work_dir %s denied by fs_policy: %s", profile.WorkDir, decision.Reason)
+			}
+			opts.WorkDir = decision.Resolved
+		} else if profile.Sandbox == nil || !profile.Sandbox.Enabled {
+			workDir, err := defaultWorkDir(resolveProfileKey(req.Profile))
+			if err != nil {
+				return "", err
+			}
+			opts.WorkDir = workDir
+		}
+		if profile.Sandbox != nil && profile.Sandbox.Enabled {
+			profileKey := resolveProfileKey(req.Profile)
+			if opts.WorkDir == "" {
+				workDir, err := sandboxWorkspace(profileKey)
+				if err != nil {
+					return "", err
+				}
+				opts.WorkDir = workDir
+				opts.SandboxOwnsWorkDir = true
+			}
+			stateDir, err := gatewayProfileDir("homes", profileKey)
+			if err != nil {
+				return "", err
+			}
+			opts.SandboxStateDir = stateDir
+			// 复制一份，追加其他 profile 的工作目录作为隐藏路径
+			sandbox := *profile.Sandbox
+			sandbox.HiddenPaths = append(append([]string{}, profile.Sandbox.HiddenPaths...), otherProfileDirs(profileKey, opts.WorkDir)...)
+			opts.Sandbox = &sandbox
+		}
+		opts.Resources = GetResourceLimitsConfig(*profile)
+		opts.Codex = profile.Codex
+		opts.CaptureSteps = opts.CaptureSteps || profile.CaptureSteps
+		if profile.MCPServers != nil {
+			// 复制一份，审批等功能会向其中注入 server
+			opts.MCPServers = make(map[string]cli.MCPServerConfig, len(profile.MCPServers))
+			for name, server := range profile.MCPServers {
+				opts.MCPServers[name] = server
+			}
+			opts.StrictMCPConfig = true
+		}
 		if len(opts.AllowedTools) == 0 && len(profile.AllowedTools) > 0 {
 			opts.AllowedTools = profile.AllowedTools
 		}
@@ -93,11 +173,193 @@ func runCLI(cliName string, prompt string, systemPrompt string, profileName stri
 	opts.SystemPrompt = appendSystemPrompt(opts.SystemPrompt, enforcedSystemPrompt)
 
 	// 标记这是 HTTP 请求，避免在非交互环境中使用 --resume
-	if opts.Env == nil {
-		opts.Env = make(map[string]string)
+	// 复制一份 env，避免中间件或 runner 修改全局 profile 配置
+	env := make(map[string]string, len(opts.Env)+1)
+	for key, value := range opts.Env {
+		env[key] = value
 	}
-	opts.Env["HTTP_REQUEST"] = "true"
+	env["HTTP_REQUEST"] = "true"
+	opts.Env = env
+
+	// 构建网关中间件管道
+	pipeline, err := buildCachedPipeline(withRequestCoalescing(withResponseCache(resolveMiddlewareConfigs(profile), profile), profile))
+	if err != nil {
+		return "", fmt.Errorf("failed to build middleware pipeline: %v", err)
+	}
+
+	// 直接使用调用方的 metadata，便于调用方读取中间件写回的状态（如 cache 命中）
+	metadata := req.Metadata
+	if metadata == nil {
+		metadata = make(map[string]string)
+	}
+	metadata["cli_source"] = cliSource
+	pipelineReq := &cli.PipelineRequest{
+		CLI:      cliName,
+		Profile:  resolveProfileKey(req.Profile),
+		Options:  opts,
+		Metadata: metadata,
+		Context:  req.Context,
+	}
+	if req.SessionID != "" {
+		pipelineReq.SetMeta("session_id", req.SessionID)
+		// 恢复会话前校验创建者（CLI 与 API Key）
+		if err := authorizeSession(req.SessionID, cliName, metadata); err != nil {
+			log.Printf("🚫 Session resume rejected: %v", err)
+			return "", err
+		}
+	}
+
+	// 准入队列放在管道最内层，仅真正启动 CLI 的请求占用执行槽位
+	if scheduler := getAdmissionScheduler(); scheduler != nil {
+		if metadata["tenant"] == "" {
+			metadata["tenant"] = "anonymous"
+		}
+		metadata["priority"] = string(resolveAdmissionClass(metadata, profile))
+		pipeline.Use(&admissionMiddleware{scheduler: scheduler})
+	}
+
+	// 启用审批时注入 permission-prompt MCP server，调用结束后注销
+	closeApproval := beginApproval(profile, pipelineReq.Profile, cliName, opts, metadata)
+	defer closeApproval()
 
 	// 执行 CLI
-	return runner.Run(opts)
+	output, err := pipeline.Run(runner, pipelineReq)
+	if err != nil {
+		return "", err
+	}
+	recordSession(output, cliName, pipelineReq.Profile, opts, metadata)
+	return output, nil
+}
+
+// resolveMiddlewareConfigs 返回 profile 的中间件配置，未配置时使用全局默认
+func resolveMiddlewareConfigs(profile *ProfileConfig) []cli.MiddlewareConfig {
+	if profile != nil && len(profile.Middleware) > 0 {
+		return profile.Middleware
+	}
+	if cfg := getGlobalConfig(); cfg != nil {
+		return cfg.Middleware
+	}
+	return nil
+}
+
+// withRequestCoalescing 在 profile 启用 coalesce 且未显式配置时加入合并中间件（位于 cache 之后）
+func withRequestCoalescing(configs []cli.MiddlewareConfig, profile *ProfileConfig) []cli.MiddlewareConfig {
+	if profile == nil || !profile.Coalesce {
+		return configs
+	}
+	insertAt := 0
+	for i, cfg := range configs {
+		if cfg.Name == "coalesce" {
+			return configs
+		}
+		if cfg.Name == "cache" {
+			insertAt = i + 1
+		}
+	}
+	result := make([]cli.MiddlewareConfig, 0, len(configs)+1)
+	result = append(result, configs[:insertAt]...)
+	result = append(result, cli.MiddlewareConfig{Name: "coalesce", Enabled: true})
+	return append(result, configs[insertAt:]...)
+}
+
+// defaultWorkDirNotices 已提示过改用 data/workspaces 的 profile 及其工作目录，避免每个请求都打印
+var defaultWorkDirNotices sync.Map
+
+// defaultWorkDir 未配置 work_dir 时 CLI 在网关当前目录运行，同样需要通过 fs_policy 检查；
+// 当前目录被拒绝（如包含 configs.json、.env）时改用 data/workspaces/<profile>
+func defaultWorkDir(profileKey string) (string, error) {
+	cwd, err := os.Getwd()
+	if err != nil {
+		return "", err
+	}
+	subject := "profile:" + profileKey
+	if decision := checkFSPath(fs_policy.PurposeWorkDir, cwd, subject); decision.Allowed {
+		return decision.Resolved, nil
+	}
+	workspace, err := sandboxWorkspace(profileKey)
+	if err != nil {
+		return "", err
+	}
+	decision := checkFSPath(fs_policy.PurposeWorkDir, workspace, subject)
+	if !decision.Allowed {
+		return "", fmt.Errorf("work_dir is not set and neither the gateway directory nor %s is allowed by fs_policy: %s", workspace, decision.Reason)
+	}
+	if previous, loaded := defaultWorkDirNotices.Swap(profileKey, decision.Resolved); !loaded || previous != decision.Resolved {
+		log.Printf("📁 [FSPolicy] Gateway directory %s denied for profile %s, using %s as work_dir", cwd, profileKey, decision.Resolved)
+	}
+	return decision.Resolved, nil
+}
+
+// sandboxWorkspace 返回沙箱 profile 未配置 work_dir 时使用的独立工作目录（data/workspaces/<profile>）
+func sandboxWorkspace(profileKey string) (string, error) {
+	return gatewayProfileDir("workspaces", profileKey)
+}
+
+// gatewayProfileDir 创建并返回网关为 profile 管理的目录 data/<kind>/<profile>
+func gatewayProfileDir(kind, profileKey string) (string, error) {
+	dir, err := gatewayProfilePath(kind, profileKey)
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return "", fmt.Errorf("failed to create %s: %v", dir, err)
+	}
+	return dir, nil
+}
+
+// gatewayProfilePath 返回 data/<kind>/<profile> 的绝对路径（不创建目录）
+func gatewayProfilePath(kind, profileKey string) (string, error) {
+	name := strings.Map(func(r rune) rune {
+		if r == '-' || r == '_' || r == '.' || (r >= '0' && r <= '9') || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') {
+			return r
+		}
+		return '_'
+	}, profileKey)
+	if name == "" || strings.Trim(name, ".") == "" {
+		name = "default"
+	}
+	return filepath.Abs(filepath.Join("data", kind, name))
+}
+
+// otherProfileDirs 返回沙箱中需要隐藏的其他 profile 的数据：配置的 work_dir，
+// 以及网关管理的 data/workspaces、data/homes（当前 profile 的目录在更深层重新挂载）
+func otherProfileDirs(profileKey, workDir string) []string {
+	var dirs []string
+	for _, kind := range []string{"workspaces", "homes"} {
+		if dir, err := filepath.Abs(filepath.Join("data", kind)); err == nil {
+			dirs = append(dirs, dir)
+		}
+	}
+	cfg := getGlobalConfig()
+	if cfg == nil {
+		return dirs
+	}
+	keys := make([]string, 0, len(cfg.Profiles))
+	for key := range cfg.Profiles {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		other := cfg.Profiles[key].WorkDir
+		if key == profileKey || other == "" {
+			continue
+		}
+		resolved, err := fs_policy.Resolve(other)
+		if err != nil || resolved == workDir || resolved == "/" {
+			continue
+		}
+		dirs = append(dirs, resolved)
+	}
+	return dirs
+}
+
+// resolveProfileKey 返回实际使用的 profile key（空值解析为默认 profile）
+func resolveProfileKey(profileName string) string {
+	if profileName != "" {
+		return profileName
+	}
+	if cfg := getGlobalConfig(); cfg != nil {
+		return cfg.Default
+	}
+	return ""
 }
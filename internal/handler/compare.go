@@ -0,0 +1,271 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"dify-cli-gateway/internal/compare"
+)
+
+// CompareRequest 表示 /compare 请求
+type CompareRequest struct {
+	Prompt   string           `json:"prompt"`
+	Message  string           `json:"message,omitempty"` // 兼容 /chat 字段
+	System   string           `json:"system,omitempty"`
+	Targets  []compare.Target `json:"targets,omitempty"`
+	Profiles []string         `json:"profiles,omitempty"` // targets 的简写
+	CLIs     []string         `json:"clis,omitempty"`     // targets 的简写
+	Judge    *CompareJudge    `json:"judge,omitempty"`
+	Store    *bool            `json:"store,omitempty"` // 是否保存记录，默认 true
+}
+
+// CompareJudge 表示裁判配置
+type CompareJudge struct {
+	Profile  string `json:"profile,omitempty"`
+	CLI      string `json:"cli,omitempty"`
+	Mode     string `json:"mode,omitempty"`     // score | rank | merge，默认 score
+	Criteria string `json:"criteria,omitempty"` // 额外评判标准
+}
+
+var (
+	compareStoreMu sync.RWMutex
+	compareStore   *compare.Store
+)
+
+// InitCompareStore 初始化对比记录存储（存储目录不可用时退化为内存模式）
+func InitCompareStore() {
+	cfg := GetCompareConfig()
+	store, err := compare.NewStore(cfg.StorageDir, cfg.MaxStored)
+	if err != nil {
+		log.Printf("⚠️  Compare storage unavailable, records will not survive restart: %v", err)
+		store, _ = compare.NewStore("", cfg.MaxStored)
+	}
+
+	compareStoreMu.Lock()
+	compareStore = store
+	compareStoreMu.Unlock()
+	log.Printf("✅ Compare store initialized (storage=%s, max_stored=%d)", cfg.StorageDir, cfg.MaxStored)
+}
+
+func getCompareStore() *compare.Store {
+	compareStoreMu.RLock()
+	store := compareStore
+	compareStoreMu.RUnlock()
+	if store == nil {
+		InitCompareStore()
+		compareStoreMu.RLock()
+		store = compareStore
+		compareStoreMu.RUnlock()
+	}
+	return store
+}
+
+// HandleCompare 处理 /compare 端点：同一 prompt 并行发送给多个 profile/CLI，可选裁判评审
+func HandleCompare(w http.ResponseWriter, r *http.Request) {
+	startTime := time.Now()
+	log.Printf("📥 Received request: %s %s from %s", r.Method, r.URL.Path, r.RemoteAddr)
+
+	if r.Method != http.MethodPost {
+		writeMethodNotAllowed(w)
+		return
+	}
+
+	var req CompareRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid JSON request body"})
+		return
+	}
+	prompt := req.Prompt
+	if prompt == "" {
+		prompt = req.Message
+	}
+	if strings.TrimSpace(prompt) == "" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "prompt is required"})
+		return
+	}
+
+	targets := append([]compare.Target{}, req.Targets...)
+	for _, profile := range req.Profiles {
+		targets = append(targets, compare.Target{Profile: profile})
+	}
+	for _, cliName := range req.CLIs {
+		targets = append(targets, compare.Target{CLI: cliName})
+	}
+	targets, err := compare.NormalizeTargets(targets)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	cfg := GetCompareConfig()
+	if len(targets) < 2 {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "at least 2 targets are required"})
+		return
+	}
+	if len(targets) > cfg.MaxTargets {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("compare has %d targets, max %d", len(targets), cfg.MaxTargets)})
+		return
+	}
+
+	var judgeMode compare.JudgeMode
+	if req.Judge != nil {
+		if judgeMode, err = compare.ParseJudgeMode(req.Judge.Mode); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+	}
+
+	if shouldGuardPrompt(prompt) {
+		writeGuardedResponse(w, prompt)
+		log.Printf("📤 Response sent successfully (guarded)")
+		return
+	}
+
+	log.Printf("⚖️  [Compare] Fan-out to %d targets (judge=%t)", len(targets), req.Judge != nil)
+	result := &compare.Comparison{
+		ID:        compare.NewID(),
+		Prompt:    prompt,
+		System:    req.System,
+		CreatedAt: startTime,
+		Answers:   runCompareTargets(r, prompt, req.System, targets),
+	}
+
+	if req.Judge != nil {
+		result.Judge = runCompareJudge(r, prompt, result.Answers, req.Judge, judgeMode)
+	}
+	result.DurationMS = time.Since(startTime).Milliseconds()
+
+	if req.Store == nil || *req.Store {
+		if store := getCompareStore(); store != nil {
+			if err := store.Save(result); err != nil {
+				log.Printf("⚠️  [Compare] Failed to store %s: %v", result.ID, err)
+			}
+		}
+	}
+
+	writeJSON(w, http.StatusOK, result)
+	log.Printf("📤 Response sent successfully (compare %s, took %v)", result.ID, time.Since(startTime))
+}
+
+// runCompareTargets 并行执行所有目标，单个目标失败只记录在对应回答中
+func runCompareTargets(r *http.Request, prompt, system string, targets []compare.Target) []compare.Answer {
+	answers := make([]compare.Answer, len(targets))
+	var wg sync.WaitGroup
+	for i, target := range targets {
+		wg.Add(1)
+		go func(i int, target compare.Target) {
+			defer wg.Done()
+			answers[i] = runCompareCall(r, prompt, system, target)
+		}(i, target)
+	}
+	wg.Wait()
+	return answers
+}
+
+func runCompareCall(r *http.Request, prompt, system string, target compare.Target) compare.Answer {
+	answer := compare.Answer{Label: target.Label, Profile: target.Profile, CLI: target.CLI}
+	metadata := requestMetadata(r)
+	metadata["compare_target"] = target.Label
+
+	start := time.Now()
+	output, err := runCLI(cliRequest{
+		Context:  r.Context(),
+		CLI:      target.CLI,
+		Prompt:   prompt,
+		System:   system,
+		Profile:  target.Profile,
+		Metadata: metadata,
+	})
+	answer.LatencyMS = time.Since(start).Milliseconds()
+	if err != nil {
+		answer.Error = err.Error()
+		if errors.Is(err, context.Canceled) {
+			answer.Error = "request canceled"
+		}
+		return answer
+	}
+
+	var cliOut CLIOutput
+	if err := json.Unmarshal([]byte(output), &cliOut); err != nil {
+		answer.Response = output
+		return answer
+	}
+	answer.Response = cliOut.Response
+	if answer.Response == "" {
+		answer.Response = cliOut.Codex
+	}
+	answer.SessionID = cliOut.SessionID
+	answer.CostUSD = cliOut.TotalCostUSD
+	return answer
+}
+
+// runCompareJudge 使用裁判 profile 评审回答；裁判失败不影响对比结果
+func runCompareJudge(r *http.Request, prompt string, answers []compare.Answer, judge *CompareJudge, mode compare.JudgeMode) *compare.Verdict {
+	verdict := &compare.Verdict{Profile: judge.Profile, CLI: judge.CLI, Mode: mode}
+	judgePrompt, err := compare.BuildJudgePrompt(prompt, answers, mode, judge.Criteria)
+	if err != nil {
+		verdict.Error = err.Error()
+		return verdict
+	}
+
+	answer := runCompareCall(r, judgePrompt, "", compare.Target{Label: "judge", Profile: judge.Profile, CLI: judge.CLI})
+	if answer.Error != "" {
+		verdict.Error = answer.Error
+		verdict.LatencyMS = answer.LatencyMS
+		return verdict
+	}
+
+	parsed, err := compare.ParseVerdict(answers, mode, answer.Response)
+	parsed.Profile = judge.Profile
+	parsed.CLI = judge.CLI
+	parsed.LatencyMS = answer.LatencyMS
+	parsed.CostUSD = answer.CostUSD
+	if err != nil {
+		log.Printf("⚠️  [Compare] Judge output unparseable: %v", err)
+		parsed.Error = err.Error()
+	}
+	return parsed
+}
+
+// handleAdminCompare 处理 /api/compare 与 /api/compare/{id}
+func handleAdminCompare(w http.ResponseWriter, r *http.Request, relativePath string) {
+	store := getCompareStore()
+	if store == nil {
+		writeJSON(w, http.StatusServiceUnavailable, map[string]string{"error": "compare store unavailable"})
+		return
+	}
+
+	id := strings.Trim(strings.TrimPrefix(relativePath, "/api/compare"), "/")
+	if id == "" {
+		if r.Method != http.MethodGet {
+			writeMethodNotAllowed(w)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"comparisons": store.List()})
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		result, err := store.Get(id)
+		if err != nil {
+			writeJSON(w, http.StatusNotFound, map[string]string{"error": err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusOK, result)
+	case http.MethodDelete:
+		if err := store.Delete(id); err != nil {
+			writeJSON(w, http.StatusNotFound, map[string]string{"error": err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]string{"status": "deleted", "id": id})
+	default:
+		writeMethodNotAllowed(w)
+	}
+}
@@ -10,17 +10,46 @@ import (
 	"strings"
 	"sync"
 	"time"
+
+	"dify-cli-gateway/internal/approval"
+	"dify-cli-gateway/internal/cli"
 )
 
 // ProfileConfig 表示单个配置 profile
 type ProfileConfig struct {
-	Name         string            `json:"name"`
-	CLI          string            `json:"cli,omitempty"`           // 可选：指定使用的 CLI 工具（"claude", "codex", "cursor"）
-	Model        string            `json:"model,omitempty"`         // 可选：指定模型名称
-	AllowedTools []string          `json:"allowed_tools,omitempty"` // 可选：允许的 MCP 工具列表（仅 Claude CLI）
-	Skills       []string          `json:"skills,omitempty"`        // 可选：Claude Skills 列表（目录或文件路径）
-	SystemPrompt string            `json:"system_prompt,omitempty"` // 可选：系统提示词
-	Env          map[string]string `json:"env"`
+	Name         string                 `json:"name"`
+	CLI          string                 `json:"cli,omitempty"`           // 可选：指定使用的 CLI 工具（"claude", "codex", "cursor"）
+	Model        string                 `json:"model,omitempty"`         // 可选：指定模型名称
+	AllowedTools []string               `json:"allowed_tools,omitempty"` // 可选：允许的 MCP 工具列表（仅 Claude CLI）
+	Skills       []string               `json:"skills,omitempty"`        // 可选：Claude Skills 列表（目录或文件路径）
+	SkillRefs    []string               `json:"skill_refs,omitempty"`    // 可选：受管 skills（"name" 使用当前版本，"name@3" 固定版本）
+	SystemPrompt string                 `json:"system_prompt,omitempty"` // 可选：系统提示词
+	Middleware   []cli.MiddlewareConfig `json:"middleware,omitempty"`    // 可选：网关中间件管道（按顺序执行）
+	Cache        *cli.CacheConfig       `json:"cache,omitempty"`         // 可选：响应缓存（需显式启用）
+	Coalesce     bool                   `json:"coalesce,omitempty"`      // 可选：合并相同的无会话并发请求
+	Priority     string                 `json:"priority,omitempty"`      // 可选：准入队列优先级（interactive/batch/background）
+	Env          map[string]string      `json:"env"`                     // 覆盖继承的同名变量；值可以是 ${env:NAME} / ${file:/path} 引用，执行时解析
+	EnvPolicy    *cli.EnvPolicy         `json:"env_policy,omitempty"`    // 可选：从网关继承环境变量的策略（all/none/allowlist），覆盖全局 env_policy
+
+	PromptDelivery    string `json:"prompt_delivery,omitempty"`      // 可选：prompt 传递方式（auto/arg/stdin），默认 auto
+	PromptArgMaxBytes int    `json:"prompt_arg_max_bytes,omitempty"` // 可选：auto 模式下作为参数传递的上限，默认 32768
+	WorkDir           string `json:"work_dir,omitempty"`             // 可选：CLI 工作目录（Codex --cd / Cursor --workspace）
+
+	Codex        *cli.CodexOptions `json:"codex,omitempty"`         // 可选：Codex 专用选项（sandbox / profile / config 覆盖）
+	CaptureSteps bool              `json:"capture_steps,omitempty"` // 可选：始终在输出中记录工具调用步骤（steps）
+
+	Approval   *ProfileApprovalConfig         `json:"approval,omitempty"`    // 可选：工具调用人工审批（仅 Claude）
+	MCPServers map[string]cli.MCPServerConfig `json:"mcp_servers,omitempty"` // 可选：profile 专属 MCP servers（配置后不再加载用户目录中的 MCP 配置）
+	Sandbox    *cli.SandboxConfig             `json:"sandbox,omitempty"`     // 可选：在 bubblewrap 沙箱中执行 CLI（仅 Linux）
+	Resources  *cli.ResourceLimits            `json:"resources,omitempty"`   // 可选：CLI 子进程资源限制，逐项覆盖全局 resource_limits
+}
+
+// ProfileApprovalConfig 表示 profile 的工具调用审批设置
+type ProfileApprovalConfig struct {
+	Enabled         bool            `json:"enabled"`
+	TimeoutSeconds  int             `json:"timeout_seconds,omitempty"`  // 覆盖全局等待时长
+	TimeoutDecision string          `json:"timeout_decision,omitempty"` // 超时后的决定：deny（默认）/ allow
+	Rules           []approval.Rule `json:"rules,omitempty"`            // 自动审批规则（按顺序匹配，第一条命中的生效）
 }
 
 // ServerConfig 表示服务器配置
@@ -66,6 +95,103 @@ type WorkflowSessionConfig struct {
 	Redis               *WorkflowSessionRedisConfig `json:"redis,omitempty"`
 }
 
+// ResponseCacheConfig 表示网关响应缓存配置
+type ResponseCacheConfig struct {
+	Backend    string                      `json:"backend"`         // 存储后端："memory"（默认）或 "redis"
+	Strategy   string                      `json:"strategy"`        // 内存淘汰策略：lru（默认）、lfu、fifo
+	MaxSizeMB  int                         `json:"max_size_mb"`     // 每个 profile 的内存上限（MB），默认 100
+	TTLMinutes int                         `json:"ttl_minutes"`     // 缓存 TTL（分钟），默认 60
+	KeyPrefix  string                      `json:"key_prefix"`      // Redis key 前缀，默认 "cli-gateway:cache"
+	Redis      *WorkflowSessionRedisConfig `json:"redis,omitempty"` // Redis 配置，未配置时复用 workflow_session.redis
+}
+
+// AdmissionAPIKey 表示一个 API Key 对应的租户与优先级
+type AdmissionAPIKey struct {
+	Key      string `json:"key"`                // API Key（支持 ${ENV} 占位）
+	Tenant   string `json:"tenant"`             // 租户名（用于公平排队）
+	Priority string `json:"priority,omitempty"` // 优先级上限（interactive/batch/background）
+}
+
+// AdmissionConfig 表示 CLI 执行准入队列配置
+type AdmissionConfig struct {
+	Enabled         bool              `json:"enabled"`                  // 是否启用准入队列
+	MaxConcurrent   int               `json:"max_concurrent"`           // 同时执行的 CLI 数量，默认 4
+	MaxQueueDepth   int               `json:"max_queue_depth"`          // 排队上限，默认 100
+	MaxWaitMS       int               `json:"max_wait_ms"`              // 最长排队时间（毫秒），默认 120000
+	DefaultPriority string            `json:"default_priority"`         // 默认优先级，默认 "interactive"
+	PriorityHeader  string            `json:"priority_header"`          // 请求头名，默认 "X-Priority"
+	TenantWeights   map[string]int    `json:"tenant_weights,omitempty"` // 租户权重，默认 1
+	APIKeys         []AdmissionAPIKey `json:"api_keys,omitempty"`
+}
+
+// BatchConfig 表示批任务配置
+type BatchConfig struct {
+	StorageDir     string `json:"storage_dir"`     // 进度持久化目录，默认 "data/batches"
+	MaxConcurrency int    `json:"max_concurrency"` // 单个批任务的并发上限，默认 4
+	MaxItems       int    `json:"max_items"`       // 单个批任务的条目上限，默认 1000
+	MaxBodyMB      int    `json:"max_body_mb"`     // 请求体上限（MB），默认 16
+}
+
+// CompareConfig 表示多 CLI 对比配置
+type CompareConfig struct {
+	StorageDir string `json:"storage_dir"` // 对比记录目录，默认 "data/comparisons"
+	MaxStored  int    `json:"max_stored"`  // 最多保留的记录数，默认 500
+	MaxTargets int    `json:"max_targets"` // 单次对比的目标上限，默认 8
+}
+
+// StructuredOutputConfig 表示 response_schema 结构化输出配置
+type StructuredOutputConfig struct {
+	MaxRepairs *int `json:"max_repairs,omitempty"` // 校验失败后的修复重试次数，默认 2，0 表示不重试
+}
+
+// InvokeReplayConfig 表示 /invoke 多轮回放配置
+type InvokeReplayConfig struct {
+	Enabled    *bool `json:"enabled,omitempty"` // 是否按历史前缀恢复 CLI 会话，默认 true
+	TTLMinutes int   `json:"ttl_minutes"`       // 历史前缀映射 TTL（分钟），默认 1440
+}
+
+// SessionRegistryConfig 表示会话登记配置
+type SessionRegistryConfig struct {
+	StoragePath      string `json:"storage_path"`      // 持久化文件，默认 "data/sessions.json"
+	IdleTTLMinutes   int    `json:"idle_ttl_minutes"`  // 空闲超过该时间后不可再恢复，默认 1440
+	RetentionMinutes int    `json:"retention_minutes"` // 过期/关闭的会话记录保留时长，默认同 idle_ttl_minutes
+	HandoffMaxChars  int    `json:"handoff_max_chars"` // 转交时携带的会话记录上限（字符），默认 60000
+}
+
+// MCPGatewayConfig 表示网关作为 MCP server 的配置（每个 profile 发布为一个 ask_<profile> 工具）
+type MCPGatewayConfig struct {
+	Enabled    bool     `json:"enabled"`            // 是否启用 Streamable HTTP 端点
+	Path       string   `json:"path"`               // HTTP 路径，默认 "/mcp"
+	Profiles   []string `json:"profiles,omitempty"` // 发布的 profile，空表示全部
+	ToolPrefix string   `json:"tool_prefix"`        // 工具名前缀，默认 "ask_"
+}
+
+// ApprovalConfig 表示工具调用审批队列配置
+type ApprovalConfig struct {
+	CallbackURL    string   `json:"callback_url"`             // CLI 访问网关审批端点的地址，默认 http://127.0.0.1:<port>
+	TimeoutSeconds int      `json:"timeout_seconds"`          // 默认等待时长（秒），默认 300
+	HistorySize    int      `json:"history_size"`             // 保留最近已决定的审批数量，默认 200
+	Webhooks       []string `json:"webhooks,omitempty"`       // 审批事件 webhook 地址
+	WebhookSecret  string   `json:"webhook_secret,omitempty"` // webhook HMAC 签名密钥（支持 ${ENV} 占位）
+}
+
+// SkillsConfig 表示受管 skills 存储配置
+type SkillsConfig struct {
+	Root           string `json:"root"`             // skills 根目录，默认 "data/skills"
+	MaxBundleMB    int    `json:"max_bundle_mb"`    // 上传包大小上限（MB），默认 20
+	MaxExtractedMB int    `json:"max_extracted_mb"` // 解压后大小上限（MB），默认 100
+	MaxFiles       int    `json:"max_files"`        // 上传包文件数上限，默认 1000
+	MaxVersions    int    `json:"max_versions"`     // 每个 skill 保留的版本数，默认 10
+}
+
+// FSPolicyConfig 表示文件系统访问策略（作用于 skills、work_dir、上传目录与文件下载）
+type FSPolicyConfig struct {
+	AllowRoots         []string `json:"allow_roots,omitempty"`          // 允许访问的根目录，空表示不限制（受管 skills 根目录始终允许）
+	DenyGlobs          []string `json:"deny_globs,omitempty"`           // 额外的拒绝规则，追加在内置规则之后
+	DisableBuiltinDeny bool     `json:"disable_builtin_deny,omitempty"` // 不使用内置拒绝规则（配置、.env、日志、数据文件与 CLI 凭据）
+	AuditSize          int      `json:"audit_size"`                     // 保留的判定记录数，默认 200
+}
+
 // Config 表示整个配置文件
 type Config struct {
 	Server          *ServerConfig            `json:"server,omitempty"`
@@ -74,6 +200,20 @@ type Config struct {
 	ReleaseNotes    *ReleaseNotesConfig      `json:"release_notes,omitempty"`
 	WorkflowSession *WorkflowSessionConfig   `json:"workflow_session,omitempty"`
 	AdminUI         *AdminUIConfig           `json:"admin_ui,omitempty"`
+	Middleware      []cli.MiddlewareConfig   `json:"middleware,omitempty"` // profile 未配置中间件时使用的默认管道
+	ResponseCache   *ResponseCacheConfig     `json:"response_cache,omitempty"`
+	Admission       *AdmissionConfig         `json:"admission,omitempty"`
+	Batch           *BatchConfig             `json:"batch,omitempty"`
+	Compare         *CompareConfig           `json:"compare,omitempty"`
+	Structured      *StructuredOutputConfig  `json:"structured_output,omitempty"`
+	InvokeReplay    *InvokeReplayConfig      `json:"invoke_replay,omitempty"`
+	Sessions        *SessionRegistryConfig   `json:"sessions,omitempty"`
+	Approval        *ApprovalConfig          `json:"approval,omitempty"`
+	MCPServer       *MCPGatewayConfig        `json:"mcp_server,omitempty"`
+	Skills          *SkillsConfig            `json:"skills,omitempty"`
+	FSPolicy        *FSPolicyConfig          `json:"fs_policy,omitempty"`
+	ResourceLimits  *cli.ResourceLimits      `json:"resource_limits,omitempty"` // 所有 profile 的默认资源限制
+	EnvPolicy       *cli.EnvPolicy           `json:"env_policy,omitempty"`      // profile 未配置 env_policy 时使用的环境变量继承策略
 }
 
 const redactedValue = "__REDACTED__"
@@ -156,6 +296,7 @@ func loadConfigToGlobal(configPath string, strict bool) error {
 			return fmt.Errorf("config file not found: %s", configPath)
 		}
 		log.Printf("⚠️  Config file not found: %s, using environment variables", configPath)
+		cli.SetGatewaySecretEnv(gatewaySecretEnvKeys(nil))
 		setGlobalConfig(nil, configPath, time.Time{})
 		return nil
 	}
@@ -165,6 +306,8 @@ func loadConfigToGlobal(configPath string, strict bool) error {
 		return err
 	}
 
+	// 在解析占位符之前记录网关自身 secret 对应的变量名，CLI 子进程不继承它们
+	cli.SetGatewaySecretEnv(gatewaySecretEnvKeys(config))
 	applyEnvOverrides(config)
 
 	setGlobalConfig(config, configPath, time.Now())
@@ -174,6 +317,7 @@ func loadConfigToGlobal(configPath string, strict bool) error {
 	for name, profile := range config.Profiles {
 		log.Printf("   - %s: %s", name, profile.Name)
 	}
+	warnImplicitEnvAllowlist(config)
 
 	// 打印 release notes 配置
 	if config.ReleaseNotes != nil {
@@ -284,9 +428,38 @@ func applyEnvOverrides(cfg *Config) {
 		cfg.WorkflowSession.Redis.Password = resolveEnvPlaceholder(cfg.WorkflowSession.Redis.Password)
 	}
 
+	if cfg.ResponseCache != nil && cfg.ResponseCache.Redis != nil {
+		cfg.ResponseCache.Redis.Addr = resolveEnvPlaceholder(cfg.ResponseCache.Redis.Addr)
+		cfg.ResponseCache.Redis.Username = resolveEnvPlaceholder(cfg.ResponseCache.Redis.Username)
+		cfg.ResponseCache.Redis.Password = resolveEnvPlaceholder(cfg.ResponseCache.Redis.Password)
+	}
+
+	if cfg.Admission != nil {
+		for i := range cfg.Admission.APIKeys {
+			cfg.Admission.APIKeys[i].Key = resolveEnvPlaceholder(cfg.Admission.APIKeys[i].Key)
+		}
+	}
+
+	if cfg.Approval != nil {
+		cfg.Approval.WebhookSecret = resolveEnvPlaceholder(cfg.Approval.WebhookSecret)
+	}
+
 	for name, profile := range cfg.Profiles {
+		for serverName, server := range profile.MCPServers {
+			for key, value := range server.Env {
+				server.Env[key] = resolveEnvPlaceholder(value)
+			}
+			for key, value := range server.Headers {
+				server.Headers[key] = resolveEnvPlaceholder(value)
+			}
+			profile.MCPServers[serverName] = server
+		}
 		if profile.Env != nil {
 			for key, value := range profile.Env {
+				if isSecretReference(value) {
+					// ${env:...} / ${file:...} 在执行时解析，避免把 secret 写回配置
+					continue
+				}
 				resolved := resolveEnvPlaceholder(value)
 				if resolved == "" && value == "" {
 					if envValue := os.Getenv(key); envValue != "" {
@@ -301,6 +474,13 @@ func applyEnvOverrides(cfg *Config) {
 }
 
 func resolveEnvPlaceholder(value string) string {
+	if isSecretReference(value) {
+		resolved, err := resolveSecretReference(value)
+		if err != nil {
+			log.Printf("⚠️  Failed to resolve %s: %v", strings.TrimSpace(value), err)
+		}
+		return resolved
+	}
 	trimmed := strings.TrimSpace(value)
 	if strings.HasPrefix(trimmed, "${") && strings.HasSuffix(trimmed, "}") {
 		key := strings.TrimSuffix(strings.TrimPrefix(trimmed, "${"), "}")
@@ -415,6 +595,323 @@ func GetWorkflowSessionConfig() WorkflowSessionConfig {
 	return defaultConfig
 }
 
+// GetResponseCacheConfig 返回响应缓存配置，如果未配置则返回默认值
+func GetResponseCacheConfig() ResponseCacheConfig {
+	cfg := ResponseCacheConfig{
+		Backend:    "memory",
+		Strategy:   "lru",
+		MaxSizeMB:  100,
+		TTLMinutes: 60,
+		KeyPrefix:  "cli-gateway:cache",
+	}
+
+	cfgPtr := getGlobalConfig()
+	if cfgPtr != nil && cfgPtr.ResponseCache != nil {
+		custom := *cfgPtr.ResponseCache
+		if custom.Backend != "" {
+			cfg.Backend = custom.Backend
+		}
+		if custom.Strategy != "" {
+			cfg.Strategy = custom.Strategy
+		}
+		if custom.MaxSizeMB > 0 {
+			cfg.MaxSizeMB = custom.MaxSizeMB
+		}
+		if custom.TTLMinutes > 0 {
+			cfg.TTLMinutes = custom.TTLMinutes
+		}
+		if custom.KeyPrefix != "" {
+			cfg.KeyPrefix = custom.KeyPrefix
+		}
+		cfg.Redis = custom.Redis
+	}
+
+	if cfg.Backend == "redis" && cfg.Redis == nil {
+		cfg.Redis = GetWorkflowSessionConfig().Redis
+	}
+	return cfg
+}
+
+// GetAdmissionConfig 返回准入队列配置，如果未配置则返回默认值（未启用）
+func GetAdmissionConfig() AdmissionConfig {
+	cfg := AdmissionConfig{
+		MaxConcurrent:   4,
+		MaxQueueDepth:   100,
+		MaxWaitMS:       120000,
+		DefaultPriority: "interactive",
+		PriorityHeader:  "X-Priority",
+	}
+
+	cfgPtr := getGlobalConfig()
+	if cfgPtr != nil && cfgPtr.Admission != nil {
+		custom := *cfgPtr.Admission
+		cfg.Enabled = custom.Enabled
+		if custom.MaxConcurrent > 0 {
+			cfg.MaxConcurrent = custom.MaxConcurrent
+		}
+		if custom.MaxQueueDepth > 0 {
+			cfg.MaxQueueDepth = custom.MaxQueueDepth
+		}
+		if custom.MaxWaitMS > 0 {
+			cfg.MaxWaitMS = custom.MaxWaitMS
+		}
+		if custom.DefaultPriority != "" {
+			cfg.DefaultPriority = custom.DefaultPriority
+		}
+		if custom.PriorityHeader != "" {
+			cfg.PriorityHeader = custom.PriorityHeader
+		}
+		cfg.TenantWeights = custom.TenantWeights
+		cfg.APIKeys = custom.APIKeys
+	}
+	return cfg
+}
+
+// GetBatchConfig 返回批任务配置，如果未配置则返回默认值
+func GetBatchConfig() BatchConfig {
+	cfg := BatchConfig{
+		StorageDir:     "data/batches",
+		MaxConcurrency: 4,
+		MaxItems:       1000,
+		MaxBodyMB:      16,
+	}
+
+	cfgPtr := getGlobalConfig()
+	if cfgPtr != nil && cfgPtr.Batch != nil {
+		custom := *cfgPtr.Batch
+		if custom.StorageDir != "" {
+			cfg.StorageDir = custom.StorageDir
+		}
+		if custom.MaxConcurrency > 0 {
+			cfg.MaxConcurrency = custom.MaxConcurrency
+		}
+		if custom.MaxItems > 0 {
+			cfg.MaxItems = custom.MaxItems
+		}
+		if custom.MaxBodyMB > 0 {
+			cfg.MaxBodyMB = custom.MaxBodyMB
+		}
+	}
+	return cfg
+}
+
+// GetCompareConfig 返回多 CLI 对比配置，如果未配置则返回默认值
+func GetCompareConfig() CompareConfig {
+	cfg := CompareConfig{
+		StorageDir: "data/comparisons",
+		MaxStored:  500,
+		MaxTargets: 8,
+	}
+
+	cfgPtr := getGlobalConfig()
+	if cfgPtr != nil && cfgPtr.Compare != nil {
+		custom := *cfgPtr.Compare
+		if custom.StorageDir != "" {
+			cfg.StorageDir = custom.StorageDir
+		}
+		if custom.MaxStored > 0 {
+			cfg.MaxStored = custom.MaxStored
+		}
+		if custom.MaxTargets > 0 {
+			cfg.MaxTargets = custom.MaxTargets
+		}
+	}
+	return cfg
+}
+
+// GetStructuredOutputConfig 返回结构化输出配置，如果未配置则返回默认值
+func GetStructuredOutputConfig() StructuredOutputConfig {
+	maxRepairs := 2
+	cfgPtr := getGlobalConfig()
+	if cfgPtr != nil && cfgPtr.Structured != nil && cfgPtr.Structured.MaxRepairs != nil && *cfgPtr.Structured.MaxRepairs >= 0 {
+		maxRepairs = *cfgPtr.Structured.MaxRepairs
+	}
+	return StructuredOutputConfig{MaxRepairs: &maxRepairs}
+}
+
+// GetInvokeReplayConfig 返回 /invoke 多轮回放配置，如果未配置则返回默认值
+func GetInvokeReplayConfig() InvokeReplayConfig {
+	enabled := true
+	cfg := InvokeReplayConfig{
+		Enabled:    &enabled,
+		TTLMinutes: 1440,
+	}
+
+	cfgPtr := getGlobalConfig()
+	if cfgPtr != nil && cfgPtr.InvokeReplay != nil {
+		if cfgPtr.InvokeReplay.Enabled != nil {
+			enabled = *cfgPtr.InvokeReplay.Enabled
+		}
+		if cfgPtr.InvokeReplay.TTLMinutes > 0 {
+			cfg.TTLMinutes = cfgPtr.InvokeReplay.TTLMinutes
+		}
+	}
+	return cfg
+}
+
+// GetSessionRegistryConfig 返回会话登记配置，如果未配置则返回默认值
+func GetSessionRegistryConfig() SessionRegistryConfig {
+	cfg := SessionRegistryConfig{
+		StoragePath:     "data/sessions.json",
+		IdleTTLMinutes:  1440,
+		HandoffMaxChars: 60000,
+	}
+
+	cfgPtr := getGlobalConfig()
+	if cfgPtr != nil && cfgPtr.Sessions != nil {
+		custom := *cfgPtr.Sessions
+		if custom.StoragePath != "" {
+			cfg.StoragePath = custom.StoragePath
+		}
+		if custom.IdleTTLMinutes > 0 {
+			cfg.IdleTTLMinutes = custom.IdleTTLMinutes
+		}
+		if custom.RetentionMinutes > 0 {
+			cfg.RetentionMinutes = custom.RetentionMinutes
+		}
+		if custom.HandoffMaxChars > 0 {
+			cfg.HandoffMaxChars = custom.HandoffMaxChars
+		}
+	}
+	return cfg
+}
+
+// GetApprovalConfig 返回工具调用审批配置，如果未配置则返回默认值
+func GetApprovalConfig() ApprovalConfig {
+	cfg := ApprovalConfig{
+		TimeoutSeconds: 300,
+		HistorySize:    200,
+	}
+
+	cfgPtr := getGlobalConfig()
+	if cfgPtr != nil && cfgPtr.Approval != nil {
+		custom := *cfgPtr.Approval
+		cfg.CallbackURL = custom.CallbackURL
+		cfg.Webhooks = custom.Webhooks
+		cfg.WebhookSecret = custom.WebhookSecret
+		if custom.TimeoutSeconds > 0 {
+			cfg.TimeoutSeconds = custom.TimeoutSeconds
+		}
+		if custom.HistorySize > 0 {
+			cfg.HistorySize = custom.HistorySize
+		}
+	}
+	if cfg.CallbackURL == "" {
+		// 与 main 一致：PORT 环境变量优先于配置文件
+		port := GetServerConfig().Port
+		if envPort, err := strconv.Atoi(os.Getenv("PORT")); err == nil && envPort > 0 {
+			port = envPort
+		}
+		cfg.CallbackURL = fmt.Sprintf("http://127.0.0.1:%d", port)
+	}
+	cfg.CallbackURL = strings.TrimRight(cfg.CallbackURL, "/")
+	return cfg
+}
+
+// GetMCPGatewayConfig 返回网关 MCP server 配置，如果未配置则返回默认值（未启用）
+func GetMCPGatewayConfig() MCPGatewayConfig {
+	cfg := MCPGatewayConfig{
+		Path:       "/mcp",
+		ToolPrefix: "ask_",
+	}
+
+	cfgPtr := getGlobalConfig()
+	if cfgPtr != nil && cfgPtr.MCPServer != nil {
+		custom := *cfgPtr.MCPServer
+		cfg.Enabled = custom.Enabled
+		cfg.Profiles = custom.Profiles
+		if custom.Path != "" {
+			cfg.Path = "/" + strings.Trim(custom.Path, "/")
+		}
+		if custom.ToolPrefix != "" {
+			cfg.ToolPrefix = custom.ToolPrefix
+		}
+	}
+	return cfg
+}
+
+// GetSkillsConfig 返回受管 skills 配置，如果未配置则返回默认值
+func GetSkillsConfig() SkillsConfig {
+	cfg := SkillsConfig{
+		Root:           "data/skills",
+		MaxBundleMB:    20,
+		MaxExtractedMB: 100,
+		MaxFiles:       1000,
+		MaxVersions:    10,
+	}
+
+	cfgPtr := getGlobalConfig()
+	if cfgPtr != nil && cfgPtr.Skills != nil {
+		custom := *cfgPtr.Skills
+		if custom.Root != "" {
+			cfg.Root = custom.Root
+		}
+		if custom.MaxBundleMB > 0 {
+			cfg.MaxBundleMB = custom.MaxBundleMB
+		}
+		if custom.MaxExtractedMB > 0 {
+			cfg.MaxExtractedMB = custom.MaxExtractedMB
+		}
+		if custom.MaxFiles > 0 {
+			cfg.MaxFiles = custom.MaxFiles
+		}
+		if custom.MaxVersions > 0 {
+			cfg.MaxVersions = custom.MaxVersions
+		}
+	}
+	return cfg
+}
+
+// GetFSPolicyConfig 返回文件系统访问策略配置，如果未配置则返回默认值（仅内置拒绝规则）
+func GetFSPolicyConfig() FSPolicyConfig {
+	cfg := FSPolicyConfig{AuditSize: 200}
+
+	cfgPtr := getGlobalConfig()
+	if cfgPtr != nil && cfgPtr.FSPolicy != nil {
+		custom := *cfgPtr.FSPolicy
+		cfg.AllowRoots = custom.AllowRoots
+		cfg.DenyGlobs = custom.DenyGlobs
+		cfg.DisableBuiltinDeny = custom.DisableBuiltinDeny
+		if custom.AuditSize > 0 {
+			cfg.AuditSize = custom.AuditSize
+		}
+	}
+	return cfg
+}
+
+// GetResourceLimitsConfig 返回 profile 的资源限制：profile.resources 中的非零项覆盖全局 resource_limits，均未配置时返回 nil
+func GetResourceLimitsConfig(profile ProfileConfig) *cli.ResourceLimits {
+	var cfg cli.ResourceLimits
+	cfgPtr := getGlobalConfig()
+	if cfgPtr != nil && cfgPtr.ResourceLimits != nil {
+		cfg = *cfgPtr.ResourceLimits
+	}
+	if custom := profile.Resources; custom != nil {
+		if custom.CgroupParent != "" {
+			cfg.CgroupParent = custom.CgroupParent
+		}
+		if custom.MemoryMB > 0 {
+			cfg.MemoryMB = custom.MemoryMB
+		}
+		if custom.CPUs > 0 {
+			cfg.CPUs = custom.CPUs
+		}
+		if custom.MaxPids > 0 {
+			cfg.MaxPids = custom.MaxPids
+		}
+		if custom.MaxFileSizeMB > 0 {
+			cfg.MaxFileSizeMB = custom.MaxFileSizeMB
+		}
+		if custom.MaxOpenFiles > 0 {
+			cfg.MaxOpenFiles = custom.MaxOpenFiles
+		}
+	}
+	if cfg == (cli.ResourceLimits{}) {
+		return nil
+	}
+	return &cfg
+}
+
 // GetAdminUIConfig 返回后台 UI 配置（含环境变量覆盖与默认值）
 func GetAdminUIConfig() AdminUIConfig {
 	defaultConfig := AdminUIConfig{
@@ -530,17 +1027,42 @@ func redactConfig(cfg *Config) (*Config, error) {
 	if clone.WorkflowSession != nil && clone.WorkflowSession.Redis != nil && clone.WorkflowSession.Redis.Password != "" {
 		clone.WorkflowSession.Redis.Password = redactedValue
 	}
+	if clone.ResponseCache != nil && clone.ResponseCache.Redis != nil && clone.ResponseCache.Redis.Password != "" {
+		clone.ResponseCache.Redis.Password = redactedValue
+	}
+	if clone.Admission != nil {
+		for i := range clone.Admission.APIKeys {
+			if clone.Admission.APIKeys[i].Key != "" {
+				clone.Admission.APIKeys[i].Key = redactedValue
+			}
+		}
+	}
+	if clone.Approval != nil && clone.Approval.WebhookSecret != "" {
+		clone.Approval.WebhookSecret = redactedValue
+	}
 	for name, profile := range clone.Profiles {
 		if profile.SystemPrompt != "" {
 			profile.SystemPrompt = redactedValue
 		}
 		if profile.Env != nil {
 			for key, value := range profile.Env {
-				if value != "" && isSensitiveEnvKey(key) {
+				if value != "" && isSensitiveEnvKey(key) && !isSecretReference(value) {
 					profile.Env[key] = redactedValue
 				}
 			}
 		}
+		for _, server := range profile.MCPServers {
+			for key, value := range server.Env {
+				if value != "" && isSensitiveEnvKey(key) {
+					server.Env[key] = redactedValue
+				}
+			}
+			for key, value := range server.Headers {
+				if value != "" && isSensitiveEnvKey(key) {
+					server.Headers[key] = redactedValue
+				}
+			}
+		}
 		clone.Profiles[name] = profile
 	}
 	return clone, nil
@@ -0,0 +1,142 @@
+package handler
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+
+	"dify-cli-gateway/internal/cli"
+)
+
+// parseSecretReference 解析 secret 引用：${env:NAME} 读取网关环境变量，${file:/path} 读取文件内容（如 Docker / Kubernetes secrets）
+func parseSecretReference(value string) (kind string, target string, ok bool) {
+	trimmed := strings.TrimSpace(value)
+	if !strings.HasPrefix(trimmed, "${") || !strings.HasSuffix(trimmed, "}") {
+		return "", "", false
+	}
+	kind, target, ok = strings.Cut(trimmed[2:len(trimmed)-1], ":")
+	if !ok || target == "" || (kind != "env" && kind != "file") {
+		return "", "", false
+	}
+	return kind, target, true
+}
+
+// isSecretReference 值是否为 secret 引用（引用本身不是敏感信息，可以明文展示）
+func isSecretReference(value string) bool {
+	_, _, ok := parseSecretReference(value)
+	return ok
+}
+
+// resolveSecretReference 解析 secret 引用的值；不是引用时原样返回
+func resolveSecretReference(value string) (string, error) {
+	kind, target, ok := parseSecretReference(value)
+	if !ok {
+		return value, nil
+	}
+	switch kind {
+	case "env":
+		resolved, exists := os.LookupEnv(target)
+		if !exists {
+			return "", fmt.Errorf("environment variable %s is not set", target)
+		}
+		return resolved, nil
+	default:
+		data, err := os.ReadFile(target)
+		if err != nil {
+			return "", fmt.Errorf("failed to read secret file: %v", err)
+		}
+		return strings.TrimRight(string(data), "\r\n"), nil
+	}
+}
+
+// resolveProfileEnv 复制 profile env 并在执行时解析其中的 secret 引用（不修改配置本身）
+func resolveProfileEnv(env map[string]string) (map[string]string, error) {
+	resolved := make(map[string]string, len(env))
+	for key, value := range env {
+		secret, err := resolveSecretReference(value)
+		if err != nil {
+			return nil, fmt.Errorf("profile env %s: %v", key, err)
+		}
+		resolved[key] = secret
+	}
+	return resolved, nil
+}
+
+// gatewaySecretEnvKeys 网关自身使用的 secret 变量名：管理 token、stdio 模式的 API Key，
+// 以及配置中 Redis 凭据、准入 API Key、审批 webhook 签名等字段通过占位符引用的变量
+func gatewaySecretEnvKeys(cfg *Config) []string {
+	keys := []string{"ADMIN_UI_TOKEN", "CLI_GATEWAY_API_KEY"}
+	if cfg == nil {
+		return keys
+	}
+	values := []string{}
+	if cfg.AdminUI != nil {
+		values = append(values, cfg.AdminUI.Token)
+	}
+	if cfg.WorkflowSession != nil && cfg.WorkflowSession.Redis != nil {
+		values = append(values, cfg.WorkflowSession.Redis.Username, cfg.WorkflowSession.Redis.Password)
+	}
+	if cfg.ResponseCache != nil && cfg.ResponseCache.Redis != nil {
+		values = append(values, cfg.ResponseCache.Redis.Username, cfg.ResponseCache.Redis.Password)
+	}
+	if cfg.Admission != nil {
+		for _, apiKey := range cfg.Admission.APIKeys {
+			values = append(values, apiKey.Key)
+		}
+	}
+	if cfg.Approval != nil {
+		values = append(values, cfg.Approval.WebhookSecret)
+	}
+	for _, value := range values {
+		if key := placeholderEnvKey(value); key != "" {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// placeholderEnvKey 返回 ${NAME} / ${env:NAME} 引用的变量名
+func placeholderEnvKey(value string) string {
+	trimmed := strings.TrimSpace(value)
+	if !strings.HasPrefix(trimmed, "${") || !strings.HasSuffix(trimmed, "}") {
+		return ""
+	}
+	name := trimmed[2 : len(trimmed)-1]
+	if kind, target, ok := strings.Cut(name, ":"); ok {
+		if kind != "env" {
+			return ""
+		}
+		name = target
+	}
+	return name
+}
+
+// GetEnvPolicyConfig 返回 profile 的环境变量继承策略：profile.env_policy 优先，其次全局 env_policy，均未配置时返回 nil（默认 allowlist）
+func GetEnvPolicyConfig(profile ProfileConfig) *cli.EnvPolicy {
+	if profile.EnvPolicy != nil {
+		return profile.EnvPolicy
+	}
+	if cfgPtr := getGlobalConfig(); cfgPtr != nil {
+		return cfgPtr.EnvPolicy
+	}
+	return nil
+}
+
+// warnImplicitEnvAllowlist 提示未配置 env 与 env_policy 的 profile：默认 allowlist 不再继承网关环境中的 API key
+func warnImplicitEnvAllowlist(config *Config) {
+	if config == nil || config.EnvPolicy != nil {
+		return
+	}
+	names := make([]string, 0, len(config.Profiles))
+	for name, profile := range config.Profiles {
+		if len(profile.Env) == 0 && profile.EnvPolicy == nil {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		log.Printf("⚠️  [EnvPolicy] Profile %s has no env or env_policy: CLI only inherits the default allowlist (PATH, HOME, proxy vars...), API keys such as ANTHROPIC_API_KEY are not passed; reference them in env (\"${env:NAME}\") or set env_policy.inherit to \"all\"", name)
+	}
+}
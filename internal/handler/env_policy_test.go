@@ -0,0 +1,50 @@
+package handler
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestResolveProfileEnv_SecretReferences(t *testing.T) {
+	t.Setenv("TEST_GATEWAY_OPENAI_KEY", "sk-env")
+	secretFile := filepath.Join(t.TempDir(), "anthropic")
+	os.WriteFile(secretFile, []byte("sk-file\n"), 0o600)
+
+	profileEnv := map[string]string{
+		"OPENAI_API_KEY":    "${env:TEST_GATEWAY_OPENAI_KEY}",
+		"ANTHROPIC_API_KEY": "${file:" + secretFile + "}",
+		"MODEL":             "gpt-5",
+	}
+	env, err := resolveProfileEnv(profileEnv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if env["OPENAI_API_KEY"] != "sk-env" || env["ANTHROPIC_API_KEY"] != "sk-file" || env["MODEL"] != "gpt-5" {
+		t.Errorf("unexpected resolved env: %v", env)
+	}
+	if profileEnv["OPENAI_API_KEY"] != "${env:TEST_GATEWAY_OPENAI_KEY}" {
+		t.Error("profile config should keep the reference")
+	}
+
+	if _, err := resolveProfileEnv(map[string]string{"KEY": "${env:TEST_GATEWAY_MISSING}"}); err == nil || !strings.Contains(err.Error(), "KEY") {
+		t.Errorf("missing secret should fail the request, got %v", err)
+	}
+
+	cfg := &Config{Profiles: map[string]ProfileConfig{"p": {Env: map[string]string{
+		"OPENAI_API_KEY": "${env:TEST_GATEWAY_OPENAI_KEY}",
+		"LEGACY_TOKEN":   "${TEST_GATEWAY_OPENAI_KEY}",
+	}}}}
+	applyEnvOverrides(cfg)
+	if got := cfg.Profiles["p"].Env; got["OPENAI_API_KEY"] != "${env:TEST_GATEWAY_OPENAI_KEY}" || got["LEGACY_TOKEN"] != "sk-env" {
+		t.Errorf("references should be resolved at run time, legacy placeholders at load time: %v", got)
+	}
+	redacted, err := redactConfig(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := redacted.Profiles["p"].Env; got["OPENAI_API_KEY"] != "${env:TEST_GATEWAY_OPENAI_KEY}" || got["LEGACY_TOKEN"] != redactedValue {
+		t.Errorf("references are not secrets and should stay visible: %v", got)
+	}
+}
@@ -0,0 +1,135 @@
+package handler
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"dify-cli-gateway/internal/fs_policy"
+)
+
+var (
+	fsPolicyMu     sync.Mutex
+	fsPolicy       *fs_policy.Policy
+	fsPolicyErr    error
+	fsPolicyConfig *Config
+	fsPolicyBuilt  bool
+	fsPolicyAudit  *fs_policy.AuditLog
+)
+
+// builtinFSDenyGlobs 内置拒绝规则：网关配置与 .env、日志、数据文件，以及各 CLI 的登录凭据
+func builtinFSDenyGlobs() []string {
+	globs := []string{
+		"configs.json",
+		"configs/configs.json",
+		".env",
+		".env.*",
+		"logs",
+		GetReleaseNotesConfig().StoragePath,
+		GetSessionRegistryConfig().StoragePath,
+		GetBatchConfig().StorageDir,
+		GetCompareConfig().StorageDir,
+		"~/.claude.json",
+		"~/.claude/.credentials.json",
+		"~/.codex/auth.json",
+		"~/.gemini/oauth_creds.json",
+		"~/.gemini/google_accounts.json",
+		"~/.qwen/oauth_creds.json",
+		"~/.iflow/*creds*",
+		"~/.config/cursor/auth.json",
+		"~/.cursor/*auth*",
+		"~/.ssh",
+		"~/.aws",
+		"~/.netrc",
+		"~/.git-credentials",
+		"~/.docker/config.json",
+	}
+	if path := getConfigPath(); path != "" {
+		globs = append(globs, path)
+	}
+	return globs
+}
+
+// getFSPolicy 返回当前配置对应的访问策略（配置重新加载后重建），策略无效时返回错误
+func getFSPolicy() (*fs_policy.Policy, error) {
+	cfg := getGlobalConfig()
+	fsPolicyMu.Lock()
+	defer fsPolicyMu.Unlock()
+	if fsPolicyBuilt && fsPolicyConfig == cfg {
+		return fsPolicy, fsPolicyErr
+	}
+
+	policyCfg := GetFSPolicyConfig()
+	rules := fs_policy.Rules{DenyGlobs: policyCfg.DenyGlobs}
+	if !policyCfg.DisableBuiltinDeny {
+		rules.DenyGlobs = append(builtinFSDenyGlobs(), policyCfg.DenyGlobs...)
+	}
+	if len(policyCfg.AllowRoots) > 0 {
+		rules.AllowRoots = append(append([]string{}, policyCfg.AllowRoots...), GetSkillsConfig().Root)
+	}
+	fsPolicy, fsPolicyErr = fs_policy.New(rules)
+	if fsPolicyErr != nil {
+		log.Printf("⚠️  [FSPolicy] Invalid fs_policy, all checked paths will be denied: %v", fsPolicyErr)
+	}
+	if fsPolicyAudit == nil {
+		fsPolicyAudit = fs_policy.NewAuditLog(policyCfg.AuditSize)
+	}
+	fsPolicyConfig, fsPolicyBuilt = cfg, true
+	return fsPolicy, fsPolicyErr
+}
+
+// checkFSPath 按访问策略判定路径，记录日志与审计；subject 标识发起方（profile、上传者等）
+func checkFSPath(purpose fs_policy.Purpose, path, subject string) fs_policy.Decision {
+	policy, err := getFSPolicy()
+	var decision fs_policy.Decision
+	if err != nil {
+		decision = fs_policy.Decision{Time: time.Now(), Purpose: purpose, Path: path, Reason: "invalid fs_policy: " + err.Error()}
+	} else {
+		decision = policy.Check(purpose, path)
+	}
+	decision.Subject = subject
+
+	fsPolicyMu.Lock()
+	audit := fsPolicyAudit
+	fsPolicyMu.Unlock()
+	if audit != nil {
+		audit.Record(decision)
+	}
+
+	if decision.Allowed {
+		log.Printf("🛡️  [FSPolicy] allow %s %s (subject=%s resolved=%s)", purpose, path, subject, decision.Resolved)
+	} else {
+		log.Printf("⚠️  [FSPolicy] deny %s %s (subject=%s resolved=%s rule=%s reason=%s)", purpose, path, subject, decision.Resolved, decision.Rule, decision.Reason)
+	}
+	return decision
+}
+
+// handleAdminFSPolicy 返回生效的访问策略与最近的判定：GET /api/fs-policy?limit=100&denied=true
+func handleAdminFSPolicy(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeMethodNotAllowed(w)
+		return
+	}
+	policy, err := getFSPolicy()
+	response := map[string]interface{}{}
+	if err != nil {
+		response["error"] = err.Error()
+	} else {
+		response["allow_roots"] = policy.AllowRoots()
+		response["deny_globs"] = policy.DenyGlobs()
+	}
+
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	deniedOnly, _ := strconv.ParseBool(r.URL.Query().Get("denied"))
+	decisions := []fs_policy.Decision{}
+	fsPolicyMu.Lock()
+	audit := fsPolicyAudit
+	fsPolicyMu.Unlock()
+	if audit != nil {
+		decisions = audit.Recent(limit, deniedOnly)
+	}
+	response["decisions"] = decisions
+	writeJSON(w, http.StatusOK, response)
+}
@@ -0,0 +1,117 @@
+package handler
+
+import (
+	"bytes"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"dify-cli-gateway/internal/fs_policy"
+)
+
+func TestFSPolicy_SkillsUploadsAndAudit(t *testing.T) {
+	base := t.TempDir()
+	allowed := filepath.Join(base, "skills", "pdf")
+	os.MkdirAll(allowed, 0o755)
+	os.MkdirAll(filepath.Join(base, "secrets"), 0o755)
+	os.WriteFile(filepath.Join(base, "secrets", "token.txt"), []byte("x"), 0o600)
+	link := filepath.Join(base, "skills", "linked")
+	if err := os.Symlink(filepath.Join(base, "secrets"), link); err != nil {
+		t.Skip(err)
+	}
+	skillsRoot := filepath.Join(base, "secrets", "store")
+
+	withGlobalConfig(t, &Config{
+		Skills: &SkillsConfig{Root: skillsRoot},
+		FSPolicy: &FSPolicyConfig{
+			AllowRoots: []string{filepath.Join(base, "skills")},
+			DenyGlobs:  []string{filepath.Join(base, "secrets")},
+		},
+	})
+	InitSkillStore()
+	t.Cleanup(func() {
+		skillStoreMu.Lock()
+		skillStore = nil
+		skillStoreMu.Unlock()
+	})
+
+	got := filterSkillPaths([]string{allowed, link, "/etc", ".env"}, "profile:test")
+	resolved, _ := filepath.EvalSymlinks(allowed)
+	if len(got) != 1 || got[0] != resolved {
+		t.Errorf("only the allowed skill should remain (resolved): %v", got)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/admin/api/skills", bytes.NewReader(skillZip(t, map[string]string{"SKILL.md": "---\nname: x\ndescription: y\n---\n"})))
+	rec := httptest.NewRecorder()
+	handleAdminSkills(rec, req, "/api/skills")
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("uploads into a denied skills root should be rejected, got %d %s", rec.Code, rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	handleAdminFSPolicy(rec, httptest.NewRequest(http.MethodGet, "/v1/admin/api/fs-policy?denied=true&limit=10", nil))
+	body := rec.Body.String()
+	if rec.Code != http.StatusOK || !strings.Contains(body, `"purpose":"upload"`) || !strings.Contains(body, `"subject":"profile:test"`) || !strings.Contains(body, `"rule":"deny:.env"`) {
+		t.Errorf("denied decisions should be auditable: %d %s", rec.Code, body)
+	}
+	if strings.Contains(body, `"allowed":true`) {
+		t.Errorf("denied=true should filter allowed decisions: %s", body)
+	}
+}
+
+func TestDefaultWorkDir_ChecksGatewayDirectory(t *testing.T) {
+	base := t.TempDir()
+	previous, _ := os.Getwd()
+	if err := os.Chdir(base); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(previous) })
+	resolvedBase, _ := filepath.EvalSymlinks(base)
+
+	// 内置规则拒绝网关目录中的 configs.json / .env，因此网关目录不能作为 work_dir
+	withGlobalConfig(t, &Config{})
+	var logs bytes.Buffer
+	log.SetOutput(&logs)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+	for i := 0; i < 2; i++ {
+		dir, err := defaultWorkDir("team/a")
+		if err != nil || dir != filepath.Join(resolvedBase, "data", "workspaces", "team_a") {
+			t.Errorf("the gateway directory must not be exposed as work_dir: %s %v", dir, err)
+		}
+	}
+	if n := strings.Count(logs.String(), "📁 [FSPolicy]"); n != 1 {
+		t.Errorf("the work_dir fallback should be logged once per profile, got %d:\n%s", n, logs.String())
+	}
+
+	withGlobalConfig(t, &Config{FSPolicy: &FSPolicyConfig{DisableBuiltinDeny: true}})
+	if dir, err := defaultWorkDir("team/a"); err != nil || dir != resolvedBase {
+		t.Errorf("an allowed gateway directory should stay the work_dir: %s %v", dir, err)
+	}
+}
+
+func TestOtherProfileDirs_HidesOtherTenants(t *testing.T) {
+	base := t.TempDir()
+	tenantA, tenantB := filepath.Join(base, "a"), filepath.Join(base, "b")
+	withGlobalConfig(t, &Config{Profiles: map[string]ProfileConfig{
+		"a":      {CLI: "claude", WorkDir: tenantA},
+		"b":      {CLI: "claude", WorkDir: tenantB},
+		"shared": {CLI: "claude", WorkDir: tenantA},
+		"none":   {CLI: "claude"},
+	}})
+	resolvedA, _ := fs_policy.Resolve(tenantA)
+	resolvedB, _ := fs_policy.Resolve(tenantB)
+
+	dirs := strings.Join(otherProfileDirs("a", resolvedA), "\n")
+	if !strings.Contains(dirs, resolvedB) || strings.Contains(dirs, resolvedA) {
+		t.Errorf("only other profiles' work_dirs should be hidden: %s", dirs)
+	}
+	workspaces, _ := filepath.Abs(filepath.Join("data", "workspaces"))
+	homes, _ := filepath.Abs(filepath.Join("data", "homes"))
+	if !strings.Contains(dirs, workspaces) || !strings.Contains(dirs, homes) {
+		t.Errorf("gateway-managed workspaces and homes should be hidden: %s", dirs)
+	}
+}
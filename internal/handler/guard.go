@@ -5,6 +5,8 @@ import (
 	"log"
 	"net/http"
 	"strings"
+
+	"dify-cli-gateway/internal/cli"
 )
 
 const guardedResponseText = "我是您的AI助手啊，有什么问题尽管问。"
@@ -100,6 +102,12 @@ func shouldGuardMessages(messages []Message) (string, bool) {
 func writeGuardedResponse(w http.ResponseWriter, prompt string) {
 	log.Printf("🛑 Guarded prompt detected, returning safe response")
 
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(InvokeResponse{Answer: guardedOutput(prompt)})
+}
+
+func guardedOutput(prompt string) string {
 	result := CLIOutput{
 		SessionID: "",
 		User:      prompt,
@@ -108,12 +116,32 @@ func writeGuardedResponse(w http.ResponseWriter, prompt string) {
 
 	payload, err := json.Marshal(result)
 	if err != nil {
-		payload = []byte(guardedResponseText)
+		return guardedResponseText
 	}
+	return string(payload)
+}
 
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(InvokeResponse{Answer: string(payload)})
+func init() {
+	cli.RegisterPipelineMiddleware("guard", func(cfg cli.MiddlewareConfig) (cli.PipelineMiddleware, error) {
+		return &guardMiddleware{}, nil
+	})
+}
+
+// guardMiddleware 网关中间件：命中 guard 规则时短路返回安全回复，不启动 CLI
+// 用于在 prompt_rewrite 等中间件改写 prompt 之后再次检查
+type guardMiddleware struct{}
+
+func (m *guardMiddleware) Name() string {
+	return "guard"
+}
+
+func (m *guardMiddleware) Handle(req *cli.PipelineRequest, next cli.PipelineHandler) (string, error) {
+	if shouldGuardPrompt(req.Options.Prompt) {
+		log.Printf("🛑 [Pipeline:Guard] Guarded prompt detected, short-circuit")
+		req.SetMeta("short_circuit", m.Name())
+		return guardedOutput(req.Options.Prompt), nil
+	}
+	return next(req)
 }
 
 func containsAny(text string, phrases []string) bool {
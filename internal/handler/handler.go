@@ -42,39 +42,87 @@ func HandleInvoke(w http.ResponseWriter, r *http.Request) {
 	log.Printf("📝 Request parsed - System: %q, Messages: %d, Profile: %s (took %v)",
 		req.System, len(req.Messages), profileInfo, parseDuration)
 
+	schema, err := parseResponseSchema(req.ResponseSchema)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
 	if promptSource, guarded := shouldGuardMessages(req.Messages); guarded {
 		writeGuardedResponse(w, promptSource)
 		log.Printf("📤 Response sent successfully (guarded)")
 		return
 	}
 
-	// 调用 buildPrompt 函数构建 prompt
+	// 历史前缀命中已记录的会话时恢复该会话，只发送最新一条用户消息；否则展开完整历史
 	buildStart := time.Now()
-	prompt := buildPrompt(req.Messages)
+	replay := newInvokeReplay(req, requestOwner(r))
+	sessionID, resumed := replay.lookup()
+	var prompt string
+	if resumed {
+		prompt = replay.latest.Content
+		log.Printf("♻️  [InvokeReplay] History matched, resuming session %s (sending 1 of %d messages)", sessionID, len(req.Messages))
+	} else {
+		prompt = buildPrompt(req.Messages)
+	}
 	buildDuration := time.Since(buildStart)
 	log.Printf("🔨 Built prompt (%d chars, took %v)", len(prompt), buildDuration)
 
 	// 调用 runCLI 函数执行 CLI
 	log.Println("🚀 Calling CLI...")
 	cliStart := time.Now()
-	result, err := runCLI(req.CLI, prompt, req.System, req.Profile, "", false, nil, "")
+	metadata := requestMetadata(r)
+	cliReq := cliRequest{
+		Context:      r.Context(),
+		CLI:          req.CLI,
+		Prompt:       prompt,
+		System:       req.System,
+		Profile:      req.Profile,
+		SessionID:    sessionID,
+		CaptureSteps: req.IncludeSteps,
+		Metadata:     metadata,
+	}
+	var result string
+	var structured *structuredResult
+	run := func(cliReq cliRequest) {
+		if schema != nil {
+			result, structured, err = runStructuredCLI(cliReq, req.ResponseSchema, schema)
+		} else {
+			result, err = runCLI(cliReq)
+		}
+	}
+	run(cliReq)
+	if resumed && r.Context().Err() == nil && shouldReplayFullHistory(err) {
+		// 会话已被 CLI 清理（或登记过期），回退为展开完整历史重新开始
+		log.Printf("⚠️  [InvokeReplay] Resume failed, retrying with full history: %v", err)
+		cliReq.SessionID = ""
+		cliReq.Prompt = buildPrompt(req.Messages)
+		run(cliReq)
+	}
 	cliDuration := time.Since(cliStart)
 
 	if err != nil {
 		// 如果 runCLI 返回错误，返回 500 错误响应
 		log.Printf("❌ CLI failed after %v: %v", cliDuration, err)
-		w.WriteHeader(http.StatusInternalServerError)
+		w.WriteHeader(cliErrorStatus(err))
 		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
 		return
 	}
 
 	log.Printf("✅ CLI succeeded, response length: %d chars (took %v)", len(result), cliDuration)
+	replay.remember(result, metadata)
 
 	// 如果成功，构建 InvokeResponse 并返回 200 响应
 	// 设置响应头 Content-Type 为 application/json
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(InvokeResponse{Answer: result})
+	setCacheHeader(w, metadata)
+	setResourceHeaders(w, metadata)
+	if structured != nil {
+		writeStructuredResponse(w, result, structured)
+	} else {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(InvokeResponse{Answer: result})
+	}
 
 	totalDuration := time.Since(startTime)
 	log.Printf("📤 Response sent successfully")
@@ -112,6 +160,12 @@ func HandleChat(w http.ResponseWriter, r *http.Request) {
 		prompt = req.Message
 	}
 
+	schema, err := parseResponseSchema(req.ResponseSchema)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
 	profileInfo := req.Profile
 	if profileInfo == "" {
 		profileInfo = "default"
@@ -125,6 +179,11 @@ func HandleChat(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	metadata := requestMetadata(r)
+	if req.WorkflowRunID != "" {
+		metadata["workflow_run_id"] = req.WorkflowRunID
+	}
+
 	// 处理 workflow_run_id：自动管理会话
 	sessionID := req.SessionID
 	newSession := bool(req.NewSession) // 转换 FlexBool 为 bool
@@ -132,6 +191,17 @@ func HandleChat(w http.ResponseWriter, r *http.Request) {
 	var cliDuration time.Duration
 	handledByWorkflow := false
 
+	// 携带 response_schema 时通过 runStructuredCLI 执行（含校验与修复重试）
+	var structured *structuredResult
+	run := func(cliReq cliRequest) (string, error) {
+		if schema == nil {
+			return runCLI(cliReq)
+		}
+		output, res, err := runStructuredCLI(cliReq, req.ResponseSchema, schema)
+		structured = res
+		return output, err
+	}
+
 	if req.WorkflowRunID != "" {
 		log.Printf("🔗 Workflow Run ID: %s", req.WorkflowRunID)
 
@@ -143,7 +213,19 @@ func HandleChat(w http.ResponseWriter, r *http.Request) {
 				log.Printf("🆕 New workflow run, will create new session")
 				log.Println("🚀 Calling CLI...")
 				cliStart := time.Now()
-				output, err := runCLI(req.CLI, prompt, req.System, req.Profile, sessionID, true, []string(req.AllowedTools), req.PermissionMode)
+				output, err := run(cliRequest{
+					Context:        ctx,
+					CLI:            req.CLI,
+					Prompt:         prompt,
+					System:         req.System,
+					Profile:        req.Profile,
+					SessionID:      sessionID,
+					NewSession:     true,
+					AllowedTools:   []string(req.AllowedTools),
+					PermissionMode: req.PermissionMode,
+					CaptureSteps:   req.IncludeSteps,
+					Metadata:       metadata,
+				})
 				cliDuration = time.Since(cliStart)
 				if err != nil {
 					return workflow_session.CreateResult{}, err
@@ -159,7 +241,7 @@ func HandleChat(w http.ResponseWriter, r *http.Request) {
 			})
 			if err != nil {
 				log.Printf("❌ Workflow session resolve failed: %v", err)
-				w.WriteHeader(http.StatusInternalServerError)
+				w.WriteHeader(cliErrorStatus(err))
 				json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
 				return
 			}
@@ -181,14 +263,25 @@ func HandleChat(w http.ResponseWriter, r *http.Request) {
 		// 调用 runCLI 函数执行 CLI（传入 cli、prompt、system、profile、session_id、new_session、allowed_tools 和 permission_mode）
 		log.Println("🚀 Calling CLI...")
 		cliStart := time.Now()
-		var err error
-		result, err = runCLI(req.CLI, prompt, req.System, req.Profile, sessionID, newSession, []string(req.AllowedTools), req.PermissionMode)
+		result, err = run(cliRequest{
+			Context:        r.Context(),
+			CLI:            req.CLI,
+			Prompt:         prompt,
+			System:         req.System,
+			Profile:        req.Profile,
+			SessionID:      sessionID,
+			NewSession:     newSession,
+			AllowedTools:   []string(req.AllowedTools),
+			PermissionMode: req.PermissionMode,
+			CaptureSteps:   req.IncludeSteps,
+			Metadata:       metadata,
+		})
 		cliDuration = time.Since(cliStart)
 
 		if err != nil {
 			// 如果 runCLI 返回错误，返回 500 错误响应
 			log.Printf("❌ CLI failed after %v: %v", cliDuration, err)
-			w.WriteHeader(http.StatusInternalServerError)
+			w.WriteHeader(cliErrorStatus(err))
 			json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
 			return
 		}
@@ -198,12 +291,40 @@ func HandleChat(w http.ResponseWriter, r *http.Request) {
 
 	// 如果成功，构建 InvokeResponse 并返回 200 响应
 	// 设置响应头 Content-Type 为 application/json
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(InvokeResponse{Answer: result})
+	setCacheHeader(w, metadata)
+	setResourceHeaders(w, metadata)
+	if structured != nil {
+		writeStructuredResponse(w, result, structured)
+	} else {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(InvokeResponse{Answer: result})
+	}
 
 	totalDuration := time.Since(startTime)
 	log.Printf("📤 Response sent successfully")
 	log.Printf("⏱️  Total request time: %v (parse: %v, CLI: %v)",
 		totalDuration, parseDuration, cliDuration)
 }
+
+// requestMetadata 提取传递给网关中间件的请求元数据
+func requestMetadata(r *http.Request) map[string]string {
+	metadata := map[string]string{
+		"endpoint":    r.URL.Path,
+		"remote_addr": r.RemoteAddr,
+	}
+	if requestID := r.Header.Get("X-Request-ID"); requestID != "" {
+		metadata["request_id"] = requestID
+	}
+	if userAgent := r.UserAgent(); userAgent != "" {
+		metadata["user_agent"] = userAgent
+	}
+	if cacheControl := r.Header.Get("Cache-Control"); cacheControl != "" {
+		metadata["cache_control"] = cacheControl
+	}
+	if owner := requestOwner(r); owner != "" {
+		metadata["api_key_id"] = owner
+	}
+	admissionMetadata(r, metadata)
+	return metadata
+}
@@ -0,0 +1,236 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"dify-cli-gateway/internal/session_registry"
+	"dify-cli-gateway/internal/transcript"
+)
+
+// HandoffRequest 表示 POST /sessions/{id}/handoff 请求
+type HandoffRequest struct {
+	Profile       string `json:"profile,omitempty"`         // 目标 profile
+	CLI           string `json:"cli,omitempty"`             // 目标 CLI（可与 profile 同时指定）
+	Mode          string `json:"mode,omitempty"`            // transcript（默认）| summary
+	Message       string `json:"message,omitempty"`         // 可选：在新会话中紧接着发送的用户消息
+	WorkflowRunID string `json:"workflow_run_id,omitempty"` // 可选：需要改绑的 workflow_run_id，默认取源会话登记的值
+	CloseSource   bool   `json:"close_source,omitempty"`    // 转交后关闭源会话
+}
+
+// HandoffResponse 表示转交结果
+type HandoffResponse struct {
+	SessionID       string `json:"session_id"`
+	ParentSessionID string `json:"parent_session_id"`
+	CLI             string `json:"cli"`
+	Profile         string `json:"profile,omitempty"`
+	Mode            string `json:"mode"`
+	Response        string `json:"response"`
+	Summary         string `json:"summary,omitempty"`
+	OmittedMessages int    `json:"omitted_messages,omitempty"` // 超出长度上限被丢弃的早期消息数
+	WorkflowRunID   string `json:"workflow_run_id,omitempty"`
+	WorkflowRebound bool   `json:"workflow_rebound,omitempty"`
+	SourceClosed    bool   `json:"source_closed,omitempty"`
+	DurationMS      int64  `json:"duration_ms"`
+}
+
+const (
+	handoffModeTranscript = "transcript"
+	handoffModeSummary    = "summary"
+)
+
+// handleSessionHandoff 将会话转交给另一个 profile/CLI：读取源会话记录（或压缩摘要）在目标上开启新会话
+func handleSessionHandoff(w http.ResponseWriter, r *http.Request, source *session_registry.Session) {
+	startTime := time.Now()
+
+	var req HandoffRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid JSON request body"})
+		return
+	}
+	if req.Profile == "" && req.CLI == "" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "profile or cli is required"})
+		return
+	}
+	if req.Profile != "" {
+		if _, err := GetProfile(req.Profile); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+	}
+	mode := strings.ToLower(req.Mode)
+	if mode == "" {
+		mode = handoffModeTranscript
+	}
+	if mode != handoffModeTranscript && mode != handoffModeSummary {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "mode must be transcript or summary"})
+		return
+	}
+	if source.HandedOffTo != "" {
+		writeJSON(w, http.StatusConflict, map[string]string{"error": fmt.Sprintf("session already handed off to %s", source.HandedOffTo)})
+		return
+	}
+
+	t, err := transcript.Load(source.CLI, source.ID, transcriptRoots(source))
+	if err != nil {
+		status := http.StatusInternalServerError
+		switch {
+		case errors.Is(err, transcript.ErrNotFound):
+			status = http.StatusNotFound
+		case errors.Is(err, transcript.ErrUnsupported):
+			status = http.StatusNotImplemented
+		}
+		writeJSON(w, status, map[string]string{"error": "source transcript unavailable: " + err.Error()})
+		return
+	}
+	if len(t.Messages) == 0 {
+		writeJSON(w, http.StatusUnprocessableEntity, map[string]string{"error": "source session has no messages"})
+		return
+	}
+	history, omitted := transcript.Compact(t, GetSessionRegistryConfig().HandoffMaxChars)
+	if shouldGuardPrompt(req.Message) {
+		writeGuardedResponse(w, req.Message)
+		return
+	}
+
+	workflowRunID := req.WorkflowRunID
+	if workflowRunID == "" {
+		workflowRunID = source.WorkflowRun
+	}
+	result := HandoffResponse{
+		ParentSessionID: source.ID,
+		Profile:         req.Profile,
+		CLI:             resolveTargetCLI(req.CLI, req.Profile),
+		Mode:            mode,
+		OmittedMessages: omitted,
+		WorkflowRunID:   workflowRunID,
+	}
+	log.Printf("🔀 [Sessions] Handoff %s (%s) → %s (mode=%s, messages=%d, omitted=%d)", source.ID, source.CLI, result.CLI, mode, len(t.Messages), omitted)
+
+	carried := "<previous_conversation>\n" + history + "</previous_conversation>"
+	if mode == handoffModeSummary {
+		// 先在目标上做一次无状态摘要，再用摘要开启新会话
+		metadata := requestMetadata(r)
+		metadata["session_ephemeral"] = "true"
+		metadata["handoff_stage"] = "summarize"
+		output, err := runCLI(cliRequest{
+			Context:    r.Context(),
+			CLI:        req.CLI,
+			Prompt:     buildHandoffSummaryPrompt(source.CLI, carried),
+			Profile:    req.Profile,
+			NewSession: true,
+			Metadata:   metadata,
+		})
+		if err != nil {
+			log.Printf("❌ [Sessions] Handoff summary failed: %v", err)
+			writeJSON(w, cliErrorStatus(err), map[string]string{"error": err.Error()})
+			return
+		}
+		result.Summary = strings.TrimSpace(cliResponseText(output))
+		carried = "<handoff_summary>\n" + result.Summary + "\n</handoff_summary>"
+	}
+
+	metadata := requestMetadata(r)
+	metadata["parent_session_id"] = source.ID
+	metadata["handoff_stage"] = "seed"
+	if workflowRunID != "" {
+		metadata["workflow_run_id"] = workflowRunID
+	}
+	output, err := runCLI(cliRequest{
+		Context:    r.Context(),
+		CLI:        req.CLI,
+		Prompt:     buildHandoffSeedPrompt(source.CLI, carried, req.Message),
+		Profile:    req.Profile,
+		NewSession: true,
+		Metadata:   metadata,
+	})
+	if err != nil {
+		log.Printf("❌ [Sessions] Handoff seed failed: %v", err)
+		writeJSON(w, cliErrorStatus(err), map[string]string{"error": err.Error()})
+		return
+	}
+	var cliOut CLIOutput
+	if err := json.Unmarshal([]byte(output), &cliOut); err != nil || cliOut.SessionID == "" {
+		writeJSON(w, http.StatusBadGateway, map[string]string{"error": "target CLI did not return a session id"})
+		return
+	}
+	result.SessionID = cliOut.SessionID
+	result.Response = cliResponseText(output)
+
+	if registry := getSessionRegistry(); registry != nil {
+		if err := registry.Link(result.SessionID, source.ID); err != nil {
+			log.Printf("⚠️  [Sessions] Failed to link %s → %s: %v", source.ID, result.SessionID, err)
+		}
+		if req.CloseSource {
+			if _, err := registry.Close(source.ID); err != nil {
+				log.Printf("⚠️  [Sessions] Failed to close source session %s: %v", source.ID, err)
+			} else {
+				result.SourceClosed = true
+			}
+		}
+	}
+
+	if workflowRunID != "" {
+		if manager := getWorkflowSessionManager(); manager != nil {
+			rebound, err := manager.Rebind(r.Context(), workflowRunID, source.ID, result.SessionID)
+			if err != nil {
+				log.Printf("⚠️  [Sessions] Failed to rebind workflow_run_id=%s: %v", workflowRunID, err)
+			} else if rebound {
+				result.WorkflowRebound = true
+				log.Printf("💾 Rebound mapping: workflow_run_id=%s → session_id=%s", workflowRunID, result.SessionID)
+			}
+		}
+	}
+
+	result.DurationMS = time.Since(startTime).Milliseconds()
+	writeJSON(w, http.StatusOK, result)
+	log.Printf("📤 Response sent successfully (handoff %s → %s, took %v)", source.ID, result.SessionID, time.Since(startTime))
+}
+
+// resolveTargetCLI 与 runCLI 相同的 CLI 选择顺序：请求 > profile > claude
+func resolveTargetCLI(cliName, profileKey string) string {
+	if cliName != "" {
+		return cliName
+	}
+	if profile, err := GetProfile(profileKey); err == nil && profile.CLI != "" {
+		return profile.CLI
+	}
+	return "claude"
+}
+
+// cliResponseText 提取 CLI 输出中的回答文本
+func cliResponseText(output string) string {
+	var cliOut CLIOutput
+	if err := json.Unmarshal([]byte(output), &cliOut); err != nil {
+		return output
+	}
+	if cliOut.Response != "" {
+		return cliOut.Response
+	}
+	return cliOut.Codex
+}
+
+func buildHandoffSummaryPrompt(sourceCLI, carried string) string {
+	return fmt.Sprintf(`The following is a conversation from another coding assistant session (%s). Write a concise handoff summary for the assistant who will continue it. Cover: the user's goal, decisions made, work completed (files, commands, results), the current state, and open tasks or questions. Output only the summary.
+
+%s`, sourceCLI, carried)
+}
+
+func buildHandoffSeedPrompt(sourceCLI, carried, message string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "You are taking over a conversation that was started in another assistant session (%s). ", sourceCLI)
+	b.WriteString("Its context is below. Continue from where it left off and do not redo completed work.\n\n")
+	b.WriteString(carried)
+	b.WriteString("\n\n")
+	if strings.TrimSpace(message) != "" {
+		b.WriteString(message)
+	} else {
+		b.WriteString("Briefly confirm the current state and the next step.")
+	}
+	return b.String()
+}
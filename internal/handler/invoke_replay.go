@@ -0,0 +1,173 @@
+package handler
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"dify-cli-gateway/internal/admission"
+	"dify-cli-gateway/internal/session_registry"
+	"dify-cli-gateway/internal/workflow_session"
+)
+
+// /invoke 多轮回放：每轮成功后以「完整历史 + 本轮回答」的哈希记录 CLI 会话，
+// 下一轮请求的历史前缀命中时直接 --resume 该会话，只发送最新一条用户消息。
+// 恢复会推进 CLI 会话，映射在命中时即被消费：调用方从同一前缀重新生成或重试时，
+// 不会恢复到已经包含被丢弃回答的会话，而是展开完整历史重新开始。
+// CLI 会话保存在本机，因此映射只使用进程内存储（不走 Redis）。
+
+var (
+	invokeReplayMu    sync.Mutex
+	invokeReplayStore *workflow_session.MemoryMappingStore
+	invokeReplayPrune time.Time
+)
+
+const invokeReplayPruneInterval = time.Minute
+
+func getInvokeReplayStore() *workflow_session.MemoryMappingStore {
+	invokeReplayMu.Lock()
+	defer invokeReplayMu.Unlock()
+	if invokeReplayStore == nil {
+		invokeReplayStore = workflow_session.NewMemoryMappingStore()
+	}
+	if time.Since(invokeReplayPrune) > invokeReplayPruneInterval {
+		invokeReplayPrune = time.Now()
+		invokeReplayStore.PruneExpired()
+	}
+	return invokeReplayStore
+}
+
+// invokeReplay 描述一次 /invoke 调用的回放状态
+type invokeReplay struct {
+	owner    string
+	profile  string
+	cli      string
+	system   string
+	messages []Message
+	history  []Message // 最后一条用户消息之前的历史
+	latest   Message   // 最后一条用户消息
+}
+
+// newInvokeReplay 仅在最后一条消息为用户消息时启用回放（owner 区分调用方，避免恢复他人的会话）
+func newInvokeReplay(req InvokeRequest, owner string) *invokeReplay {
+	if !*GetInvokeReplayConfig().Enabled || len(req.Messages) == 0 {
+		return nil
+	}
+	latest := req.Messages[len(req.Messages)-1]
+	if latest.Role != "user" {
+		return nil
+	}
+	return &invokeReplay{
+		owner:    owner,
+		profile:  resolveProfileKey(req.Profile),
+		cli:      req.CLI,
+		system:   req.System,
+		messages: req.Messages,
+		history:  req.Messages[:len(req.Messages)-1],
+		latest:   latest,
+	}
+}
+
+// lookup 返回并消费历史前缀对应的 CLI 会话
+func (r *invokeReplay) lookup() (string, bool) {
+	if r == nil || len(r.history) == 0 {
+		return "", false
+	}
+	sessionID, found, err := getInvokeReplayStore().Take(context.Background(), r.key(r.history))
+	if err != nil || !found {
+		return "", false
+	}
+	return sessionID, true
+}
+
+// remember 记录本轮结束后的会话，供下一轮恢复
+// 缓存命中或合并的结果属于其他调用方的会话，不记录
+func (r *invokeReplay) remember(answer string, metadata map[string]string) {
+	if r == nil || metadata["cache"] == "hit" || metadata["coalesced"] == "true" {
+		return
+	}
+	var cliOut CLIOutput
+	if err := json.Unmarshal([]byte(answer), &cliOut); err != nil || cliOut.SessionID == "" {
+		return
+	}
+
+	ttl := time.Duration(GetInvokeReplayConfig().TTLMinutes) * time.Minute
+	next := append(append([]Message{}, r.messages...), Message{Role: "assistant", Content: answer})
+	if err := getInvokeReplayStore().Set(context.Background(), r.key(next), cliOut.SessionID, ttl); err != nil {
+		log.Printf("⚠️  [InvokeReplay] Failed to record session: %v", err)
+		return
+	}
+	log.Printf("💾 [InvokeReplay] Recorded turn %d → session_id=%s", len(r.messages), cliOut.SessionID)
+}
+
+// key 计算历史前缀哈希（包含调用方、profile、CLI 与系统提示词）
+func (r *invokeReplay) key(messages []Message) string {
+	h := sha256.New()
+	writeField := func(value string) {
+		h.Write([]byte(value))
+		h.Write([]byte{0})
+	}
+	writeField(r.owner)
+	writeField(r.profile)
+	writeField(r.cli)
+	writeField(strings.TrimSpace(r.system))
+	for _, msg := range messages {
+		if msg.Role == "user" {
+			writeField("user")
+			writeField(strings.TrimSpace(msg.Content))
+			continue
+		}
+		writeField("assistant")
+		writeField(strings.TrimSpace(replayAssistantText(msg.Content)))
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// replayAssistantText 调用方回传的 assistant 内容可能是原始 answer，也可能是解析后的回复文本，统一为回复文本
+func replayAssistantText(content string) string {
+	var cliOut CLIOutput
+	if err := json.Unmarshal([]byte(content), &cliOut); err == nil {
+		if cliOut.Response != "" {
+			return cliOut.Response
+		}
+		if cliOut.Codex != "" {
+			return cliOut.Codex
+		}
+	}
+	return content
+}
+
+// shouldReplayFullHistory 恢复失败时是否展开完整历史重试：只针对会话已不存在的错误，
+// 鉴权、准入（排队已满 / 超时）与客户端取消等错误直接返回，避免在网关繁忙时加倍负载
+func shouldReplayFullHistory(err error) bool {
+	switch {
+	case err == nil,
+		errors.Is(err, admission.ErrQueueFull), errors.Is(err, admission.ErrWaitTimeout),
+		errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded),
+		errors.Is(err, session_registry.ErrOwnerMismatch), errors.Is(err, session_registry.ErrCLIMismatch):
+		return false
+	case errors.Is(err, session_registry.ErrNotFound), errors.Is(err, session_registry.ErrInactive):
+		return true
+	}
+	message := strings.ToLower(err.Error())
+	if strings.Contains(message, "no conversation found") {
+		return true
+	}
+	for _, subject := range []string{"session", "conversation", "thread", "chat"} {
+		if !strings.Contains(message, subject) {
+			continue
+		}
+		for _, missing := range []string{"not found", "does not exist", "no such", "not exist"} {
+			if strings.Contains(message, missing) {
+				return true
+			}
+		}
+	}
+	return false
+}
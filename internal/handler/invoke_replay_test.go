@@ -0,0 +1,95 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"dify-cli-gateway/internal/admission"
+	"dify-cli-gateway/internal/session_registry"
+)
+
+func TestInvokeReplay_ResumesMatchingHistory(t *testing.T) {
+	invokeReplayMu.Lock()
+	invokeReplayStore = nil
+	invokeReplayMu.Unlock()
+
+	turn1 := InvokeRequest{System: "be brief", Messages: []Message{{Role: "user", Content: "hi"}}}
+	replay := newInvokeReplay(turn1, "")
+	if _, ok := replay.lookup(); ok {
+		t.Fatal("first turn should not resume a session")
+	}
+	answer, _ := json.Marshal(CLIOutput{SessionID: "sess-1", User: "hi", Response: "hello!"})
+
+	// 调用方回传原始 answer 或解析后的回复文本都应命中
+	for _, assistant := range []string{string(answer), "hello!"} {
+		replay.remember(string(answer), map[string]string{"cache": "miss"})
+		turn2 := InvokeRequest{System: "be brief", Messages: []Message{
+			{Role: "user", Content: "hi"},
+			{Role: "assistant", Content: assistant},
+			{Role: "user", Content: "how are you?"},
+		}}
+		replay2 := newInvokeReplay(turn2, "")
+		sessionID, ok := replay2.lookup()
+		if !ok || sessionID != "sess-1" {
+			t.Errorf("assistant=%q: expected resume of sess-1, got %q (ok=%v)", assistant, sessionID, ok)
+		}
+		if replay2.latest.Content != "how are you?" {
+			t.Errorf("unexpected latest message %q", replay2.latest.Content)
+		}
+		// 映射在恢复时被消费：从同一前缀重新生成时不能再恢复已推进的会话
+		if _, ok := newInvokeReplay(turn2, "").lookup(); ok {
+			t.Errorf("assistant=%q: mapping should be consumed by the first resume", assistant)
+		}
+	}
+
+	// 系统提示词不同或历史被改写时不命中
+	changed := InvokeRequest{System: "be verbose", Messages: []Message{
+		{Role: "user", Content: "hi"},
+		{Role: "assistant", Content: "hello!"},
+		{Role: "user", Content: "how are you?"},
+	}}
+	if _, ok := newInvokeReplay(changed, "").lookup(); ok {
+		t.Error("different system prompt should not resume")
+	}
+}
+
+func TestInvokeReplay_SkipsCachedAnswers(t *testing.T) {
+	invokeReplayMu.Lock()
+	invokeReplayStore = nil
+	invokeReplayMu.Unlock()
+
+	turn1 := InvokeRequest{Messages: []Message{{Role: "user", Content: "faq"}}}
+	answer, _ := json.Marshal(CLIOutput{SessionID: "shared", Response: "answer"})
+	newInvokeReplay(turn1, "").remember(string(answer), map[string]string{"cache": "hit"})
+
+	turn2 := InvokeRequest{Messages: []Message{
+		{Role: "user", Content: "faq"},
+		{Role: "assistant", Content: "answer"},
+		{Role: "user", Content: "more"},
+	}}
+	if _, ok := newInvokeReplay(turn2, "").lookup(); ok {
+		t.Error("sessions from cache hits must not be resumed")
+	}
+
+	if newInvokeReplay(InvokeRequest{Messages: []Message{{Role: "assistant", Content: "x"}}}, "") != nil {
+		t.Error("replay requires the last message to be from the user")
+	}
+}
+
+func TestShouldReplayFullHistory(t *testing.T) {
+	cases := map[error]bool{
+		fmt.Errorf("claude CLI failed: No conversation found with session ID: abc"): true,
+		fmt.Errorf("codex failed: thread 123 not found"):                            true,
+		fmt.Errorf("resume: %w", session_registry.ErrNotFound):                      true,
+		fmt.Errorf("resume: %w", session_registry.ErrOwnerMismatch):                 false,
+		fmt.Errorf("admission: %w", admission.ErrQueueFull):                         false,
+		fmt.Errorf("admission: %w", admission.ErrWaitTimeout):                       false,
+		fmt.Errorf("claude CLI failed: exit status 1: rate limit exceeded"):         false,
+	}
+	for err, want := range cases {
+		if got := shouldReplayFullHistory(err); got != want {
+			t.Errorf("%v: got %v, want %v", err, got, want)
+		}
+	}
+}
@@ -0,0 +1,165 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"dify-cli-gateway/internal/mcp_server"
+)
+
+const mcpGatewayName = "cli-gateway"
+
+var invalidToolNameChars = regexp.MustCompile(`[^a-zA-Z0-9_-]`)
+
+var askProfileInputSchema = json.RawMessage(`{
+	"type": "object",
+	"properties": {
+		"prompt": {"type": "string", "description": "Task or question for the agent"},
+		"system": {"type": "string", "description": "Optional extra system prompt"},
+		"session_id": {"type": "string", "description": "Continue a previous session returned by this tool"},
+		"new_session": {"type": "boolean", "description": "Start a new session"}
+	},
+	"required": ["prompt"]
+}`)
+
+// askProfileArgs ask_<profile> 工具参数
+type askProfileArgs struct {
+	Prompt     string `json:"prompt"`
+	System     string `json:"system"`
+	SessionID  string `json:"session_id"`
+	NewSession bool   `json:"new_session"`
+}
+
+// newGatewayMCPServer 创建发布 profile 的 MCP server；metadata 提供调用方身份（API Key、租户等）
+func newGatewayMCPServer(metadata func() map[string]string) *mcp_server.Server {
+	return mcp_server.NewDynamicServer(mcpGatewayName, "1.0.0", func() []mcp_server.Tool {
+		return gatewayMCPTools(metadata)
+	})
+}
+
+// gatewayMCPTools 按当前配置为每个发布的 profile 生成一个工具
+func gatewayMCPTools(metadata func() map[string]string) []mcp_server.Tool {
+	cfg := getGlobalConfig()
+	if cfg == nil {
+		return nil
+	}
+	mcpCfg := GetMCPGatewayConfig()
+
+	keys := mcpCfg.Profiles
+	if len(keys) == 0 {
+		for key := range cfg.Profiles {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	tools := make([]mcp_server.Tool, 0, len(keys))
+	for _, key := range keys {
+		profile, ok := cfg.Profiles[key]
+		if !ok {
+			log.Printf("⚠️  [MCP] mcp_server.profiles references unknown profile %q", key)
+			continue
+		}
+		tools = append(tools, askProfileTool(mcpCfg.ToolPrefix, key, profile, metadata))
+	}
+	return tools
+}
+
+func askProfileTool(prefix, key string, profile ProfileConfig, metadata func() map[string]string) mcp_server.Tool {
+	name := prefix + invalidToolNameChars.ReplaceAllString(key, "_")
+	if len(name) > 64 {
+		name = name[:64]
+	}
+	cliName := profile.CLI
+	if cliName == "" {
+		cliName = "claude"
+	}
+	description := fmt.Sprintf("Delegate a task to the %q agent (%s", key, cliName)
+	if profile.Model != "" {
+		description += ", model " + profile.Model
+	}
+	description += ")."
+	if profile.Name != "" && profile.Name != key {
+		description = profile.Name + ": " + description
+	}
+	description += " Returns the answer and a session_id that can be passed back to continue the conversation."
+
+	return mcp_server.Tool{
+		Name:        name,
+		Description: description,
+		InputSchema: askProfileInputSchema,
+		Handler: func(ctx context.Context, raw json.RawMessage) (mcp_server.ToolResult, error) {
+			var args askProfileArgs
+			if err := json.Unmarshal(raw, &args); err != nil || strings.TrimSpace(args.Prompt) == "" {
+				return mcp_server.ToolResult{}, fmt.Errorf("prompt is required")
+			}
+			if shouldGuardPrompt(args.Prompt) {
+				log.Printf("🛑 [MCP] Guarded prompt detected for %s", name)
+				return mcp_server.ToolResult{Text: guardedResponseText}, nil
+			}
+
+			md := metadata()
+			md["mcp_tool"] = name
+			log.Printf("🧰 [MCP] %s called (profile=%s, session=%s)", name, key, args.SessionID)
+			output, err := runCLI(cliRequest{
+				Context:    ctx,
+				Prompt:     args.Prompt,
+				System:     args.System,
+				Profile:    key,
+				SessionID:  args.SessionID,
+				NewSession: args.NewSession,
+				Metadata:   md,
+			})
+			if err != nil {
+				return mcp_server.ToolResult{}, err
+			}
+
+			var cliOut CLIOutput
+			if err := json.Unmarshal([]byte(output), &cliOut); err != nil {
+				return mcp_server.ToolResult{Text: output}, nil
+			}
+			text := cliOut.Response
+			if cliOut.SessionID != "" {
+				text += fmt.Sprintf("\n\n(session_id: %s)", cliOut.SessionID)
+			}
+			return mcp_server.ToolResult{
+				Text: text,
+				Structured: map[string]string{
+					"response":   cliOut.Response,
+					"session_id": cliOut.SessionID,
+					"profile":    key,
+					"cli":        cliName,
+				},
+			}, nil
+		},
+	}
+}
+
+// HandleMCP 处理网关 MCP server 的 Streamable HTTP 端点（与 /chat 相同的 API Key、准入与会话归属校验）
+func HandleMCP(w http.ResponseWriter, r *http.Request) {
+	log.Printf("📥 Received request: %s %s from %s", r.Method, r.URL.Path, r.RemoteAddr)
+	newGatewayMCPServer(func() map[string]string { return requestMetadata(r) }).ServeHTTP(w, r)
+}
+
+// ServeMCPStdio 以 stdio 运行网关 MCP server；调用方身份取自 CLI_GATEWAY_API_KEY 环境变量
+func ServeMCPStdio(ctx context.Context, in io.Reader, out io.Writer) error {
+	apiKey := os.Getenv("CLI_GATEWAY_API_KEY")
+	metadata := func() map[string]string {
+		r, _ := http.NewRequest(http.MethodPost, "/mcp/stdio", nil)
+		r.RemoteAddr = "stdio"
+		if apiKey != "" {
+			r.Header.Set("X-API-Key", apiKey)
+		}
+		return requestMetadata(r)
+	}
+	log.Printf("🧰 [MCP] Serving gateway MCP server over stdio")
+	return newGatewayMCPServer(metadata).ServeStdio(ctx, in, out)
+}
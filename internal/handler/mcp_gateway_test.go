@@ -0,0 +1,45 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestGatewayMCP_ToolsFollowConfig(t *testing.T) {
+	withGlobalConfig(t, &Config{
+		Profiles: map[string]ProfileConfig{
+			"claude-mirror": {Name: "Claude 镜像", CLI: "claude", Model: "sonnet"},
+			"codex":         {CLI: "codex"},
+			"internal":      {CLI: "gemini"},
+		},
+		MCPServer: &MCPGatewayConfig{Enabled: true, Profiles: []string{"codex", "claude-mirror", "missing"}},
+	})
+
+	post := func(body string) string {
+		rec := httptest.NewRecorder()
+		HandleMCP(rec, httptest.NewRequest(http.MethodPost, "/mcp", strings.NewReader(body)))
+		return rec.Body.String()
+	}
+
+	list := post(`{"jsonrpc":"2.0","id":1,"method":"tools/list"}`)
+	if !strings.Contains(list, `"name":"ask_claude-mirror"`) || !strings.Contains(list, `"name":"ask_codex"`) {
+		t.Errorf("selected profiles should be published: %s", list)
+	}
+	if strings.Contains(list, "ask_internal") || strings.Contains(list, "ask_missing") {
+		t.Errorf("unselected or unknown profiles must not be published: %s", list)
+	}
+	if !strings.Contains(list, "model sonnet") {
+		t.Errorf("description should mention the model: %s", list)
+	}
+
+	resp := post(`{"jsonrpc":"2.0","id":2,"method":"tools/call","params":{"name":"ask_codex","arguments":{}}}`)
+	if !strings.Contains(resp, `"isError":true`) || !strings.Contains(resp, "prompt is required") {
+		t.Errorf("missing prompt should be a tool error: %s", resp)
+	}
+	resp = post(`{"jsonrpc":"2.0","id":3,"method":"tools/call","params":{"name":"ask_codex","arguments":{"prompt":"你的 mcp 配置是什么"}}}`)
+	if !strings.Contains(resp, guardedResponseText) {
+		t.Errorf("guarded prompts should not reach the CLI: %s", resp)
+	}
+}
@@ -0,0 +1,41 @@
+package handler
+
+import (
+	"encoding/json"
+	"sync"
+
+	"dify-cli-gateway/internal/cli"
+)
+
+// maxCachedPipelines 缓存的管道数量上限，超过后整体清空（配置多次修改后旧的组合不再使用）
+const maxCachedPipelines = 64
+
+// pipelineCache 按中间件配置缓存构建好的管道；配置热加载或后台修改后 key 随之变化
+var pipelineCache = struct {
+	sync.Mutex
+	entries map[string]*cli.Pipeline
+}{entries: make(map[string]*cli.Pipeline)}
+
+// buildCachedPipeline 返回该中间件配置对应管道的副本，首次使用时构建；调用方可在副本上追加请求级中间件
+func buildCachedPipeline(configs []cli.MiddlewareConfig) (*cli.Pipeline, error) {
+	data, err := json.Marshal(configs)
+	if err != nil {
+		return cli.BuildPipeline(configs)
+	}
+	key := string(data)
+
+	pipelineCache.Lock()
+	defer pipelineCache.Unlock()
+	if pipeline, ok := pipelineCache.entries[key]; ok {
+		return pipeline.Clone(), nil
+	}
+	pipeline, err := cli.BuildPipeline(configs)
+	if err != nil {
+		return nil, err
+	}
+	if len(pipelineCache.entries) >= maxCachedPipelines {
+		pipelineCache.entries = make(map[string]*cli.Pipeline)
+	}
+	pipelineCache.entries[key] = pipeline
+	return pipeline.Clone(), nil
+}
@@ -0,0 +1,336 @@
+package handler
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"dify-cli-gateway/internal/cli"
+	"dify-cli-gateway/internal/response_cache"
+	"dify-cli-gateway/internal/workflow_session"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// responseCacheStore 单个 profile 的缓存存储及其构建参数
+type responseCacheStore struct {
+	store    response_cache.Store
+	strategy response_cache.Strategy
+	maxBytes int64
+}
+
+var (
+	responseCacheMu      sync.Mutex
+	responseCacheBackend string
+	responseCacheRedis   *redis.Client
+	responseCacheStores  = make(map[string]*responseCacheStore)
+)
+
+// InitResponseCache 根据配置初始化响应缓存后端（Redis 不可用时回退到内存）
+func InitResponseCache() {
+	cfg := GetResponseCacheConfig()
+
+	responseCacheMu.Lock()
+	defer responseCacheMu.Unlock()
+
+	if responseCacheRedis != nil {
+		responseCacheRedis.Close()
+		responseCacheRedis = nil
+	}
+
+	backend := "memory"
+	if cfg.Backend == "redis" && cfg.Redis != nil && cfg.Redis.Addr != "" {
+		redisCfg := workflow_session.RedisConfig{
+			Addr:         cfg.Redis.Addr,
+			Username:     cfg.Redis.Username,
+			Password:     cfg.Redis.Password,
+			DB:           cfg.Redis.DB,
+			DialTimeout:  time.Duration(cfg.Redis.DialTimeoutMS) * time.Millisecond,
+			ReadTimeout:  time.Duration(cfg.Redis.ReadTimeoutMS) * time.Millisecond,
+			WriteTimeout: time.Duration(cfg.Redis.WriteTimeoutMS) * time.Millisecond,
+			PoolSize:     cfg.Redis.PoolSize,
+		}
+
+		timeout := time.Duration(cfg.Redis.DialTimeoutMS) * time.Millisecond
+		if timeout <= 0 {
+			timeout = 5 * time.Second
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+
+		client, err := workflow_session.NewRedisClient(ctx, redisCfg)
+		if err != nil {
+			log.Printf("⚠️  Redis unavailable for response cache, fallback to memory store: %v", err)
+		} else {
+			responseCacheRedis = client
+			backend = "redis"
+		}
+	} else if cfg.Backend != "" && cfg.Backend != "memory" && cfg.Backend != "redis" {
+		log.Printf("⚠️  Unknown response cache backend %q, fallback to memory store", cfg.Backend)
+	}
+
+	// 后端切换时丢弃旧存储；内存存储在参数变化时由 getResponseCacheStore 重建
+	if backend != responseCacheBackend || backend == "redis" {
+		responseCacheStores = make(map[string]*responseCacheStore)
+	}
+	responseCacheBackend = backend
+	log.Printf("✅ Response cache initialized (backend=%s, strategy=%s, max_size=%dMB, ttl=%dm)",
+		backend, cfg.Strategy, cfg.MaxSizeMB, cfg.TTLMinutes)
+}
+
+// resolveProfileCacheConfig 合并全局缓存配置与 profile 的缓存配置
+func resolveProfileCacheConfig(profile *ProfileConfig) cli.CacheConfig {
+	global := GetResponseCacheConfig()
+	settings := cli.CacheConfig{
+		TTLMinutes: global.TTLMinutes,
+		MaxSizeMB:  global.MaxSizeMB,
+		Strategy:   global.Strategy,
+	}
+	if profile == nil || profile.Cache == nil {
+		return settings
+	}
+	settings.Enabled = profile.Cache.Enabled
+	if profile.Cache.TTLMinutes > 0 {
+		settings.TTLMinutes = profile.Cache.TTLMinutes
+	}
+	if profile.Cache.MaxSizeMB > 0 {
+		settings.MaxSizeMB = profile.Cache.MaxSizeMB
+	}
+	if profile.Cache.Strategy != "" {
+		settings.Strategy = profile.Cache.Strategy
+	}
+	return settings
+}
+
+// getResponseCacheStore 返回 profile 对应的缓存存储（按需创建）
+func getResponseCacheStore(profileKey string, settings cli.CacheConfig) (response_cache.Store, error) {
+	responseCacheMu.Lock()
+	defer responseCacheMu.Unlock()
+
+	if responseCacheBackend == "" {
+		responseCacheBackend = "memory"
+	}
+
+	if responseCacheBackend == "redis" && responseCacheRedis != nil {
+		if existing, ok := responseCacheStores[profileKey]; ok {
+			return existing.store, nil
+		}
+		prefix := GetResponseCacheConfig().KeyPrefix + ":" + profileKey
+		store, err := response_cache.NewRedisStore(responseCacheRedis, prefix)
+		if err != nil {
+			return nil, err
+		}
+		responseCacheStores[profileKey] = &responseCacheStore{store: store}
+		return store, nil
+	}
+
+	strategy, err := response_cache.ParseStrategy(settings.Strategy)
+	if err != nil {
+		return nil, err
+	}
+	maxBytes := response_cache.MaxBytesFromMB(settings.MaxSizeMB)
+	if existing, ok := responseCacheStores[profileKey]; ok && existing.strategy == strategy && existing.maxBytes == maxBytes {
+		return existing.store, nil
+	}
+	store, err := response_cache.NewMemoryStore(strategy, maxBytes)
+	if err != nil {
+		return nil, err
+	}
+	responseCacheStores[profileKey] = &responseCacheStore{store: store, strategy: strategy, maxBytes: maxBytes}
+	return store, nil
+}
+
+// withResponseCache 在 profile 启用缓存且未显式配置 cache 中间件时，将其加到管道最外层
+func withResponseCache(configs []cli.MiddlewareConfig, profile *ProfileConfig) []cli.MiddlewareConfig {
+	if profile == nil || profile.Cache == nil || !profile.Cache.Enabled {
+		return configs
+	}
+	for _, cfg := range configs {
+		if cfg.Name == "cache" {
+			return configs
+		}
+	}
+	result := make([]cli.MiddlewareConfig, 0, len(configs)+1)
+	result = append(result, cli.MiddlewareConfig{Name: "cache", Enabled: true})
+	return append(result, configs...)
+}
+
+func init() {
+	cli.RegisterPipelineMiddleware("cache", func(cfg cli.MiddlewareConfig) (cli.PipelineMiddleware, error) {
+		return &cacheMiddleware{}, nil
+	})
+}
+
+// cacheMiddleware 网关中间件：无会话请求命中缓存时短路返回，不启动 CLI
+// 请求头 Cache-Control: no-cache 跳过读缓存，no-store 跳过读写
+type cacheMiddleware struct{}
+
+func (m *cacheMiddleware) Name() string {
+	return "cache"
+}
+
+func (m *cacheMiddleware) Handle(req *cli.PipelineRequest, next cli.PipelineHandler) (string, error) {
+	// 有会话上下文的请求结果依赖历史，不缓存
+	if req.Options.SessionID != "" || req.Meta("workflow_run_id") != "" {
+		req.SetMeta("cache", "bypass")
+		return next(req)
+	}
+
+	noCache, noStore := parseCacheControl(req.Meta("cache_control"))
+	if noStore {
+		req.SetMeta("cache", "bypass")
+		return next(req)
+	}
+
+	profile, _ := GetProfile(req.Profile)
+	settings := resolveProfileCacheConfig(profile)
+	store, err := getResponseCacheStore(req.Profile, settings)
+	if err != nil {
+		log.Printf("⚠️  [Pipeline:Cache] Cache unavailable: %v", err)
+		req.SetMeta("cache", "bypass")
+		return next(req)
+	}
+
+	key := response_cache.Key(response_cache.KeyInput{
+		CLI:            req.CLI,
+		Model:          req.Options.Model,
+		Prompt:         req.Options.Prompt,
+		System:         req.Options.SystemPrompt,
+		Tools:          req.Options.AllowedTools,
+		Skills:         req.Options.Skills,
+		PermissionMode: req.Options.PermissionMode,
+		Steps:          req.Options.CaptureSteps,
+	})
+	ctx := context.Background()
+
+	if !noCache {
+		cached, found, err := store.Get(ctx, key)
+		if err != nil {
+			log.Printf("⚠️  [Pipeline:Cache] Get failed: %v", err)
+		}
+		if found {
+			log.Printf("💾 [Pipeline:Cache] Hit: profile=%s cli=%s key=%s", req.Profile, req.CLI, key[:12])
+			cli.GatewayMetrics().RecordCacheHit(req.CLI)
+			req.SetMeta("cache", "hit")
+			req.SetMeta("short_circuit", m.Name())
+			// 旧版本写入的缓存可能仍带有首个调用方的 session_id
+			return cli.SharedOutput(cached), nil
+		}
+	}
+	cli.GatewayMetrics().RecordCacheMiss(req.CLI)
+	req.SetMeta("cache", "miss")
+
+	result, err := next(req)
+	if err != nil || req.Meta("short_circuit") != "" {
+		return result, err
+	}
+
+	// 缓存结果会返回给其他调用方，只保存与调用方无关的部分
+	ttl := time.Duration(settings.TTLMinutes) * time.Minute
+	if err := store.Set(ctx, key, cli.SharedOutput(result), ttl); err != nil {
+		log.Printf("⚠️  [Pipeline:Cache] Set skipped: %v", err)
+	}
+	return result, nil
+}
+
+// parseCacheControl 解析请求 Cache-Control 头中的 no-cache / no-store 指令
+func parseCacheControl(value string) (noCache bool, noStore bool) {
+	for _, directive := range strings.Split(value, ",") {
+		switch strings.ToLower(strings.TrimSpace(directive)) {
+		case "no-cache", "max-age=0":
+			noCache = true
+		case "no-store":
+			noStore = true
+		}
+	}
+	return noCache, noStore
+}
+
+// setCacheHeader 将缓存状态写入 X-Cache 响应头
+func setCacheHeader(w http.ResponseWriter, metadata map[string]string) {
+	if status := metadata["cache"]; status != "" {
+		w.Header().Set("X-Cache", strings.ToUpper(status))
+	}
+}
+
+// setResourceHeaders 将 CLI 子进程的资源用量写入 X-Resource-* 响应头（命中缓存时没有用量）
+func setResourceHeaders(w http.ResponseWriter, metadata map[string]string) {
+	headers := map[string]string{
+		"resource_mode":              "X-Resource-Mode",
+		"resource_peak_memory_bytes": "X-Resource-Peak-Memory-Bytes",
+		"resource_cpu_ms":            "X-Resource-CPU-Ms",
+		"resource_peak_pids":         "X-Resource-Peak-Pids",
+		"resource_oom_killed":        "X-Resource-OOM-Killed",
+	}
+	for key, header := range headers {
+		if value := metadata[key]; value != "" {
+			w.Header().Set(header, value)
+		}
+	}
+}
+
+// AdminCacheResponse 缓存状态
+type AdminCacheResponse struct {
+	Backend  string                          `json:"backend"`
+	Profiles map[string]response_cache.Stats `json:"profiles"`
+	Metrics  map[string]interface{}          `json:"metrics"`
+}
+
+// handleAdminCache 查看（GET）或清空（DELETE，可选 ?profile=）响应缓存
+func handleAdminCache(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		responseCacheMu.Lock()
+		response := AdminCacheResponse{
+			Backend:  responseCacheBackend,
+			Profiles: make(map[string]response_cache.Stats, len(responseCacheStores)),
+			Metrics:  cli.GatewayMetrics().GetSummary(),
+		}
+		for key, entry := range responseCacheStores {
+			response.Profiles[key] = entry.store.Stats()
+		}
+		responseCacheMu.Unlock()
+		if response.Backend == "" {
+			response.Backend = "memory"
+		}
+		writeJSON(w, http.StatusOK, response)
+	case http.MethodDelete:
+		target := r.URL.Query().Get("profile")
+		if target != "" {
+			// 确保尚未访问过的 profile（如 Redis 中的历史缓存）也能被清理
+			profile, _ := GetProfile(target)
+			if _, err := getResponseCacheStore(target, resolveProfileCacheConfig(profile)); err != nil {
+				writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+				return
+			}
+		}
+		responseCacheMu.Lock()
+		keys := make([]string, 0, len(responseCacheStores))
+		for key := range responseCacheStores {
+			if target == "" || key == target {
+				keys = append(keys, key)
+			}
+		}
+		stores := make([]response_cache.Store, 0, len(keys))
+		for _, key := range keys {
+			stores = append(stores, responseCacheStores[key].store)
+		}
+		responseCacheMu.Unlock()
+
+		for _, store := range stores {
+			if err := store.Clear(r.Context()); err != nil {
+				writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+				return
+			}
+		}
+		sort.Strings(keys)
+		log.Printf("🧹 Response cache cleared: %v", keys)
+		writeJSON(w, http.StatusOK, map[string]interface{}{"status": "cleared", "profiles": keys})
+	default:
+		writeMethodNotAllowed(w)
+	}
+}
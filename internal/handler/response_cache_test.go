@@ -0,0 +1,86 @@
+package handler
+
+import (
+	"strings"
+	"testing"
+
+	"dify-cli-gateway/internal/cli"
+)
+
+type countingRunner struct {
+	calls int
+}
+
+func (r *countingRunner) Name() string {
+	return "counting"
+}
+
+func (r *countingRunner) Run(opts *cli.RunOptions) (string, error) {
+	r.calls++
+	return `{"session_id":"s1","response":"ok","steps":[{"type":"tool_use","name":"Read"}]}`, nil
+}
+
+func TestCacheMiddleware_HitMissAndBypass(t *testing.T) {
+	withGlobalConfig(t, &Config{
+		Default: "cached",
+		Profiles: map[string]ProfileConfig{
+			"cached": {Name: "cached", Cache: &cli.CacheConfig{Enabled: true, Strategy: "lfu"}},
+		},
+	})
+	InitResponseCache()
+
+	profile, _ := GetProfile("cached")
+	pipeline, err := cli.BuildPipeline(withResponseCache(nil, profile))
+	if err != nil {
+		t.Fatalf("BuildPipeline failed: %v", err)
+	}
+	runner := &countingRunner{}
+	var output string
+	run := func(opts cli.RunOptions, metadata map[string]string) map[string]string {
+		if metadata == nil {
+			metadata = map[string]string{}
+		}
+		var err error
+		if output, err = pipeline.Run(runner, &cli.PipelineRequest{Profile: "cached", Options: &opts, Metadata: metadata}); err != nil {
+			t.Fatalf("Run failed: %v", err)
+		}
+		return metadata
+	}
+
+	if meta := run(cli.RunOptions{Prompt: "  hello world \r\n"}, nil); meta["cache"] != "miss" {
+		t.Errorf("expected miss, got %q", meta["cache"])
+	}
+	if !strings.Contains(output, `"session_id":"s1"`) {
+		t.Errorf("the first caller should get its own session: %s", output)
+	}
+	if meta := run(cli.RunOptions{Prompt: "hello world"}, nil); meta["cache"] != "hit" {
+		t.Errorf("expected hit for normalized prompt, got %q", meta["cache"])
+	}
+	if strings.Contains(output, "s1") || strings.Contains(output, "steps") || !strings.Contains(output, `"response":"ok"`) {
+		t.Errorf("a cache hit must not return the first caller's session_id or steps: %s", output)
+	}
+	if runner.calls != 1 {
+		t.Errorf("expected runner called once, got %d", runner.calls)
+	}
+
+	if meta := run(cli.RunOptions{Prompt: "hello world"}, map[string]string{"cache_control": "no-cache"}); meta["cache"] != "miss" {
+		t.Errorf("expected no-cache to skip read, got %q", meta["cache"])
+	}
+	if meta := run(cli.RunOptions{Prompt: "hello world", SessionID: "abc"}, nil); meta["cache"] != "bypass" {
+		t.Errorf("expected session request to bypass, got %q", meta["cache"])
+	}
+	if runner.calls != 3 {
+		t.Errorf("expected runner called 3 times, got %d", runner.calls)
+	}
+}
+
+func TestParseCacheControl(t *testing.T) {
+	noCache, noStore := parseCacheControl("No-Cache, no-store")
+	if !noCache || !noStore {
+		t.Fatalf("expected both directives, got no-cache=%v no-store=%v", noCache, noStore)
+	}
+	noCache, noStore = parseCacheControl("max-age=60")
+	if noCache || noStore {
+		t.Fatalf("expected no directives, got no-cache=%v no-store=%v", noCache, noStore)
+	}
+}
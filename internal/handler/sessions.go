@@ -0,0 +1,266 @@
+package handler
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"dify-cli-gateway/internal/cli"
+	"dify-cli-gateway/internal/session_registry"
+)
+
+var (
+	sessionRegistryMu    sync.RWMutex
+	sessionRegistry      *session_registry.Registry
+	sessionSweeperOnce   sync.Once
+	sessionSweepInterval = time.Minute
+)
+
+// InitSessionRegistry 初始化会话登记表（存储不可用时退化为内存模式），并启动空闲过期清理
+func InitSessionRegistry() {
+	cfg := GetSessionRegistryConfig()
+	registryCfg := session_registry.Config{
+		StoragePath: cfg.StoragePath,
+		IdleTTL:     time.Duration(cfg.IdleTTLMinutes) * time.Minute,
+		Retention:   time.Duration(cfg.RetentionMinutes) * time.Minute,
+	}
+	registry, err := session_registry.NewRegistry(registryCfg)
+	if err != nil {
+		log.Printf("⚠️  Session registry storage unavailable, sessions will not survive restart: %v", err)
+		registryCfg.StoragePath = ""
+		registry, _ = session_registry.NewRegistry(registryCfg)
+	}
+
+	sessionRegistryMu.Lock()
+	sessionRegistry = registry
+	sessionRegistryMu.Unlock()
+	log.Printf("✅ Session registry initialized (storage=%s, idle_ttl=%dm)", cfg.StoragePath, cfg.IdleTTLMinutes)
+
+	sessionSweeperOnce.Do(func() {
+		go func() {
+			ticker := time.NewTicker(sessionSweepInterval)
+			defer ticker.Stop()
+			for range ticker.C {
+				if registry := getSessionRegistry(); registry != nil {
+					expired, purged, err := registry.Sweep()
+					if err != nil {
+						log.Printf("⚠️  [Sessions] Sweep failed: %v", err)
+					} else if expired > 0 || purged > 0 {
+						log.Printf("🧹 [Sessions] Expired %d idle session(s), purged %d", expired, purged)
+					}
+				}
+			}
+		}()
+	})
+}
+
+func getSessionRegistry() *session_registry.Registry {
+	sessionRegistryMu.RLock()
+	registry := sessionRegistry
+	sessionRegistryMu.RUnlock()
+	if registry == nil {
+		InitSessionRegistry()
+		sessionRegistryMu.RLock()
+		registry = sessionRegistry
+		sessionRegistryMu.RUnlock()
+	}
+	return registry
+}
+
+// apiKeyFingerprint 返回 API Key 指纹（用于会话归属，不保存原文）
+func apiKeyFingerprint(apiKey string) string {
+	sum := sha256.Sum256([]byte(apiKey))
+	return hex.EncodeToString(sum[:])[:16]
+}
+
+// requestOwner 返回调用方身份（API Key 指纹，匿名为空）
+func requestOwner(r *http.Request) string {
+	if apiKey := extractAPIKey(r); apiKey != "" {
+		return apiKeyFingerprint(apiKey)
+	}
+	return ""
+}
+
+// authorizeSession 恢复会话前校验 CLI 与调用方是否与创建者一致
+func authorizeSession(sessionID, cliName string, metadata map[string]string) error {
+	registry := getSessionRegistry()
+	if registry == nil {
+		return nil
+	}
+	return registry.Authorize(sessionID, cliName, metadata["api_key_id"])
+}
+
+// recordSession 从 CLI 输出中登记会话；缓存命中或合并的结果属于其他调用方，一次性调用（如转交摘要）也不登记
+func recordSession(output, cliName, profileKey string, opts *cli.RunOptions, metadata map[string]string) {
+	if metadata["cache"] == "hit" || metadata["coalesced"] == "true" || metadata["session_ephemeral"] == "true" {
+		return
+	}
+	var cliOut CLIOutput
+	if err := json.Unmarshal([]byte(output), &cliOut); err != nil || cliOut.SessionID == "" {
+		return
+	}
+	registry := getSessionRegistry()
+	if registry == nil {
+		return
+	}
+
+	workspace := opts.WorkDir
+	if workspace == "" {
+		workspace, _ = os.Getwd()
+	}
+	if _, err := registry.Observe(session_registry.Observation{
+		SessionID:   cliOut.SessionID,
+		CLI:         cliName,
+		Profile:     profileKey,
+		Model:       opts.Model,
+		Owner:       metadata["api_key_id"],
+		Tenant:      metadata["tenant"],
+		Workspace:   workspace,
+		Endpoint:    metadata["endpoint"],
+		CostUSD:     cliOut.TotalCostUSD,
+		ParentID:    metadata["parent_session_id"],
+		WorkflowRun: metadata["workflow_run_id"],
+	}); err != nil {
+		log.Printf("⚠️  [Sessions] Failed to record session %s: %v", cliOut.SessionID, err)
+	}
+}
+
+// sessionErrorStatus 将会话校验错误映射为 HTTP 状态码
+func sessionErrorStatus(err error) (int, bool) {
+	switch {
+	case errors.Is(err, session_registry.ErrNotFound):
+		return http.StatusNotFound, true
+	case errors.Is(err, session_registry.ErrOwnerMismatch), errors.Is(err, session_registry.ErrCLIMismatch):
+		return http.StatusForbidden, true
+	case errors.Is(err, session_registry.ErrInactive):
+		return http.StatusGone, true
+	}
+	return 0, false
+}
+
+// HandleSessions 处理 /sessions 与 /sessions/{id}（仅能访问调用方自己创建的会话）
+func HandleSessions(w http.ResponseWriter, r *http.Request) {
+	log.Printf("📥 Received request: %s %s from %s", r.Method, r.URL.Path, r.RemoteAddr)
+
+	registry := getSessionRegistry()
+	if registry == nil {
+		writeJSON(w, http.StatusServiceUnavailable, map[string]string{"error": "session registry unavailable"})
+		return
+	}
+
+	owner := requestOwner(r)
+	path := strings.Trim(strings.TrimPrefix(r.URL.Path, "/sessions"), "/")
+	if path == "" {
+		if r.Method != http.MethodGet {
+			writeMethodNotAllowed(w)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"sessions": registry.List(sessionFilter(r, owner, false))})
+		return
+	}
+
+	parts := strings.Split(path, "/")
+	id := parts[0]
+	action := strings.Join(parts[1:], "/")
+
+	session, err := registry.Get(id)
+	if err != nil || session.Owner != owner {
+		// 不区分「不存在」与「不属于调用方」，避免泄露会话 ID
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": session_registry.ErrNotFound.Error()})
+		return
+	}
+
+	switch {
+	case action == "" && r.Method == http.MethodGet:
+		writeJSON(w, http.StatusOK, session)
+	case action == "" && r.Method == http.MethodDelete:
+		closed, err := registry.Close(id)
+		if err != nil {
+			writeSessionError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, closed)
+	case action == "":
+		writeMethodNotAllowed(w)
+	case action == "transcript" && r.Method == http.MethodGet:
+		writeSessionTranscript(w, r, session)
+	case action == "transcript":
+		writeMethodNotAllowed(w)
+	case action == "handoff" && r.Method == http.MethodPost:
+		handleSessionHandoff(w, r, session)
+	case action == "handoff":
+		writeMethodNotAllowed(w)
+	default:
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "not found"})
+	}
+}
+
+// handleAdminSessions 后台查看所有会话：GET /api/sessions，DELETE /api/sessions/{id}，GET /api/sessions/{id}/transcript
+func handleAdminSessions(w http.ResponseWriter, r *http.Request, relativePath string) {
+	registry := getSessionRegistry()
+	if registry == nil {
+		writeJSON(w, http.StatusServiceUnavailable, map[string]string{"error": "session registry unavailable"})
+		return
+	}
+
+	id := strings.Trim(strings.TrimPrefix(relativePath, "/api/sessions"), "/")
+	if strings.HasSuffix(id, "/transcript") {
+		if r.Method != http.MethodGet {
+			writeMethodNotAllowed(w)
+			return
+		}
+		session, err := registry.Get(strings.TrimSuffix(id, "/transcript"))
+		if err != nil {
+			writeSessionError(w, err)
+			return
+		}
+		writeSessionTranscript(w, r, session)
+		return
+	}
+	switch {
+	case id == "" && r.Method == http.MethodGet:
+		writeJSON(w, http.StatusOK, map[string]interface{}{"sessions": registry.List(sessionFilter(r, "", true))})
+	case id != "" && r.Method == http.MethodGet:
+		session, err := registry.Get(id)
+		if err != nil {
+			writeSessionError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, session)
+	case id != "" && r.Method == http.MethodDelete:
+		closed, err := registry.Close(id)
+		if err != nil {
+			writeSessionError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, closed)
+	default:
+		writeMethodNotAllowed(w)
+	}
+}
+
+func sessionFilter(r *http.Request, owner string, anyOwner bool) session_registry.Filter {
+	query := r.URL.Query()
+	return session_registry.Filter{
+		Owner:    owner,
+		AnyOwner: anyOwner,
+		CLI:      query.Get("cli"),
+		Profile:  query.Get("profile"),
+		Status:   session_registry.Status(query.Get("status")),
+	}
+}
+
+func writeSessionError(w http.ResponseWriter, err error) {
+	status, ok := sessionErrorStatus(err)
+	if !ok {
+		status = http.StatusInternalServerError
+	}
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
@@ -0,0 +1,85 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+
+	"dify-cli-gateway/internal/structured_output"
+)
+
+// structuredResult 结构化输出的校验结果
+type structuredResult struct {
+	Parsed   json.RawMessage
+	Errors   []string
+	Attempts int
+}
+
+// runStructuredCLI 注入 schema 要求后执行 CLI，校验失败时在同一会话中带上错误重新提问
+func runStructuredCLI(req cliRequest, rawSchema json.RawMessage, schema *structured_output.Schema) (string, *structuredResult, error) {
+	maxRepairs := *GetStructuredOutputConfig().MaxRepairs
+	req.System = appendSystemPrompt(req.System, structured_output.Instructions(rawSchema))
+	result := &structuredResult{}
+
+	for {
+		result.Attempts++
+		output, err := runCLI(req)
+		if err != nil {
+			return "", result, err
+		}
+
+		var cliOut CLIOutput
+		text := output
+		if err := json.Unmarshal([]byte(output), &cliOut); err == nil {
+			text = cliOut.Response
+			if text == "" {
+				text = cliOut.Codex
+			}
+		}
+
+		result.Parsed, result.Errors = structured_output.Check(schema, text)
+		if len(result.Errors) == 0 {
+			log.Printf("🧩 Structured output valid (attempt %d)", result.Attempts)
+			return output, result, nil
+		}
+		if result.Attempts > maxRepairs {
+			log.Printf("❌ Structured output invalid after %d attempt(s): %v", result.Attempts, result.Errors)
+			return output, result, nil
+		}
+
+		log.Printf("🔁 Structured output invalid (attempt %d), requesting repair: %v", result.Attempts, result.Errors)
+		repair := structured_output.RepairPrompt(result.Errors)
+		if cliOut.SessionID != "" {
+			req.SessionID = cliOut.SessionID
+			req.NewSession = false
+			req.Prompt = repair
+		} else {
+			// CLI 未返回会话 ID 时无法续接，带上原始问题和上一次回复重新提问
+			req.Prompt = fmt.Sprintf("%s\n\nPrevious answer:\n%s\n\n%s", req.Prompt, text, repair)
+		}
+		if req.Metadata != nil {
+			req.Metadata["schema_repair"] = fmt.Sprintf("%d", result.Attempts)
+		}
+	}
+}
+
+// parseResponseSchema 解析请求中的 response_schema，未提供时返回 nil
+func parseResponseSchema(raw json.RawMessage) (*structured_output.Schema, error) {
+	if len(raw) == 0 || string(raw) == "null" {
+		return nil, nil
+	}
+	return structured_output.ParseSchema(raw)
+}
+
+// writeStructuredResponse 写回结构化输出响应；重试耗尽仍未通过校验时返回 422
+func writeStructuredResponse(w http.ResponseWriter, answer string, result *structuredResult) {
+	resp := InvokeResponse{Answer: answer, Parsed: result.Parsed, SchemaAttempts: result.Attempts}
+	if len(result.Errors) > 0 {
+		resp.Error = "response does not match response_schema"
+		resp.SchemaErrors = result.Errors
+		writeJSON(w, http.StatusUnprocessableEntity, resp)
+		return
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
@@ -0,0 +1,89 @@
+package handler
+
+import (
+	"bytes"
+	"errors"
+	"log"
+	"net/http"
+	"path/filepath"
+
+	"dify-cli-gateway/internal/fs_policy"
+	"dify-cli-gateway/internal/session_registry"
+	"dify-cli-gateway/internal/transcript"
+)
+
+// transcriptRoots 按会话所属 profile 的环境变量推导原生历史目录（HOME / CLAUDE_CONFIG_DIR / CODEX_HOME 等）；
+// 沙箱 profile 的会话记录挂载自 data/homes/<profile>，以其作为 HOME
+func transcriptRoots(session *session_registry.Session) transcript.Roots {
+	var roots transcript.Roots
+	if session.Profile == "" {
+		return roots
+	}
+	profile, err := GetProfile(session.Profile)
+	if err != nil || profile == nil {
+		return roots
+	}
+	env := profile.Env
+	roots.Home = env["HOME"]
+	if profile.Sandbox != nil && profile.Sandbox.Enabled {
+		if stateDir, err := gatewayProfilePath("homes", resolveProfileKey(session.Profile)); err == nil {
+			roots.Home = stateDir
+		}
+	}
+	roots.ClaudeDir = env["CLAUDE_CONFIG_DIR"]
+	roots.CodexDir = env["CODEX_HOME"]
+	if dir := env["GEMINI_CLI_HOME"]; dir != "" {
+		roots.GeminiDir = filepath.Join(dir, ".gemini")
+	}
+	return roots
+}
+
+// writeSessionTranscript 导出会话记录：?format=json（默认）| markdown | html
+func writeSessionTranscript(w http.ResponseWriter, r *http.Request, session *session_registry.Session) {
+	format := r.URL.Query().Get("format")
+	switch format {
+	case "", "json", "markdown", "md", "html":
+	default:
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "format must be json, markdown or html"})
+		return
+	}
+
+	t, err := transcript.Load(session.CLI, session.ID, transcriptRoots(session))
+	if err != nil {
+		switch {
+		case errors.Is(err, transcript.ErrNotFound):
+			writeJSON(w, http.StatusNotFound, map[string]string{"error": err.Error()})
+		case errors.Is(err, transcript.ErrUnsupported):
+			writeJSON(w, http.StatusNotImplemented, map[string]string{"error": err.Error()})
+		default:
+			log.Printf("⚠️  [Sessions] Failed to load transcript for %s: %v", session.ID, err)
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		}
+		return
+	}
+
+	if decision := checkFSPath(fs_policy.PurposeArtifact, t.Source, "session:"+session.ID); !decision.Allowed {
+		writeJSON(w, http.StatusForbidden, map[string]string{"error": "transcript denied by fs_policy: " + decision.Reason})
+		return
+	}
+
+	var buf bytes.Buffer
+	switch format {
+	case "markdown", "md":
+		err = transcript.WriteMarkdown(&buf, t)
+		w.Header().Set("Content-Type", "text/markdown; charset=utf-8")
+	case "html":
+		err = transcript.WriteHTML(&buf, t)
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	default:
+		writeJSON(w, http.StatusOK, t)
+		return
+	}
+	if err != nil {
+		w.Header().Del("Content-Type")
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	w.Write(buf.Bytes())
+}
@@ -0,0 +1,36 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"dify-cli-gateway/internal/cli"
+	"dify-cli-gateway/internal/session_registry"
+)
+
+func TestSessionTranscript_SandboxedProfileUsesStateDir(t *testing.T) {
+	base := t.TempDir()
+	previous, _ := os.Getwd()
+	if err := os.Chdir(base); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(previous) })
+	withGlobalConfig(t, &Config{Profiles: map[string]ProfileConfig{
+		"tenant-a": {CLI: "claude", Sandbox: &cli.SandboxConfig{Enabled: true}},
+	}})
+
+	// 沙箱内的 ~/.claude/projects 挂载自 data/homes/<profile>/.claude/projects
+	path := filepath.Join(base, "data", "homes", "tenant-a", ".claude", "projects", "-work", "s1.jsonl")
+	os.MkdirAll(filepath.Dir(path), 0o700)
+	os.WriteFile(path, []byte(`{"type":"user","message":{"role":"user","content":"hello sandbox"}}`+"\n"), 0o600)
+
+	rec := httptest.NewRecorder()
+	writeSessionTranscript(rec, httptest.NewRequest(http.MethodGet, "/sessions/s1/transcript", nil), &session_registry.Session{ID: "s1", CLI: "claude", Profile: "tenant-a"})
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "hello sandbox") {
+		t.Errorf("transcript of a sandboxed profile should be found in its state dir: %d %s", rec.Code, rec.Body.String())
+	}
+}
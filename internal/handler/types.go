@@ -68,10 +68,12 @@ type Message struct {
 
 // InvokeRequest 表示 Dify 发送的请求
 type InvokeRequest struct {
-	System   string    `json:"system"`
-	Messages []Message `json:"messages"`
-	Profile  string    `json:"profile,omitempty"` // 可选：指定使用的配置 profile
-	CLI      string    `json:"cli,omitempty"`     // 可选：CLI 工具名称（"claude" 或 "codex"，默认 "claude"）
+	System         string          `json:"system"`
+	Messages       []Message       `json:"messages"`
+	Profile        string          `json:"profile,omitempty"`         // 可选：指定使用的配置 profile
+	CLI            string          `json:"cli,omitempty"`             // 可选：CLI 工具名称（"claude" 或 "codex"，默认 "claude"）
+	ResponseSchema json.RawMessage `json:"response_schema,omitempty"` // 可选：JSON Schema，返回校验后的 parsed 字段
+	IncludeSteps   bool            `json:"include_steps,omitempty"`   // 可选：在 answer 中返回工具调用步骤（steps）
 }
 
 // ChatRequest 表示简化的聊天请求
@@ -86,17 +88,26 @@ type ChatRequest struct {
 	WorkflowRunID  string          `json:"workflow_run_id,omitempty"`  // 可选：Dify 工作流运行 ID，用于自动管理会话
 	AllowedTools   FlexStringArray `json:"allowed_tools,omitempty"`    // 可选：允许使用的 MCP 工具列表（支持数组或字符串）
 	PermissionMode string          `json:"permission_mode,omitempty"`  // 可选：权限模式（仅 Claude CLI 支持，如 "bypassPermissions"）
+	ResponseSchema json.RawMessage `json:"response_schema,omitempty"`  // 可选：JSON Schema，返回校验后的 parsed 字段
+	IncludeSteps   bool            `json:"include_steps,omitempty"`    // 可选：在 answer 中返回工具调用步骤（steps）
 }
 
 // InvokeResponse 表示返回给 Dify 的响应
 type InvokeResponse struct {
 	Answer string `json:"answer"`
+
+	// 以下字段仅在请求携带 response_schema 时返回
+	Parsed         json.RawMessage `json:"parsed,omitempty"`          // 通过校验的 JSON
+	SchemaErrors   []string        `json:"schema_errors,omitempty"`   // 重试耗尽后仍存在的校验错误
+	SchemaAttempts int             `json:"schema_attempts,omitempty"` // CLI 调用次数（含修复重试）
+	Error          string          `json:"error,omitempty"`
 }
 
 // CLIOutput 表示统一的 CLI 输出格式（兼容旧格式）
 type CLIOutput struct {
-	SessionID string `json:"session_id"`
-	User      string `json:"user"`
-	Codex     string `json:"codex"`    // 保持兼容性
-	Response  string `json:"response"` // 新字段
+	SessionID    string  `json:"session_id"`
+	User         string  `json:"user"`
+	Codex        string  `json:"codex"`                    // 保持兼容性
+	Response     string  `json:"response"`                 // 新字段
+	TotalCostUSD float64 `json:"total_cost_usd,omitempty"` // CLI 上报的费用（可选）
 }
@@ -0,0 +1,137 @@
+package mcp_client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"sync"
+)
+
+// ProtocolVersion 探测时请求的 MCP 协议版本
+const ProtocolVersion = "2025-06-18"
+
+// rpcMessage JSON-RPC 消息（请求、响应或通知）
+type rpcMessage struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method,omitempty"`
+	Params  interface{}     `json:"params,omitempty"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// transport 发送一条消息；响应（以及服务端发起的请求）通过 client.deliver 回传
+type transport interface {
+	send(ctx context.Context, data []byte) error
+	closed() <-chan struct{} // 连接断开（如 server 进程退出）后关闭
+	close()
+}
+
+// client 在 transport 之上按 id 匹配请求与响应
+type client struct {
+	transport transport
+
+	mu      sync.Mutex
+	nextID  int
+	pending map[string]chan rpcMessage
+}
+
+func newClient() *client {
+	return &client{pending: map[string]chan rpcMessage{}}
+}
+
+// call 发送请求并等待对应 id 的响应
+func (c *client) call(ctx context.Context, method string, params interface{}, result interface{}) error {
+	c.mu.Lock()
+	c.nextID++
+	id := strconv.Itoa(c.nextID)
+	ch := make(chan rpcMessage, 1)
+	c.pending[id] = ch
+	c.mu.Unlock()
+	defer func() {
+		c.mu.Lock()
+		delete(c.pending, id)
+		c.mu.Unlock()
+	}()
+
+	data, err := json.Marshal(rpcMessage{JSONRPC: "2.0", ID: json.RawMessage(id), Method: method, Params: params})
+	if err != nil {
+		return err
+	}
+	if err := c.transport.send(ctx, data); err != nil {
+		return fmt.Errorf("%s: %v", method, err)
+	}
+
+	var msg rpcMessage
+	select {
+	case msg = <-ch:
+	case <-c.transport.closed():
+		// 响应可能在连接断开前已送达
+		select {
+		case msg = <-ch:
+		default:
+			return fmt.Errorf("%s: server closed the connection", method)
+		}
+	case <-ctx.Done():
+		return fmt.Errorf("%s: %v", method, ctx.Err())
+	}
+
+	if msg.Error != nil {
+		return fmt.Errorf("%s: %s (code %d)", method, msg.Error.Message, msg.Error.Code)
+	}
+	if result == nil {
+		return nil
+	}
+	if err := json.Unmarshal(msg.Result, result); err != nil {
+		return fmt.Errorf("%s: invalid result: %v", method, err)
+	}
+	return nil
+}
+
+// notify 发送通知（无响应）
+func (c *client) notify(ctx context.Context, method string) error {
+	data, err := json.Marshal(rpcMessage{JSONRPC: "2.0", Method: method})
+	if err != nil {
+		return err
+	}
+	return c.transport.send(ctx, data)
+}
+
+// deliver 分发收到的消息：响应交给等待中的 call，服务端请求仅应答 ping
+func (c *client) deliver(data []byte) {
+	var msg rpcMessage
+	if err := json.Unmarshal(data, &msg); err != nil || len(msg.ID) == 0 {
+		return
+	}
+	if msg.Method != "" {
+		go c.reply(msg)
+		return
+	}
+	c.mu.Lock()
+	ch, ok := c.pending[string(msg.ID)]
+	c.mu.Unlock()
+	if ok {
+		select {
+		case ch <- msg:
+		default:
+		}
+	}
+}
+
+func (c *client) reply(req rpcMessage) {
+	resp := rpcMessage{JSONRPC: "2.0", ID: req.ID}
+	if req.Method == "ping" {
+		resp.Result = json.RawMessage(`{}`)
+	} else {
+		resp.Error = &rpcError{Code: -32601, Message: "method not found: " + req.Method}
+	}
+	if data, err := json.Marshal(resp); err == nil {
+		c.transport.send(context.Background(), data)
+	}
+}
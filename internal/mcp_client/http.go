@@ -0,0 +1,255 @@
+package mcp_client
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxErrorBodyBytes 错误响应中保留的正文长度
+const maxErrorBodyBytes = 2 << 10
+
+// streamableTransport Streamable HTTP：每条消息一个 POST，响应为 JSON 或 SSE 流
+type streamableTransport struct {
+	client  *client
+	url     string
+	headers map[string]string
+	http    *http.Client
+	done    chan struct{}
+
+	mu        sync.Mutex
+	sessionID string
+}
+
+func newStreamableTransport(c *client, target Target) *streamableTransport {
+	return &streamableTransport{client: c, url: target.URL, headers: target.Headers, http: &http.Client{}, done: make(chan struct{})}
+}
+
+func (t *streamableTransport) send(ctx context.Context, data []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.url, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json, text/event-stream")
+	t.applyHeaders(req)
+
+	resp, err := t.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if err := checkHTTPStatus(resp); err != nil {
+		return err
+	}
+	if id := resp.Header.Get("Mcp-Session-Id"); id != "" {
+		t.mu.Lock()
+		t.sessionID = id
+		t.mu.Unlock()
+	}
+	if resp.StatusCode == http.StatusAccepted {
+		return nil
+	}
+
+	if strings.HasPrefix(resp.Header.Get("Content-Type"), "text/event-stream") {
+		return readSSE(resp.Body, func(event, data string) {
+			if event == "" || event == "message" {
+				t.client.deliver([]byte(data))
+			}
+		})
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	deliverBody(t.client, body)
+	return nil
+}
+
+func (t *streamableTransport) applyHeaders(req *http.Request) {
+	for key, value := range t.headers {
+		req.Header.Set(key, value)
+	}
+	t.mu.Lock()
+	if t.sessionID != "" {
+		req.Header.Set("Mcp-Session-Id", t.sessionID)
+	}
+	t.mu.Unlock()
+}
+
+func (t *streamableTransport) closed() <-chan struct{} {
+	return t.done
+}
+
+// close 存在会话时发送 DELETE 结束会话（尽力而为）
+func (t *streamableTransport) close() {
+	t.mu.Lock()
+	sessionID := t.sessionID
+	t.mu.Unlock()
+	if sessionID == "" {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, t.url, nil)
+	if err != nil {
+		return
+	}
+	t.applyHeaders(req)
+	if resp, err := t.http.Do(req); err == nil {
+		resp.Body.Close()
+	}
+}
+
+// sseTransport 旧版 HTTP+SSE：GET 建立事件流并从 endpoint 事件获得 POST 地址
+type sseTransport struct {
+	endpoint string
+	headers  map[string]string
+	http     *http.Client
+	cancel   context.CancelFunc
+	done     chan struct{}
+}
+
+func startSSE(ctx context.Context, c *client, target Target) (*sseTransport, error) {
+	streamCtx, cancel := context.WithCancel(context.Background())
+	t := &sseTransport{headers: target.Headers, http: &http.Client{}, cancel: cancel, done: make(chan struct{})}
+
+	req, err := http.NewRequestWithContext(streamCtx, http.MethodGet, target.URL, nil)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	req.Header.Set("Accept", "text/event-stream")
+	for key, value := range target.Headers {
+		req.Header.Set(key, value)
+	}
+	resp, err := t.http.Do(req)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	if err := checkHTTPStatus(resp); err != nil {
+		resp.Body.Close()
+		cancel()
+		return nil, err
+	}
+
+	endpoints := make(chan string, 1)
+	go func() {
+		defer close(t.done)
+		defer resp.Body.Close()
+		readSSE(resp.Body, func(event, data string) {
+			switch event {
+			case "endpoint":
+				select {
+				case endpoints <- data:
+				default:
+				}
+			case "", "message":
+				c.deliver([]byte(data))
+			}
+		})
+	}()
+
+	select {
+	case endpoint := <-endpoints:
+		base, _ := url.Parse(target.URL)
+		ref, err := url.Parse(strings.TrimSpace(endpoint))
+		if err != nil {
+			cancel()
+			return nil, fmt.Errorf("invalid endpoint event: %v", err)
+		}
+		t.endpoint = base.ResolveReference(ref).String()
+		return t, nil
+	case <-t.done:
+		cancel()
+		return nil, fmt.Errorf("event stream closed before the endpoint event")
+	case <-ctx.Done():
+		cancel()
+		return nil, fmt.Errorf("waiting for endpoint event: %v", ctx.Err())
+	}
+}
+
+func (t *sseTransport) send(ctx context.Context, data []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.endpoint, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range t.headers {
+		req.Header.Set(key, value)
+	}
+	resp, err := t.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return checkHTTPStatus(resp)
+}
+
+func (t *sseTransport) closed() <-chan struct{} {
+	return t.done
+}
+
+func (t *sseTransport) close() {
+	t.cancel()
+	<-t.done
+}
+
+func checkHTTPStatus(resp *http.Response) error {
+	if resp.StatusCode < 300 {
+		return nil
+	}
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBodyBytes))
+	return fmt.Errorf("HTTP %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+}
+
+// deliverBody 分发 JSON 响应（单条消息或批量数组）
+func deliverBody(c *client, body []byte) {
+	body = bytes.TrimSpace(body)
+	if len(body) > 0 && body[0] == '[' {
+		var batch []json.RawMessage
+		if json.Unmarshal(body, &batch) == nil {
+			for _, msg := range batch {
+				c.deliver(msg)
+			}
+		}
+		return
+	}
+	c.deliver(body)
+}
+
+// readSSE 解析 text/event-stream，每个事件回调一次（多行 data 以换行拼接）
+func readSSE(r io.Reader, fn func(event, data string)) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 8<<20)
+	var event string
+	var data []string
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case line == "":
+			if len(data) > 0 {
+				fn(event, strings.Join(data, "\n"))
+			}
+			event, data = "", nil
+		case strings.HasPrefix(line, ":"):
+		case strings.HasPrefix(line, "event:"):
+			event = strings.TrimSpace(strings.TrimPrefix(line, "event:"))
+		case strings.HasPrefix(line, "data:"):
+			data = append(data, strings.TrimPrefix(strings.TrimPrefix(line, "data:"), " "))
+		}
+	}
+	if len(data) > 0 {
+		fn(event, strings.Join(data, "\n"))
+	}
+	return scanner.Err()
+}
@@ -0,0 +1,158 @@
+package mcp_client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// maxToolPages tools/list 分页的最大页数
+const maxToolPages = 20
+
+// Target 待测试的 MCP server
+type Target struct {
+	Type    string // stdio（默认）/ http / sse
+	Command string
+	Args    []string
+	Env     map[string]string
+	Environ []string // stdio 子进程的基础环境（由调用方按 env_policy 构造，为空时不继承网关环境），Env 在其上覆盖
+	URL     string
+	Headers map[string]string
+}
+
+// Transport 实际使用的传输方式
+func (t Target) Transport() string {
+	switch {
+	case t.Type == "sse":
+		return "sse"
+	case t.Type == "http" || t.Type == "streamable-http" || (t.Command == "" && t.URL != ""):
+		return "http"
+	default:
+		return "stdio"
+	}
+}
+
+// ServerInfo initialize 返回的 server 信息
+type ServerInfo struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+// ToolInfo tools/list 中的工具
+type ToolInfo struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description,omitempty"`
+	InputSchema json.RawMessage `json:"inputSchema,omitempty"`
+}
+
+// Result 连通性测试结果
+type Result struct {
+	OK              bool            `json:"ok"`
+	Transport       string          `json:"transport"`
+	ProtocolVersion string          `json:"protocol_version,omitempty"`
+	ServerInfo      *ServerInfo     `json:"server_info,omitempty"`
+	Capabilities    json.RawMessage `json:"capabilities,omitempty"`
+	Tools           []ToolInfo      `json:"tools"`
+	InitializeMs    int64           `json:"initialize_ms"`
+	LatencyMs       int64           `json:"latency_ms"`
+	Error           string          `json:"error,omitempty"`
+	Stderr          string          `json:"stderr,omitempty"`
+}
+
+// Probe 连接 MCP server，完成 initialize 握手与 tools/list 后断开（stdio 子进程会被结束）
+func Probe(ctx context.Context, target Target, timeout time.Duration) (result Result) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	result = Result{Transport: target.Transport(), Tools: []ToolInfo{}}
+	start := time.Now()
+	defer func() { result.LatencyMs = time.Since(start).Milliseconds() }()
+
+	c := newClient()
+	var stderr *tailBuffer
+	switch result.Transport {
+	case "stdio":
+		if target.Command == "" {
+			result.Error = "command is required"
+			return result
+		}
+		t, err := startStdio(c, target)
+		if err != nil {
+			result.Error = err.Error()
+			return result
+		}
+		c.transport, stderr = t, t.stderr
+	case "sse":
+		t, err := startSSE(ctx, c, target)
+		if err != nil {
+			result.Error = err.Error()
+			return result
+		}
+		c.transport = t
+	default:
+		if target.URL == "" {
+			result.Error = "url is required"
+			return result
+		}
+		c.transport = newStreamableTransport(c, target)
+	}
+
+	err := handshake(ctx, c, &result, start)
+	c.transport.close()
+	if err != nil {
+		result.Error = err.Error()
+		if stderr != nil {
+			result.Stderr = strings.TrimSpace(stderr.String())
+		}
+		return result
+	}
+	result.OK = true
+	return result
+}
+
+func handshake(ctx context.Context, c *client, result *Result, start time.Time) error {
+	var init struct {
+		ProtocolVersion string          `json:"protocolVersion"`
+		ServerInfo      *ServerInfo     `json:"serverInfo"`
+		Capabilities    json.RawMessage `json:"capabilities"`
+	}
+	err := c.call(ctx, "initialize", map[string]interface{}{
+		"protocolVersion": ProtocolVersion,
+		"capabilities":    map[string]interface{}{},
+		"clientInfo":      map[string]string{"name": "cli-gateway", "version": "1.0.0"},
+	}, &init)
+	if err != nil {
+		return err
+	}
+	result.InitializeMs = time.Since(start).Milliseconds()
+	result.ProtocolVersion = init.ProtocolVersion
+	result.ServerInfo = init.ServerInfo
+	result.Capabilities = init.Capabilities
+
+	if err := c.notify(ctx, "notifications/initialized"); err != nil {
+		return fmt.Errorf("notifications/initialized: %v", err)
+	}
+
+	var cursor string
+	for page := 0; page < maxToolPages; page++ {
+		var params interface{}
+		if cursor != "" {
+			params = map[string]string{"cursor": cursor}
+		}
+		var list struct {
+			Tools      []ToolInfo `json:"tools"`
+			NextCursor string     `json:"nextCursor"`
+		}
+		if err := c.call(ctx, "tools/list", params, &list); err != nil {
+			return err
+		}
+		result.Tools = append(result.Tools, list.Tools...)
+		if list.NextCursor == "" {
+			break
+		}
+		cursor = list.NextCursor
+	}
+	return nil
+}
@@ -0,0 +1,167 @@
+package mcp_client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"dify-cli-gateway/internal/mcp_server"
+)
+
+func fakeServer() *mcp_server.Server {
+	return mcp_server.NewServer("fake", "0.1", mcp_server.Tool{
+		Name:        "echo",
+		Description: "Echo text",
+		InputSchema: json.RawMessage(`{"type":"object","properties":{"text":{"type":"string"}}}`),
+		Handler: func(ctx context.Context, args json.RawMessage) (mcp_server.ToolResult, error) {
+			return mcp_server.ToolResult{Text: string(args)}, nil
+		},
+	})
+}
+
+// TestMain 设置 FAKE_MCP_STDIO 时测试二进制作为 stdio MCP server 运行
+func TestMain(m *testing.M) {
+	if os.Getenv("FAKE_MCP_STDIO") == "1" {
+		fmt.Fprintln(os.Stderr, "fake server starting")
+		fakeServer().ServeStdio(context.Background(), os.Stdin, os.Stdout)
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+func assertEchoTool(t *testing.T, result Result) {
+	t.Helper()
+	if !result.OK || result.Error != "" {
+		t.Fatalf("probe failed: %+v", result)
+	}
+	if result.ServerInfo == nil || result.ServerInfo.Name != "fake" || result.ProtocolVersion != ProtocolVersion {
+		t.Errorf("unexpected server info: %+v", result)
+	}
+	if len(result.Tools) != 1 || result.Tools[0].Name != "echo" || !strings.Contains(string(result.Tools[0].InputSchema), `"text"`) {
+		t.Errorf("unexpected tools: %+v", result.Tools)
+	}
+}
+
+func TestProbe_Stdio(t *testing.T) {
+	exe, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	result := Probe(context.Background(), Target{Command: exe, Env: map[string]string{"FAKE_MCP_STDIO": "1"}}, 10*time.Second)
+	assertEchoTool(t, result)
+	if result.Transport != "stdio" || result.Stderr != "" {
+		t.Errorf("stderr is only reported on failure: %+v", result)
+	}
+}
+
+func TestProbe_StdioFailureAndTimeout(t *testing.T) {
+	result := Probe(context.Background(), Target{Command: "sh", Args: []string{"-c", "echo 'missing API_KEY' >&2; exit 1"}}, 5*time.Second)
+	if result.OK || result.Error == "" || result.Stderr != "missing API_KEY" {
+		t.Errorf("exit before handshake should report stderr: %+v", result)
+	}
+
+	start := time.Now()
+	result = Probe(context.Background(), Target{Command: "sleep", Args: []string{"30"}}, 200*time.Millisecond)
+	if result.OK || !strings.Contains(result.Error, "deadline exceeded") {
+		t.Errorf("expected timeout: %+v", result)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("timed out server should be killed promptly, took %v", elapsed)
+	}
+
+	if result = Probe(context.Background(), Target{Command: "/nonexistent/mcp-server"}, time.Second); result.OK || result.Error == "" {
+		t.Errorf("missing command should fail: %+v", result)
+	}
+}
+
+func TestProbe_StreamableHTTP(t *testing.T) {
+	var auth string
+	server := fakeServer()
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = r.Header.Get("Authorization")
+		server.ServeHTTP(w, r)
+	}))
+	defer ts.Close()
+
+	result := Probe(context.Background(), Target{Type: "http", URL: ts.URL, Headers: map[string]string{"Authorization": "Bearer t"}}, 5*time.Second)
+	assertEchoTool(t, result)
+	if auth != "Bearer t" {
+		t.Errorf("headers should be sent, got %q", auth)
+	}
+
+	ts401 := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "invalid token", http.StatusUnauthorized)
+	}))
+	defer ts401.Close()
+	if result = Probe(context.Background(), Target{URL: ts401.URL}, 5*time.Second); result.OK || !strings.Contains(result.Error, "HTTP 401: invalid token") {
+		t.Errorf("HTTP errors should be reported: %+v", result)
+	}
+}
+
+func TestProbe_SSE(t *testing.T) {
+	server := fakeServer()
+	messages := make(chan []byte, 8)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/sse", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, "event: endpoint\ndata: /messages?session=1\n\n")
+		w.(http.Flusher).Flush()
+		for {
+			select {
+			case msg := <-messages:
+				fmt.Fprintf(w, "event: message\ndata: %s\n\n", msg)
+				w.(http.Flusher).Flush()
+			case <-r.Context().Done():
+				return
+			}
+		}
+	})
+	mux.HandleFunc("/messages", func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if resp := server.HandleMessage(r.Context(), body); resp != nil {
+			messages <- resp
+		}
+		w.WriteHeader(http.StatusAccepted)
+	})
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+
+	result := Probe(context.Background(), Target{Type: "sse", URL: ts.URL + "/sse"}, 5*time.Second)
+	assertEchoTool(t, result)
+	if result.Transport != "sse" {
+		t.Errorf("unexpected transport: %s", result.Transport)
+	}
+}
+
+func TestProbe_StdioKillsProcessGroupAndIsolatesEnv(t *testing.T) {
+	t.Setenv("GATEWAY_PROBE_SECRET", "leak")
+	script := `sleep 30 & echo "pid=$! secret=${GATEWAY_PROBE_SECRET:-none} region=$REGION" >&2; exit 1`
+	result := Probe(context.Background(), Target{Command: "sh", Args: []string{"-c", script}, Environ: []string{"REGION=default"}, Env: map[string]string{"REGION": "eu"}}, 5*time.Second)
+	if result.OK || !strings.Contains(result.Stderr, "secret=none region=eu") {
+		t.Fatalf("server should only see Environ and Env: %+v", result)
+	}
+	var pid int
+	fmt.Sscanf(result.Stderr, "pid=%d", &pid)
+	if pid <= 0 {
+		t.Fatalf("missing background pid in %q", result.Stderr)
+	}
+	// 孤儿进程可能被 init 回收得较慢，僵尸状态也视为已结束
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		stat, err := os.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+		if err != nil || strings.Contains(string(stat), ") Z ") {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("background process %d should be killed with the process group: %s", pid, stat)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
@@ -0,0 +1,131 @@
+package mcp_client
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os/exec"
+	"sync"
+	"time"
+)
+
+// maxStderrBytes 保留的 stderr 末尾长度
+const maxStderrBytes = 8 << 10
+
+// stdioTransport 启动子进程，以换行分隔的 JSON-RPC 通信
+type stdioTransport struct {
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	stderr *tailBuffer
+	cancel context.CancelFunc
+	done   chan struct{}
+
+	writeMu sync.Mutex
+}
+
+func startStdio(c *client, target Target) (*stdioTransport, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cmd := exec.CommandContext(ctx, target.Command, target.Args...)
+	// 不直接使用 os.Environ()，避免把网关自身的 secret 泄露给待测试的 server；同名变量以 Env 为准
+	cmd.Env = append([]string{}, target.Environ...)
+	for key, value := range target.Env {
+		cmd.Env = append(cmd.Env, key+"="+value)
+	}
+	// npx / uvx 等启动器会再 fork 出真正的 server，放到独立进程组中以便整体结束
+	setProcessGroup(cmd)
+	// 子进程退出后不再等待其残留的孙进程关闭管道
+	cmd.WaitDelay = 2 * time.Second
+
+	t := &stdioTransport{cmd: cmd, stderr: &tailBuffer{limit: maxStderrBytes}, cancel: cancel, done: make(chan struct{})}
+	cmd.Stderr = t.stderr
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		cancel()
+		return nil, fmt.Errorf("failed to start %s: %v", target.Command, err)
+	}
+	t.stdin = stdin
+
+	go func() {
+		defer close(t.done)
+		reader := bufio.NewReaderSize(stdout, 64*1024)
+		for {
+			line, err := reader.ReadBytes('\n')
+			if line = bytes.TrimSpace(line); len(line) > 0 {
+				c.deliver(line)
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+	return t, nil
+}
+
+func (t *stdioTransport) send(ctx context.Context, data []byte) error {
+	select {
+	case <-t.done:
+		return fmt.Errorf("server process exited")
+	default:
+	}
+	t.writeMu.Lock()
+	defer t.writeMu.Unlock()
+	_, err := t.stdin.Write(append(data, '\n'))
+	return err
+}
+
+func (t *stdioTransport) closed() <-chan struct{} {
+	return t.done
+}
+
+// close 关闭 stdin 让 server 正常退出，超时后强制结束整个进程组；
+// 启动器正常退出时也结束进程组，避免残留孤儿 server
+func (t *stdioTransport) close() {
+	t.stdin.Close()
+	exited := make(chan struct{})
+	go func() {
+		t.cmd.Wait()
+		close(exited)
+	}()
+	select {
+	case <-exited:
+	case <-time.After(time.Second):
+		t.cancel()
+		<-exited
+	}
+	killProcessGroup(t.cmd)
+	t.cancel()
+}
+
+// tailBuffer 只保留最后 limit 字节的输出
+type tailBuffer struct {
+	mu    sync.Mutex
+	limit int
+	buf   []byte
+}
+
+func (b *tailBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.buf = append(b.buf, p...)
+	if len(b.buf) > b.limit {
+		b.buf = append([]byte(nil), b.buf[len(b.buf)-b.limit:]...)
+	}
+	return len(p), nil
+}
+
+func (b *tailBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return string(b.buf)
+}
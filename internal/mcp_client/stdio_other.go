@@ -0,0 +1,10 @@
+//go:build windows
+
+package mcp_client
+
+import "os/exec"
+
+// setProcessGroup Windows 上没有进程组，取消时只结束直接子进程
+func setProcessGroup(cmd *exec.Cmd) {}
+
+func killProcessGroup(cmd *exec.Cmd) {}
@@ -0,0 +1,26 @@
+//go:build !windows
+
+package mcp_client
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// setProcessGroup 让子进程成为新进程组的组长，取消时结束整个进程组
+func setProcessGroup(cmd *exec.Cmd) {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.Setpgid = true
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+}
+
+// killProcessGroup 结束进程组中的残留进程（组长已退出时同样有效）
+func killProcessGroup(cmd *exec.Cmd) {
+	if cmd.Process != nil {
+		syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+}
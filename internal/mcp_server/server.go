@@ -0,0 +1,230 @@
+package mcp_server
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"sync"
+)
+
+// ProtocolVersion 默认的 MCP 协议版本（客户端请求受支持的版本时沿用客户端版本）
+const ProtocolVersion = "2025-06-18"
+
+var supportedVersions = map[string]bool{
+	"2024-11-05": true,
+	"2025-03-26": true,
+	"2025-06-18": true,
+}
+
+// maxRequestBytes 单个 HTTP 请求体上限
+const maxRequestBytes = 8 << 20
+
+// JSON-RPC 错误码
+const (
+	codeParseError     = -32700
+	codeInvalidRequest = -32600
+	codeMethodNotFound = -32601
+	codeInvalidParams  = -32602
+)
+
+// ToolResult 工具调用结果（以单个 text content 返回）
+type ToolResult struct {
+	Text       string
+	IsError    bool
+	Structured interface{} // 可选：structuredContent
+}
+
+// Tool 一个 MCP 工具
+type Tool struct {
+	Name        string
+	Description string
+	InputSchema json.RawMessage
+	Handler     func(ctx context.Context, args json.RawMessage) (ToolResult, error)
+}
+
+// Server 只提供 tools 能力的最小 MCP server（Streamable HTTP 仅 JSON 响应，或 stdio）
+type Server struct {
+	name    string
+	version string
+	tools   func() []Tool
+}
+
+// NewServer 创建工具固定的 MCP server
+func NewServer(name, version string, tools ...Tool) *Server {
+	return NewDynamicServer(name, version, func() []Tool { return tools })
+}
+
+// NewDynamicServer 创建每次请求时重新获取工具列表的 MCP server（如随配置变化的 profile）
+func NewDynamicServer(name, version string, tools func() []Tool) *Server {
+	return &Server{name: name, version: version, tools: tools}
+}
+
+type rpcRequest struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+type rpcResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  interface{}     `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+// HandleMessage 处理一条 JSON-RPC 消息；通知（无 id）返回 nil
+func (s *Server) HandleMessage(ctx context.Context, data []byte) []byte {
+	var req rpcRequest
+	if err := json.Unmarshal(data, &req); err != nil {
+		return encodeResponse(rpcResponse{ID: json.RawMessage("null"), Error: &rpcError{Code: codeParseError, Message: "parse error"}})
+	}
+	if len(req.ID) == 0 {
+		return nil
+	}
+	if req.Method == "" {
+		return encodeResponse(rpcResponse{ID: req.ID, Error: &rpcError{Code: codeInvalidRequest, Message: "missing method"}})
+	}
+
+	result, rpcErr := s.dispatch(ctx, &req)
+	return encodeResponse(rpcResponse{ID: req.ID, Result: result, Error: rpcErr})
+}
+
+func (s *Server) dispatch(ctx context.Context, req *rpcRequest) (interface{}, *rpcError) {
+	switch req.Method {
+	case "initialize":
+		var params struct {
+			ProtocolVersion string `json:"protocolVersion"`
+		}
+		json.Unmarshal(req.Params, &params)
+		version := ProtocolVersion
+		if supportedVersions[params.ProtocolVersion] {
+			version = params.ProtocolVersion
+		}
+		return map[string]interface{}{
+			"protocolVersion": version,
+			"capabilities":    map[string]interface{}{"tools": map[string]interface{}{}},
+			"serverInfo":      map[string]string{"name": s.name, "version": s.version},
+		}, nil
+	case "ping":
+		return map[string]interface{}{}, nil
+	case "tools/list":
+		available := s.tools()
+		tools := make([]map[string]interface{}, 0, len(available))
+		for _, tool := range available {
+			schema := tool.InputSchema
+			if len(schema) == 0 {
+				schema = json.RawMessage(`{"type":"object"}`)
+			}
+			tools = append(tools, map[string]interface{}{
+				"name":        tool.Name,
+				"description": tool.Description,
+				"inputSchema": schema,
+			})
+		}
+		return map[string]interface{}{"tools": tools}, nil
+	case "tools/call":
+		var params struct {
+			Name      string          `json:"name"`
+			Arguments json.RawMessage `json:"arguments"`
+		}
+		if err := json.Unmarshal(req.Params, &params); err != nil || params.Name == "" {
+			return nil, &rpcError{Code: codeInvalidParams, Message: "invalid tools/call params"}
+		}
+		for _, tool := range s.tools() {
+			if tool.Name != params.Name {
+				continue
+			}
+			result, err := tool.Handler(ctx, params.Arguments)
+			if err != nil {
+				result = ToolResult{Text: err.Error(), IsError: true}
+			}
+			payload := map[string]interface{}{
+				"content": []map[string]string{{"type": "text", "text": result.Text}},
+				"isError": result.IsError,
+			}
+			if result.Structured != nil {
+				payload["structuredContent"] = result.Structured
+			}
+			return payload, nil
+		}
+		return nil, &rpcError{Code: codeInvalidParams, Message: "unknown tool: " + params.Name}
+	}
+	return nil, &rpcError{Code: codeMethodNotFound, Message: "method not found: " + req.Method}
+}
+
+func encodeResponse(resp rpcResponse) []byte {
+	resp.JSONRPC = "2.0"
+	data, err := json.Marshal(resp)
+	if err != nil {
+		log.Printf("⚠️  [MCP] Failed to encode response: %v", err)
+		return nil
+	}
+	return data
+}
+
+// ServeHTTP 处理 Streamable HTTP 的 POST 请求；不提供服务端推送流（GET 返回 405）
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	data, err := io.ReadAll(io.LimitReader(r.Body, maxRequestBytes))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	resp := s.HandleMessage(r.Context(), data)
+	if resp == nil {
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(resp)
+}
+
+// ServeStdio 以换行分隔的 JSON-RPC 消息处理 stdio 传输；请求并发处理，响应按完成顺序写出
+func (s *Server) ServeStdio(ctx context.Context, in io.Reader, out io.Writer) error {
+	reader := bufio.NewReaderSize(in, 64*1024)
+	var writeMu sync.Mutex
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	for {
+		line, err := reader.ReadBytes('\n')
+		if line = bytes.TrimSpace(line); len(line) > 0 {
+			message := append([]byte(nil), line...)
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				resp := s.HandleMessage(ctx, message)
+				if resp == nil {
+					return
+				}
+				writeMu.Lock()
+				defer writeMu.Unlock()
+				out.Write(append(resp, '\n'))
+			}()
+		}
+		if err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package mcp_server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestServer_HandleMessage(t *testing.T) {
+	server := NewServer("test", "1.0", Tool{
+		Name: "echo",
+		Handler: func(ctx context.Context, args json.RawMessage) (ToolResult, error) {
+			var in struct {
+				Text string `json:"text"`
+			}
+			json.Unmarshal(args, &in)
+			if in.Text == "" {
+				return ToolResult{}, errors.New("text is required")
+			}
+			return ToolResult{Text: in.Text}, nil
+		},
+	})
+	ctx := context.Background()
+
+	var init struct {
+		Result struct {
+			ProtocolVersion string `json:"protocolVersion"`
+		} `json:"result"`
+	}
+	json.Unmarshal(server.HandleMessage(ctx, []byte(`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"2025-03-26"}}`)), &init)
+	if init.Result.ProtocolVersion != "2025-03-26" {
+		t.Errorf("supported client version should be echoed, got %q", init.Result.ProtocolVersion)
+	}
+
+	if resp := server.HandleMessage(ctx, []byte(`{"jsonrpc":"2.0","method":"notifications/initialized"}`)); resp != nil {
+		t.Errorf("notifications must not be answered, got %s", resp)
+	}
+
+	resp := string(server.HandleMessage(ctx, []byte(`{"jsonrpc":"2.0","id":"a","method":"tools/list"}`)))
+	if !strings.Contains(resp, `"name":"echo"`) || !strings.Contains(resp, `"inputSchema":{"type":"object"}`) {
+		t.Errorf("unexpected tools/list: %s", resp)
+	}
+
+	resp = string(server.HandleMessage(ctx, []byte(`{"jsonrpc":"2.0","id":2,"method":"tools/call","params":{"name":"echo","arguments":{"text":"hi"}}}`)))
+	if !strings.Contains(resp, `"text":"hi"`) || !strings.Contains(resp, `"isError":false`) {
+		t.Errorf("unexpected tools/call: %s", resp)
+	}
+	resp = string(server.HandleMessage(ctx, []byte(`{"jsonrpc":"2.0","id":3,"method":"tools/call","params":{"name":"echo","arguments":{}}}`)))
+	if !strings.Contains(resp, `"isError":true`) {
+		t.Errorf("handler errors should be tool errors: %s", resp)
+	}
+	resp = string(server.HandleMessage(ctx, []byte(`{"jsonrpc":"2.0","id":4,"method":"resources/list"}`)))
+	if !strings.Contains(resp, `"code":-32601`) {
+		t.Errorf("expected method not found: %s", resp)
+	}
+}
+
+func TestServer_ServeHTTP(t *testing.T) {
+	server := NewServer("test", "1.0")
+
+	rec := httptest.NewRecorder()
+	server.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/mcp", nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("GET should be rejected, got %d", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	server.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/mcp", strings.NewReader(`{"jsonrpc":"2.0","method":"notifications/initialized"}`)))
+	if rec.Code != http.StatusAccepted {
+		t.Errorf("notification should return 202, got %d", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	server.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/mcp", strings.NewReader(`{"jsonrpc":"2.0","id":1,"method":"ping"}`)))
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "application/json" {
+		t.Errorf("unexpected ping response: %d %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestServer_ServeStdio(t *testing.T) {
+	server := NewServer("test", "1.0")
+	in := strings.NewReader(`{"jsonrpc":"2.0","id":1,"method":"ping"}` + "\n" + `{"jsonrpc":"2.0","method":"notifications/initialized"}` + "\n")
+	var out strings.Builder
+	if err := server.ServeStdio(context.Background(), in, &out); err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != `{"jsonrpc":"2.0","id":1,"result":{}}`+"\n" {
+		t.Errorf("unexpected stdio output: %q", out.String())
+	}
+}
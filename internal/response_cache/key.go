@@ -0,0 +1,84 @@
+package response_cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"sort"
+	"strings"
+)
+
+// KeyInput 参与缓存键计算的请求字段
+type KeyInput struct {
+	CLI            string
+	Profile        string
+	Model          string
+	Prompt         string
+	System         string
+	Tools          []string
+	Skills         []string
+	PermissionMode string
+	SessionID      string
+	Steps          bool // 是否记录工具调用步骤（输出内容不同）
+}
+
+type normalizedKey struct {
+	CLI            string   `json:"cli"`
+	Profile        string   `json:"profile"`
+	Model          string   `json:"model"`
+	Prompt         string   `json:"prompt"`
+	System         string   `json:"system"`
+	Tools          []string `json:"tools"`
+	Skills         []string `json:"skills"`
+	PermissionMode string   `json:"permission_mode"`
+	SessionID      string   `json:"session_id,omitempty"`
+	Steps          bool     `json:"steps,omitempty"`
+}
+
+// Key 计算规范化后的 SHA-256 缓存键
+// 文本统一换行符并去除行尾空白，工具与 skills 去重排序，标识类字段不区分大小写
+func Key(in KeyInput) string {
+	normalized := normalizedKey{
+		CLI:            normalizeIdentifier(in.CLI),
+		Profile:        normalizeIdentifier(in.Profile),
+		Model:          normalizeIdentifier(in.Model),
+		Prompt:         normalizeText(in.Prompt),
+		System:         normalizeText(in.System),
+		Tools:          normalizeList(in.Tools),
+		Skills:         normalizeList(in.Skills),
+		PermissionMode: normalizeIdentifier(in.PermissionMode),
+		SessionID:      strings.TrimSpace(in.SessionID),
+		Steps:          in.Steps,
+	}
+	data, _ := json.Marshal(normalized)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func normalizeIdentifier(value string) string {
+	return strings.ToLower(strings.TrimSpace(value))
+}
+
+func normalizeText(value string) string {
+	value = strings.ReplaceAll(value, "\r\n", "\n")
+	lines := strings.Split(value, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(line, " \t")
+	}
+	return strings.TrimSpace(strings.Join(lines, "\n"))
+}
+
+func normalizeList(items []string) []string {
+	seen := make(map[string]bool, len(items))
+	result := make([]string, 0, len(items))
+	for _, item := range items {
+		trimmed := strings.TrimSpace(item)
+		if trimmed == "" || seen[trimmed] {
+			continue
+		}
+		seen[trimmed] = true
+		result = append(result, trimmed)
+	}
+	sort.Strings(result)
+	return result
+}
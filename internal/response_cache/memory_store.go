@@ -0,0 +1,246 @@
+package response_cache
+
+import (
+	"container/list"
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// entryOverhead 估算每个条目的固定内存开销（map 槽位、链表节点、结构体字段）
+const entryOverhead = 128
+
+type memoryItem struct {
+	key       string
+	value     string
+	size      int64
+	expiresAt time.Time
+	freq      int
+	elem      *list.Element
+}
+
+// MemoryStore 进程内缓存，按 MaxBytes 计算内存占用并按策略淘汰
+type MemoryStore struct {
+	mu        sync.Mutex
+	strategy  Strategy
+	maxBytes  int64
+	items     map[string]*memoryItem
+	order     *list.List         // lru / fifo：front 为最先淘汰
+	freqLists map[int]*list.List // lfu：按访问频次分桶，桶内 front 为最旧
+	minFreq   int
+	size      int64
+	hits      int64
+	misses    int64
+	evictions int64
+}
+
+// NewMemoryStore 创建内存缓存
+func NewMemoryStore(strategy Strategy, maxBytes int64) (*MemoryStore, error) {
+	parsed, err := ParseStrategy(string(strategy))
+	if err != nil {
+		return nil, err
+	}
+	if maxBytes <= 0 {
+		return nil, fmt.Errorf("max bytes must be positive")
+	}
+	return &MemoryStore{
+		strategy:  parsed,
+		maxBytes:  maxBytes,
+		items:     make(map[string]*memoryItem),
+		order:     list.New(),
+		freqLists: make(map[int]*list.List),
+	}, nil
+}
+
+func (s *MemoryStore) Strategy() Strategy {
+	return s.strategy
+}
+
+func (s *MemoryStore) MaxBytes() int64 {
+	return s.maxBytes
+}
+
+func (s *MemoryStore) Get(_ context.Context, key string) (string, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	item, ok := s.items[key]
+	if !ok {
+		s.misses++
+		return "", false, nil
+	}
+	if !item.expiresAt.IsZero() && time.Now().After(item.expiresAt) {
+		s.removeItem(item)
+		s.misses++
+		return "", false, nil
+	}
+
+	s.touch(item)
+	s.hits++
+	return item.value, true, nil
+}
+
+func (s *MemoryStore) Set(_ context.Context, key string, value string, ttl time.Duration) error {
+	size := int64(len(key)+len(value)) + entryOverhead
+	if size > s.maxBytes {
+		return fmt.Errorf("cache entry too large: %d bytes exceeds limit %d", size, s.maxBytes)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if existing, ok := s.items[key]; ok {
+		s.removeItem(existing)
+	}
+
+	if s.size+size > s.maxBytes {
+		s.purgeExpired()
+	}
+	for s.size+size > s.maxBytes && len(s.items) > 0 {
+		s.evictOne()
+	}
+
+	item := &memoryItem{
+		key:   key,
+		value: value,
+		size:  size,
+		freq:  1,
+	}
+	if ttl > 0 {
+		item.expiresAt = time.Now().Add(ttl)
+	}
+	s.insert(item)
+	return nil
+}
+
+func (s *MemoryStore) Delete(_ context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if item, ok := s.items[key]; ok {
+		s.removeItem(item)
+	}
+	return nil
+}
+
+func (s *MemoryStore) Clear(_ context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.items = make(map[string]*memoryItem)
+	s.order = list.New()
+	s.freqLists = make(map[int]*list.List)
+	s.minFreq = 0
+	s.size = 0
+	return nil
+}
+
+func (s *MemoryStore) Stats() Stats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return Stats{
+		Backend:   "memory",
+		Strategy:  string(s.strategy),
+		Entries:   len(s.items),
+		SizeBytes: s.size,
+		MaxBytes:  s.maxBytes,
+		Hits:      s.hits,
+		Misses:    s.misses,
+		Evictions: s.evictions,
+		HitRate:   hitRate(s.hits, s.misses),
+	}
+}
+
+func (s *MemoryStore) insert(item *memoryItem) {
+	s.items[item.key] = item
+	s.size += item.size
+	if s.strategy == StrategyLFU {
+		item.elem = s.freqList(item.freq).PushBack(item)
+		s.minFreq = item.freq
+		return
+	}
+	item.elem = s.order.PushBack(item)
+}
+
+func (s *MemoryStore) touch(item *memoryItem) {
+	switch s.strategy {
+	case StrategyLRU:
+		s.order.MoveToBack(item.elem)
+	case StrategyLFU:
+		current := s.freqLists[item.freq]
+		current.Remove(item.elem)
+		if current.Len() == 0 {
+			delete(s.freqLists, item.freq)
+			if s.minFreq == item.freq {
+				s.minFreq++
+			}
+		}
+		item.freq++
+		item.elem = s.freqList(item.freq).PushBack(item)
+	}
+}
+
+func (s *MemoryStore) removeItem(item *memoryItem) {
+	delete(s.items, item.key)
+	s.size -= item.size
+	if s.strategy == StrategyLFU {
+		if bucket, ok := s.freqLists[item.freq]; ok {
+			bucket.Remove(item.elem)
+			if bucket.Len() == 0 {
+				delete(s.freqLists, item.freq)
+			}
+		}
+		return
+	}
+	s.order.Remove(item.elem)
+}
+
+func (s *MemoryStore) evictOne() {
+	var victim *memoryItem
+	if s.strategy == StrategyLFU {
+		bucket, ok := s.freqLists[s.minFreq]
+		if !ok {
+			s.recomputeMinFreq()
+			bucket = s.freqLists[s.minFreq]
+		}
+		if bucket != nil && bucket.Front() != nil {
+			victim = bucket.Front().Value.(*memoryItem)
+		}
+	} else if front := s.order.Front(); front != nil {
+		victim = front.Value.(*memoryItem)
+	}
+	if victim == nil {
+		return
+	}
+	s.removeItem(victim)
+	s.evictions++
+}
+
+func (s *MemoryStore) purgeExpired() {
+	now := time.Now()
+	for _, item := range s.items {
+		if !item.expiresAt.IsZero() && now.After(item.expiresAt) {
+			s.removeItem(item)
+		}
+	}
+	if s.strategy == StrategyLFU {
+		s.recomputeMinFreq()
+	}
+}
+
+func (s *MemoryStore) recomputeMinFreq() {
+	s.minFreq = 0
+	for freq := range s.freqLists {
+		if s.minFreq == 0 || freq < s.minFreq {
+			s.minFreq = freq
+		}
+	}
+}
+
+func (s *MemoryStore) freqList(freq int) *list.List {
+	bucket, ok := s.freqLists[freq]
+	if !ok {
+		bucket = list.New()
+		s.freqLists[freq] = bucket
+	}
+	return bucket
+}
@@ -0,0 +1,137 @@
+package response_cache
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestStore(t *testing.T, strategy Strategy, entries int) *MemoryStore {
+	t.Helper()
+	// 每个条目 key=2 字节、value=10 字节
+	store, err := NewMemoryStore(strategy, int64(entries*(12+entryOverhead)))
+	if err != nil {
+		t.Fatalf("NewMemoryStore failed: %v", err)
+	}
+	return store
+}
+
+func setValue(t *testing.T, store *MemoryStore, key string) {
+	t.Helper()
+	if err := store.Set(context.Background(), key, strings.Repeat("v", 10), time.Minute); err != nil {
+		t.Fatalf("Set(%s) failed: %v", key, err)
+	}
+}
+
+func hasKey(store *MemoryStore, key string) bool {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	_, ok := store.items[key]
+	return ok
+}
+
+func TestMemoryStore_LRUEviction(t *testing.T) {
+	store := newTestStore(t, StrategyLRU, 2)
+	ctx := context.Background()
+
+	setValue(t, store, "k1")
+	setValue(t, store, "k2")
+	if _, found, _ := store.Get(ctx, "k1"); !found {
+		t.Fatal("expected k1 to be cached")
+	}
+	setValue(t, store, "k3")
+
+	if hasKey(store, "k2") {
+		t.Error("expected least recently used k2 to be evicted")
+	}
+	if !hasKey(store, "k1") || !hasKey(store, "k3") {
+		t.Error("expected k1 and k3 to remain")
+	}
+	if stats := store.Stats(); stats.Evictions != 1 || stats.Entries != 2 {
+		t.Errorf("unexpected stats: %+v", stats)
+	}
+}
+
+func TestMemoryStore_FIFOEviction(t *testing.T) {
+	store := newTestStore(t, StrategyFIFO, 2)
+
+	setValue(t, store, "k1")
+	setValue(t, store, "k2")
+	store.Get(context.Background(), "k1")
+	setValue(t, store, "k3")
+
+	if hasKey(store, "k1") {
+		t.Error("expected first inserted k1 to be evicted regardless of access")
+	}
+}
+
+func TestMemoryStore_LFUEviction(t *testing.T) {
+	store := newTestStore(t, StrategyLFU, 2)
+	ctx := context.Background()
+
+	setValue(t, store, "k1")
+	setValue(t, store, "k2")
+	store.Get(ctx, "k1")
+	store.Get(ctx, "k1")
+	store.Get(ctx, "k2")
+	setValue(t, store, "k3")
+
+	if hasKey(store, "k2") {
+		t.Error("expected least frequently used k2 to be evicted")
+	}
+	setValue(t, store, "k4")
+	if hasKey(store, "k3") {
+		t.Error("expected new entry k3 (freq 1) to be evicted before k1")
+	}
+	if !hasKey(store, "k1") {
+		t.Error("expected frequently used k1 to remain")
+	}
+}
+
+func TestMemoryStore_SizeAccountingAndTTL(t *testing.T) {
+	store := newTestStore(t, StrategyLRU, 2)
+	ctx := context.Background()
+
+	if err := store.Set(ctx, "big", strings.Repeat("x", 1<<12), time.Minute); err == nil {
+		t.Error("expected error for entry larger than limit")
+	}
+
+	if err := store.Set(ctx, "k1", strings.Repeat("v", 10), time.Millisecond); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	time.Sleep(5 * time.Millisecond)
+	if _, found, _ := store.Get(ctx, "k1"); found {
+		t.Error("expected expired entry to miss")
+	}
+
+	stats := store.Stats()
+	if stats.SizeBytes != 0 || stats.Misses != 1 {
+		t.Errorf("unexpected stats after expiry: %+v", stats)
+	}
+}
+
+func TestKey_Normalization(t *testing.T) {
+	base := Key(KeyInput{CLI: "claude", Profile: "glm", Prompt: "hello\r\nworld  ", Tools: []string{"b", "a"}})
+	same := Key(KeyInput{CLI: "Claude", Profile: "glm", Prompt: "hello\nworld", Tools: []string{"a", "b", "a"}})
+	if base != same {
+		t.Error("expected normalized keys to match")
+	}
+	if len(base) != 64 {
+		t.Errorf("expected sha256 hex key, got %q", base)
+	}
+
+	other := Key(KeyInput{CLI: "claude", Profile: "kimi", Prompt: "hello\nworld", Tools: []string{"a", "b"}})
+	if base == other {
+		t.Error("expected different profile to produce different key")
+	}
+}
+
+func TestParseStrategy(t *testing.T) {
+	if s, err := ParseStrategy(""); err != nil || s != StrategyLRU {
+		t.Errorf("expected default lru, got %v %v", s, err)
+	}
+	if _, err := ParseStrategy("random"); err == nil {
+		t.Error("expected error for unknown strategy")
+	}
+}
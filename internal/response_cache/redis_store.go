@@ -0,0 +1,90 @@
+package response_cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// RedisStore 基于 Redis 的共享缓存，适用于多副本部署
+// 淘汰由 Redis 的 TTL 与 maxmemory-policy 负责
+type RedisStore struct {
+	client *redis.Client
+	prefix string
+	hits   atomic.Int64
+	misses atomic.Int64
+}
+
+// NewRedisStore 创建 Redis 缓存存储
+func NewRedisStore(client *redis.Client, prefix string) (*RedisStore, error) {
+	if client == nil {
+		return nil, fmt.Errorf("redis client is required")
+	}
+	if prefix == "" {
+		prefix = defaultKeyPrefix
+	}
+	return &RedisStore{
+		client: client,
+		prefix: prefix,
+	}, nil
+}
+
+func (s *RedisStore) Get(ctx context.Context, key string) (string, bool, error) {
+	value, err := s.client.Get(ctx, s.redisKey(key)).Result()
+	if errors.Is(err, redis.Nil) {
+		s.misses.Add(1)
+		return "", false, nil
+	}
+	if err != nil {
+		s.misses.Add(1)
+		return "", false, err
+	}
+	s.hits.Add(1)
+	return value, true, nil
+}
+
+func (s *RedisStore) Set(ctx context.Context, key string, value string, ttl time.Duration) error {
+	return s.client.Set(ctx, s.redisKey(key), value, ttl).Err()
+}
+
+func (s *RedisStore) Delete(ctx context.Context, key string) error {
+	return s.client.Del(ctx, s.redisKey(key)).Err()
+}
+
+func (s *RedisStore) Clear(ctx context.Context) error {
+	var cursor uint64
+	for {
+		keys, next, err := s.client.Scan(ctx, cursor, s.prefix+":*", 200).Result()
+		if err != nil {
+			return err
+		}
+		if len(keys) > 0 {
+			if err := s.client.Del(ctx, keys...).Err(); err != nil {
+				return err
+			}
+		}
+		cursor = next
+		if cursor == 0 {
+			return nil
+		}
+	}
+}
+
+func (s *RedisStore) Stats() Stats {
+	hits := s.hits.Load()
+	misses := s.misses.Load()
+	return Stats{
+		Backend: "redis",
+		Hits:    hits,
+		Misses:  misses,
+		HitRate: hitRate(hits, misses),
+	}
+}
+
+func (s *RedisStore) redisKey(key string) string {
+	return s.prefix + ":" + key
+}
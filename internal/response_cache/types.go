@@ -0,0 +1,71 @@
+package response_cache
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Strategy 内存缓存淘汰策略
+type Strategy string
+
+const (
+	StrategyLRU  Strategy = "lru"
+	StrategyLFU  Strategy = "lfu"
+	StrategyFIFO Strategy = "fifo"
+)
+
+const (
+	defaultMaxSizeMB = 100
+	defaultKeyPrefix = "cli-gateway:cache"
+)
+
+// Store 响应缓存存储
+type Store interface {
+	Get(ctx context.Context, key string) (value string, found bool, err error)
+	Set(ctx context.Context, key string, value string, ttl time.Duration) error
+	Delete(ctx context.Context, key string) error
+	Clear(ctx context.Context) error
+	Stats() Stats
+}
+
+// Stats 缓存统计信息
+type Stats struct {
+	Backend   string  `json:"backend"`
+	Strategy  string  `json:"strategy,omitempty"`
+	Entries   int     `json:"entries"`
+	SizeBytes int64   `json:"size_bytes"`
+	MaxBytes  int64   `json:"max_bytes,omitempty"`
+	Hits      int64   `json:"hits"`
+	Misses    int64   `json:"misses"`
+	Evictions int64   `json:"evictions"`
+	HitRate   float64 `json:"hit_rate"`
+}
+
+// ParseStrategy 解析淘汰策略，空值默认为 LRU
+func ParseStrategy(value string) (Strategy, error) {
+	switch Strategy(value) {
+	case "":
+		return StrategyLRU, nil
+	case StrategyLRU, StrategyLFU, StrategyFIFO:
+		return Strategy(value), nil
+	default:
+		return "", fmt.Errorf("unsupported cache strategy: %s", value)
+	}
+}
+
+// MaxBytesFromMB 将 MB 上限转换为字节数，非正数使用默认值
+func MaxBytesFromMB(maxSizeMB int) int64 {
+	if maxSizeMB <= 0 {
+		maxSizeMB = defaultMaxSizeMB
+	}
+	return int64(maxSizeMB) << 20
+}
+
+func hitRate(hits int64, misses int64) float64 {
+	total := hits + misses
+	if total == 0 {
+		return 0
+	}
+	return float64(hits) / float64(total)
+}
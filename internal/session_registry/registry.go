@@ -0,0 +1,257 @@
+package session_registry
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Config 会话登记配置
+type Config struct {
+	StoragePath string        // 持久化文件，空表示仅保存在内存
+	IdleTTL     time.Duration // 空闲超过该时间的会话标记为 expired，<=0 表示不过期
+	Retention   time.Duration // expired/closed 会话保留时长（期间拒绝恢复），默认与 IdleTTL 相同，IdleTTL 也未设置时为 DefaultRetention
+}
+
+// DefaultRetention 会话不过期（IdleTTL <= 0）且未设置 Retention 时，关闭的会话记录的保留时长
+const DefaultRetention = 24 * time.Hour
+
+// Registry 记录网关创建/使用过的 CLI 会话
+type Registry struct {
+	mu       sync.Mutex
+	cfg      Config
+	sessions map[string]*Session
+	now      func() time.Time
+}
+
+// NewRegistry 创建登记表并加载持久化数据
+func NewRegistry(cfg Config) (*Registry, error) {
+	if cfg.Retention <= 0 {
+		cfg.Retention = cfg.IdleTTL
+	}
+	if cfg.Retention <= 0 {
+		cfg.Retention = DefaultRetention
+	}
+	r := &Registry{
+		cfg:      cfg,
+		sessions: make(map[string]*Session),
+		now:      time.Now,
+	}
+	if cfg.StoragePath == "" {
+		return r, nil
+	}
+
+	data, err := os.ReadFile(cfg.StoragePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return r, nil
+		}
+		return nil, fmt.Errorf("failed to read session registry: %v", err)
+	}
+	var sessions []*Session
+	if err := json.Unmarshal(data, &sessions); err != nil {
+		return nil, fmt.Errorf("failed to parse session registry: %v", err)
+	}
+	for _, s := range sessions {
+		if s != nil && s.ID != "" {
+			r.sessions[s.ID] = s
+		}
+	}
+	return r, nil
+}
+
+// Authorize 检查调用方是否可以恢复会话；未登记的会话放行
+func (r *Registry) Authorize(sessionID, cli, owner string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	s, ok := r.sessions[sessionID]
+	if !ok {
+		return nil
+	}
+	r.expireLocked(s, r.now())
+	switch {
+	case s.Status != StatusActive:
+		return fmt.Errorf("%w: %s is %s", ErrInactive, sessionID, s.Status)
+	case s.CLI != "" && cli != "" && s.CLI != cli:
+		return fmt.Errorf("%w: %s was created by %s", ErrCLIMismatch, sessionID, s.CLI)
+	case s.Owner != owner:
+		return fmt.Errorf("%w: %s", ErrOwnerMismatch, sessionID)
+	}
+	return nil
+}
+
+// Observe 记录一次会话调用（首次出现时登记创建者信息）
+func (r *Registry) Observe(obs Observation) (*Session, error) {
+	if obs.SessionID == "" {
+		return nil, nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := r.now()
+	s, ok := r.sessions[obs.SessionID]
+	if !ok {
+		s = &Session{
+			ID:        obs.SessionID,
+			CLI:       obs.CLI,
+			Profile:   obs.Profile,
+			Model:     obs.Model,
+			Owner:     obs.Owner,
+			Tenant:    obs.Tenant,
+			Workspace: obs.Workspace,
+			Endpoint:  obs.Endpoint,
+			ParentID:  obs.ParentID,
+			Status:    StatusActive,
+			CreatedAt: now,
+		}
+		r.sessions[obs.SessionID] = s
+	}
+	s.Turns++
+	s.CostUSD += obs.CostUSD
+	s.LastActivity = now
+	if s.Model == "" {
+		s.Model = obs.Model
+	}
+	if obs.WorkflowRun != "" {
+		s.WorkflowRun = obs.WorkflowRun
+	}
+	copied := *s
+	return &copied, r.saveLocked()
+}
+
+// Link 记录会话的来源（用于跨 CLI 转交），源会话同时记录转交去向
+func (r *Registry) Link(sessionID, parentID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	s, ok := r.sessions[sessionID]
+	if !ok {
+		return ErrNotFound
+	}
+	s.ParentID = parentID
+	if parent, ok := r.sessions[parentID]; ok {
+		parent.HandedOffTo = sessionID
+		if s.WorkflowRun == "" {
+			s.WorkflowRun = parent.WorkflowRun
+		}
+	}
+	return r.saveLocked()
+}
+
+// Get 返回会话副本
+func (r *Registry) Get(sessionID string) (*Session, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	s, ok := r.sessions[sessionID]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	r.expireLocked(s, r.now())
+	copied := *s
+	return &copied, nil
+}
+
+// List 按最近活动时间倒序返回会话
+func (r *Registry) List(filter Filter) []Session {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := r.now()
+	result := make([]Session, 0, len(r.sessions))
+	for _, s := range r.sessions {
+		r.expireLocked(s, now)
+		if !filter.AnyOwner && s.Owner != filter.Owner {
+			continue
+		}
+		if (filter.CLI != "" && s.CLI != filter.CLI) ||
+			(filter.Profile != "" && s.Profile != filter.Profile) ||
+			(filter.Status != "" && s.Status != filter.Status) {
+			continue
+		}
+		result = append(result, *s)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].LastActivity.After(result[j].LastActivity)
+	})
+	return result
+}
+
+// Close 关闭会话，之后不可再恢复
+func (r *Registry) Close(sessionID string) (*Session, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	s, ok := r.sessions[sessionID]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	if s.Status != StatusClosed {
+		now := r.now()
+		s.Status = StatusClosed
+		s.ClosedAt = &now
+	}
+	copied := *s
+	return &copied, r.saveLocked()
+}
+
+// Sweep 标记空闲超时的会话并清理超过保留期的记录，返回新过期与清理的数量
+func (r *Registry) Sweep() (expired int, purged int, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := r.now()
+	for id, s := range r.sessions {
+		if r.expireLocked(s, now) {
+			expired++
+		}
+		if s.Status != StatusActive && s.ClosedAt != nil && now.Sub(*s.ClosedAt) > r.cfg.Retention {
+			delete(r.sessions, id)
+			purged++
+		}
+	}
+	if expired > 0 || purged > 0 {
+		err = r.saveLocked()
+	}
+	return expired, purged, err
+}
+
+func (r *Registry) expireLocked(s *Session, now time.Time) bool {
+	if s.Status != StatusActive || r.cfg.IdleTTL <= 0 || now.Sub(s.LastActivity) <= r.cfg.IdleTTL {
+		return false
+	}
+	s.Status = StatusExpired
+	s.ClosedAt = &now
+	return true
+}
+
+func (r *Registry) saveLocked() error {
+	if r.cfg.StoragePath == "" {
+		return nil
+	}
+	sessions := make([]*Session, 0, len(r.sessions))
+	for _, s := range r.sessions {
+		sessions = append(sessions, s)
+	}
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].CreatedAt.Before(sessions[j].CreatedAt)
+	})
+	data, err := json.MarshalIndent(sessions, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(r.cfg.StoragePath), 0755); err != nil {
+		return fmt.Errorf("failed to create session registry dir: %v", err)
+	}
+	tmp := r.cfg.StoragePath + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to write session registry: %v", err)
+	}
+	if err := os.Rename(tmp, r.cfg.StoragePath); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to write session registry: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,112 @@
+package session_registry
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRegistry_ObserveAndAuthorize(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sessions.json")
+	r, err := NewRegistry(Config{StoragePath: path, IdleTTL: time.Hour})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if err := r.Authorize("unknown", "claude", "key-a"); err != nil {
+		t.Errorf("unknown sessions should be allowed, got %v", err)
+	}
+
+	r.Observe(Observation{SessionID: "s1", CLI: "claude", Profile: "default", Model: "sonnet", Owner: "key-a", CostUSD: 0.01})
+	s, _ := r.Observe(Observation{SessionID: "s1", CLI: "claude", Owner: "key-a", CostUSD: 0.02})
+	if s.Turns != 2 || s.CostUSD < 0.0299 || s.Model != "sonnet" {
+		t.Errorf("unexpected session after two turns: %+v", s)
+	}
+
+	if err := r.Authorize("s1", "claude", "key-a"); err != nil {
+		t.Errorf("creator should be allowed, got %v", err)
+	}
+	if err := r.Authorize("s1", "codex", "key-a"); !errors.Is(err, ErrCLIMismatch) {
+		t.Errorf("expected ErrCLIMismatch, got %v", err)
+	}
+	if err := r.Authorize("s1", "claude", "key-b"); !errors.Is(err, ErrOwnerMismatch) {
+		t.Errorf("expected ErrOwnerMismatch, got %v", err)
+	}
+	if err := r.Authorize("s1", "claude", ""); !errors.Is(err, ErrOwnerMismatch) {
+		t.Errorf("anonymous caller should not resume a keyed session, got %v", err)
+	}
+
+	reloaded, err := NewRegistry(Config{StoragePath: path, IdleTTL: time.Hour})
+	if err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+	if list := reloaded.List(Filter{Owner: "key-a"}); len(list) != 1 || list[0].Turns != 2 {
+		t.Errorf("unexpected sessions after reload: %+v", list)
+	}
+	if list := reloaded.List(Filter{Owner: "key-b"}); len(list) != 0 {
+		t.Errorf("other owners should not see the session: %+v", list)
+	}
+
+	if _, err := reloaded.Close("s1"); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	if err := reloaded.Authorize("s1", "claude", "key-a"); !errors.Is(err, ErrInactive) {
+		t.Errorf("closed session should be rejected, got %v", err)
+	}
+}
+
+func TestRegistry_IdleExpiry(t *testing.T) {
+	r, _ := NewRegistry(Config{IdleTTL: time.Minute})
+	now := time.Now()
+	r.now = func() time.Time { return now }
+	r.Observe(Observation{SessionID: "s1", CLI: "claude"})
+
+	now = now.Add(2 * time.Minute)
+	expired, purged, err := r.Sweep()
+	if err != nil || expired != 1 || purged != 0 {
+		t.Fatalf("sweep = %d/%d/%v, want 1/0/nil", expired, purged, err)
+	}
+	if err := r.Authorize("s1", "claude", ""); !errors.Is(err, ErrInactive) {
+		t.Errorf("expired session should be rejected, got %v", err)
+	}
+
+	now = now.Add(2 * time.Minute)
+	if _, purged, _ := r.Sweep(); purged != 1 {
+		t.Errorf("expired session should be purged after retention, purged=%d", purged)
+	}
+	if _, err := r.Get("s1"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound after purge, got %v", err)
+	}
+}
+
+func TestRegistry_ClosedSessionsPurgedWithoutIdleTTL(t *testing.T) {
+	r, _ := NewRegistry(Config{})
+	now := time.Now()
+	r.now = func() time.Time { return now }
+	r.Observe(Observation{SessionID: "s1", CLI: "claude"})
+	r.Close("s1")
+
+	now = now.Add(DefaultRetention + time.Minute)
+	if _, purged, _ := r.Sweep(); purged != 1 {
+		t.Errorf("closed session should be purged after the default retention, purged=%d", purged)
+	}
+}
+
+func TestRegistry_LinkHandoff(t *testing.T) {
+	r, _ := NewRegistry(Config{IdleTTL: time.Hour})
+	r.Observe(Observation{SessionID: "src", CLI: "codex", Owner: "key-a", WorkflowRun: "run-1"})
+	r.Observe(Observation{SessionID: "dst", CLI: "claude", Owner: "key-a"})
+
+	if err := r.Link("dst", "src"); err != nil {
+		t.Fatalf("link: %v", err)
+	}
+	src, _ := r.Get("src")
+	dst, _ := r.Get("dst")
+	if src.HandedOffTo != "dst" || dst.ParentID != "src" || dst.WorkflowRun != "run-1" {
+		t.Errorf("unexpected link result: src=%+v dst=%+v", src, dst)
+	}
+	if err := r.Link("missing", "src"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+}
@@ -0,0 +1,67 @@
+package session_registry
+
+import (
+	"errors"
+	"time"
+)
+
+// Status 会话状态
+type Status string
+
+const (
+	StatusActive  Status = "active"
+	StatusExpired Status = "expired" // 超过空闲时间，不可再恢复
+	StatusClosed  Status = "closed"  // 已被调用方删除，不可再恢复
+)
+
+var (
+	ErrNotFound      = errors.New("session not found")
+	ErrCLIMismatch   = errors.New("session was created by a different CLI")
+	ErrOwnerMismatch = errors.New("session belongs to a different API key")
+	ErrInactive      = errors.New("session is no longer active")
+)
+
+// Session 网关记录的 CLI 会话
+type Session struct {
+	ID           string     `json:"id"`
+	CLI          string     `json:"cli"`
+	Profile      string     `json:"profile,omitempty"`
+	Model        string     `json:"model,omitempty"`
+	Owner        string     `json:"owner,omitempty"` // 创建者 API Key 指纹，空表示匿名
+	Tenant       string     `json:"tenant,omitempty"`
+	Workspace    string     `json:"workspace,omitempty"`
+	Endpoint     string     `json:"endpoint,omitempty"`
+	Status       Status     `json:"status"`
+	Turns        int        `json:"turns"`
+	CostUSD      float64    `json:"cost_usd"`
+	CreatedAt    time.Time  `json:"created_at"`
+	LastActivity time.Time  `json:"last_activity"`
+	ClosedAt     *time.Time `json:"closed_at,omitempty"`
+	ParentID     string     `json:"parent_id,omitempty"`       // 由其他会话转交而来时的源会话
+	HandedOffTo  string     `json:"handed_off_to,omitempty"`   // 已转交到的新会话
+	WorkflowRun  string     `json:"workflow_run_id,omitempty"` // 关联的 workflow_run_id
+}
+
+// Observation 一次 CLI 调用结束后观察到的会话信息
+type Observation struct {
+	SessionID   string
+	CLI         string
+	Profile     string
+	Model       string
+	Owner       string
+	Tenant      string
+	Workspace   string
+	Endpoint    string
+	CostUSD     float64
+	ParentID    string
+	WorkflowRun string // 关联的 workflow_run_id（为空不覆盖已有值）
+}
+
+// Filter 列表筛选条件（空字段不筛选）
+type Filter struct {
+	Owner    string
+	AnyOwner bool // 为 true 时忽略 Owner（后台使用）
+	CLI      string
+	Profile  string
+	Status   Status
+}
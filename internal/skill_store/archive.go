@@ -0,0 +1,199 @@
+package skill_store
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Limits 上传包限制
+type Limits struct {
+	MaxBundleBytes    int64 // 上传包大小
+	MaxExtractedBytes int64 // 解压后总大小
+	MaxFiles          int   // 文件数量
+}
+
+// DefaultLimits 默认限制
+var DefaultLimits = Limits{
+	MaxBundleBytes:    20 << 20,
+	MaxExtractedBytes: 100 << 20,
+	MaxFiles:          1000,
+}
+
+// extractor 将归档条目写入 dest，拒绝路径穿越、符号链接与特殊文件
+type extractor struct {
+	dest   string
+	limits Limits
+	files  int
+	size   int64
+}
+
+// extractBundle 按魔数识别 zip / tar / tar.gz 并解压到 dest
+func extractBundle(data []byte, dest string, limits Limits) (int, int64, error) {
+	e := &extractor{dest: dest, limits: limits}
+	var err error
+	switch {
+	case bytes.HasPrefix(data, []byte("PK\x03\x04")):
+		err = e.extractZip(data)
+	case bytes.HasPrefix(data, []byte{0x1f, 0x8b}):
+		var gz *gzip.Reader
+		if gz, err = gzip.NewReader(bytes.NewReader(data)); err == nil {
+			err = e.extractTar(gz)
+		}
+	case len(data) > 262 && string(data[257:262]) == "ustar":
+		err = e.extractTar(bytes.NewReader(data))
+	default:
+		err = fmt.Errorf("unsupported archive format (expected zip, tar or tar.gz)")
+	}
+	if err != nil {
+		return 0, 0, fmt.Errorf("%w: %v", ErrInvalidBundle, err)
+	}
+	return e.files, e.size, nil
+}
+
+func (e *extractor) extractZip(data []byte) error {
+	reader, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return err
+	}
+	for _, file := range reader.File {
+		mode := file.Mode()
+		if mode.IsDir() {
+			if _, err := e.target(file.Name, true); err != nil {
+				return err
+			}
+			continue
+		}
+		if !mode.IsRegular() {
+			return fmt.Errorf("%s: only regular files are allowed (symlinks and special files are rejected)", file.Name)
+		}
+		rc, err := file.Open()
+		if err != nil {
+			return err
+		}
+		err = e.writeFile(file.Name, rc, mode.Perm())
+		rc.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (e *extractor) extractTar(r io.Reader) error {
+	reader := tar.NewReader(r)
+	for {
+		header, err := reader.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		switch header.Typeflag {
+		case tar.TypeDir:
+			if _, err := e.target(header.Name, true); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if err := e.writeFile(header.Name, reader, os.FileMode(header.Mode).Perm()); err != nil {
+				return err
+			}
+		case tar.TypeXGlobalHeader:
+		default:
+			return fmt.Errorf("%s: only regular files are allowed (symlinks and special files are rejected)", header.Name)
+		}
+	}
+}
+
+// target 校验条目路径并返回 dest 下的绝对路径；需忽略的条目（如 __MACOSX）返回空路径
+func (e *extractor) target(name string, dir bool) (string, error) {
+	cleaned := path.Clean(strings.ReplaceAll(name, "\\", "/"))
+	if cleaned == "." {
+		return "", nil
+	}
+	if path.IsAbs(cleaned) || cleaned == ".." || strings.HasPrefix(cleaned, "../") || strings.Contains(cleaned, ":") {
+		return "", fmt.Errorf("%s: path escapes the bundle", name)
+	}
+	if strings.HasPrefix(cleaned, "__MACOSX/") || cleaned == "__MACOSX" || path.Base(cleaned) == ".DS_Store" {
+		return "", nil
+	}
+
+	target := filepath.Join(e.dest, filepath.FromSlash(cleaned))
+	if rel, err := filepath.Rel(e.dest, target); err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("%s: path escapes the bundle", name)
+	}
+	parent := target
+	if !dir {
+		parent = filepath.Dir(target)
+	}
+	if err := os.MkdirAll(parent, 0o755); err != nil {
+		return "", err
+	}
+	return target, nil
+}
+
+func (e *extractor) writeFile(name string, r io.Reader, perm os.FileMode) error {
+	target, err := e.target(name, false)
+	if err != nil || target == "" {
+		return err
+	}
+	e.files++
+	if e.limits.MaxFiles > 0 && e.files > e.limits.MaxFiles {
+		return fmt.Errorf("bundle contains more than %d files", e.limits.MaxFiles)
+	}
+
+	mode := os.FileMode(0o644)
+	if perm&0o111 != 0 {
+		mode = 0o755
+	}
+	// O_EXCL：重复条目不能覆盖已写入的文件
+	file, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_EXCL, mode)
+	if err != nil {
+		return fmt.Errorf("%s: %v", name, err)
+	}
+	remaining := e.limits.MaxExtractedBytes - e.size
+	written, err := io.Copy(file, io.LimitReader(r, remaining+1))
+	closeErr := file.Close()
+	e.size += written
+	if err != nil {
+		return err
+	}
+	if closeErr != nil {
+		return closeErr
+	}
+	if e.size > e.limits.MaxExtractedBytes {
+		return fmt.Errorf("bundle exceeds %d bytes when extracted", e.limits.MaxExtractedBytes)
+	}
+	return nil
+}
+
+// skillRoot 返回包含 SKILL.md 的目录（支持包内仅有一个顶层目录的打包方式）
+func skillRoot(dir string) (string, error) {
+	if fileExists(filepath.Join(dir, "SKILL.md")) {
+		return dir, nil
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return "", err
+	}
+	if len(entries) == 1 && entries[0].IsDir() {
+		nested := filepath.Join(dir, entries[0].Name())
+		if fileExists(filepath.Join(nested, "SKILL.md")) {
+			return nested, nil
+		}
+	}
+	return "", fmt.Errorf("%w: SKILL.md not found at the bundle root", ErrInvalidBundle)
+}
+
+func fileExists(path string) bool {
+	info, err := os.Lstat(path)
+	return err == nil && info.Mode().IsRegular()
+}
@@ -0,0 +1,74 @@
+package skill_store
+
+import (
+	"bufio"
+	"fmt"
+	"strings"
+)
+
+// Frontmatter SKILL.md 头部的 YAML 元数据（仅支持单行 key: value）
+type Frontmatter struct {
+	Name        string
+	Description string
+	Metadata    map[string]string
+}
+
+// ParseFrontmatter 解析并校验 SKILL.md 的 frontmatter（name、description 必填）
+func ParseFrontmatter(content string) (Frontmatter, error) {
+	fm := Frontmatter{Metadata: map[string]string{}}
+	scanner := bufio.NewScanner(strings.NewReader(strings.TrimPrefix(content, "\uFEFF")))
+	if !scanner.Scan() || strings.TrimSpace(scanner.Text()) != "---" {
+		return fm, fmt.Errorf("SKILL.md must start with a --- frontmatter block")
+	}
+
+	closed := false
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.TrimSpace(line) == "---" {
+			closed = true
+			break
+		}
+		if strings.TrimSpace(line) == "" || strings.HasPrefix(strings.TrimSpace(line), "#") {
+			continue
+		}
+		key, value, ok := strings.Cut(line, ":")
+		if !ok || strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t") {
+			// 嵌套或多行值不解析，保留给 CLI 自行处理
+			continue
+		}
+		key = strings.TrimSpace(key)
+		value = unquoteYAML(strings.TrimSpace(value))
+		switch key {
+		case "name":
+			fm.Name = value
+		case "description":
+			fm.Description = value
+		default:
+			if value != "" {
+				fm.Metadata[key] = value
+			}
+		}
+	}
+	if !closed {
+		return fm, fmt.Errorf("SKILL.md frontmatter is not closed")
+	}
+	if err := ValidateName(fm.Name); err != nil {
+		return fm, err
+	}
+	if fm.Description == "" {
+		return fm, fmt.Errorf("description is required")
+	}
+	if len(fm.Description) > maxDescriptionLen {
+		return fm, fmt.Errorf("description exceeds %d characters", maxDescriptionLen)
+	}
+	return fm, nil
+}
+
+func unquoteYAML(value string) string {
+	if len(value) >= 2 {
+		if (value[0] == '"' && value[len(value)-1] == '"') || (value[0] == '\'' && value[len(value)-1] == '\'') {
+			return value[1 : len(value)-1]
+		}
+	}
+	return value
+}
@@ -0,0 +1,327 @@
+package skill_store
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	metadataFile       = "skill.json"
+	defaultMaxVersions = 10
+)
+
+// Store 管理 skills 根目录：<root>/<name>/v<N>/ 为各版本内容，<root>/<name>/skill.json 为元数据
+type Store struct {
+	mu          sync.Mutex
+	root        string
+	limits      Limits
+	maxVersions int
+	skills      map[string]*Skill
+	refs        func(name string) []Ref
+}
+
+// NewStore 创建存储并加载已有 skills；maxVersions 为每个 skill 保留的版本数
+func NewStore(root string, limits Limits, maxVersions int) (*Store, error) {
+	if root == "" {
+		return nil, fmt.Errorf("skills root is required")
+	}
+	absRoot, err := filepath.Abs(root)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(absRoot, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create skills root: %v", err)
+	}
+	// 根目录本身可能是符号链接，统一使用解析后的真实路径做包含校验
+	if absRoot, err = filepath.EvalSymlinks(absRoot); err != nil {
+		return nil, err
+	}
+	if limits.MaxBundleBytes <= 0 {
+		limits.MaxBundleBytes = DefaultLimits.MaxBundleBytes
+	}
+	if limits.MaxExtractedBytes <= 0 {
+		limits.MaxExtractedBytes = DefaultLimits.MaxExtractedBytes
+	}
+	if limits.MaxFiles <= 0 {
+		limits.MaxFiles = DefaultLimits.MaxFiles
+	}
+	if maxVersions <= 0 {
+		maxVersions = defaultMaxVersions
+	}
+
+	s := &Store{root: absRoot, limits: limits, maxVersions: maxVersions, skills: map[string]*Skill{}}
+	entries, err := os.ReadDir(absRoot)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read skills root: %v", err)
+	}
+	for _, entry := range entries {
+		if !entry.IsDir() || ValidateName(entry.Name()) != nil {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(absRoot, entry.Name(), metadataFile))
+		if err != nil {
+			continue
+		}
+		var skill Skill
+		if err := json.Unmarshal(data, &skill); err != nil || skill.Name != entry.Name() {
+			log.Printf("⚠️  [Skills] Skipping invalid metadata for %s", entry.Name())
+			continue
+		}
+		s.skills[skill.Name] = &skill
+	}
+	return s, nil
+}
+
+// SetRefsFunc 设置查询 profile skill_refs 的回调：被引用固定的版本不会被清理，仍被引用的 skill 不能删除
+func (s *Store) SetRefsFunc(refs func(name string) []Ref) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.refs = refs
+}
+
+// refsTo 返回指向该 skill 的引用（未设置回调时为空）
+func (s *Store) refsTo(name string) []Ref {
+	if s.refs == nil {
+		return nil
+	}
+	return s.refs(name)
+}
+
+// Root 返回 skills 根目录
+func (s *Store) Root() string {
+	return s.root
+}
+
+// Install 解压上传包、校验 SKILL.md 并保存为新版本（同名 skill 递增版本号并启用）
+func (s *Store) Install(r io.Reader, uploadedBy string) (Skill, Version, error) {
+	data, err := io.ReadAll(io.LimitReader(r, s.limits.MaxBundleBytes+1))
+	if err != nil {
+		return Skill{}, Version{}, err
+	}
+	if int64(len(data)) > s.limits.MaxBundleBytes {
+		return Skill{}, Version{}, fmt.Errorf("%w: bundle exceeds %d bytes", ErrInvalidBundle, s.limits.MaxBundleBytes)
+	}
+
+	staging, err := os.MkdirTemp(s.root, ".upload-")
+	if err != nil {
+		return Skill{}, Version{}, err
+	}
+	defer os.RemoveAll(staging)
+
+	files, size, err := extractBundle(data, staging, s.limits)
+	if err != nil {
+		return Skill{}, Version{}, err
+	}
+	dir, err := skillRoot(staging)
+	if err != nil {
+		return Skill{}, Version{}, err
+	}
+	content, err := os.ReadFile(filepath.Join(dir, "SKILL.md"))
+	if err != nil {
+		return Skill{}, Version{}, err
+	}
+	fm, err := ParseFrontmatter(string(content))
+	if err != nil {
+		return Skill{}, Version{}, fmt.Errorf("%w: %v", ErrInvalidBundle, err)
+	}
+
+	sum := sha256.Sum256(data)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	skill := s.skills[fm.Name]
+	if skill == nil {
+		skill = &Skill{Name: fm.Name}
+	}
+	next := 1
+	for _, v := range skill.Versions {
+		if v.Version >= next {
+			next = v.Version + 1
+		}
+	}
+	version := Version{
+		Version:     next,
+		Description: fm.Description,
+		Metadata:    fm.Metadata,
+		SHA256:      hex.EncodeToString(sum[:]),
+		Size:        size,
+		Files:       files,
+		UploadedAt:  time.Now(),
+		UploadedBy:  uploadedBy,
+	}
+
+	if err := os.MkdirAll(filepath.Join(s.root, fm.Name), 0o755); err != nil {
+		return Skill{}, Version{}, err
+	}
+	if err := os.Rename(dir, s.versionDir(fm.Name, next)); err != nil {
+		return Skill{}, Version{}, fmt.Errorf("failed to store skill: %v", err)
+	}
+
+	updated := *skill
+	updated.Versions = append(append([]Version{}, skill.Versions...), version)
+	updated.ActiveVersion = next
+	updated.Description = fm.Description
+	updated.UpdatedAt = version.UploadedAt
+	removed := s.pruneVersions(&updated)
+	if err := s.saveLocked(&updated); err != nil {
+		os.RemoveAll(s.versionDir(fm.Name, next))
+		return Skill{}, Version{}, err
+	}
+	for _, old := range removed {
+		os.RemoveAll(s.versionDir(fm.Name, old))
+	}
+	s.skills[fm.Name] = &updated
+	log.Printf("📚 [Skills] Installed %s v%d (%d files, %d bytes)", fm.Name, next, files, size)
+	return cloneSkill(&updated), version, nil
+}
+
+// pruneVersions 超过保留数量时删除最旧的、既不是当前版本也未被 skill_refs 固定的版本，返回被删除的版本号
+func (s *Store) pruneVersions(skill *Skill) []int {
+	pinned := map[int]bool{skill.ActiveVersion: true}
+	for _, ref := range s.refsTo(skill.Name) {
+		pinned[ref.Version] = true
+	}
+	var removed []int
+	for len(skill.Versions) > s.maxVersions {
+		index := -1
+		for i, v := range skill.Versions {
+			if !pinned[v.Version] {
+				index = i
+				break
+			}
+		}
+		if index < 0 {
+			break
+		}
+		removed = append(removed, skill.Versions[index].Version)
+		skill.Versions = append(skill.Versions[:index:index], skill.Versions[index+1:]...)
+	}
+	return removed
+}
+
+// List 返回所有 skills（按名称排序）
+func (s *Store) List() []Skill {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	skills := make([]Skill, 0, len(s.skills))
+	for _, skill := range s.skills {
+		skills = append(skills, cloneSkill(skill))
+	}
+	sort.Slice(skills, func(i, j int) bool { return skills[i].Name < skills[j].Name })
+	return skills
+}
+
+// Get 返回单个 skill
+func (s *Store) Get(name string) (Skill, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	skill, ok := s.skills[name]
+	if !ok {
+		return Skill{}, fmt.Errorf("%w: %s", ErrNotFound, name)
+	}
+	return cloneSkill(skill), nil
+}
+
+// Activate 切换当前版本（用于回滚）
+func (s *Store) Activate(name string, version int) (Skill, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	skill, ok := s.skills[name]
+	if !ok {
+		return Skill{}, fmt.Errorf("%w: %s", ErrNotFound, name)
+	}
+	updated := cloneSkill(skill)
+	updated.ActiveVersion = version
+	active, ok := updated.Active()
+	if !ok {
+		return Skill{}, fmt.Errorf("%w: %s@%d", ErrNotFound, name, version)
+	}
+	updated.Description = active.Description
+	updated.UpdatedAt = time.Now()
+	if err := s.saveLocked(&updated); err != nil {
+		return Skill{}, err
+	}
+	s.skills[name] = &updated
+	log.Printf("📚 [Skills] Activated %s v%d", name, version)
+	return cloneSkill(&updated), nil
+}
+
+// Delete 删除 skill 及其所有版本；仍被 profile 引用时返回 ErrInUse
+func (s *Store) Delete(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.skills[name]; !ok {
+		return fmt.Errorf("%w: %s", ErrNotFound, name)
+	}
+	if len(s.refsTo(name)) > 0 {
+		return fmt.Errorf("%w: %s", ErrInUse, name)
+	}
+	if err := os.RemoveAll(filepath.Join(s.root, name)); err != nil {
+		return err
+	}
+	delete(s.skills, name)
+	log.Printf("🗑️  [Skills] Deleted %s", name)
+	return nil
+}
+
+// Resolve 将引用解析为版本目录的绝对路径，并确认解析符号链接后仍位于根目录内
+func (s *Store) Resolve(value string) (string, error) {
+	ref, err := ParseRef(value)
+	if err != nil {
+		return "", err
+	}
+	s.mu.Lock()
+	skill, ok := s.skills[ref.Name]
+	version := ref.Version
+	if ok && version == 0 {
+		version = skill.ActiveVersion
+	}
+	s.mu.Unlock()
+	if !ok {
+		return "", fmt.Errorf("%w: %s", ErrNotFound, ref.Name)
+	}
+
+	dir := s.versionDir(ref.Name, version)
+	real, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		return "", fmt.Errorf("%w: %s", ErrNotFound, value)
+	}
+	if rel, err := filepath.Rel(s.root, real); err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("skill %s resolves outside the skills root", value)
+	}
+	return real, nil
+}
+
+func (s *Store) versionDir(name string, version int) string {
+	return filepath.Join(s.root, name, "v"+strconv.Itoa(version))
+}
+
+func (s *Store) saveLocked(skill *Skill) error {
+	data, err := json.MarshalIndent(skill, "", "  ")
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(s.root, skill.Name, metadataFile)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return fmt.Errorf("failed to save skill metadata: %v", err)
+	}
+	return os.Rename(tmp, path)
+}
+
+func cloneSkill(skill *Skill) Skill {
+	copied := *skill
+	copied.Versions = append([]Version(nil), skill.Versions...)
+	return copied
+}
@@ -0,0 +1,202 @@
+package skill_store
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const skillMD = "---\nname: pdf-tools\ndescription: \"Extract text from PDFs\"\nlicense: MIT\n---\n# PDF tools\n"
+
+type entry struct {
+	name, body string
+	typeflag   byte
+}
+
+func zipBundle(t *testing.T, entries ...entry) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, e := range entries {
+		header := &zip.FileHeader{Name: e.name, Method: zip.Deflate}
+		header.SetMode(0o644)
+		if e.typeflag == tar.TypeSymlink {
+			header.SetMode(os.ModeSymlink | 0o777)
+		}
+		w, err := zw.CreateHeader(header)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte(e.body))
+	}
+	zw.Close()
+	return buf.Bytes()
+}
+
+func tarGzBundle(t *testing.T, entries ...entry) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for _, e := range entries {
+		header := &tar.Header{Name: e.name, Mode: 0o644, Size: int64(len(e.body)), Typeflag: tar.TypeReg}
+		if e.typeflag != 0 {
+			header.Typeflag, header.Size, header.Linkname = e.typeflag, 0, e.body
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			t.Fatal(err)
+		}
+		if header.Typeflag == tar.TypeReg {
+			tw.Write([]byte(e.body))
+		}
+	}
+	tw.Close()
+	gz.Close()
+	return buf.Bytes()
+}
+
+func TestStore_InstallVersionsAndRollback(t *testing.T) {
+	root := t.TempDir()
+	store, err := NewStore(root, Limits{}, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	skill, v1, err := store.Install(bytes.NewReader(zipBundle(t,
+		entry{name: "pdf-tools/SKILL.md", body: skillMD},
+		entry{name: "pdf-tools/scripts/extract.py", body: "print('hi')"},
+		entry{name: "__MACOSX/pdf-tools/._SKILL.md", body: "junk"},
+	)), "key-a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if skill.Name != "pdf-tools" || skill.ActiveVersion != 1 || v1.Files != 2 || v1.Metadata["license"] != "MIT" || v1.Description != "Extract text from PDFs" {
+		t.Fatalf("unexpected install result: %+v %+v", skill, v1)
+	}
+	path, err := store.Resolve("pdf-tools")
+	if err != nil || !fileExists(filepath.Join(path, "scripts", "extract.py")) {
+		t.Fatalf("bundle should be stored with the top-level directory stripped: %s %v", path, err)
+	}
+
+	v2md := strings.Replace(skillMD, "Extract text from PDFs", "Extract and summarize PDFs", 1)
+	store.Install(bytes.NewReader(tarGzBundle(t, entry{name: "SKILL.md", body: v2md})), "key-a")
+	skill, _, err = store.Install(bytes.NewReader(tarGzBundle(t, entry{name: "SKILL.md", body: v2md})), "key-b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if skill.ActiveVersion != 3 || len(skill.Versions) != 2 || skill.Versions[0].Version != 2 {
+		t.Errorf("oldest version beyond max_versions should be pruned: %+v", skill.Versions)
+	}
+	if _, err := os.Stat(filepath.Join(root, "pdf-tools", "v1")); !os.IsNotExist(err) {
+		t.Error("pruned version directory should be removed")
+	}
+
+	if _, err := store.Activate("pdf-tools", 1); !errors.Is(err, ErrNotFound) {
+		t.Errorf("pruned version cannot be activated: %v", err)
+	}
+	skill, err = store.Activate("pdf-tools", 2)
+	if err != nil || skill.ActiveVersion != 2 || skill.Description != "Extract and summarize PDFs" {
+		t.Fatalf("rollback failed: %+v %v", skill, err)
+	}
+	if pinned, _ := store.Resolve("pdf-tools@3"); filepath.Base(pinned) != "v3" {
+		t.Errorf("pinned reference should resolve to v3, got %s", pinned)
+	}
+
+	reloaded, err := NewStore(root, Limits{}, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := reloaded.Get("pdf-tools"); err != nil || got.ActiveVersion != 2 {
+		t.Errorf("metadata should survive a restart: %+v %v", got, err)
+	}
+	if entries, _ := os.ReadDir(root); len(entries) != 1 {
+		t.Errorf("staging directories should be cleaned up: %v", entries)
+	}
+}
+
+func TestStore_KeepsPinnedVersionsAndRefusesDeleteInUse(t *testing.T) {
+	store, err := NewStore(t.TempDir(), Limits{}, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var refs []Ref
+	store.SetRefsFunc(func(name string) []Ref { return refs })
+	refs = []Ref{{Name: "pdf-tools", Version: 1}}
+
+	bundle := tarGzBundle(t, entry{name: "SKILL.md", body: skillMD})
+	for i := 0; i < 3; i++ {
+		if _, _, err := store.Install(bytes.NewReader(bundle), "key-a"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	skill, _ := store.Get("pdf-tools")
+	if len(skill.Versions) != 2 || skill.Versions[0].Version != 1 || skill.ActiveVersion != 3 {
+		t.Errorf("pinned v1 and the active version should be kept: %+v", skill.Versions)
+	}
+	if _, err := store.Resolve("pdf-tools@1"); err != nil {
+		t.Errorf("pinned version should still resolve: %v", err)
+	}
+
+	if err := store.Delete("pdf-tools"); !errors.Is(err, ErrInUse) {
+		t.Errorf("referenced skill must not be deleted: %v", err)
+	}
+	refs = nil
+	if err := store.Delete("pdf-tools"); err != nil {
+		t.Errorf("unreferenced skill should be deleted: %v", err)
+	}
+}
+
+func TestStore_RejectsUnsafeBundles(t *testing.T) {
+	store, err := NewStore(t.TempDir(), Limits{MaxFiles: 3}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := map[string][]byte{
+		"traversal":      tarGzBundle(t, entry{name: "SKILL.md", body: skillMD}, entry{name: "../../etc/cron.d/x", body: "x"}),
+		"absolute":       zipBundle(t, entry{name: "SKILL.md", body: skillMD}, entry{name: "/tmp/x", body: "x"}),
+		"tar symlink":    tarGzBundle(t, entry{name: "SKILL.md", body: skillMD}, entry{name: "secrets", body: "/etc", typeflag: tar.TypeSymlink}),
+		"zip symlink":    zipBundle(t, entry{name: "SKILL.md", body: skillMD}, entry{name: "link", body: "../../configs.json", typeflag: tar.TypeSymlink}),
+		"hardlink":       tarGzBundle(t, entry{name: "SKILL.md", body: skillMD}, entry{name: "h", body: "/etc/passwd", typeflag: tar.TypeLink}),
+		"no SKILL.md":    zipBundle(t, entry{name: "README.md", body: "x"}),
+		"bad name":       zipBundle(t, entry{name: "SKILL.md", body: "---\nname: ../evil\ndescription: x\n---\n"}),
+		"no description": zipBundle(t, entry{name: "SKILL.md", body: "---\nname: ok\n---\n"}),
+		"too many files": zipBundle(t, entry{name: "SKILL.md", body: skillMD}, entry{name: "a"}, entry{name: "b"}, entry{name: "c"}),
+		"duplicate":      tarGzBundle(t, entry{name: "SKILL.md", body: skillMD}, entry{name: "SKILL.md", body: skillMD}),
+		"not an archive": []byte("hello"),
+	}
+	for name, bundle := range cases {
+		if _, _, err := store.Install(bytes.NewReader(bundle), ""); !errors.Is(err, ErrInvalidBundle) {
+			t.Errorf("%s: expected ErrInvalidBundle, got %v", name, err)
+		}
+	}
+	if len(store.List()) != 0 {
+		t.Errorf("rejected bundles must not be stored: %+v", store.List())
+	}
+}
+
+func TestStore_ResolveRejectsSymlinkEscape(t *testing.T) {
+	root := t.TempDir()
+	store, _ := NewStore(root, Limits{}, 0)
+	if _, _, err := store.Install(bytes.NewReader(zipBundle(t, entry{name: "SKILL.md", body: skillMD})), ""); err != nil {
+		t.Fatal(err)
+	}
+	versionDir := filepath.Join(store.Root(), "pdf-tools", "v1")
+	os.RemoveAll(versionDir)
+	if err := os.Symlink(t.TempDir(), versionDir); err != nil {
+		t.Skip(err)
+	}
+	if _, err := store.Resolve("pdf-tools"); err == nil || !strings.Contains(err.Error(), "outside") {
+		t.Errorf("symlinked version outside the root must be rejected: %v", err)
+	}
+	for _, ref := range []string{"../x", "pdf-tools@0", "pdf-tools@latest", "Pdf"} {
+		if _, err := store.Resolve(ref); !errors.Is(err, ErrInvalidRef) {
+			t.Errorf("%s: expected ErrInvalidRef, got %v", ref, err)
+		}
+	}
+}
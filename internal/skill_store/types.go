@@ -0,0 +1,85 @@
+package skill_store
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrNotFound      = errors.New("skill not found")
+	ErrInvalidBundle = errors.New("invalid skill bundle")
+	ErrInvalidRef    = errors.New("invalid skill reference")
+	ErrInUse         = errors.New("skill is referenced by profiles")
+)
+
+// namePattern skill 名称：小写字母、数字与连字符（与 SKILL.md 规范一致）
+var namePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{0,63}$`)
+
+// maxDescriptionLen description 最大长度
+const maxDescriptionLen = 1024
+
+// Version 一次上传产生的版本
+type Version struct {
+	Version     int               `json:"version"`
+	Description string            `json:"description"`
+	Metadata    map[string]string `json:"metadata,omitempty"` // frontmatter 中的其他字段
+	SHA256      string            `json:"sha256"`             // 上传包摘要
+	Size        int64             `json:"size"`               // 解压后总大小
+	Files       int               `json:"files"`
+	UploadedAt  time.Time         `json:"uploaded_at"`
+	UploadedBy  string            `json:"uploaded_by,omitempty"`
+}
+
+// Skill 一个受管 skill 及其版本历史
+type Skill struct {
+	Name          string    `json:"name"`
+	Description   string    `json:"description"` // 当前版本的 description
+	ActiveVersion int       `json:"active_version"`
+	Versions      []Version `json:"versions"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+// Active 返回当前启用的版本
+func (s *Skill) Active() (Version, bool) {
+	for _, v := range s.Versions {
+		if v.Version == s.ActiveVersion {
+			return v, true
+		}
+	}
+	return Version{}, false
+}
+
+// Ref profile 中对 skill 的引用："name" 使用当前版本，"name@3" 固定版本
+type Ref struct {
+	Name    string
+	Version int // 0 表示当前版本
+}
+
+// ParseRef 解析 skill 引用
+func ParseRef(value string) (Ref, error) {
+	name, version, pinned := strings.Cut(strings.TrimSpace(value), "@")
+	if err := ValidateName(name); err != nil {
+		return Ref{}, fmt.Errorf("%w: %s", ErrInvalidRef, value)
+	}
+	ref := Ref{Name: name}
+	if pinned {
+		n, err := strconv.Atoi(version)
+		if err != nil || n <= 0 {
+			return Ref{}, fmt.Errorf("%w: %s", ErrInvalidRef, value)
+		}
+		ref.Version = n
+	}
+	return ref, nil
+}
+
+// ValidateName 校验 skill 名称
+func ValidateName(name string) error {
+	if !namePattern.MatchString(name) {
+		return fmt.Errorf("name must be lowercase letters, digits and hyphens (max 64): %q", name)
+	}
+	return nil
+}
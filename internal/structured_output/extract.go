@@ -0,0 +1,113 @@
+package structured_output
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Extract 从 CLI 文本回复中提取 JSON 值：
+// 依次尝试整段文本、```json 代码块、文本中第一个完整的 {...} 或 [...]
+func Extract(text string) (interface{}, json.RawMessage, error) {
+	candidates := []string{strings.TrimSpace(text)}
+	candidates = append(candidates, fencedBlocks(text)...)
+	if balanced := firstBalanced(text); balanced != "" {
+		candidates = append(candidates, balanced)
+	}
+
+	for _, candidate := range candidates {
+		if candidate == "" {
+			continue
+		}
+		var value interface{}
+		if err := json.Unmarshal([]byte(candidate), &value); err != nil {
+			continue
+		}
+		return value, json.RawMessage(candidate), nil
+	}
+	return nil, nil, fmt.Errorf("no valid JSON found in response")
+}
+
+// fencedBlocks 返回所有 Markdown 代码块内容（优先 json 标注的代码块）
+func fencedBlocks(text string) []string {
+	var tagged, untagged []string
+	rest := text
+	for {
+		start := strings.Index(rest, "```")
+		if start == -1 {
+			break
+		}
+		rest = rest[start+3:]
+		newline := strings.Index(rest, "\n")
+		if newline == -1 {
+			break
+		}
+		lang := strings.ToLower(strings.TrimSpace(rest[:newline]))
+		rest = rest[newline+1:]
+		end := strings.Index(rest, "```")
+		if end == -1 {
+			break
+		}
+		block := strings.TrimSpace(rest[:end])
+		rest = rest[end+3:]
+		if lang == "json" || lang == "jsonc" {
+			tagged = append(tagged, block)
+		} else {
+			untagged = append(untagged, block)
+		}
+	}
+	return append(tagged, untagged...)
+}
+
+// firstBalanced 返回文本中第一个括号配对完整的 JSON 对象或数组（忽略字符串内的括号）
+func firstBalanced(text string) string {
+	for start := 0; start < len(text); start++ {
+		if text[start] != '{' && text[start] != '[' {
+			continue
+		}
+		if end := matchClosing(text, start); end != -1 {
+			candidate := text[start : end+1]
+			if json.Valid([]byte(candidate)) {
+				return candidate
+			}
+		}
+	}
+	return ""
+}
+
+func matchClosing(text string, start int) int {
+	var stack []byte
+	inString := false
+	escaped := false
+	for i := start; i < len(text); i++ {
+		c := text[i]
+		if inString {
+			switch {
+			case escaped:
+				escaped = false
+			case c == '\\':
+				escaped = true
+			case c == '"':
+				inString = false
+			}
+			continue
+		}
+		switch c {
+		case '"':
+			inString = true
+		case '{':
+			stack = append(stack, '}')
+		case '[':
+			stack = append(stack, ']')
+		case '}', ']':
+			if len(stack) == 0 || stack[len(stack)-1] != c {
+				return -1
+			}
+			stack = stack[:len(stack)-1]
+			if len(stack) == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
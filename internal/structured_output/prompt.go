@@ -0,0 +1,52 @@
+package structured_output
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// maxReportedErrors 修复提示中最多列出的校验错误数
+const maxReportedErrors = 20
+
+// Instructions 生成注入到系统提示词中的输出格式要求
+func Instructions(raw json.RawMessage) string {
+	var pretty bytes.Buffer
+	if err := json.Indent(&pretty, raw, "", "  "); err != nil {
+		pretty.Write(raw)
+	}
+	return "Your final answer MUST be a single JSON value that validates against the following JSON Schema. " +
+		"Output only the JSON, without Markdown fences or any other text.\n" +
+		"JSON Schema:\n" + pretty.String()
+}
+
+// RepairPrompt 生成在同一会话中重新提问的修复提示
+func RepairPrompt(errs []string) string {
+	var b strings.Builder
+	b.WriteString("Your previous answer did not satisfy the required JSON Schema.\n")
+	b.WriteString("Problems:\n")
+	for i, e := range errs {
+		if i == maxReportedErrors {
+			fmt.Fprintf(&b, "- ... and %d more\n", len(errs)-maxReportedErrors)
+			break
+		}
+		b.WriteString("- ")
+		b.WriteString(e)
+		b.WriteString("\n")
+	}
+	b.WriteString("Reply again with only the corrected JSON value, without Markdown fences or any other text.")
+	return b.String()
+}
+
+// Check 提取并校验回复，返回解析后的 JSON 与错误列表
+func Check(schema *Schema, text string) (json.RawMessage, []string) {
+	value, raw, err := Extract(text)
+	if err != nil {
+		return nil, []string{err.Error()}
+	}
+	if errs := schema.Validate(value); len(errs) > 0 {
+		return nil, errs
+	}
+	return raw, nil
+}
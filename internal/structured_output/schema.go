@@ -0,0 +1,287 @@
+package structured_output
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Schema 是网关支持的 JSON Schema 子集：
+// type、properties、required、additionalProperties、items、enum、const、
+// minimum/maximum、minLength/maxLength、pattern、minItems/maxItems、anyOf/oneOf/allOf
+type Schema struct {
+	Type                 schemaType         `json:"type,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	AdditionalProperties *bool              `json:"additionalProperties,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	Enum                 []interface{}      `json:"enum,omitempty"`
+	Const                interface{}        `json:"const,omitempty"`
+	Minimum              *float64           `json:"minimum,omitempty"`
+	Maximum              *float64           `json:"maximum,omitempty"`
+	MinLength            *int               `json:"minLength,omitempty"`
+	MaxLength            *int               `json:"maxLength,omitempty"`
+	Pattern              string             `json:"pattern,omitempty"`
+	MinItems             *int               `json:"minItems,omitempty"`
+	MaxItems             *int               `json:"maxItems,omitempty"`
+	AnyOf                []*Schema          `json:"anyOf,omitempty"`
+	OneOf                []*Schema          `json:"oneOf,omitempty"`
+	AllOf                []*Schema          `json:"allOf,omitempty"`
+
+	hasConst bool // 是否声明了 const（区分 "const": null 与未声明）
+	pattern  *regexp.Regexp
+}
+
+// UnmarshalJSON 在常规解析之外记录是否声明了 const，使 "const": null 同样生效
+func (s *Schema) UnmarshalJSON(data []byte) error {
+	type plain Schema
+	if err := json.Unmarshal(data, (*plain)(s)); err != nil {
+		return err
+	}
+	var keys map[string]json.RawMessage
+	if err := json.Unmarshal(data, &keys); err != nil {
+		return err
+	}
+	_, s.hasConst = keys["const"]
+	return nil
+}
+
+// schemaType 兼容 "type": "string" 与 "type": ["string", "null"]
+type schemaType []string
+
+func (t *schemaType) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*t = schemaType{single}
+		return nil
+	}
+	var multiple []string
+	if err := json.Unmarshal(data, &multiple); err != nil {
+		return fmt.Errorf("type must be a string or array of strings")
+	}
+	*t = schemaType(multiple)
+	return nil
+}
+
+var supportedTypes = map[string]bool{
+	"object": true, "array": true, "string": true, "number": true,
+	"integer": true, "boolean": true, "null": true,
+}
+
+// ParseSchema 解析并预编译 schema
+func ParseSchema(raw json.RawMessage) (*Schema, error) {
+	if len(bytes.TrimSpace(raw)) == 0 {
+		return nil, fmt.Errorf("response_schema is empty")
+	}
+	var schema Schema
+	if err := json.Unmarshal(raw, &schema); err != nil {
+		return nil, fmt.Errorf("invalid response_schema: %v", err)
+	}
+	if err := schema.compile("$"); err != nil {
+		return nil, fmt.Errorf("invalid response_schema: %v", err)
+	}
+	return &schema, nil
+}
+
+func (s *Schema) compile(path string) error {
+	for _, t := range s.Type {
+		if !supportedTypes[t] {
+			return fmt.Errorf("%s: unsupported type %q", path, t)
+		}
+	}
+	if s.Pattern != "" {
+		re, err := regexp.Compile(s.Pattern)
+		if err != nil {
+			return fmt.Errorf("%s: invalid pattern: %v", path, err)
+		}
+		s.pattern = re
+	}
+	for name, prop := range s.Properties {
+		if prop == nil {
+			continue
+		}
+		if err := prop.compile(path + "." + name); err != nil {
+			return err
+		}
+	}
+	if s.Items != nil {
+		if err := s.Items.compile(path + "[]"); err != nil {
+			return err
+		}
+	}
+	for _, group := range [][]*Schema{s.AnyOf, s.OneOf, s.AllOf} {
+		for _, sub := range group {
+			if sub == nil {
+				continue
+			}
+			if err := sub.compile(path); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Validate 校验 JSON 值，返回所有违反项（为空表示通过）
+func (s *Schema) Validate(value interface{}) []string {
+	var errs []string
+	s.validate("$", value, &errs)
+	return errs
+}
+
+func (s *Schema) validate(path string, value interface{}, errs *[]string) {
+	if s == nil {
+		return
+	}
+	if len(s.Type) > 0 && !s.matchesType(value) {
+		*errs = append(*errs, fmt.Sprintf("%s: expected %s, got %s", path, strings.Join(s.Type, " or "), jsonTypeOf(value)))
+		return
+	}
+	if s.hasConst && !jsonEqual(s.Const, value) {
+		*errs = append(*errs, fmt.Sprintf("%s: must equal %s", path, compactJSON(s.Const)))
+	}
+	if len(s.Enum) > 0 {
+		matched := false
+		for _, candidate := range s.Enum {
+			if jsonEqual(candidate, value) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			*errs = append(*errs, fmt.Sprintf("%s: must be one of %s", path, compactJSON(s.Enum)))
+		}
+	}
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		s.validateObject(path, v, errs)
+	case []interface{}:
+		if s.MinItems != nil && len(v) < *s.MinItems {
+			*errs = append(*errs, fmt.Sprintf("%s: must have at least %d items", path, *s.MinItems))
+		}
+		if s.MaxItems != nil && len(v) > *s.MaxItems {
+			*errs = append(*errs, fmt.Sprintf("%s: must have at most %d items", path, *s.MaxItems))
+		}
+		if s.Items != nil {
+			for i, item := range v {
+				s.Items.validate(fmt.Sprintf("%s[%d]", path, i), item, errs)
+			}
+		}
+	case string:
+		length := len([]rune(v))
+		if s.MinLength != nil && length < *s.MinLength {
+			*errs = append(*errs, fmt.Sprintf("%s: must be at least %d characters", path, *s.MinLength))
+		}
+		if s.MaxLength != nil && length > *s.MaxLength {
+			*errs = append(*errs, fmt.Sprintf("%s: must be at most %d characters", path, *s.MaxLength))
+		}
+		if s.pattern != nil && !s.pattern.MatchString(v) {
+			*errs = append(*errs, fmt.Sprintf("%s: must match pattern %q", path, s.Pattern))
+		}
+	case float64:
+		if s.Minimum != nil && v < *s.Minimum {
+			*errs = append(*errs, fmt.Sprintf("%s: must be >= %v", path, *s.Minimum))
+		}
+		if s.Maximum != nil && v > *s.Maximum {
+			*errs = append(*errs, fmt.Sprintf("%s: must be <= %v", path, *s.Maximum))
+		}
+	}
+
+	for _, sub := range s.AllOf {
+		sub.validate(path, value, errs)
+	}
+	if len(s.AnyOf) > 0 && countMatches(s.AnyOf, path, value) == 0 {
+		*errs = append(*errs, fmt.Sprintf("%s: must match at least one schema in anyOf", path))
+	}
+	if len(s.OneOf) > 0 && countMatches(s.OneOf, path, value) != 1 {
+		*errs = append(*errs, fmt.Sprintf("%s: must match exactly one schema in oneOf", path))
+	}
+}
+
+func (s *Schema) validateObject(path string, obj map[string]interface{}, errs *[]string) {
+	for _, name := range s.Required {
+		if _, ok := obj[name]; !ok {
+			*errs = append(*errs, fmt.Sprintf("%s: missing required property %q", path, name))
+		}
+	}
+
+	keys := make([]string, 0, len(obj))
+	for key := range obj {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		prop, ok := s.Properties[key]
+		if !ok {
+			if s.AdditionalProperties != nil && !*s.AdditionalProperties {
+				*errs = append(*errs, fmt.Sprintf("%s: unexpected property %q", path, key))
+			}
+			continue
+		}
+		prop.validate(path+"."+key, obj[key], errs)
+	}
+}
+
+func (s *Schema) matchesType(value interface{}) bool {
+	actual := jsonTypeOf(value)
+	for _, t := range s.Type {
+		if t == actual {
+			return true
+		}
+		if t == "number" && actual == "integer" {
+			return true
+		}
+	}
+	return false
+}
+
+func countMatches(schemas []*Schema, path string, value interface{}) int {
+	matches := 0
+	for _, sub := range schemas {
+		var subErrs []string
+		sub.validate(path, value, &subErrs)
+		if len(subErrs) == 0 {
+			matches++
+		}
+	}
+	return matches
+}
+
+func jsonTypeOf(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case float64:
+		if v == math.Trunc(v) && !math.IsInf(v, 0) {
+			return "integer"
+		}
+		return "number"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	default:
+		return fmt.Sprintf("%T", value)
+	}
+}
+
+func jsonEqual(a, b interface{}) bool {
+	return compactJSON(a) == compactJSON(b)
+}
+
+func compactJSON(value interface{}) string {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprintf("%v", value)
+	}
+	return string(data)
+}
@@ -0,0 +1,98 @@
+package structured_output
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+const testSchema = `{
+	"type": "object",
+	"required": ["category", "confidence"],
+	"additionalProperties": false,
+	"properties": {
+		"category": {"type": "string", "enum": ["bug", "feature", "question"]},
+		"confidence": {"type": "number", "minimum": 0, "maximum": 1},
+		"tags": {"type": "array", "items": {"type": "string", "minLength": 1}, "maxItems": 3},
+		"note": {"type": ["string", "null"]}
+	}
+}`
+
+func TestExtract(t *testing.T) {
+	cases := map[string]string{
+		"plain":  `{"a": 1}`,
+		"fenced": "Sure, here it is:\n```json\n{\"a\": 1}\n```\nLet me know!",
+		"prose":  `The answer is {"a": 1, "b": "x}"} as requested.`,
+		"array":  "Result: [1, 2, 3]",
+	}
+	for name, text := range cases {
+		value, raw, err := Extract(text)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", name, err)
+			continue
+		}
+		if value == nil || !json.Valid(raw) {
+			t.Errorf("%s: invalid extraction %q", name, raw)
+		}
+	}
+
+	if _, _, err := Extract("no json here {broken"); err == nil {
+		t.Error("expected error when no JSON present")
+	}
+}
+
+func TestSchemaValidate(t *testing.T) {
+	schema, err := ParseSchema(json.RawMessage(testSchema))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	raw, errs := Check(schema, "```json\n{\"category\": \"bug\", \"confidence\": 0.9, \"tags\": [\"ui\"], \"note\": null}\n```")
+	if len(errs) != 0 || raw == nil {
+		t.Fatalf("expected valid output, got errors %v", errs)
+	}
+
+	_, errs = Check(schema, `{"category": "spam", "confidence": 2, "tags": ["", "a", "b", "c"], "extra": true}`)
+	want := []string{
+		`$.category: must be one of`,
+		`$.confidence: must be <= 1`,
+		`$.tags: must have at most 3 items`,
+		`$.tags[0]: must be at least 1 characters`,
+		`$: unexpected property "extra"`,
+	}
+	joined := strings.Join(errs, "\n")
+	for _, w := range want {
+		if !strings.Contains(joined, w) {
+			t.Errorf("missing error %q in:\n%s", w, joined)
+		}
+	}
+
+	_, errs = Check(schema, `{"confidence": "high"}`)
+	joined = strings.Join(errs, "\n")
+	if !strings.Contains(joined, `missing required property "category"`) || !strings.Contains(joined, "expected number, got string") {
+		t.Errorf("unexpected errors:\n%s", joined)
+	}
+
+	if _, err := ParseSchema(json.RawMessage(`{"type": "object", "properties": {"x": {"pattern": "("}}}`)); err == nil {
+		t.Error("expected error for invalid pattern")
+	}
+
+	nullConst, _ := ParseSchema(json.RawMessage(`{"type": "object", "properties": {"x": {"const": null}}}`))
+	if _, errs := Check(nullConst, `{"x": 1}`); !strings.Contains(strings.Join(errs, "\n"), "$.x: must equal null") {
+		t.Errorf("const null should be enforced, got %v", errs)
+	}
+	if _, errs := Check(nullConst, `{"x": null}`); len(errs) != 0 {
+		t.Errorf("null should satisfy const null, got %v", errs)
+	}
+}
+
+func TestRepairPrompt(t *testing.T) {
+	errs := make([]string, maxReportedErrors+5)
+	for i := range errs {
+		errs[i] = "problem"
+	}
+	prompt := RepairPrompt(errs)
+	if !strings.Contains(prompt, "and 5 more") || !strings.Contains(prompt, "only the corrected JSON") {
+		t.Errorf("unexpected repair prompt:\n%s", prompt)
+	}
+}
@@ -0,0 +1,78 @@
+package transcript
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	compactToolInputChars  = 300
+	compactToolOutputChars = 800
+)
+
+// Compact 将会话记录压缩为纯文本（工具输入/输出截断），超出 maxChars 时丢弃最早的消息。
+// 返回文本与被丢弃的消息数。
+func Compact(t *Transcript, maxChars int) (string, int) {
+	blocks := make([]string, len(t.Messages))
+	for i, msg := range t.Messages {
+		blocks[i] = compactMessage(msg)
+	}
+
+	start, total := 0, 0
+	for _, block := range blocks {
+		total += len(block)
+	}
+	for maxChars > 0 && total > maxChars && start < len(blocks)-1 {
+		total -= len(blocks[start])
+		start++
+	}
+
+	var b strings.Builder
+	if start > 0 {
+		fmt.Fprintf(&b, "[%d earlier message(s) omitted]\n\n", start)
+	}
+	b.WriteString(strings.Join(blocks[start:], ""))
+	text := b.String()
+	if maxChars > 0 && len(text) > maxChars {
+		// 仅剩一条消息仍超限时保留结尾部分
+		cut := len(text) - maxChars
+		for cut < len(text) && !utf8.RuneStart(text[cut]) {
+			cut++
+		}
+		text = "…" + text[cut:]
+	}
+	return text, start
+}
+
+func compactMessage(msg Message) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "[%s]\n", msg.Role)
+	if content := strings.TrimSpace(msg.Content); content != "" {
+		b.WriteString(content)
+		b.WriteString("\n")
+	}
+	for _, call := range msg.ToolCalls {
+		fmt.Fprintf(&b, "(tool call) %s %s\n", call.Name, truncate(strings.TrimSpace(string(call.Input)), compactToolInputChars))
+		if call.Output != "" {
+			label := "(tool output)"
+			if call.IsError {
+				label = "(tool error)"
+			}
+			fmt.Fprintf(&b, "%s %s\n", label, truncate(strings.TrimSpace(call.Output), compactToolOutputChars))
+		}
+	}
+	b.WriteString("\n")
+	return b.String()
+}
+
+func truncate(value string, max int) string {
+	if len(value) <= max {
+		return value
+	}
+	cut := max
+	for cut > 0 && !utf8.RuneStart(value[cut]) {
+		cut--
+	}
+	return fmt.Sprintf("%s… [%d bytes truncated]", value[:cut], len(value)-cut)
+}
@@ -0,0 +1,166 @@
+package transcript
+
+import (
+	"bufio"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// maxLineBytes 单行 JSONL 上限（工具输出可能很长）
+const maxLineBytes = 32 * 1024 * 1024
+
+// Load 定位并解析指定 CLI 会话的原生历史
+func Load(cli, sessionID string, roots Roots) (*Transcript, error) {
+	if sessionID == "" || strings.ContainsAny(sessionID, `/\`) || strings.Contains(sessionID, "..") {
+		return nil, fmt.Errorf("invalid session id: %q", sessionID)
+	}
+	roots = roots.withDefaults()
+
+	var (
+		path     string
+		messages []Message
+		err      error
+	)
+	switch normalizeCLI(cli) {
+	case "claude":
+		path, err = findClaudeTranscript(roots.ClaudeDir, sessionID)
+		if err == nil {
+			messages, err = parseClaudeFile(path)
+		}
+	case "codex":
+		path, err = findByName(filepath.Join(roots.CodexDir, "sessions"), sessionID, ".jsonl")
+		if err == nil {
+			messages, err = parseCodexFile(path)
+		}
+	case "gemini":
+		path, messages, err = loadGeminiStyle(filepath.Join(roots.GeminiDir, "tmp"), sessionID)
+	case "qwen":
+		path, messages, err = loadGeminiStyle(filepath.Join(roots.QwenDir, "tmp"), sessionID)
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnsupported, cli)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &Transcript{SessionID: sessionID, CLI: normalizeCLI(cli), Source: path, Messages: messages}, nil
+}
+
+func normalizeCLI(cli string) string {
+	switch strings.ToLower(cli) {
+	case "claude", "claude-code":
+		return "claude"
+	case "codex":
+		return "codex"
+	case "gemini":
+		return "gemini"
+	case "qwen":
+		return "qwen"
+	default:
+		return strings.ToLower(cli)
+	}
+}
+
+func (r Roots) withDefaults() Roots {
+	if r.Home == "" {
+		r.Home, _ = os.UserHomeDir()
+	}
+	if r.ClaudeDir == "" {
+		r.ClaudeDir = filepath.Join(r.Home, ".claude")
+	}
+	if r.CodexDir == "" {
+		r.CodexDir = filepath.Join(r.Home, ".codex")
+	}
+	if r.GeminiDir == "" {
+		r.GeminiDir = filepath.Join(r.Home, ".gemini")
+	}
+	if r.QwenDir == "" {
+		r.QwenDir = filepath.Join(r.Home, ".qwen")
+	}
+	return r
+}
+
+// findClaudeTranscript Claude 按项目目录保存：~/.claude/projects/<project>/<session>.jsonl
+func findClaudeTranscript(dir, sessionID string) (string, error) {
+	matches, _ := filepath.Glob(filepath.Join(dir, "projects", "*", sessionID+".jsonl"))
+	if len(matches) == 0 {
+		return "", ErrNotFound
+	}
+	return newest(matches), nil
+}
+
+// findByName 在目录树中查找文件名包含会话 ID 的历史文件
+func findByName(root, sessionID, ext string) (string, error) {
+	var matches []string
+	filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if !d.IsDir() && d.Type()&fs.ModeSymlink == 0 && strings.HasSuffix(d.Name(), ext) && strings.Contains(d.Name(), sessionID) {
+			matches = append(matches, path)
+		}
+		return nil
+	})
+	if len(matches) == 0 {
+		return "", ErrNotFound
+	}
+	return newest(matches), nil
+}
+
+func newest(paths []string) string {
+	best := paths[0]
+	var bestTime int64
+	for _, path := range paths {
+		if info, err := os.Stat(path); err == nil && info.ModTime().UnixNano() > bestTime {
+			best, bestTime = path, info.ModTime().UnixNano()
+		}
+	}
+	return best
+}
+
+// readJSONLines 逐行读取 JSONL 文件
+func readJSONLines(path string, fn func(line []byte)) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open transcript: %v", err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), maxLineBytes)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(strings.TrimSpace(string(line))) == 0 {
+			continue
+		}
+		fn(line)
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read transcript: %v", err)
+	}
+	return nil
+}
+
+// toolIndex 记录工具调用位置，便于把后续的工具结果挂到对应调用上
+type toolIndex map[string][2]int
+
+func (idx toolIndex) attach(messages []Message, id, output string, isError bool) bool {
+	pos, ok := idx[id]
+	if !ok {
+		return false
+	}
+	call := &messages[pos[0]].ToolCalls[pos[1]]
+	call.Output = output
+	call.IsError = isError
+	return true
+}
+
+func (idx toolIndex) add(messages []Message, call ToolCall) {
+	last := len(messages) - 1
+	messages[last].ToolCalls = append(messages[last].ToolCalls, call)
+	if call.ID != "" {
+		idx[call.ID] = [2]int{last, len(messages[last].ToolCalls) - 1}
+	}
+}
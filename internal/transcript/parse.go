@@ -0,0 +1,370 @@
+package transcript
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ---- Claude: ~/.claude/projects/<project>/<session>.jsonl ----
+
+type claudeLine struct {
+	Type      string `json:"type"`
+	Timestamp string `json:"timestamp"`
+	Message   struct {
+		Role    string          `json:"role"`
+		Content json.RawMessage `json:"content"`
+	} `json:"message"`
+}
+
+type contentBlock struct {
+	Type      string          `json:"type"`
+	Text      string          `json:"text"`
+	ID        string          `json:"id"`
+	Name      string          `json:"name"`
+	Input     json.RawMessage `json:"input"`
+	ToolUseID string          `json:"tool_use_id"`
+	Content   json.RawMessage `json:"content"`
+	IsError   bool            `json:"is_error"`
+}
+
+func parseClaudeFile(path string) ([]Message, error) {
+	var messages []Message
+	tools := toolIndex{}
+	err := readJSONLines(path, func(raw []byte) {
+		var line claudeLine
+		if json.Unmarshal(raw, &line) != nil || (line.Type != "user" && line.Type != "assistant") {
+			return
+		}
+		role := line.Message.Role
+		if role == "" {
+			role = line.Type
+		}
+		msg := Message{Role: role, Timestamp: parseTime(line.Timestamp)}
+
+		var text string
+		if json.Unmarshal(line.Message.Content, &text) == nil {
+			msg.Content = text
+			messages = append(messages, msg)
+			return
+		}
+		var blocks []contentBlock
+		if json.Unmarshal(line.Message.Content, &blocks) != nil {
+			return
+		}
+
+		var texts []string
+		var calls []ToolCall
+		for _, block := range blocks {
+			switch block.Type {
+			case "text":
+				texts = append(texts, block.Text)
+			case "tool_use":
+				calls = append(calls, ToolCall{ID: block.ID, Name: block.Name, Input: block.Input})
+			case "tool_result":
+				// 工具结果挂到对应调用上，不作为单独的用户消息
+				tools.attach(messages, block.ToolUseID, flattenContent(block.Content), block.IsError)
+			}
+		}
+		if len(texts) == 0 && len(calls) == 0 {
+			return
+		}
+		msg.Content = strings.Join(texts, "\n")
+		messages = append(messages, msg)
+		for _, call := range calls {
+			tools.add(messages, call)
+		}
+	})
+	return messages, err
+}
+
+// flattenContent 将 string 或 [{type:text,text}] 形式的内容展开为文本
+func flattenContent(raw json.RawMessage) string {
+	if len(raw) == 0 {
+		return ""
+	}
+	var text string
+	if json.Unmarshal(raw, &text) == nil {
+		return text
+	}
+	var blocks []contentBlock
+	if json.Unmarshal(raw, &blocks) == nil {
+		var parts []string
+		for _, block := range blocks {
+			if block.Text != "" {
+				parts = append(parts, block.Text)
+			}
+		}
+		return strings.Join(parts, "\n")
+	}
+	return string(raw)
+}
+
+// ---- Codex: ~/.codex/sessions/YYYY/MM/DD/rollout-<time>-<session>.jsonl ----
+
+type codexLine struct {
+	Timestamp string          `json:"timestamp"`
+	Type      string          `json:"type"`
+	Payload   json.RawMessage `json:"payload"`
+}
+
+type codexItem struct {
+	Type    string `json:"type"`
+	Role    string `json:"role"`
+	Content []struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	} `json:"content"`
+	Name      string          `json:"name"`
+	Arguments string          `json:"arguments"`
+	Input     string          `json:"input"`
+	CallID    string          `json:"call_id"`
+	Output    json.RawMessage `json:"output"`
+}
+
+func parseCodexFile(path string) ([]Message, error) {
+	var messages []Message
+	tools := toolIndex{}
+	err := readJSONLines(path, func(raw []byte) {
+		var line codexLine
+		if json.Unmarshal(raw, &line) != nil {
+			return
+		}
+		itemRaw := raw
+		if len(line.Payload) > 0 {
+			// 新格式：{"type":"response_item","payload":{...}}；旧格式直接是 item
+			if line.Type != "response_item" {
+				return
+			}
+			itemRaw = line.Payload
+		}
+		var item codexItem
+		if json.Unmarshal(itemRaw, &item) != nil {
+			return
+		}
+		ts := parseTime(line.Timestamp)
+
+		switch item.Type {
+		case "message":
+			var texts []string
+			for _, part := range item.Content {
+				if part.Text != "" {
+					texts = append(texts, part.Text)
+				}
+			}
+			// 跳过 Codex 注入的环境/指令上下文
+			text := strings.Join(texts, "\n")
+			if item.Role == "user" && (strings.HasPrefix(text, "<environment_context>") || strings.HasPrefix(text, "<user_instructions>")) {
+				return
+			}
+			if item.Role != "user" && item.Role != "assistant" {
+				return
+			}
+			messages = append(messages, Message{Role: item.Role, Content: text, Timestamp: ts})
+		case "function_call", "custom_tool_call", "local_shell_call":
+			if len(messages) == 0 || messages[len(messages)-1].Role != "assistant" {
+				messages = append(messages, Message{Role: "assistant", Timestamp: ts})
+			}
+			input := item.Arguments
+			if input == "" {
+				input = item.Input
+			}
+			call := ToolCall{ID: item.CallID, Name: item.Name}
+			if input != "" {
+				if json.Valid([]byte(input)) {
+					call.Input = json.RawMessage(input)
+				} else {
+					encoded, _ := json.Marshal(input)
+					call.Input = encoded
+				}
+			}
+			if call.Name == "" {
+				call.Name = item.Type
+			}
+			tools.add(messages, call)
+		case "function_call_output", "custom_tool_call_output":
+			tools.attach(messages, item.CallID, codexOutput(item.Output), false)
+		}
+	})
+	return messages, err
+}
+
+func codexOutput(raw json.RawMessage) string {
+	var text string
+	if json.Unmarshal(raw, &text) == nil {
+		return text
+	}
+	var wrapped struct {
+		Output string `json:"output"`
+	}
+	if json.Unmarshal(raw, &wrapped) == nil && wrapped.Output != "" {
+		return wrapped.Output
+	}
+	return string(raw)
+}
+
+// ---- Gemini / Qwen: ~/.gemini/tmp/<project>/chats/session-*.json 或 checkpoint-*.json ----
+
+type geminiSession struct {
+	SessionID string `json:"sessionId"`
+	Messages  []struct {
+		Timestamp string `json:"timestamp"`
+		Type      string `json:"type"`
+		Content   string `json:"content"`
+		ToolCalls []struct {
+			ID     string          `json:"id"`
+			Name   string          `json:"name"`
+			Args   json.RawMessage `json:"args"`
+			Result json.RawMessage `json:"result"`
+			Status string          `json:"status"`
+		} `json:"toolCalls"`
+	} `json:"messages"`
+}
+
+type geminiContent struct {
+	Role  string `json:"role"`
+	Parts []struct {
+		Text         string `json:"text"`
+		FunctionCall *struct {
+			ID   string          `json:"id"`
+			Name string          `json:"name"`
+			Args json.RawMessage `json:"args"`
+		} `json:"functionCall"`
+		FunctionResponse *struct {
+			ID       string          `json:"id"`
+			Name     string          `json:"name"`
+			Response json.RawMessage `json:"response"`
+		} `json:"functionResponse"`
+	} `json:"parts"`
+}
+
+func loadGeminiStyle(root, sessionID string) (string, []Message, error) {
+	path, err := findByName(root, sessionID, ".json")
+	if err != nil {
+		path, err = findGeminiSessionByContent(root, sessionID)
+		if err != nil {
+			return "", nil, err
+		}
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to read transcript: %v", err)
+	}
+
+	var session geminiSession
+	if json.Unmarshal(data, &session) == nil && len(session.Messages) > 0 {
+		return path, parseGeminiSession(session), nil
+	}
+	var contents []geminiContent
+	if err := json.Unmarshal(data, &contents); err != nil {
+		return "", nil, fmt.Errorf("unrecognized transcript format: %s", path)
+	}
+	return path, parseGeminiCheckpoint(contents), nil
+}
+
+// findGeminiSessionByContent 会话文件名不含完整 ID 时，按文件内的 sessionId 查找
+func findGeminiSessionByContent(root, sessionID string) (string, error) {
+	matches, _ := filepath.Glob(filepath.Join(root, "*", "chats", "*.json"))
+	for _, path := range matches {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		var header struct {
+			SessionID string `json:"sessionId"`
+		}
+		if json.Unmarshal(data, &header) == nil && header.SessionID == sessionID {
+			return path, nil
+		}
+	}
+	return "", ErrNotFound
+}
+
+func parseGeminiSession(session geminiSession) []Message {
+	var messages []Message
+	for _, item := range session.Messages {
+		role := "assistant"
+		switch item.Type {
+		case "user":
+			role = "user"
+		case "info", "error", "warning":
+			role = "system"
+		}
+		msg := Message{Role: role, Content: item.Content, Timestamp: parseTime(item.Timestamp)}
+		for _, call := range item.ToolCalls {
+			msg.ToolCalls = append(msg.ToolCalls, ToolCall{
+				ID:      call.ID,
+				Name:    call.Name,
+				Input:   call.Args,
+				Output:  flattenGeminiResult(call.Result),
+				IsError: call.Status == "error",
+			})
+		}
+		messages = append(messages, msg)
+	}
+	return messages
+}
+
+func parseGeminiCheckpoint(contents []geminiContent) []Message {
+	var messages []Message
+	tools := toolIndex{}
+	for _, content := range contents {
+		role := "assistant"
+		if content.Role == "user" {
+			role = "user"
+		}
+		var texts []string
+		var calls []ToolCall
+		for _, part := range content.Parts {
+			switch {
+			case part.FunctionCall != nil:
+				id := part.FunctionCall.ID
+				if id == "" {
+					id = part.FunctionCall.Name
+				}
+				calls = append(calls, ToolCall{ID: id, Name: part.FunctionCall.Name, Input: part.FunctionCall.Args})
+			case part.FunctionResponse != nil:
+				id := part.FunctionResponse.ID
+				if id == "" {
+					id = part.FunctionResponse.Name
+				}
+				tools.attach(messages, id, string(part.FunctionResponse.Response), false)
+			case part.Text != "":
+				texts = append(texts, part.Text)
+			}
+		}
+		if len(texts) == 0 && len(calls) == 0 {
+			continue
+		}
+		messages = append(messages, Message{Role: role, Content: strings.Join(texts, "\n")})
+		for _, call := range calls {
+			tools.add(messages, call)
+		}
+	}
+	return messages
+}
+
+func flattenGeminiResult(raw json.RawMessage) string {
+	if len(raw) == 0 || string(raw) == "null" {
+		return ""
+	}
+	var text string
+	if json.Unmarshal(raw, &text) == nil {
+		return text
+	}
+	return string(raw)
+}
+
+func parseTime(value string) *time.Time {
+	if value == "" {
+		return nil
+	}
+	t, err := time.Parse(time.RFC3339Nano, value)
+	if err != nil {
+		return nil
+	}
+	return &t
+}
@@ -0,0 +1,107 @@
+package transcript
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"strings"
+)
+
+// WriteMarkdown 导出为 Markdown
+func WriteMarkdown(w io.Writer, t *Transcript) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Session %s\n\n", t.SessionID)
+	fmt.Fprintf(&b, "- CLI: %s\n- Source: `%s`\n- Messages: %d\n\n", t.CLI, t.Source, len(t.Messages))
+	for _, msg := range t.Messages {
+		fmt.Fprintf(&b, "## %s", roleTitle(msg.Role))
+		if msg.Timestamp != nil {
+			fmt.Fprintf(&b, " · %s", msg.Timestamp.Format("2006-01-02 15:04:05"))
+		}
+		b.WriteString("\n\n")
+		if msg.Content != "" {
+			b.WriteString(msg.Content)
+			b.WriteString("\n\n")
+		}
+		for _, call := range msg.ToolCalls {
+			fmt.Fprintf(&b, "**Tool call: `%s`**", call.Name)
+			if call.IsError {
+				b.WriteString(" (error)")
+			}
+			b.WriteString("\n\n")
+			if input := prettyJSON(call.Input); input != "" {
+				fmt.Fprintf(&b, "```json\n%s\n```\n\n", input)
+			}
+			if call.Output != "" {
+				fmt.Fprintf(&b, "<details><summary>Output</summary>\n\n```\n%s\n```\n\n</details>\n\n", call.Output)
+			}
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+var htmlTemplate = template.Must(template.New("transcript").Funcs(template.FuncMap{
+	"roleTitle":  roleTitle,
+	"prettyJSON": prettyJSON,
+}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>Session {{.SessionID}}</title>
+<style>
+body{font-family:-apple-system,BlinkMacSystemFont,"Segoe UI",sans-serif;max-width:960px;margin:2rem auto;padding:0 1rem;color:#222}
+.meta{color:#666;font-size:.9rem}
+.msg{border:1px solid #ddd;border-radius:8px;margin:1rem 0;padding:.75rem 1rem}
+.msg.user{background:#f4f8ff}.msg.assistant{background:#fff}.msg.system{background:#fafafa;color:#666}
+.role{font-weight:600;margin-bottom:.5rem}.time{color:#999;font-weight:400;font-size:.85rem;margin-left:.5rem}
+.content{white-space:pre-wrap}
+.tool{border-left:3px solid #999;margin:.5rem 0;padding-left:.75rem}.tool.error{border-color:#d33}
+pre{background:#f6f6f6;padding:.5rem;overflow-x:auto;white-space:pre-wrap}
+</style>
+</head>
+<body>
+<h1>Session {{.SessionID}}</h1>
+<p class="meta">CLI: {{.CLI}} · Source: <code>{{.Source}}</code> · {{len .Messages}} messages</p>
+{{range .Messages}}<div class="msg {{.Role}}">
+<div class="role">{{roleTitle .Role}}{{if .Timestamp}}<span class="time">{{.Timestamp.Format "2006-01-02 15:04:05"}}</span>{{end}}</div>
+{{if .Content}}<div class="content">{{.Content}}</div>{{end}}
+{{range .ToolCalls}}<div class="tool{{if .IsError}} error{{end}}">
+<div><strong>Tool call:</strong> <code>{{.Name}}</code>{{if .IsError}} (error){{end}}</div>
+{{with prettyJSON .Input}}<pre>{{.}}</pre>{{end}}
+{{if .Output}}<details><summary>Output</summary><pre>{{.Output}}</pre></details>{{end}}
+</div>{{end}}
+</div>
+{{end}}</body>
+</html>
+`))
+
+// WriteHTML 导出为独立 HTML 页面（内容经过转义）
+func WriteHTML(w io.Writer, t *Transcript) error {
+	return htmlTemplate.Execute(w, t)
+}
+
+func roleTitle(role string) string {
+	switch role {
+	case "user":
+		return "User"
+	case "assistant":
+		return "Assistant"
+	case "system":
+		return "System"
+	default:
+		return role
+	}
+}
+
+func prettyJSON(raw json.RawMessage) string {
+	if len(raw) == 0 || string(raw) == "null" {
+		return ""
+	}
+	var buf bytes.Buffer
+	if err := json.Indent(&buf, raw, "", "  "); err != nil {
+		return string(raw)
+	}
+	return buf.String()
+}
@@ -0,0 +1,140 @@
+package transcript
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoad_Claude(t *testing.T) {
+	home := t.TempDir()
+	writeFile(t, filepath.Join(home, ".claude", "projects", "-work-app", "s1.jsonl"), strings.Join([]string{
+		`{"type":"summary","summary":"ignored"}`,
+		`{"type":"user","timestamp":"2025-01-01T00:00:00Z","message":{"role":"user","content":"list files"}}`,
+		`{"type":"assistant","message":{"role":"assistant","content":[{"type":"thinking","thinking":"hmm"},{"type":"text","text":"Listing."},{"type":"tool_use","id":"t1","name":"Bash","input":{"command":"ls"}}]}}`,
+		`{"type":"user","message":{"role":"user","content":[{"type":"tool_result","tool_use_id":"t1","content":[{"type":"text","text":"a.go"}]}]}}`,
+		`{"type":"assistant","message":{"role":"assistant","content":[{"type":"text","text":"Found a.go"}]}}`,
+	}, "\n"))
+
+	tr, err := Load("claude", "s1", Roots{Home: home})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(tr.Messages) != 3 {
+		t.Fatalf("expected 3 messages, got %+v", tr.Messages)
+	}
+	if tr.Messages[0].Content != "list files" || tr.Messages[0].Timestamp == nil {
+		t.Errorf("unexpected first message: %+v", tr.Messages[0])
+	}
+	calls := tr.Messages[1].ToolCalls
+	if len(calls) != 1 || calls[0].Name != "Bash" || calls[0].Output != "a.go" {
+		t.Errorf("tool result should be attached to its call: %+v", calls)
+	}
+
+	if _, err := Load("claude", "missing", Roots{Home: home}); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+	if _, err := Load("claude", "../s1", Roots{Home: home}); err == nil {
+		t.Error("expected error for path-like session id")
+	}
+	if _, err := Load("cursor", "s1", Roots{Home: home}); !errors.Is(err, ErrUnsupported) {
+		t.Errorf("expected ErrUnsupported, got %v", err)
+	}
+}
+
+func TestLoad_Codex(t *testing.T) {
+	home := t.TempDir()
+	writeFile(t, filepath.Join(home, ".codex", "sessions", "2025", "01", "01", "rollout-2025-01-01T00-00-00-abc.jsonl"), strings.Join([]string{
+		`{"type":"session_meta","payload":{"id":"abc"}}`,
+		`{"type":"response_item","payload":{"type":"message","role":"user","content":[{"type":"input_text","text":"<environment_context>cwd</environment_context>"}]}}`,
+		`{"type":"response_item","payload":{"type":"message","role":"user","content":[{"type":"input_text","text":"run tests"}]}}`,
+		`{"type":"response_item","payload":{"type":"function_call","name":"shell","arguments":"{\"command\":[\"go\",\"test\"]}","call_id":"c1"}}`,
+		`{"type":"response_item","payload":{"type":"function_call_output","call_id":"c1","output":"{\"output\":\"ok\"}"}}`,
+		`{"type":"response_item","payload":{"type":"message","role":"assistant","content":[{"type":"output_text","text":"All green."}]}}`,
+	}, "\n"))
+
+	tr, err := Load("codex", "abc", Roots{Home: home})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(tr.Messages) != 3 || tr.Messages[0].Content != "run tests" {
+		t.Fatalf("unexpected messages: %+v", tr.Messages)
+	}
+	calls := tr.Messages[1].ToolCalls
+	if len(calls) != 1 || calls[0].Name != "shell" || calls[0].Output != `{"output":"ok"}` {
+		t.Errorf("unexpected tool calls: %+v", calls)
+	}
+}
+
+func TestLoad_Gemini(t *testing.T) {
+	home := t.TempDir()
+	writeFile(t, filepath.Join(home, ".gemini", "tmp", "hash", "chats", "session-2025-01-01.json"), `{
+		"sessionId": "g1",
+		"messages": [
+			{"type": "user", "content": "hi"},
+			{"type": "gemini", "content": "hello", "toolCalls": [{"id": "x", "name": "read_file", "args": {"path": "a"}, "result": "data", "status": "success"}]}
+		]
+	}`)
+
+	tr, err := Load("gemini", "g1", Roots{Home: home})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(tr.Messages) != 2 || tr.Messages[1].Role != "assistant" || tr.Messages[1].ToolCalls[0].Output != "data" {
+		t.Errorf("unexpected messages: %+v", tr.Messages)
+	}
+}
+
+func TestRender(t *testing.T) {
+	tr := &Transcript{SessionID: "s1", CLI: "claude", Messages: []Message{
+		{Role: "user", Content: "<script>alert(1)</script>"},
+		{Role: "assistant", Content: "ok", ToolCalls: []ToolCall{{Name: "Bash", Input: []byte(`{"command":"ls"}`), Output: "a.go"}}},
+	}}
+
+	var md bytes.Buffer
+	if err := WriteMarkdown(&md, tr); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(md.String(), "## Assistant") || !strings.Contains(md.String(), `"command": "ls"`) {
+		t.Errorf("unexpected markdown:\n%s", md.String())
+	}
+
+	var page bytes.Buffer
+	if err := WriteHTML(&page, tr); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(page.String(), "<script>alert") {
+		t.Error("HTML export must escape message content")
+	}
+}
+
+func TestCompact(t *testing.T) {
+	tr := &Transcript{Messages: []Message{
+		{Role: "user", Content: strings.Repeat("old ", 100)},
+		{Role: "assistant", Content: "done", ToolCalls: []ToolCall{{Name: "Bash", Input: []byte(`{"command":"ls"}`), Output: strings.Repeat("x", 2000)}}},
+		{Role: "user", Content: "next step?"},
+	}}
+
+	full, omitted := Compact(tr, 0)
+	if omitted != 0 || !strings.Contains(full, "[assistant]\ndone\n(tool call) Bash") || !strings.Contains(full, "bytes truncated") {
+		t.Errorf("unexpected compact output:\n%s", full)
+	}
+
+	short, omitted := Compact(tr, len(full)-100)
+	if omitted != 1 || strings.Contains(short, "old old") || !strings.Contains(short, "next step?") {
+		t.Errorf("oldest message should be dropped, omitted=%d:\n%s", omitted, short)
+	}
+}
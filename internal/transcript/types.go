@@ -0,0 +1,46 @@
+package transcript
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+var (
+	ErrNotFound    = errors.New("transcript not found")
+	ErrUnsupported = errors.New("transcript export is not supported for this CLI")
+)
+
+// ToolCall 一次工具调用及其结果
+type ToolCall struct {
+	ID      string          `json:"id,omitempty"`
+	Name    string          `json:"name"`
+	Input   json.RawMessage `json:"input,omitempty"`
+	Output  string          `json:"output,omitempty"`
+	IsError bool            `json:"is_error,omitempty"`
+}
+
+// Message 归一化后的一条消息
+type Message struct {
+	Role      string     `json:"role"` // user / assistant / system
+	Content   string     `json:"content,omitempty"`
+	ToolCalls []ToolCall `json:"tool_calls,omitempty"`
+	Timestamp *time.Time `json:"timestamp,omitempty"`
+}
+
+// Transcript 会话完整记录
+type Transcript struct {
+	SessionID string    `json:"session_id"`
+	CLI       string    `json:"cli"`
+	Source    string    `json:"source"` // 原生历史文件路径
+	Messages  []Message `json:"messages"`
+}
+
+// Roots 各 CLI 原生历史的根目录（为空时使用默认位置）
+type Roots struct {
+	Home      string // 用户主目录
+	ClaudeDir string // 默认 ~/.claude（CLAUDE_CONFIG_DIR）
+	CodexDir  string // 默认 ~/.codex（CODEX_HOME）
+	GeminiDir string // 默认 ~/.gemini
+	QwenDir   string // 默认 ~/.qwen
+}
@@ -75,6 +75,24 @@ func (m *Manager) Get(ctx context.Context, workflowRunID string) (string, bool,
 	return sessionID, found, nil
 }
 
+// Rebind 将仍指向 fromSessionID 的 workflow 映射改为 toSessionID（会话转交后使用）
+func (m *Manager) Rebind(ctx context.Context, workflowRunID string, fromSessionID string, toSessionID string) (bool, error) {
+	if workflowRunID == "" {
+		return false, fmt.Errorf("workflow run id is required")
+	}
+	sessionID, found, err := m.store.Get(ctx, workflowRunID)
+	if err != nil {
+		return false, err
+	}
+	if !found || sessionID != fromSessionID {
+		return false, nil
+	}
+	if err := m.store.Set(ctx, workflowRunID, toSessionID, m.mappingTTL); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
 func (m *Manager) GetOrCreate(ctx context.Context, workflowRunID string, creator SessionCreator) (CreateResult, bool, error) {
 	if workflowRunID == "" {
 		return CreateResult{}, false, fmt.Errorf("workflow run id is required")
@@ -43,6 +43,21 @@ func (s *MemoryMappingStore) Get(_ context.Context, workflowRunID string) (strin
 	return entry.sessionID, true, nil
 }
 
+// Take 读取并删除映射（一次性使用），并发调用时只有一个调用方拿到
+func (s *MemoryMappingStore) Take(_ context.Context, workflowRunID string) (string, bool, error) {
+	if workflowRunID == "" {
+		return "", false, fmt.Errorf("workflow run id is required")
+	}
+	s.mu.Lock()
+	entry, ok := s.items[workflowRunID]
+	delete(s.items, workflowRunID)
+	s.mu.Unlock()
+	if !ok || (!entry.expiresAt.IsZero() && time.Now().After(entry.expiresAt)) {
+		return "", false, nil
+	}
+	return entry.sessionID, true, nil
+}
+
 func (s *MemoryMappingStore) Set(_ context.Context, workflowRunID string, sessionID string, ttl time.Duration) error {
 	if workflowRunID == "" {
 		return fmt.Errorf("workflow run id is required")
@@ -78,3 +93,18 @@ func (s *MemoryMappingStore) Touch(_ context.Context, workflowRunID string, ttl
 	s.mu.Unlock()
 	return nil
 }
+
+// PruneExpired 清理已过期的映射，返回清理数量
+func (s *MemoryMappingStore) PruneExpired() int {
+	now := time.Now()
+	removed := 0
+	s.mu.Lock()
+	for key, entry := range s.items {
+		if !entry.expiresAt.IsZero() && now.After(entry.expiresAt) {
+			delete(s.items, key)
+			removed++
+		}
+	}
+	s.mu.Unlock()
+	return removed
+}